@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:         "audit --max-scopes scope[,scope...] [host...]",
+	Annotations: map[string]string{hostArgAnnotation: "true"},
+	Short:       "Flag tokens with more scopes than allowed",
+	Long: `Check the scopes of each configured token against the allowed set given with
+--max-scopes, and report every token that has a scope outside it. This helps
+enforce least-privilege policies, such as read-only tokens, by catching tokens
+that were created with more access than intended.
+
+If no hosts are specified, all configured tokens are audited. Scopes are
+compared exactly as the provider reports them, so implied scopes are not
+expanded: on GitHub, a token with "repo" is flagged even if "public_repo" is
+allowed.
+
+Tokens whose scopes cannot be determined, such as GitHub fine-grained tokens,
+GitLab OAuth tokens or tokens for providers that do not report scopes, are
+listed as warnings.
+
+Exits with a non-zero status if an over-privileged token is found, so it can
+be used in CI.`,
+	RunE:         runAudit,
+	SilenceUsage: true,
+}
+
+var auditMaxScopes []string
+
+func init() {
+	auditCmd.Flags().StringSliceVar(&auditMaxScopes, "max-scopes", nil, "Comma-separated scopes tokens may have (required)")
+	_ = auditCmd.MarkFlagRequired("max-scopes")
+
+	rootCmd.AddCommand(auditCmd)
+}
+
+func runAudit(_ *cobra.Command, args []string) error {
+	cfg, err := openNixConfig()
+	if err != nil {
+		return fmt.Errorf("failed to initialize config: %w", err)
+	}
+
+	args, err = parseHosts(args)
+	if err != nil {
+		return err
+	}
+
+	allowed := make(map[string]bool, len(auditMaxScopes))
+
+	for _, scope := range auditMaxScopes {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			allowed[scope] = true
+		}
+	}
+
+	if len(allowed) == 0 {
+		return fmt.Errorf("--max-scopes must list at least one scope")
+	}
+
+	hosts, err := getHostsToShow(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	if len(hosts) == 0 {
+		showNoTokensMessage(os.Stdout, cfg)
+
+		return nil
+	}
+
+	ctx := context.Background()
+	overPrivileged := 0
+
+	for _, host := range hosts {
+		if !auditHost(ctx, host, cfg, allowed) {
+			overPrivileged++
+		}
+	}
+
+	if overPrivileged > 0 {
+		return fmt.Errorf("found %d over-privileged token(s)", overPrivileged)
+	}
+
+	return nil
+}
+
+// auditHost prints whether the token for host stays within the allowed scopes.
+// It returns false only if the token has a scope outside them; tokens whose
+// scopes cannot be determined are reported but not counted against the policy.
+func auditHost(ctx context.Context, host string, cfg *nixconf.NixConfig, allowed map[string]bool) bool {
+	token, _, err := cfg.LookupToken(host)
+
+	switch {
+	case err != nil:
+		fmt.Printf("⚠ %s: %v\n", host, err)
+		return true
+	case token == "":
+		fmt.Printf("⚠ %s: no token configured\n", host)
+		return true
+	}
+
+	prov := provider.DetectCached(ctx, providerConfig(host))
+
+	if !provider.ReportsTokenScopes(prov) {
+		fmt.Printf("⚠ %s: %s does not report token scopes, unable to audit\n", host, prov.Name())
+		return true
+	}
+
+	warnPrivateHost(ctx, os.Stderr, host)
+
+	scopes, err := prov.GetTokenScopes(ctx, token)
+
+	var notExposed *provider.ScopesNotExposedError
+
+	switch {
+	case errors.As(err, &notExposed):
+		fmt.Printf("⚠ %s: %v, unable to audit\n", host, err)
+		return true
+	case err != nil:
+		fmt.Printf("⚠ %s: unable to retrieve scopes: %v\n", host, err)
+		return true
+	}
+
+	excess := excessScopes(scopes, allowed)
+	if len(excess) > 0 {
+		fmt.Printf("✗ %s: scopes not allowed: %s\n", host, strings.Join(excess, ", "))
+		return false
+	}
+
+	fmt.Printf("✓ %s: within allowed scopes\n", host)
+
+	return true
+}
+
+// excessScopes returns the scopes that are not in allowed, in their original order.
+func excessScopes(scopes []string, allowed map[string]bool) []string {
+	var excess []string
+
+	for _, scope := range scopes {
+		if !allowed[scope] {
+			excess = append(excess, scope)
+		}
+	}
+
+	return excess
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/provider"
+)
+
+func TestRunAudit(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+	originalMaxScopes := auditMaxScopes
+
+	defer func() {
+		configPath = originalConfigPath
+		auditMaxScopes = originalMaxScopes
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	scopesByHost := map[string][]string{
+		"read.example.com":  {"read_api", "read_repository"},
+		"write.example.com": {"read_api", "write_repository", "api"},
+	}
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	provider.RegisterProvider("scoped", provider.Registration{
+		Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+			if scopes, ok := scopesByHost[host]; ok {
+				return &rawScopesProvider{mockStatusProvider: mockStatusProvider{name: "scoped", host: host, valid: true, scopes: scopes}}, nil
+			}
+
+			return &mockStatusProvider{name: "plain", host: host, valid: true, scopes: []string{"read"}}, nil
+		},
+	})
+
+	configPath = createTestConfig(t,
+		"access-tokens = read.example.com=tok_read write.example.com=tok_write other.example.com=tok_other\n")
+
+	t.Run("flags over-privileged tokens", func(t *testing.T) {
+		auditMaxScopes = []string{"read_api", "read_repository"}
+
+		var runErr error
+
+		output := captureStdout(t, func() {
+			runErr = runAudit(nil, nil)
+		})
+
+		if runErr == nil || !strings.Contains(runErr.Error(), "found 1 over-privileged token(s)") {
+			t.Errorf("expected one over-privileged token, got %v", runErr)
+		}
+
+		for _, want := range []string{
+			"✓ read.example.com: within allowed scopes",
+			"✗ write.example.com: scopes not allowed: write_repository, api",
+			"⚠ other.example.com: plain does not report token scopes, unable to audit",
+		} {
+			if !strings.Contains(output, want) {
+				t.Errorf("expected %q in output\nGot output:\n%s", want, output)
+			}
+		}
+	})
+
+	t.Run("passes when all scopes are allowed", func(t *testing.T) {
+		auditMaxScopes = []string{"read_api", "read_repository", "write_repository", "api"}
+
+		var runErr error
+
+		output := captureStdout(t, func() {
+			runErr = runAudit(nil, []string{"read.example.com", "write.example.com"})
+		})
+
+		if runErr != nil {
+			t.Errorf("unexpected error: %v\nGot output:\n%s", runErr, output)
+		}
+	})
+
+	t.Run("rejects an empty allowed set", func(t *testing.T) {
+		auditMaxScopes = []string{" "}
+
+		if err := runAudit(nil, nil); err == nil || !strings.Contains(err.Error(), "--max-scopes") {
+			t.Errorf("expected --max-scopes error, got %v", err)
+		}
+	})
+}
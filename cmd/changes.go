@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+)
+
+// printChangeSummary prints which files a command modified, unless --quiet is set.
+func printChangeSummary(cfg *nixconf.NixConfig) {
+	changes := cfg.Changes()
+	if quiet || len(changes.Files) == 0 {
+		return
+	}
+
+	summary := "Changed: " + displayPaths(changes.Files)
+	if len(changes.Backups) > 0 {
+		summary += " (backup: " + displayPaths(changes.Backups) + ")"
+	}
+
+	fmt.Println(summary)
+}
+
+// displayPaths joins paths for display, abbreviating the home directory to ~.
+func displayPaths(paths []string) string {
+	home, _ := os.UserHomeDir()
+
+	shown := make([]string, len(paths))
+	for i, path := range paths {
+		shown[i] = path
+
+		if rel, err := filepath.Rel(home, path); home != "" && err == nil && !strings.HasPrefix(rel, "..") {
+			shown[i] = filepath.Join("~", rel)
+		}
+	}
+
+	return strings.Join(shown, ", ")
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+)
+
+func TestPrintChangeSummary(t *testing.T) {
+	originalQuiet := quiet
+
+	t.Cleanup(func() { quiet = originalQuiet })
+
+	tests := []struct {
+		name  string
+		quiet bool
+		want  string
+	}{
+		{
+			name: "lists changed files and backup",
+			want: "Changed: " + filepath.Join("~", "nix", "access-tokens.conf") + ", " +
+				filepath.Join("~", "nix", "nix.conf") + " (backup: " + filepath.Join("~", "nix", "nix.conf.backup-"),
+		},
+		{name: "quiet", quiet: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+
+			path := filepath.Join(home, "nix", "nix.conf")
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatalf("MkdirAll() error = %v", err)
+			}
+
+			if err := os.WriteFile(path, []byte("access-tokens = existing.com=token\n"), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			cfg, err := nixconf.New(path)
+			if err != nil {
+				t.Fatalf("failed to open config: %v", err)
+			}
+
+			quiet = tt.quiet
+
+			output := captureStdout(t, func() {
+				if err := cfg.SetToken("github.com", "token"); err != nil {
+					t.Errorf("SetToken() error = %v", err)
+				}
+
+				printChangeSummary(cfg)
+			})
+
+			if tt.quiet {
+				if strings.Contains(output, "Changed:") {
+					t.Errorf("expected no summary with --quiet, got:\n%s", output)
+				}
+
+				return
+			}
+
+			if !strings.Contains(output, tt.want) {
+				t.Errorf("expected output to contain %q, got:\n%s", tt.want, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var checkCmd = &cobra.Command{
+	Use:         "check [host...]",
+	Annotations: map[string]string{hostArgAnnotation: "true"},
+	Short:       "Validate all tokens quietly, for monitoring",
+	Long: `Validate every configured token, or those of the given hosts, in parallel and
+print nothing if they are all fine. Otherwise only the hosts with a problem are
+printed, one per line, so check can run from cron or a healthcheck that should
+only make noise when something needs attention.
+
+A token fails the check if its provider rejects it or its recorded expiry has
+passed. Tokens for providers that cannot validate them pass.
+
+Exit status:
+  0  every token is valid
+  2  a token is invalid or expired
+  3  a provider could not be reached or did not answer before --timeout
+  4  a given host has no token
+
+An invalid token takes precedence over the other problems.`,
+	Example: `  nix-auth check
+  nix-auth check github.com gitlab.company.com`,
+	RunE:         runCheck,
+	SilenceUsage: true,
+}
+
+var (
+	checkJobs    int
+	checkTimeout time.Duration
+)
+
+// defaultCheckTimeout is the default deadline for checking all hosts, so that
+// a monitoring job always finishes.
+const defaultCheckTimeout = time.Minute
+
+func init() {
+	checkCmd.Flags().IntVar(&checkJobs, "jobs", defaultStatusJobs, "Maximum number of hosts to check at once")
+	checkCmd.Flags().DurationVar(&checkTimeout, "timeout", defaultCheckTimeout,
+		"Deadline for checking all hosts; hosts not done by then fail as unreachable (0 for no deadline)")
+
+	rootCmd.AddCommand(checkCmd)
+}
+
+// tokenProblem is why the token of a host failed the check, with the exit
+// code it maps to.
+type tokenProblem struct {
+	message string
+	code    int
+}
+
+func runCheck(_ *cobra.Command, args []string) error {
+	if checkJobs < 1 {
+		return fmt.Errorf("--jobs must be at least 1, got %d", checkJobs)
+	}
+
+	if checkTimeout < 0 {
+		return fmt.Errorf("--timeout must not be negative, got %s", checkTimeout)
+	}
+
+	cfg, err := openNixConfig()
+	if err != nil {
+		return fmt.Errorf("failed to initialize config: %w", err)
+	}
+
+	args, err = parseHosts(args)
+	if err != nil {
+		return err
+	}
+
+	hosts, err := getHostsToShow(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if checkTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, checkTimeout)
+		defer cancel()
+	}
+
+	problems := checkTokens(ctx, hosts, cfg)
+
+	failed := 0
+	code := ExitOK
+
+	for i, problem := range problems {
+		if problem == nil {
+			continue
+		}
+
+		fmt.Printf("%s: %s\n", hosts[i], problem.message)
+
+		failed++
+
+		// An invalid token is what the check is for, so it wins over the rest
+		if code != ExitInvalidToken && (code == ExitOK || problem.code == ExitInvalidToken) {
+			code = problem.code
+		}
+	}
+
+	if failed == 0 {
+		return nil
+	}
+
+	return &ExitCodeError{Code: code, Err: fmt.Errorf("%d of %d token(s) failed the check", failed, len(hosts))}
+}
+
+// checkTokens validates the tokens of hosts, up to --jobs at once, and returns
+// the problem of each host in host order, or nil for a host that passed.
+func checkTokens(ctx context.Context, hosts []string, cfg *nixconf.NixConfig) []*tokenProblem {
+	problems := make([]*tokenProblem, len(hosts))
+
+	forEachHost(ctx, hosts, checkJobs, func(i int, host string) {
+		if ctx.Err() != nil {
+			problems[i] = &tokenProblem{message: "timed out", code: ExitNetwork}
+			return
+		}
+
+		problems[i] = checkToken(ctx, host, cfg)
+	})
+
+	return problems
+}
+
+// checkToken validates the token of host and returns its problem, or nil if
+// the token passed.
+func checkToken(ctx context.Context, host string, cfg *nixconf.NixConfig) *tokenProblem {
+	token, _, err := cfg.LookupToken(host)
+
+	switch {
+	case err != nil:
+		return &tokenProblem{message: err.Error(), code: ExitError}
+	case token == "":
+		return &tokenProblem{message: "no token configured", code: ExitNotConfigured}
+	}
+
+	if expiresAt := tokenExpiry(host); !expiresAt.IsZero() && time.Now().After(expiresAt) {
+		return &tokenProblem{message: "token expired " + expiresAt.Local().Format(time.RFC1123), code: ExitInvalidToken}
+	}
+
+	prov := provider.DetectCached(ctx, providerConfig(host))
+
+	warnPrivateHost(ctx, os.Stderr, host)
+
+	status, err := prov.ValidateToken(ctx, token)
+
+	switch {
+	case ctx.Err() != nil:
+		return &tokenProblem{message: "timed out", code: ExitNetwork}
+	case provider.IsNetworkError(err):
+		return &tokenProblem{message: fmt.Sprintf("unreachable: %v", err), code: ExitNetwork}
+	case status == provider.ValidationStatusInvalid && err != nil && !errors.Is(err, provider.ErrInvalidToken):
+		return &tokenProblem{message: fmt.Sprintf("invalid: %v", err), code: ExitInvalidToken}
+	case status == provider.ValidationStatusInvalid:
+		return &tokenProblem{message: "invalid", code: ExitInvalidToken}
+	}
+
+	return nil
+}
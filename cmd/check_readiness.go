@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var checkReadinessCmd = &cobra.Command{
+	Use:         "check-readiness [host...]",
+	Annotations: map[string]string{hostArgAnnotation: "true"},
+	Short:       "Check that each token has the scopes Nix needs",
+	Long: `Check that the token for each host is valid and has the scopes Nix needs to
+fetch private flakes from it, such as "repo" on GitHub or "read_api" and
+"read_repository" on GitLab, and report which scopes are missing.
+
+Unlike status, which lists the scopes a token has, this compares them with the
+scopes nix-auth requests at login, so it answers "am I set up correctly?".
+A broader scope counts for the narrower ones it grants, such as "api" on
+GitLab.
+
+If no hosts are specified, all configured tokens are checked. Tokens whose
+scopes cannot be determined, such as GitHub fine-grained tokens, GitLab OAuth
+tokens or tokens for providers that do not report scopes, are listed as
+warnings.
+
+Exits with a non-zero status if a token cannot be read from the configuration,
+is invalid or is missing a scope.`,
+	Example: `  nix-auth check-readiness
+  nix-auth check-readiness github.com gitlab.company.com`,
+	RunE:         runCheckReadiness,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(checkReadinessCmd)
+}
+
+func runCheckReadiness(_ *cobra.Command, args []string) error {
+	cfg, err := openNixConfig()
+	if err != nil {
+		return fmt.Errorf("failed to initialize config: %w", err)
+	}
+
+	args, err = parseHosts(args)
+	if err != nil {
+		return err
+	}
+
+	hosts, err := getHostsToShow(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	if len(hosts) == 0 {
+		showNoTokensMessage(os.Stdout, cfg)
+
+		return nil
+	}
+
+	ctx := context.Background()
+	notReady := 0
+
+	for _, host := range hosts {
+		if !checkHostReadiness(ctx, host, cfg) {
+			notReady++
+		}
+	}
+
+	if notReady > 0 {
+		return fmt.Errorf("%d host(s) not ready", notReady)
+	}
+
+	return nil
+}
+
+// checkHostReadiness prints whether the token for host has the scopes its
+// provider needs, with how to fix it if not. It returns false if the token
+// cannot be read from the configuration, is invalid or lacks a scope; tokens
+// whose scopes cannot be checked are reported but not counted as failures.
+func checkHostReadiness(ctx context.Context, host string, cfg *nixconf.NixConfig) bool {
+	token, _, err := cfg.LookupToken(host)
+
+	switch {
+	case err != nil:
+		fmt.Printf("✗ %s: %v\n", host, err)
+		return false
+	case token == "":
+		fmt.Printf("✗ %s: no token configured\n", host)
+		fmt.Printf("  Run 'nix-auth login %s' to add one.\n", host)
+
+		return false
+	}
+
+	prov := provider.DetectCached(ctx, providerConfig(host))
+
+	warnPrivateHost(ctx, os.Stderr, host)
+
+	status, err := prov.ValidateToken(ctx, token)
+
+	switch {
+	case provider.IsNetworkError(err):
+		fmt.Printf("⚠ %s: unreachable (%v), unable to check\n", host, err)
+		return true
+	case status == provider.ValidationStatusInvalid:
+		fmt.Printf("✗ %s: token is invalid\n", host)
+		fmt.Printf("  Run 'nix-auth login %s' to replace it.\n", host)
+
+		return false
+	}
+
+	if !provider.ReportsTokenScopes(prov) {
+		fmt.Printf("⚠ %s: %s does not report token scopes, unable to check\n", host, prov.Name())
+		return true
+	}
+
+	scopes, err := prov.GetTokenScopes(ctx, token)
+
+	var notExposed *provider.ScopesNotExposedError
+
+	switch {
+	case errors.As(err, &notExposed):
+		fmt.Printf("⚠ %s: %v, unable to check\n", host, err)
+		return true
+	case err != nil:
+		fmt.Printf("⚠ %s: unable to retrieve scopes: %v\n", host, err)
+		return true
+	}
+
+	missing := provider.MissingScopes(prov, scopes)
+	if len(missing) > 0 {
+		fmt.Printf("✗ %s: missing scopes: %s\n", host, strings.Join(missing, ", "))
+		fmt.Printf("  Run 'nix-auth login %s' for a token with %s.\n", host, strings.Join(prov.GetScopes(), ", "))
+
+		return false
+	}
+
+	fmt.Printf("✓ %s: ready\n", host)
+
+	return true
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/provider"
+)
+
+// requiredScopesProvider reports token scopes that differ from the ones it needs.
+type requiredScopesProvider struct {
+	rawScopesProvider
+	required []string
+}
+
+func (m *requiredScopesProvider) GetScopes() []string { return m.required }
+
+func TestRunCheckReadiness(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+
+	defer func() {
+		configPath = originalConfigPath
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	tokenScopes := map[string][]string{
+		"ready.example.com":   {"read_api", "read_repository", "read_user"},
+		"partial.example.com": {"read_api"},
+	}
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	provider.RegisterProvider("scoped", provider.Registration{
+		Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+			switch host {
+			case "plain.example.com":
+				return &mockStatusProvider{name: "plain", host: host, valid: true}, nil
+			case "invalid.example.com":
+				return &mockStatusProvider{name: "scoped", host: host}, nil
+			case "oauth.example.com":
+				return &rawScopesProvider{mockStatusProvider: mockStatusProvider{
+					name: "scoped", host: host, valid: true, scopesErr: &provider.ScopesNotExposedError{TokenType: "OAuth"},
+				}}, nil
+			}
+
+			return &requiredScopesProvider{
+				rawScopesProvider: rawScopesProvider{mockStatusProvider: mockStatusProvider{
+					name: "scoped", host: host, valid: true, scopes: tokenScopes[host],
+				}},
+				required: []string{"read_api", "read_repository"},
+			}, nil
+		},
+	})
+
+	configPath = createTestConfig(t, "access-tokens = ready.example.com=tok_ready partial.example.com=tok_partial "+
+		"plain.example.com=tok_plain invalid.example.com=tok_invalid oauth.example.com=tok_oauth\n")
+
+	t.Run("reports each host", func(t *testing.T) {
+		var runErr error
+
+		output := captureStdout(t, func() {
+			runErr = runCheckReadiness(nil, nil)
+		})
+
+		if runErr == nil || !strings.Contains(runErr.Error(), "2 host(s) not ready") {
+			t.Errorf("expected two hosts not to be ready, got %v", runErr)
+		}
+
+		for _, want := range []string{
+			"✓ ready.example.com: ready",
+			"✗ partial.example.com: missing scopes: read_repository",
+			"Run 'nix-auth login partial.example.com' for a token with read_api, read_repository.",
+			"✗ invalid.example.com: token is invalid",
+			"⚠ plain.example.com: plain does not report token scopes, unable to check",
+			"⚠ oauth.example.com: scopes are not exposed for OAuth tokens, unable to check",
+		} {
+			if !strings.Contains(output, want) {
+				t.Errorf("expected %q in output\nGot output:\n%s", want, output)
+			}
+		}
+	})
+
+	t.Run("passes for ready hosts", func(t *testing.T) {
+		var runErr error
+
+		output := captureStdout(t, func() {
+			runErr = runCheckReadiness(nil, []string{"ready.example.com", "plain.example.com"})
+		})
+
+		if runErr != nil {
+			t.Errorf("unexpected error: %v\nGot output:\n%s", runErr, output)
+		}
+	})
+
+	t.Run("host without a token", func(t *testing.T) {
+		var runErr error
+
+		output := captureStdout(t, func() {
+			runErr = runCheckReadiness(nil, []string{"other.example.com"})
+		})
+
+		if runErr == nil || !strings.Contains(output, "✗ other.example.com: no token configured") {
+			t.Errorf("expected a missing token to fail, got %v\nGot output:\n%s", runErr, output)
+		}
+	})
+
+	t.Run("unreadable configuration", func(t *testing.T) {
+		t.Setenv("NIX_CONFIG", "access-tokens = ready.example.com")
+
+		var runErr error
+
+		output := captureStdout(t, func() {
+			runErr = runCheckReadiness(nil, []string{"ready.example.com"})
+		})
+
+		if runErr == nil || !strings.Contains(output, "✗ ready.example.com: ") || !strings.Contains(output, "invalid token format") {
+			t.Errorf("expected a broken configuration to fail, got %v\nGot output:\n%s", runErr, output)
+		}
+	})
+}
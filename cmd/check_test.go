@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/numtide/nix-auth/internal/tokenmeta"
+)
+
+func TestRunCheck(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+
+	defer func() {
+		configPath = originalConfigPath
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	provider.RegisterProvider("mock", provider.Registration{
+		Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+			switch host {
+			case "invalid.example.com":
+				return &mockStatusProvider{name: "mock", host: host, validError: provider.ErrInvalidToken}, nil
+			case "offline.example.com":
+				return &mockStatusProvider{name: "mock", host: host, validError: &net.DNSError{Err: "no such host", Name: host}}, nil
+			case "down.example.com":
+				return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused")}
+			}
+
+			return &mockStatusProvider{name: "mock", host: host, valid: true}, nil
+		},
+	})
+
+	configPath = createTestConfig(t, "access-tokens = good.example.com=tok_good other.example.com=tok_other "+
+		"invalid.example.com=tok_invalid offline.example.com=tok_offline expired.example.com=tok_expired "+
+		"down.example.com=tok_down\n")
+
+	if err := updateTokenMeta("expired.example.com", func(m *tokenmeta.Metadata) {
+		m.ExpiresAt = time.Now().Add(-time.Hour)
+	}); err != nil {
+		t.Fatalf("failed to record expiry: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		want     []string
+		wantCode int
+	}{
+		{name: "valid tokens print nothing", args: []string{"good.example.com", "other.example.com"}, wantCode: ExitOK},
+		{name: "unreachable host", args: []string{"good.example.com", "offline.example.com"}, want: []string{"offline.example.com: unreachable"}, wantCode: ExitNetwork},
+		{
+			name:     "unreachable during detection",
+			args:     []string{"good.example.com", "down.example.com"},
+			want:     []string{"down.example.com: unreachable: could not reach the host to detect its provider"},
+			wantCode: ExitNetwork,
+		},
+		{name: "missing token", args: []string{"missing.example.com"}, want: []string{"missing.example.com: no token configured"}, wantCode: ExitNotConfigured},
+		{
+			name: "invalid token wins",
+			want: []string{
+				"invalid.example.com: invalid",
+				"offline.example.com: unreachable",
+				"expired.example.com: token expired",
+			},
+			wantCode: ExitInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runErr error
+
+			output := captureStdout(t, func() {
+				runErr = runCheck(nil, tt.args)
+			})
+
+			if code := ExitCode(runErr); code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (error: %v)", code, tt.wantCode, runErr)
+			}
+
+			if len(tt.want) == 0 && output != "" {
+				t.Errorf("expected no output, got:\n%s", output)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("expected %q in output\nGot output:\n%s", want, output)
+				}
+			}
+
+			for _, host := range []string{"good.example.com", "other.example.com"} {
+				if strings.Contains(output, host) {
+					t.Errorf("expected passing host %s to be left out\nGot output:\n%s", host, output)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Print the decrypted token file for use with NIX_CONFIG",
+	Long: `Print the tokens from the encrypted token file as an access-tokens line.
+
+Nix cannot read a token file encrypted with --store age or --store gpg, so pass
+the tokens to it on demand instead:
+
+  NIX_CONFIG="$(nix-auth --store age --identity ~/.config/age/key.txt decrypt)" nix flake update`,
+	Args:         cobra.NoArgs,
+	RunE:         runDecrypt,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(decryptCmd)
+}
+
+func runDecrypt(_ *cobra.Command, _ []string) error {
+	cfg, err := openNixConfig()
+	if err != nil {
+		return fmt.Errorf("failed to initialize config: %w", err)
+	}
+
+	content, err := cfg.DecryptedTokenConfig()
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(content)
+
+	return nil
+}
@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show the config changes a command would make",
+	Long: `Print a unified diff of the changes set-token or logout would make to nix.conf
+and the token file, without applying them. This is useful to review the
+rewrite of nix.conf when tokens are migrated to the token file.
+
+Tokens in the diff are masked.`,
+}
+
+var diffSetTokenCmd = &cobra.Command{
+	Use:         "set-token <host> [token]",
+	Annotations: map[string]string{hostArgAnnotation: "true"},
+	Short:       "Show the changes set-token would make",
+	Args:        cobra.RangeArgs(minSetTokenArgs, maxSetTokenArgs),
+	RunE: func(_ *cobra.Command, args []string) error {
+		host, err := parseHost(args[0])
+		if err != nil {
+			return err
+		}
+
+		var token string
+		if len(args) == maxSetTokenArgs {
+			token = args[1]
+		} else {
+			token, err = ui.ReadSecureInput(fmt.Sprintf("Enter token for %s: ", host))
+			if err != nil {
+				return fmt.Errorf("failed to read token: %w", err)
+			}
+		}
+
+		if token == "" {
+			return fmt.Errorf("token cannot be empty")
+		}
+
+		cfg, err := openNixConfig()
+		if err != nil {
+			return fmt.Errorf("failed to initialize config: %w", err)
+		}
+
+		changes, err := cfg.PreviewSetToken(host, token)
+		if err != nil {
+			return err
+		}
+
+		return printChanges(os.Stdout, changes)
+	},
+	SilenceUsage: true,
+}
+
+var diffLogoutCmd = &cobra.Command{
+	Use:         "logout <host>",
+	Annotations: map[string]string{hostArgAnnotation: "true"},
+	Short:       "Show the changes logout would make",
+	Args:        cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		cfg, err := openNixConfig()
+		if err != nil {
+			return fmt.Errorf("failed to initialize config: %w", err)
+		}
+
+		host, err := parseHost(args[0])
+		if err != nil {
+			return err
+		}
+
+		changes, err := cfg.PreviewRemoveToken(host)
+		if err != nil {
+			return err
+		}
+
+		return printChanges(os.Stdout, changes)
+	},
+	SilenceUsage: true,
+}
+
+func init() {
+	diffCmd.AddCommand(diffSetTokenCmd)
+	diffCmd.AddCommand(diffLogoutCmd)
+	rootCmd.AddCommand(diffCmd)
+}
+
+// printChanges writes a unified diff of changes with tokens masked.
+func printChanges(w io.Writer, changes []nixconf.FileChange) error {
+	if len(changes) == 0 {
+		_, _ = fmt.Fprintln(w, "No changes.")
+		return nil
+	}
+
+	for _, change := range changes {
+		oldName, newName := change.Path, change.Path
+		if change.Old == "" {
+			oldName = "/dev/null"
+		}
+
+		if change.Removed {
+			newName = "/dev/null"
+		}
+
+		_, _ = fmt.Fprint(w, unifiedDiff(oldName, newName, maskTokenLines(change.Old), maskTokenLines(change.New)))
+	}
+
+	return nil
+}
+
+// maskTokenLines masks the tokens of access-tokens settings in content.
+func maskTokenLines(content string) string {
+	lines := strings.Split(content, "\n")
+
+	for i, line := range lines {
+		key, value, found := strings.Cut(line, "=")
+		if !found || strings.TrimSpace(key) != "access-tokens" {
+			continue
+		}
+
+		fields := strings.Fields(value)
+
+		for j, field := range fields {
+			// Leave a trailing comment alone
+			if strings.HasPrefix(field, "#") {
+				break
+			}
+
+			if host, token, ok := strings.Cut(field, "="); ok {
+				fields[j] = host + "=" + ui.MaskToken(token)
+			}
+		}
+
+		lines[i] = key + "= " + strings.Join(fields, " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// diffOp is one line of a line diff: ' ' unchanged, '-' removed or '+' added.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// splitLines splits content into lines without a trailing empty line.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// diffLines computes a minimal line diff from a to b using the longest common
+// subsequence. Config files are small, so the quadratic table is fine.
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+
+	return ops
+}
+
+// unifiedDiff formats the difference between oldContent and newContent as a
+// unified diff, or returns "" if they are equal.
+func unifiedDiff(oldName, newName, oldContent, newContent string) string {
+	ops := diffLines(splitLines(oldContent), splitLines(newContent))
+
+	var changed []int
+
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changed = append(changed, i)
+		}
+	}
+
+	if len(changed) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(changed); {
+		// Group changes whose context would overlap into one hunk
+		end := start
+		for end+1 < len(changed) && changed[end+1]-changed[end] <= 2*diffContext {
+			end++
+		}
+
+		first := max(0, changed[start]-diffContext)
+		last := min(len(ops), changed[end]+diffContext+1)
+
+		writeHunk(&b, ops, first, last)
+
+		start = end + 1
+	}
+
+	return b.String()
+}
+
+// writeHunk writes ops[first:last] as a hunk with its line ranges.
+func writeHunk(b *strings.Builder, ops []diffOp, first, last int) {
+	oldStart, newStart := 1, 1
+
+	for _, op := range ops[:first] {
+		if op.kind != '+' {
+			oldStart++
+		}
+
+		if op.kind != '-' {
+			newStart++
+		}
+	}
+
+	oldCount, newCount := 0, 0
+
+	for _, op := range ops[first:last] {
+		if op.kind != '+' {
+			oldCount++
+		}
+
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+
+	// An empty range starts at the line before it
+	if oldCount == 0 {
+		oldStart--
+	}
+
+	if newCount == 0 {
+		newStart--
+	}
+
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+
+	for _, op := range ops[first:last] {
+		b.WriteByte(op.kind)
+		b.WriteString(op.line)
+		b.WriteByte('\n')
+	}
+}
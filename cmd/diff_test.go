@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		oldContent string
+		newContent string
+		want       string
+	}{
+		{
+			name:       "equal",
+			oldContent: "a\nb\n",
+			newContent: "a\nb\n",
+			want:       "",
+		},
+		{
+			name:       "new file",
+			newContent: "a\nb\n",
+			want:       "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:       "replaced line with context",
+			oldContent: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			newContent: "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want:       "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:       "separate hunks",
+			oldContent: "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			newContent: "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			want:       "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("old", "new", tt.oldContent, tt.newContent); got != tt.want {
+				t.Errorf("unifiedDiff() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffSetToken(t *testing.T) {
+	originalConfigPath := configPath
+
+	defer func() {
+		configPath = originalConfigPath
+	}()
+
+	configPath = createTestConfig(t, "experimental-features = nix-command flakes\naccess-tokens = github.com=ghp_existingsecret123\n")
+	original, _ := os.ReadFile(configPath)
+
+	var runErr error
+
+	output := captureStdout(t, func() {
+		runErr = diffSetTokenCmd.RunE(nil, []string{"gitlab.com", "glpat-newsecret456789"})
+	})
+
+	if runErr != nil {
+		t.Fatalf("diff set-token error = %v", runErr)
+	}
+
+	tokenFile := filepath.Join(filepath.Dir(configPath), "access-tokens.conf")
+
+	for _, want := range []string{
+		"--- /dev/null\n+++ " + tokenFile,
+		"--- " + configPath + "\n+++ " + configPath,
+		"-access-tokens = github.com=",
+		"+!include access-tokens.conf",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q\nGot output:\n%s", want, output)
+		}
+	}
+
+	for _, secret := range []string{"ghp_existingsecret123", "glpat-newsecret456789"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("diff leaks token %s:\n%s", secret, output)
+		}
+	}
+
+	// Nothing is applied
+	if current, _ := os.ReadFile(configPath); string(current) != string(original) {
+		t.Errorf("config was modified:\n%s", current)
+	}
+
+	if _, err := os.Stat(tokenFile); !os.IsNotExist(err) {
+		t.Errorf("token file should not be created, stat error = %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var dumpConfigCmd = &cobra.Command{
+	Use:   "dump-config",
+	Short: "Print every config source read and the resolved tokens",
+	Long: `Print nix-auth's complete view of the Nix configuration for debugging: every
+token source with the files read for it (including followed includes), the
+tokens each source defines, and the effective token per host after applying
+Nix's precedence. Tokens are masked, so the output can be pasted into issue
+reports.`,
+	Args:         cobra.NoArgs,
+	RunE:         runDumpConfig,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(dumpConfigCmd)
+}
+
+func runDumpConfig(_ *cobra.Command, _ []string) error {
+	cfg, err := openNixConfig()
+	if err != nil {
+		return fmt.Errorf("failed to initialize config: %w", err)
+	}
+
+	return dumpConfig(os.Stdout, cfg)
+}
+
+// dumpConfig writes the sources and resolved tokens of cfg.
+func dumpConfig(out io.Writer, cfg *nixconf.NixConfig) error {
+	_, _ = fmt.Fprintf(out, "Managed config: %s\n", cfg.GetPath())
+	_, _ = fmt.Fprintf(out, "Token file: %s\n", cfg.GetTokenFilePath())
+
+	_, _ = fmt.Fprintln(out, "\nSources (lowest precedence first):")
+
+	for _, info := range cfg.DescribeSources() {
+		dumpSource(out, info)
+	}
+
+	hosts, err := cfg.ListAllTokens()
+	if err != nil {
+		return fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	_, _ = fmt.Fprintln(out, "\nEffective tokens:")
+
+	if len(hosts) == 0 {
+		_, _ = fmt.Fprintln(out, "  (none)")
+	}
+
+	for _, host := range hosts {
+		entries, err := cfg.TokenEntries(host)
+		if err != nil {
+			return fmt.Errorf("failed to resolve token for %s: %w", host, err)
+		}
+
+		_, _ = fmt.Fprintf(out, "  %s\n", host)
+
+		for i, entry := range entries {
+			label, note := "shadowed", ""
+
+			switch {
+			case i == 0 && entry.Effective():
+				label = "effective"
+			case i == 0:
+				label = "ignored"
+				note = fmt.Sprintf(" (replaced by access-tokens in %s)", entry.OverriddenBy)
+			}
+
+			_, _ = fmt.Fprintf(out, "    %-9s  %s  from %s%s\n", label, ui.MaskToken(entry.Token), entry.Origin(), note)
+		}
+	}
+
+	return nil
+}
+
+// dumpSource writes one token source with its files and tokens.
+func dumpSource(out io.Writer, info nixconf.SourceInfo) {
+	name := info.Source.String()
+	if info.Path != "" {
+		name = fmt.Sprintf("%s (%s)", name, info.Path)
+	}
+
+	_, _ = fmt.Fprintf(out, "  %s\n", name)
+
+	if info.Err != nil {
+		status := fmt.Sprintf("error: %v", info.Err)
+		if nixconf.IsNotExist(info.Err) {
+			status = "not found"
+		}
+
+		_, _ = fmt.Fprintf(out, "    %s\n", status)
+	}
+
+	for _, file := range info.Files {
+		_, _ = fmt.Fprintf(out, "    read %s\n", file)
+	}
+
+	hosts := make([]string, 0, len(info.Tokens))
+	for host := range info.Tokens {
+		hosts = append(hosts, host)
+	}
+
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		_, _ = fmt.Fprintf(out, "    token %s=%s\n", host, ui.MaskToken(info.Tokens[host]))
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+)
+
+func TestDumpConfig(t *testing.T) {
+	configDir := t.TempDir()
+	configFile := filepath.Join(configDir, "nix.conf")
+	tokenFile := filepath.Join(configDir, "access-tokens.conf")
+
+	t.Setenv("NIX_CONF_DIR", t.TempDir())
+	t.Setenv("NIX_CONFIG", "access-tokens = github.com=gho_envtoken123456789")
+
+	if err := os.WriteFile(configFile, []byte("!include access-tokens.conf\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(tokenFile, []byte("access-tokens = github.com=gho_filetoken12345678 gitlab.com=glpat-filetoken123456\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := nixconf.New(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := dumpConfig(&buf, cfg); err != nil {
+		t.Fatalf("dumpConfig() error = %v", err)
+	}
+
+	output := buf.String()
+
+	expected := []string{
+		"system config (",
+		"    not found",
+		"config file (" + configFile + ")",
+		"    read " + configFile,
+		"    read " + tokenFile,
+		"    token gitlab.com=glpat-******56",
+		"NIX_CONFIG",
+		"  github.com\n    effective  gho_******89  from NIX_CONFIG\n    shadowed   gho_******78  from " + tokenFile,
+		"  gitlab.com\n    ignored    glpat-******56  from " + tokenFile + " (replaced by access-tokens in NIX_CONFIG)",
+	}
+
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q\nGot output:\n%s", want, output)
+		}
+	}
+
+	for _, secret := range []string{"gho_envtoken123456789", "gho_filetoken12345678", "glpat-filetoken123456"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("output contains unmasked token %q", secret)
+		}
+	}
+}
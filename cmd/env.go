@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var envVars []string
+
+var envCmd = &cobra.Command{
+	Use:         "env [host...]",
+	Annotations: map[string]string{hostArgAnnotation: "true"},
+	Short:       "Print configured tokens as shell export lines",
+	Long: `Print export lines that put configured tokens into the environment variables
+tools conventionally read them from, for example in CI:
+
+  eval "$(nix-auth env)"
+
+github.com maps to GITHUB_TOKEN and gitlab.com to GITLAB_TOKEN. Other hosts
+have no conventional variable; name one with --var host=NAME. Hosts without
+a variable are listed on stderr and skipped. Tokens of GitLab hosts, as set in
+the settings file, by default host or as detected, are exported without the
+OAuth2: or PAT: prefix Nix needs in access-tokens.
+
+If no hosts are specified, all configured tokens are exported.
+
+The output contains the tokens in plain text. Only run this where the output
+is consumed directly, and never in logs that are kept.`,
+	Example: `  # Export the github.com and gitlab.com tokens
+  eval "$(nix-auth env)"
+
+  # Export the token of a self-hosted instance too
+  eval "$(nix-auth env --var git.company.com=COMPANY_GIT_TOKEN)"`,
+	RunE:         runEnv,
+	SilenceUsage: true,
+}
+
+// conventionalEnvVars maps hosts to the environment variable tools read their token from.
+var conventionalEnvVars = map[string]string{
+	"github.com": "GITHUB_TOKEN",
+	"gitlab.com": "GITLAB_TOKEN",
+}
+
+// envVarPattern matches a valid shell variable name.
+var envVarPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func init() {
+	envCmd.Flags().StringArrayVar(&envVars, "var", nil, "Export the token for a host as this variable, as host=NAME (repeatable)")
+	rootCmd.AddCommand(envCmd)
+}
+
+func runEnv(_ *cobra.Command, args []string) error {
+	names, err := parseEnvVars(envVars)
+	if err != nil {
+		return err
+	}
+
+	args, err = parseHosts(args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := openNixConfig()
+	if err != nil {
+		return fmt.Errorf("failed to initialize config: %w", err)
+	}
+
+	hosts, err := getHostsToShow(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(hosts)
+
+	ctx := context.Background()
+
+	for _, host := range hosts {
+		name, ok := names[strings.ToLower(host)]
+		if !ok {
+			_, _ = fmt.Fprintf(os.Stderr, "Skipping %s: no conventional variable (use --var %s=NAME)\n", host, host)
+			continue
+		}
+
+		token, _, err := cfg.LookupToken(host)
+		if err != nil {
+			return fmt.Errorf("failed to look up token for %s: %w", host, err)
+		}
+
+		if token == "" {
+			_, _ = fmt.Fprintf(os.Stderr, "Skipping %s: no token configured\n", host)
+			continue
+		}
+
+		// Tools expect a GitLab token as issued, without the prefix Nix needs
+		writeExport(os.Stdout, name, provider.IssuedToken(knownProvider(ctx, host), token))
+	}
+
+	return nil
+}
+
+// parseEnvVars returns the variable to export each host's token as: the
+// conventional ones, overridden by the host=NAME pairs given with --var.
+func parseEnvVars(pairs []string) (map[string]string, error) {
+	names := make(map[string]string, len(conventionalEnvVars)+len(pairs))
+	for host, name := range conventionalEnvVars {
+		names[host] = name
+	}
+
+	for _, pair := range pairs {
+		host, name, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --var %q: expected host=NAME", pair)
+		}
+
+		host, err := parseHost(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --var %q: %w", pair, err)
+		}
+
+		if !envVarPattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid --var %q: %q is not a valid variable name", pair, name)
+		}
+
+		names[host] = name
+	}
+
+	return names, nil
+}
+
+// writeExport writes a shell export line for name, single-quoting value.
+func writeExport(w io.Writer, name, value string) {
+	_, _ = fmt.Fprintf(w, "export %s='%s'\n", name, strings.ReplaceAll(value, "'", `'\''`))
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/settings"
+)
+
+func TestRunEnv(t *testing.T) {
+	originalConfigPath := configPath
+	originalVars := envVars
+	originalSettings := userSettings
+
+	defer func() {
+		configPath = originalConfigPath
+		envVars = originalVars
+		userSettings = originalSettings
+	}()
+
+	// The providers are set so that no host needs to be detected over the network
+	userSettings = &settings.Settings{Hosts: map[string]settings.Host{
+		"git.company.com":    {Provider: "gitea"},
+		"gitlab.company.com": {Provider: "gitlab"},
+	}}
+
+	configPath = createTestConfig(t, "access-tokens = github.com=ghp_env123 gitlab.com=PAT:glpat-env456 "+
+		"git.company.com=OAuth2:company789 gitlab.company.com=OAuth2:gloas-self123\n")
+
+	tests := []struct {
+		name     string
+		vars     []string
+		args     []string
+		want     []string
+		notWant  []string
+		errorMsg string
+	}{
+		{
+			name:    "conventional variables",
+			want:    []string{"export GITHUB_TOKEN='ghp_env123'\n", "export GITLAB_TOKEN='glpat-env456'\n"},
+			notWant: []string{"company789"},
+		},
+		{
+			name: "variable for a self-hosted instance",
+			vars: []string{"git.company.com=COMPANY_TOKEN"},
+			args: []string{"git.company.com"},
+			want: []string{"export COMPANY_TOKEN='OAuth2:company789'\n"},
+		},
+		{
+			name: "variable for a self-hosted GitLab instance",
+			vars: []string{"gitlab.company.com=COMPANY_GITLAB_TOKEN"},
+			args: []string{"gitlab.company.com"},
+			want: []string{"export COMPANY_GITLAB_TOKEN='gloas-self123'\n"},
+		},
+		{
+			name:    "override a conventional variable",
+			vars:    []string{"github.com=GH_TOKEN"},
+			args:    []string{"github.com"},
+			want:    []string{"export GH_TOKEN='ghp_env123'\n"},
+			notWant: []string{"GITHUB_TOKEN"},
+		},
+		{
+			name:     "invalid variable name",
+			vars:     []string{"github.com=1TOKEN"},
+			errorMsg: "not a valid variable name",
+		},
+		{
+			name:     "missing variable name",
+			vars:     []string{"github.com"},
+			errorMsg: "expected host=NAME",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envVars = tt.vars
+
+			var runErr error
+
+			output := captureStdout(t, func() {
+				runErr = runEnv(nil, tt.args)
+			})
+
+			if tt.errorMsg != "" {
+				if runErr == nil || !strings.Contains(runErr.Error(), tt.errorMsg) {
+					t.Errorf("expected error containing %q, got %v", tt.errorMsg, runErr)
+				}
+
+				return
+			}
+
+			if runErr != nil {
+				t.Fatalf("unexpected error: %v", runErr)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("expected %q in output\nGot output:\n%s", want, output)
+				}
+			}
+
+			for _, notWant := range tt.notWant {
+				if strings.Contains(output, notWant) {
+					t.Errorf("expected no %q in output\nGot output:\n%s", notWant, output)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteExport(t *testing.T) {
+	var buf bytes.Buffer
+
+	writeExport(&buf, "TOKEN", "it's")
+
+	if want := `export TOKEN='it'\''s'` + "\n"; buf.String() != want {
+		t.Errorf("writeExport() = %q, want %q", buf.String(), want)
+	}
+}
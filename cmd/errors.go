@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+// Process exit codes. They are part of the CLI interface so that scripts can
+// react differently to each kind of failure.
+const (
+	// ExitOK means the command succeeded.
+	ExitOK = 0
+	// ExitError is any failure without a more specific code.
+	ExitError = 1
+	// ExitInvalidToken means a provider rejected a token as invalid or expired.
+	ExitInvalidToken = 2
+	// ExitNetwork means a provider could not be reached.
+	ExitNetwork = 3
+	// ExitNotConfigured means there is no config file or no token for the host.
+	ExitNotConfigured = 4
+)
+
+// ExitCodeError is an error together with the exit code the process should use.
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitCodeError) Error() string { return e.Err.Error() }
+
+func (e *ExitCodeError) Unwrap() error { return e.Err }
+
+// ExitCode returns the process exit code for err.
+func ExitCode(err error) int {
+	var (
+		exitErr *ExitCodeError
+		urlErr  *url.Error
+		netErr  net.Error
+	)
+
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &exitErr):
+		return exitErr.Code
+	case errors.As(err, &urlErr), errors.As(err, &netErr):
+		return ExitNetwork
+	case errors.Is(err, nixconf.ErrNotConfigured):
+		return ExitNotConfigured
+	case errors.Is(err, provider.ErrInvalidToken):
+		return ExitInvalidToken
+	default:
+		return ExitError
+	}
+}
+
+// Error formats for --error-format.
+const (
+	errorFormatText = "text"
+	errorFormatJSON = "json"
+)
+
+// hostArgAnnotation marks commands whose first argument is a provider or host,
+// so that a JSON error can name the host.
+const hostArgAnnotation = "nix-auth/host-arg"
+
+// Error codes in --error-format json output. Like the exit codes, they are
+// part of the CLI interface and must not change.
+var errorCodes = map[int]string{
+	ExitError:         "error",
+	ExitInvalidToken:  "token_invalid",
+	ExitNetwork:       "network",
+	ExitNotConfigured: "not_configured",
+}
+
+// jsonError is the object printed for a failure with --error-format json.
+type jsonError struct {
+	Error jsonErrorDetails `json:"error"`
+}
+
+type jsonErrorDetails struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Host    string `json:"host,omitempty"`
+}
+
+// checkErrorFormat rejects an unknown --error-format.
+func checkErrorFormat() error {
+	if errorFormat != errorFormatText && errorFormat != errorFormatJSON {
+		format := errorFormat
+		errorFormat = errorFormatText
+
+		return fmt.Errorf("invalid --error-format %q: must be %s or %s", format, errorFormatText, errorFormatJSON)
+	}
+
+	return nil
+}
+
+// printError writes err, from running cmd, to w as text or, with
+// --error-format json, as a JSON object with a stable code.
+func printError(w io.Writer, cmd *cobra.Command, err error) {
+	if errorFormat != errorFormatJSON {
+		_, _ = fmt.Fprintln(w, "Error:", err)
+		return
+	}
+
+	details := jsonErrorDetails{
+		Code:    errorCodes[ExitCode(err)],
+		Message: err.Error(),
+		Host:    errorHost(cmd),
+	}
+
+	if details.Code == "" {
+		details.Code = errorCodes[ExitError]
+	}
+
+	data, marshalErr := json.Marshal(jsonError{Error: details})
+	if marshalErr != nil {
+		_, _ = fmt.Fprintln(w, "Error:", err)
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, string(data))
+}
+
+// errorHost returns the host a failed command was run for, resolving a
+// provider alias to its default host, or "" if it does not take one.
+func errorHost(cmd *cobra.Command) string {
+	if cmd == nil || cmd.Annotations[hostArgAnnotation] == "" {
+		return ""
+	}
+
+	args := cmd.Flags().Args()
+	if len(args) == 0 {
+		return ""
+	}
+
+	host := normalizeHost(resolveAlias(args[0]))
+	if reg, ok := provider.GetRegistration(host); ok && reg.DefaultHost != "" {
+		return reg.DefaultHost
+	}
+
+	return host
+}
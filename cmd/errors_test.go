@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+func TestExitCode(t *testing.T) {
+	networkErr := &url.Error{Op: "Get", URL: "https://git.example.com", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", err: nil, want: ExitOK},
+		{name: "generic", err: errors.New("boom"), want: ExitError},
+		{name: "invalid token", err: fmt.Errorf("token validation failed: %w", provider.ErrInvalidToken), want: ExitInvalidToken},
+		{name: "network", err: fmt.Errorf("token validation failed: %w", networkErr), want: ExitNetwork},
+		{name: "not configured", err: fmt.Errorf("failed to remove token: %w", nixconf.NotConfiguredf("no token found for %s", "github.com")), want: ExitNotConfigured},
+		{name: "explicit code", err: &ExitCodeError{Code: ExitNetwork, Err: errors.New("down")}, want: ExitNetwork},
+		{name: "wrapped explicit code", err: &ExitCodeError{Code: ExitNotConfigured, Err: provider.ErrInvalidToken}, want: ExitNotConfigured},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintError(t *testing.T) {
+	originalFormat := errorFormat
+
+	t.Cleanup(func() { errorFormat = originalFormat })
+
+	hostCmd := &cobra.Command{Use: "logout <host>", Annotations: map[string]string{hostArgAnnotation: "true"}}
+	_ = hostCmd.Flags().Parse([]string{"https://GitHub.com/"})
+
+	otherCmd := &cobra.Command{Use: "lint"}
+
+	tests := []struct {
+		name   string
+		format string
+		cmd    *cobra.Command
+		err    error
+		want   string
+	}{
+		{
+			name:   "text",
+			format: errorFormatText,
+			cmd:    hostCmd,
+			err:    errors.New("boom"),
+			want:   "Error: boom\n",
+		},
+		{
+			name:   "json with host",
+			format: errorFormatJSON,
+			cmd:    hostCmd,
+			err:    fmt.Errorf("token validation failed: %w", provider.ErrInvalidToken),
+			want:   `{"error":{"code":"token_invalid","message":"token validation failed: token is invalid or expired","host":"github.com"}}` + "\n",
+		},
+		{
+			name:   "json without host",
+			format: errorFormatJSON,
+			cmd:    otherCmd,
+			err:    nixconf.NotConfiguredf("no tokens configured"),
+			want:   `{"error":{"code":"not_configured","message":"no tokens configured"}}` + "\n",
+		},
+		{
+			name:   "json explicit exit code",
+			format: errorFormatJSON,
+			cmd:    nil,
+			err:    &ExitCodeError{Code: ExitNetwork, Err: errors.New("down")},
+			want:   `{"error":{"code":"network","message":"down"}}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errorFormat = tt.format
+
+			var buf bytes.Buffer
+
+			printError(&buf, tt.cmd, tt.err)
+
+			if buf.String() != tt.want {
+				t.Errorf("printError() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
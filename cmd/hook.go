@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+)
+
+// postHookEnv overrides the post-change hook from the settings file.
+const postHookEnv = "NIX_AUTH_POST_HOOK"
+
+// postChangeHook returns the command to run after tokens change, if any.
+func postChangeHook() string {
+	if hook := os.Getenv(postHookEnv); hook != "" {
+		return hook
+	}
+
+	if userSettings == nil {
+		return ""
+	}
+
+	return userSettings.PostHook
+}
+
+// runPostChangeHook runs the configured post-change hook after a successful
+// token change. The hook is run by sh with the operation and host as $1 and $2,
+// and also receives them as NIX_AUTH_OPERATION and NIX_AUTH_HOST.
+// A failing hook only produces a warning since the change itself succeeded.
+func runPostChangeHook(operation, host string, cfg *nixconf.NixConfig) {
+	hook := postChangeHook()
+	if hook == "" {
+		return
+	}
+
+	cmd := exec.Command("sh", "-c", hook, "nix-auth-hook", operation, host) //nolint:gosec // hook is user configured
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"NIX_AUTH_OPERATION="+operation,
+		"NIX_AUTH_HOST="+host,
+		"NIX_AUTH_CONFIG_FILE="+cfg.GetPath(),
+		"NIX_AUTH_TOKEN_FILE="+cfg.GetTokenFilePath(),
+	)
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			fmt.Printf("Warning: post-change hook exited with code %d\n", exitErr.ExitCode())
+			return
+		}
+
+		fmt.Printf("Warning: failed to run post-change hook: %v\n", err)
+	}
+}
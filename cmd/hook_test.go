@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/settings"
+)
+
+func TestRunPostChangeHook(t *testing.T) {
+	originalSettings := userSettings
+
+	t.Cleanup(func() {
+		userSettings = originalSettings
+	})
+
+	cfg, err := nixconf.New(filepath.Join(t.TempDir(), "nix.conf"))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	t.Run("env hook receives operation and host", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "hook.out")
+		t.Setenv(postHookEnv, `echo "$1 $2 $NIX_AUTH_OPERATION $NIX_AUTH_HOST" > `+out)
+
+		userSettings = &settings.Settings{PostHook: "exit 1"}
+
+		runPostChangeHook("set-token", "github.com", cfg)
+
+		content, err := os.ReadFile(out) //nolint:gosec // test file path
+		if err != nil {
+			t.Fatalf("hook did not run: %v", err)
+		}
+
+		if got := strings.TrimSpace(string(content)); got != "set-token github.com set-token github.com" {
+			t.Errorf("hook got %q", got)
+		}
+	})
+
+	t.Run("settings hook failure is a warning", func(t *testing.T) {
+		t.Setenv(postHookEnv, "")
+
+		userSettings = &settings.Settings{PostHook: "exit 3"}
+
+		output := captureStdout(t, func() {
+			runPostChangeHook("logout", "github.com", cfg)
+		})
+
+		if !strings.Contains(output, "Warning: post-change hook exited with code 3") {
+			t.Errorf("expected exit code warning, got %q", output)
+		}
+	})
+
+	t.Run("no hook configured", func(t *testing.T) {
+		t.Setenv(postHookEnv, "")
+
+		userSettings = nil
+
+		output := captureStdout(t, func() {
+			runPostChangeHook("login", "github.com", cfg)
+		})
+
+		if output != "" {
+			t.Errorf("expected no output, got %q", output)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// hostArgPattern matches a normalized host argument: a host name, IPv4 or
+// bracketed IPv6 address with an optional port, optionally followed by a path.
+var hostArgPattern = regexp.MustCompile(`^([a-z0-9_]([a-z0-9_.-]*[a-z0-9_])?|\[[0-9a-f:.]+\])(:[0-9]+)?(/[^\s=]*)?$`)
+
+// normalizeHost turns a user-supplied host argument into the form used as an
+// access-tokens key: surrounding whitespace, a URL scheme such as "https://" and
+// trailing slashes are removed, and the result is lowercased.
+func normalizeHost(input string) string {
+	host := strings.TrimSpace(input)
+
+	if idx := strings.Index(host, "://"); idx != -1 {
+		host = host[idx+len("://"):]
+	}
+
+	host = strings.TrimRight(host, "/")
+
+	return strings.ToLower(host)
+}
+
+// normalizeHosts applies normalizeHost to every host.
+func normalizeHosts(inputs []string) []string {
+	hosts := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		hosts = append(hosts, normalizeHost(input))
+	}
+
+	return hosts
+}
+
+// resolveAlias returns the host an alias from the settings file names, or
+// input itself if it is not an alias.
+func resolveAlias(input string) string {
+	if host, ok := userSettings.ResolveAlias(strings.TrimSpace(input)); ok {
+		return host
+	}
+
+	return input
+}
+
+// parseHost resolves an alias and normalizes a host argument like
+// normalizeHost. It rejects one that is empty or does not look like a host,
+// such as a shell variable that expanded to nothing, so it never ends up as an
+// access-tokens key or in a URL.
+func parseHost(input string) (string, error) {
+	host := normalizeHost(resolveAlias(input))
+	if host == "" {
+		return "", fmt.Errorf("host argument is empty")
+	}
+
+	if !hostArgPattern.MatchString(host) {
+		return "", fmt.Errorf("invalid host %q: expected a host name such as github.com", strings.TrimSpace(input))
+	}
+
+	return host, nil
+}
+
+// parseHosts applies parseHost to every host.
+func parseHosts(inputs []string) ([]string, error) {
+	hosts := make([]string, 0, len(inputs))
+
+	for _, input := range inputs {
+		host, err := parseHost(input)
+		if err != nil {
+			return nil, err
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/settings"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "github.com", want: "github.com"},
+		{input: "https://github.com", want: "github.com"},
+		{input: "github.com/", want: "github.com"},
+		{input: "GitHub.com", want: "github.com"},
+		{input: "https://GitHub.com//", want: "github.com"},
+		{input: "http://git.company.com:8080/", want: "git.company.com:8080"},
+		{input: "  gitlab.com  ", want: "gitlab.com"},
+		{input: "github", want: "github"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := normalizeHost(tt.input); got != tt.want {
+				t.Errorf("normalizeHost(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHost(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{input: "https://GitHub.com/", want: "github.com"},
+		{input: "git.company.com:8080", want: "git.company.com:8080"},
+		{input: "127.0.0.1:3000", want: "127.0.0.1:3000"},
+		{input: "[::1]:3000", want: "[::1]:3000"},
+		{input: "github.com/numtide", want: "github.com/numtide"},
+		{input: "github", want: "github"},
+		{input: "", wantErr: "host argument is empty"},
+		{input: "   ", wantErr: "host argument is empty"},
+		{input: "https://", wantErr: "host argument is empty"},
+		{input: "git company.com", wantErr: "invalid host"},
+		{input: "github.com=ghp_token", wantErr: "invalid host"},
+		{input: "-github.com", wantErr: "invalid host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseHost(tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseHost(%q) error = %v, want %q", tt.input, err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil || got != tt.want {
+				t.Errorf("parseHost(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHostAlias(t *testing.T) {
+	originalSettings := userSettings
+
+	defer func() { userSettings = originalSettings }()
+
+	userSettings = &settings.Settings{Aliases: map[string]settings.Alias{
+		"work": {Host: "Git.Company.com"},
+	}}
+
+	for input, want := range map[string]string{"work": "git.company.com", " WORK ": "git.company.com", "workshop": "workshop"} {
+		if got, err := parseHost(input); err != nil || got != want {
+			t.Errorf("parseHost(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+}
+
+func TestCommandsRejectEmptyHost(t *testing.T) {
+	setupLoginTest(t)
+
+	configPath = createTestConfig(t, "access-tokens = github.com=ghp_existing\n")
+
+	commands := []struct {
+		name string
+		run  func(args []string) error
+	}{
+		{name: "login", run: func(args []string) error { return runLogin(nil, args) }},
+		{name: "logout", run: func(args []string) error { return logoutCmd.RunE(logoutCmd, args) }},
+		{name: "set-token", run: func(args []string) error { return setTokenCmd.RunE(setTokenCmd, append(args, "ghp_token")) }},
+		{name: "status", run: func(args []string) error { return runStatus(nil, args) }},
+	}
+
+	for _, command := range commands {
+		for _, arg := range []string{"", "  \t"} {
+			t.Run(command.name+"/"+strconv.Quote(arg), func(t *testing.T) {
+				var err error
+
+				captureStdout(t, func() { err = command.run([]string{arg}) })
+
+				if err == nil || !strings.Contains(err.Error(), "host argument is empty") {
+					t.Errorf("expected empty host error, got %v", err)
+				}
+			})
+		}
+	}
+
+	cfg, err := nixconf.New(configPath)
+	if err != nil {
+		t.Fatalf("failed to open config: %v", err)
+	}
+
+	if hosts, _ := cfg.ListTokens(); len(hosts) != 1 || hosts[0] != "github.com" {
+		t.Errorf("expected tokens to be unchanged, got %v", hosts)
+	}
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check that tokens are stored securely",
+	Long: `Check that access tokens are only stored in the separate token file included
+from nix.conf, never directly in nix.conf itself, and that the token file has
+0600 permissions, or those set with --token-mode. Includes that resolve outside the nix.conf directory are
+reported as warnings, since they may pull tokens from an unexpected place.
+
+Exits with a non-zero status if a problem is found, so it can be used in
+pre-commit hooks or CI to keep tokens out of a committed nix.conf. With
+--strict-parse, unparseable lines, duplicate settings and malformed tokens
+are reported as problems too.`,
+	Args:         cobra.NoArgs,
+	RunE:         runLint,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
+
+func runLint(_ *cobra.Command, _ []string) error {
+	cfg, err := openNixConfig()
+	if err != nil {
+		return fmt.Errorf("failed to initialize config: %w", err)
+	}
+
+	issues, err := cfg.Lint()
+	if err != nil {
+		return fmt.Errorf("failed to lint config: %w", err)
+	}
+
+	if len(issues) == 0 {
+		fmt.Printf("✓ No problems found in %s\n", cfg.GetPath())
+		return nil
+	}
+
+	problems := 0
+	syntaxOnly := true
+
+	for _, issue := range issues {
+		fmt.Println(issue)
+
+		if !issue.Warning {
+			problems++
+			syntaxOnly = syntaxOnly && issue.Syntax
+		}
+	}
+
+	if problems == 0 {
+		return nil
+	}
+
+	if !syntaxOnly {
+		fmt.Println("\nRun 'nix-auth set-token' or 'nix-auth login' to migrate tokens to the token file.")
+	}
+
+	return fmt.Errorf("found %d problem(s)", problems)
+}
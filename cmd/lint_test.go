@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunLint(t *testing.T) {
+	originalConfigPath := configPath
+	originalStrictParse := strictParse
+
+	defer func() {
+		configPath = originalConfigPath
+		strictParse = originalStrictParse
+	}()
+
+	t.Run("clean config", func(t *testing.T) {
+		configPath = createTestConfig(t, "experimental-features = nix-command flakes\n")
+
+		var runErr error
+
+		output := captureStdout(t, func() {
+			runErr = runLint(nil, nil)
+		})
+
+		if runErr != nil || !strings.Contains(output, "No problems found") {
+			t.Errorf("runLint() = %v\nGot output:\n%s", runErr, output)
+		}
+	})
+
+	t.Run("inline tokens fail", func(t *testing.T) {
+		configPath = createTestConfig(t, "access-tokens = github.com=ghp_inline\n")
+
+		var runErr error
+
+		output := captureStdout(t, func() {
+			runErr = runLint(nil, nil)
+		})
+
+		if runErr == nil || ExitCode(runErr) == ExitOK {
+			t.Errorf("expected lint to fail, got %v", runErr)
+		}
+
+		if !strings.Contains(output, "nix.conf:1: access-tokens set directly in the main config") {
+			t.Errorf("expected issue in output\nGot output:\n%s", output)
+		}
+	})
+
+	t.Run("strict parsing reports malformed lines", func(t *testing.T) {
+		configPath = createTestConfig(t, "max-jobs = 4\nmax-jobs = 8\n")
+		strictParse = true
+
+		defer func() { strictParse = false }()
+
+		var runErr error
+
+		output := captureStdout(t, func() {
+			runErr = runLint(nil, nil)
+		})
+
+		if runErr == nil {
+			t.Errorf("expected lint to fail")
+		}
+
+		if !strings.Contains(output, "nix.conf:2: max-jobs is already set at") {
+			t.Errorf("expected duplicate setting in output\nGot output:\n%s", output)
+		}
+	})
+}
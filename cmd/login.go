@@ -3,9 +3,15 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"slices"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/numtide/nix-auth/internal/nixconf"
 	"github.com/numtide/nix-auth/internal/provider"
@@ -14,8 +20,9 @@ import (
 )
 
 var loginCmd = &cobra.Command{
-	Use:   "login [provider-or-host]",
-	Short: "Authenticate with a provider and save the access token",
+	Use:         "login [provider-or-host]",
+	Annotations: map[string]string{hostArgAnnotation: "true"},
+	Short:       "Authenticate with a provider and save the access token",
 	Long: `Authenticate with a provider using OAuth device flow (or Personal Access Token for Gitea/Forgejo)
 and save the access token to your nix.conf for use with Nix flakes.
 
@@ -26,7 +33,12 @@ You can specify either:
 Notes:
 - The --provider flag only works when specifying a host, not with provider aliases
 - For Forgejo, you must specify a host as it has no default: nix-auth login <host> --provider forgejo
-- Using both a provider alias and --provider flag will result in an error`,
+- Using both a provider alias and --provider flag will result in an error
+
+If you already have a token, --token skips the authentication flow: the token
+is validated and saved for the resolved host like one obtained by logging in.
+Use --token - to read it from stdin, which keeps it out of the shell history.
+A raw GitLab token gets the PAT: or OAuth2: prefix Nix needs before saving.`,
 	SilenceUsage: true,
 	Example: `  # Using provider aliases
   nix-auth login                           # defaults to github
@@ -42,66 +54,191 @@ Notes:
   
   # Explicit provider specification
   nix-auth login git.company.com --provider forgejo
-  nix-auth login github.company.com --client-id abc123`,
+  nix-auth login github.company.com --client-id abc123
+
+  # Machine-readable preview
+  nix-auth login gitlab.company.com --dry-run --json
+
+  # Log in on a headless server by scanning a QR code with a phone
+  nix-auth login gitlab --qr
+
+  # Check a new OAuth app setup without saving the token
+  nix-auth login github.company.com --client-id abc123 --validate-only
+
+  # Fail instead of logging in if the host is not GitLab
+  nix-auth login git.company.com --expect-provider gitlab
+
+  # Save an existing token instead of authenticating
+  pass show gitlab-token | nix-auth login gitlab.company.com --token -`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runLogin,
 }
 
 var (
-	loginProvider string
-	loginClientID string
-	loginForce    bool
-	loginDryRun   bool
+	loginProvider     string
+	loginClientID     string
+	loginClientSecret string
+	loginForce        bool
+	loginDryRun       bool
+	loginJSON         bool
+	loginAPIURL       string
+	loginScopes       []string
+	loginValidateOnly bool
+	loginExpect       string
+	loginNoBrowser    bool
+	loginWaitForEnter bool
+	loginQRCode       bool
+	loginToken        string
+
+	loginPollInterval      time.Duration
+	loginSlowDownIncrement time.Duration
+)
+
+const (
+	// stdinToken as the value of --token reads the token from stdin.
+	stdinToken = "-"
+	// clientSecretEnv sets the default for --client-secret.
+	clientSecretEnv = "NIX_AUTH_CLIENT_SECRET"
+	// pollIntervalEnv sets the default for --poll-interval.
+	pollIntervalEnv = "NIX_AUTH_POLL_INTERVAL"
+	// slowDownIncrementEnv sets the default for --slow-down-increment.
+	slowDownIncrementEnv = "NIX_AUTH_SLOW_DOWN_INCREMENT"
 )
 
 func init() {
 	loginCmd.Flags().StringVar(&loginProvider, "provider", "auto", "Provider type when using a host (auto, github, gitlab, gitea, forgejo, codeberg)")
 	loginCmd.Flags().StringVar(&loginClientID, "client-id", "", "OAuth client ID (required for GitHub Enterprise, optional for others)")
+	loginCmd.Flags().StringVar(&loginClientSecret, "client-secret", "",
+		"OAuth client secret for confidential apps (prefer $"+clientSecretEnv+" or the settings file, as flags are visible to other users)")
 	loginCmd.Flags().BoolVar(&loginForce, "force", false, "Skip confirmation prompt when replacing existing tokens")
 	loginCmd.Flags().BoolVar(&loginDryRun, "dry-run", false, "Preview what would happen without authenticating")
+	loginCmd.Flags().BoolVar(&loginJSON, "json", false, "Print the --dry-run preview as JSON")
+	loginCmd.Flags().BoolVar(&loginValidateOnly, "validate-only", false, "Authenticate and validate, then discard the token without saving it")
+	loginCmd.Flags().StringSliceVar(&loginScopes, "scopes", nil, "Comma-separated scopes to request instead of the configured or default scopes; +scope adds to them")
+	loginCmd.Flags().BoolVar(&loginNoBrowser, "no-browser", false, "Print the authorization URL instead of opening a browser")
+	loginCmd.Flags().BoolVar(&loginWaitForEnter, "wait-for-enter", false,
+		"Wait for Enter after showing the one-time code before opening the browser (skipped when not in a terminal)")
+	loginCmd.Flags().BoolVar(&loginQRCode, "qr", false,
+		"Show the authorization URL as a QR code to scan with a phone instead of opening a browser")
+	loginCmd.Flags().StringVar(&loginExpect, "expect-provider", "",
+		"Fail unless the host resolves to this provider, e.g. to catch detection surprises in scripted logins")
+	loginCmd.Flags().StringVar(&loginToken, "token", "",
+		"Validate and save this token instead of authenticating, or read it from stdin with -")
+	loginCmd.Flags().StringVar(&loginAPIURL, "api-url", "", "API base URL for validation when it differs from the host (e.g., https://api.git.company.com)")
+	loginCmd.Flags().DurationVar(&loginPollInterval, "poll-interval", 0,
+		"Minimum time between device flow token requests (default 5s, or $"+pollIntervalEnv+")")
+	loginCmd.Flags().DurationVar(&loginSlowDownIncrement, "slow-down-increment", 0,
+		"Added to the polling interval when the server asks to slow down (default 5s, or $"+slowDownIncrementEnv+")")
+}
+
+// loginPlan is the --dry-run preview of a login, as printed with --json.
+type loginPlan struct {
+	Provider        string   `json:"provider"`
+	Host            string   `json:"host"`
+	Scopes          []string `json:"scopes"`
+	ClientIDPresent bool     `json:"client_id_present"`
+	// ClientSecretPresent never carries the secret itself.
+	ClientSecretPresent bool   `json:"client_secret_present"`
+	ConfigPath          string `json:"config_path"`
+	AuthMethod          string `json:"auth_method"`
 }
 
 func runLogin(_ *cobra.Command, args []string) error {
+	if loginJSON && !loginDryRun {
+		return fmt.Errorf("--json can only be used with --dry-run")
+	}
+
+	if loginValidateOnly && loginDryRun {
+		return fmt.Errorf("--validate-only cannot be used with --dry-run")
+	}
+
+	if loginToken != "" && loginDryRun {
+		return fmt.Errorf("--token cannot be used with --dry-run")
+	}
+
 	// Parse the input
 	input := "github" // default
 	if len(args) > 0 {
-		input = strings.ToLower(args[0])
+		var err error
+
+		input, err = parseHost(args[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := checkPollSettings(); err != nil {
+		return err
 	}
 
 	// Resolve provider and host
-	prov, host, err := resolveProviderAndHost(input, loginProvider)
+	prov, host, err := resolveProviderAndHost(input, configuredProvider(input, loginProvider))
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Authenticating with %s (%s)...\n", prov.Name(), host)
+	if err := checkExpectedProvider(prov, host, loginExpect); err != nil {
+		return err
+	}
 
-	// If dry-run, show what would happen and exit
-	if loginDryRun {
-		fmt.Println("\nDry-run mode: Preview of what would happen:")
-		fmt.Printf("- Provider: %s\n", prov.Name())
-		fmt.Printf("- Host: %s\n", host)
-		fmt.Printf("- OAuth scopes: %s\n", strings.Join(prov.GetScopes(), ", "))
+	tokenFromStdin := loginToken == stdinToken
+	if tokenFromStdin {
+		if loginToken, err = readTokenLine(os.Stdin); err != nil {
+			return err
+		}
 
-		if loginClientID != "" {
-			fmt.Printf("- Client ID: %s\n", loginClientID)
+		if loginToken == "" {
+			return fmt.Errorf("token cannot be empty")
 		}
+	}
 
-		fmt.Printf("- Config file: %s\n", configPath)
-		fmt.Println("\nNo authentication performed. Run without --dry-run to authenticate.")
+	if warning := ui.TruncatedTokenWarning(loginToken); warning != "" {
+		_, _ = fmt.Fprintln(loginOut(), warning)
+	}
 
-		return nil
+	if !loginDryRun {
+		warnPrivateHost(context.Background(), loginOut(), host)
 	}
 
-	// Check if token already exists
-	cfg, err := nixconf.New(configPath)
+	if loginToken != "" {
+		_, _ = fmt.Fprintf(loginOut(), "Using the given token for %s (%s)...\n", prov.Name(), host)
+	} else {
+		_, _ = fmt.Fprintf(loginOut(), "Authenticating with %s (%s)...\n", prov.Name(), host)
+	}
+
+	cfg, err := openNixConfig()
 	if err != nil {
 		return fmt.Errorf("failed to initialize config: %w", err)
 	}
 
+	// If dry-run, show what would happen and exit
+	if loginDryRun {
+		return showLoginPlan(prov, host, cfg)
+	}
+
+	// Validate-only never touches the config, so it works on read-only setups too
+	if loginValidateOnly {
+		return authenticateAndDiscard(context.Background(), prov, host)
+	}
+
+	if err := requireWritableConfig(); err != nil {
+		return err
+	}
+
+	if err := checkMainConfig(cfg); err != nil {
+		return err
+	}
+
+	// Check if token already exists
+
 	existingToken, _ := cfg.GetToken(host)
 	if existingToken != "" && !loginForce {
-		confirm, err := ui.ReadYesNo(fmt.Sprintf("A token for %s already exists. Do you want to replace it? [y/N] ", host))
+		// Stdin already held the token, so it cannot answer the prompt
+		if tokenFromStdin && !assumeYes {
+			return fmt.Errorf("a token for %s already exists; use --force or --yes to replace it with the token from stdin", host)
+		}
+
+		confirm, err := ui.Confirm(fmt.Sprintf("A token for %s already exists. Do you want to replace it?", host), false)
 		if err != nil {
 			return fmt.Errorf("failed to read confirmation: %w", err)
 		}
@@ -112,10 +249,45 @@ func runLogin(_ *cobra.Command, args []string) error {
 		}
 	}
 
-	// Perform authentication
-	ctx := context.Background()
+	return authenticateAndSave(context.Background(), prov, host, cfg)
+}
 
-	token, err := prov.Authenticate(ctx)
+// authenticateAndSave runs the provider's authentication flow for host, validates
+// the resulting token and saves it to the config.
+func authenticateAndSave(ctx context.Context, prov provider.Provider, host string, cfg *nixconf.NixConfig) error {
+	token, err := authenticateAndValidate(ctx, prov)
+	if err != nil {
+		return err
+	}
+
+	// Save token
+	if err := cfg.SetToken(host, token); err != nil {
+		return fmt.Errorf("failed to save token: %w", err)
+	}
+
+	if loginToken != "" {
+		fmt.Printf("\nSuccessfully saved token for %s\n", host)
+	} else {
+		fmt.Printf("\nSuccessfully authenticated and saved token for %s\n", host)
+	}
+	fmt.Printf("Token saved to: %s\n", cfg.GetPath())
+
+	recordTokenExpiry(host, provider.GetTokenExpiry(prov))
+	// A token given with --token was not obtained with the client ID
+	if loginToken == "" {
+		recordClientID(host, clientIDForHost(host))
+	}
+	printChangeSummary(cfg)
+
+	runPostChangeHook("login", host, cfg)
+
+	return nil
+}
+
+// authenticateAndValidate runs the provider's authentication flow and
+// validates the resulting token.
+func authenticateAndValidate(ctx context.Context, prov provider.Provider) (string, error) {
+	token, err := obtainToken(ctx, prov)
 	if err != nil {
 		errMsg := fmt.Sprintf("authentication failed: %v", err)
 		if strings.Contains(err.Error(), "client ID") {
@@ -123,7 +295,7 @@ func runLogin(_ *cobra.Command, args []string) error {
 				"See the instructions above or use --dry-run to preview the configuration."
 		}
 
-		return errors.New(errMsg)
+		return "", errors.New(errMsg)
 	}
 
 	// Validate token
@@ -131,28 +303,106 @@ func runLogin(_ *cobra.Command, args []string) error {
 
 	status, err := prov.ValidateToken(ctx, token)
 	if err != nil && status != provider.ValidationStatusUnknown {
-		return fmt.Errorf("token validation failed: %w", err)
+		return "", fmt.Errorf("token validation failed: %w", err)
 	}
 
 	if status == provider.ValidationStatusInvalid {
-		return fmt.Errorf("token is invalid")
+		return "", provider.ErrInvalidToken
 	}
 
 	if status == provider.ValidationStatusUnknown {
 		fmt.Println("Warning: Token cannot be verified (unknown provider)")
 	}
 
-	// Save token
-	if err := cfg.SetToken(host, token); err != nil {
-		return fmt.Errorf("failed to save token: %w", err)
+	return token, nil
+}
+
+// obtainToken returns the token given with --token, in the form it is stored
+// in, or runs the provider's authentication flow to get one.
+func obtainToken(ctx context.Context, prov provider.Provider) (string, error) {
+	if loginToken != "" {
+		return provider.StoredToken(prov, loginToken), nil
 	}
 
-	fmt.Printf("\nSuccessfully authenticated and saved token for %s\n", host)
-	fmt.Printf("Token saved to: %s\n", cfg.GetPath())
+	return prov.Authenticate(ctx)
+}
+
+// authenticateAndDiscard is login --validate-only: it authenticates, reports
+// what the token grants and then drops it without writing anything.
+func authenticateAndDiscard(ctx context.Context, prov provider.Provider, host string) error {
+	token, err := authenticateAndValidate(ctx, prov)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nSuccessfully authenticated with %s\n", host)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, tabPadding, ' ', 0)
+	showUserInfo(ctx, prov, token, w)
+	showTokenScopes(ctx, w, prov, token)
+
+	if expiresAt := provider.GetTokenExpiry(prov); !expiresAt.IsZero() {
+		_, _ = fmt.Fprintf(w, "  Expires\tin %s (%s)\n",
+			formatDuration(time.Until(expiresAt)), expiresAt.Local().Format(time.RFC1123))
+	}
+
+	_ = w.Flush()
+
+	fmt.Println("Token discarded (--validate-only); nothing was saved.")
 
 	return nil
 }
 
+// showLoginPlan prints what a login would do without authenticating.
+func showLoginPlan(prov provider.Provider, host string, cfg *nixconf.NixConfig) error {
+	clientID := clientIDForHost(host)
+
+	if loginJSON {
+		plan := loginPlan{
+			Provider:            prov.Name(),
+			Host:                host,
+			Scopes:              prov.GetScopes(),
+			ClientIDPresent:     clientID != "",
+			ClientSecretPresent: clientSecretForHost(host) != "",
+			ConfigPath:          cfg.GetPath(),
+			AuthMethod:          string(provider.GetAuthMethod(prov)),
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(plan)
+	}
+
+	fmt.Println("\nDry-run mode: Preview of what would happen:")
+	fmt.Printf("- Provider: %s\n", prov.Name())
+	fmt.Printf("- Host: %s\n", host)
+	fmt.Printf("- OAuth scopes: %s\n", strings.Join(prov.GetScopes(), ", "))
+
+	if clientID != "" {
+		fmt.Printf("- Client ID: %s\n", clientID)
+	}
+
+	if clientSecretForHost(host) != "" {
+		fmt.Println("- Client secret: set")
+	}
+
+	fmt.Printf("- Config file: %s\n", cfg.GetPath())
+	fmt.Println("\nNo authentication performed. Run without --dry-run to authenticate.")
+
+	return nil
+}
+
+// loginOut returns where progress messages are written.
+// With --json they go to stderr so stdout only contains the JSON document.
+func loginOut() io.Writer {
+	if loginJSON {
+		return os.Stderr
+	}
+
+	return os.Stdout
+}
+
 // resolveProviderAndHost determines the provider and host from the input.
 func resolveProviderAndHost(input, providerFlag string) (provider.Provider, string, error) {
 	// Check if input is a provider alias
@@ -170,11 +420,7 @@ func resolveProviderAndHost(input, providerFlag string) (provider.Provider, stri
 		}
 
 		// Create provider with config
-		cfg := provider.Config{
-			Host:     host,
-			ClientID: loginClientID,
-		}
-		prov := reg.New(cfg)
+		prov := reg.New(loginProviderConfig(host))
 
 		return prov, host, nil
 	}
@@ -183,33 +429,45 @@ func resolveProviderAndHost(input, providerFlag string) (provider.Provider, stri
 	return resolveProviderForHost(input, providerFlag)
 }
 
+// checkExpectedProvider returns an error if --expect-provider names a provider
+// other than prov. Aliases are compared by the provider they create, so
+// codeberg matches a Forgejo provider.
+func checkExpectedProvider(prov provider.Provider, host, expected string) error {
+	if expected == "" {
+		return nil
+	}
+
+	want, ok := provider.GetWithConfig(expected, provider.Config{Host: host})
+	if !ok {
+		available := strings.Join(provider.List(), ", ")
+		return fmt.Errorf("unknown provider '%s' for --expect-provider. Available providers: %s", expected, available)
+	}
+
+	if want.Name() != prov.Name() {
+		return fmt.Errorf("%s resolved to provider %s, but %s was expected (--expect-provider); not logging in",
+			host, prov.Name(), expected)
+	}
+
+	return nil
+}
+
 // resolveProviderForHost handles the case where input is a host.
 func resolveProviderForHost(host, providerFlag string) (provider.Provider, string, error) {
 	if providerFlag == "auto" {
 		// Auto-detect provider type
-		fmt.Printf("Detecting provider type for %s by querying API...\n", host)
+		_, _ = fmt.Fprintf(loginOut(), "Detecting provider type for %s by querying API...\n", host)
 
 		ctx := context.Background()
 
-		prov, err := provider.Detect(ctx, host, loginClientID)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to detect provider for %s: %w\n"+
-				"Try: nix-auth login %s --provider <github|gitlab|gitea|forgejo>",
-				host, err, host)
-		}
+		prov := provider.DetectCached(ctx, loginProviderConfig(host))
 
-		fmt.Printf("Detected: %s\n\n", prov.Name())
+		_, _ = fmt.Fprintf(loginOut(), "Detected: %s\n\n", prov.Name())
 
 		return prov, host, nil
 	}
 
 	// Use explicitly specified provider
-	cfg := provider.Config{
-		Host:     host,
-		ClientID: loginClientID,
-	}
-
-	prov, ok := provider.GetWithConfig(providerFlag, cfg)
+	prov, ok := provider.GetWithConfig(providerFlag, loginProviderConfig(host))
 	if !ok {
 		available := strings.Join(provider.List(), ", ")
 		return nil, "", fmt.Errorf("unknown provider '%s'. Available providers: %s", providerFlag, available)
@@ -217,3 +475,109 @@ func resolveProviderForHost(host, providerFlag string) (provider.Provider, strin
 
 	return prov, host, nil
 }
+
+// loginProviderConfig returns the provider configuration for host,
+// with --client-id, --api-url and --scopes taking precedence over the settings file.
+// --scopes entries that only add scopes (+scope) add them to the settings scopes.
+func loginProviderConfig(host string) provider.Config {
+	cfg := providerConfig(host)
+	cfg.ClientID = clientIDForHost(host)
+	cfg.ClientSecret = clientSecretForHost(host)
+
+	if loginAPIURL != "" {
+		cfg.APIURL = loginAPIURL
+	}
+
+	if len(loginScopes) > 0 {
+		if onlyAddedScopes(loginScopes) {
+			cfg.Scopes = append(slices.Clone(cfg.Scopes), loginScopes...)
+		} else {
+			cfg.Scopes = loginScopes
+		}
+	}
+
+	cfg.NoBrowser = loginNoBrowser
+	cfg.WaitForEnter = loginWaitForEnter
+	cfg.QRCode = loginQRCode
+
+	cfg.PollInterval, _ = pollSetting(loginPollInterval, pollIntervalEnv)
+	cfg.SlowDownIncrement, _ = pollSetting(loginSlowDownIncrement, slowDownIncrementEnv)
+
+	return cfg
+}
+
+// onlyAddedScopes reports whether every scope is written as +scope.
+func onlyAddedScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !strings.HasPrefix(scope, "+") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pollSetting returns a device flow polling duration: flag if set, otherwise
+// the duration in the environment variable env, otherwise zero for the default.
+func pollSetting(flag time.Duration, env string) (time.Duration, error) {
+	if flag < 0 {
+		return 0, fmt.Errorf("polling durations must not be negative, got %s", flag)
+	}
+
+	if flag != 0 {
+		return flag, nil
+	}
+
+	value := os.Getenv(env)
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", env, err)
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("invalid %s: must not be negative, got %s", env, d)
+	}
+
+	return d, nil
+}
+
+// checkPollSettings rejects invalid --poll-interval, --slow-down-increment
+// and environment values before a login starts.
+func checkPollSettings() error {
+	if _, err := pollSetting(loginPollInterval, pollIntervalEnv); err != nil {
+		return err
+	}
+
+	_, err := pollSetting(loginSlowDownIncrement, slowDownIncrementEnv)
+
+	return err
+}
+
+// clientIDForHost returns the OAuth client ID to use for a host.
+// The --client-id flag takes precedence over configuredClientID.
+func clientIDForHost(host string) string {
+	if loginClientID != "" {
+		return loginClientID
+	}
+
+	return configuredClientID(host)
+}
+
+// clientSecretForHost returns the OAuth client secret to use for a host, from
+// --client-secret, NIX_AUTH_CLIENT_SECRET or the settings file, in that order.
+// The secret must never be printed.
+func clientSecretForHost(host string) string {
+	if loginClientSecret != "" {
+		return loginClientSecret
+	}
+
+	if secret := os.Getenv(clientSecretEnv); secret != "" {
+		return secret
+	}
+
+	return userSettings.Host(host).ClientSecret
+}
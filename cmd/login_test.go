@@ -0,0 +1,721 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/numtide/nix-auth/internal/settings"
+	"github.com/numtide/nix-auth/internal/ui"
+)
+
+// setupLoginTest saves and restores global state for login tests.
+func setupLoginTest(t *testing.T) {
+	t.Helper()
+
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+	originalProvider := loginProvider
+	originalClientID := loginClientID
+	originalClientSecret := loginClientSecret
+	originalDryRun := loginDryRun
+	originalJSON := loginJSON
+	originalValidateOnly := loginValidateOnly
+	originalExpect := loginExpect
+	originalToken := loginToken
+	originalForce := loginForce
+
+	t.Cleanup(func() {
+		configPath = originalConfigPath
+
+		provider.SetRegistry(originalRegistry)
+
+		loginProvider = originalProvider
+		loginClientID = originalClientID
+		loginClientSecret = originalClientSecret
+		loginDryRun = originalDryRun
+		loginJSON = originalJSON
+		loginValidateOnly = originalValidateOnly
+		loginExpect = originalExpect
+		loginToken = originalToken
+		loginForce = originalForce
+	})
+
+	loginProvider = "auto"
+	loginClientID = ""
+	loginClientSecret = ""
+	loginDryRun = false
+	loginJSON = false
+	loginValidateOnly = false
+	loginExpect = ""
+	loginToken = ""
+	loginForce = false
+}
+
+// captureLoginOutput runs the login command and returns what it wrote to stdout.
+func captureLoginOutput(t *testing.T, args []string) (string, error) {
+	t.Helper()
+
+	var err error
+
+	output := captureStdout(t, func() {
+		err = runLogin(nil, args)
+	})
+
+	return output, err
+}
+
+// captureStdout returns everything fn writes to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	fn()
+
+	_ = w.Close()
+
+	os.Stdout = oldStdout
+
+	_, _ = buf.ReadFrom(r)
+
+	return buf.String()
+}
+
+func TestLoginDryRunJSON(t *testing.T) {
+	setupLoginTest(t)
+
+	configPath = filepath.Join(t.TempDir(), "nix.conf")
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	provider.RegisterProvider("mock", provider.Registration{
+		New: func(cfg provider.Config) provider.Provider {
+			return &mockStatusProvider{name: "mock", host: cfg.Host, scopes: []string{"read_api"}}
+		},
+		DefaultHost: "mock.example.com",
+	})
+
+	loginDryRun = true
+	loginJSON = true
+	loginClientID = "abc123"
+	loginClientSecret = "s3cr3t-value"
+
+	output, err := captureLoginOutput(t, []string{"mock"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var plan loginPlan
+	if err := json.Unmarshal([]byte(output), &plan); err != nil {
+		t.Fatalf("stdout is not a JSON plan: %v\nGot output:\n%s", err, output)
+	}
+
+	expected := loginPlan{
+		Provider:            "mock",
+		Host:                "mock.example.com",
+		Scopes:              []string{"read_api"},
+		ClientIDPresent:     true,
+		ClientSecretPresent: true,
+		ConfigPath:          configPath,
+		AuthMethod:          string(provider.AuthMethodManual),
+	}
+
+	if plan.Provider != expected.Provider || plan.Host != expected.Host ||
+		strings.Join(plan.Scopes, ",") != strings.Join(expected.Scopes, ",") ||
+		plan.ClientIDPresent != expected.ClientIDPresent || plan.ClientSecretPresent != expected.ClientSecretPresent ||
+		plan.ConfigPath != expected.ConfigPath ||
+		plan.AuthMethod != expected.AuthMethod {
+		t.Errorf("plan = %+v, want %+v", plan, expected)
+	}
+
+	if strings.Contains(output, "abc123") {
+		t.Errorf("JSON plan must not include the client ID itself\nGot output:\n%s", output)
+	}
+
+	if strings.Contains(output, "s3cr3t-value") {
+		t.Errorf("JSON plan must not include the client secret\nGot output:\n%s", output)
+	}
+}
+
+func TestLoginJSONRequiresDryRun(t *testing.T) {
+	setupLoginTest(t)
+
+	loginJSON = true
+
+	err := runLogin(nil, []string{"github"})
+	if err == nil || !strings.Contains(err.Error(), "--dry-run") {
+		t.Errorf("expected error requiring --dry-run, got %v", err)
+	}
+}
+
+func TestLoginProviderConfigScopes(t *testing.T) {
+	setupLoginTest(t)
+
+	originalSettings := userSettings
+	originalScopes := loginScopes
+
+	t.Cleanup(func() {
+		userSettings = originalSettings
+		loginScopes = originalScopes
+	})
+
+	userSettings = &settings.Settings{Hosts: map[string]settings.Host{
+		"git.company.com": {Scopes: []string{"read_api", "read_registry"}},
+	}}
+
+	tests := []struct {
+		name       string
+		host       string
+		flagScopes []string
+		want       []string
+	}{
+		{name: "settings scopes", host: "git.company.com", want: []string{"read_api", "read_registry"}},
+		{name: "flag wins", host: "git.company.com", flagScopes: []string{"api"}, want: []string{"api"}},
+		{name: "added to settings scopes", host: "git.company.com", flagScopes: []string{"+write_registry"}, want: []string{"read_api", "read_registry", "+write_registry"}},
+		{name: "added without settings scopes", host: "gitlab.com", flagScopes: []string{"+read_registry"}, want: []string{"+read_registry"}},
+		{name: "mixed flag wins", host: "git.company.com", flagScopes: []string{"api", "+read_registry"}, want: []string{"api", "+read_registry"}},
+		{name: "no override", host: "gitlab.com", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loginScopes = tt.flagScopes
+
+			got := loginProviderConfig(tt.host).Scopes
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Scopes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginHostAliasProvider(t *testing.T) {
+	setupLoginTest(t)
+
+	originalSettings := userSettings
+
+	t.Cleanup(func() { userSettings = originalSettings })
+
+	configPath = filepath.Join(t.TempDir(), "nix.conf")
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+
+	for _, name := range []string{"mock", "other"} {
+		provider.RegisterProvider(name, provider.Registration{
+			New: func(cfg provider.Config) provider.Provider {
+				return &mockStatusProvider{name: name, host: cfg.Host}
+			},
+			Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+				return &mockStatusProvider{name: name, host: host}, nil
+			},
+		})
+	}
+
+	settingsPath := filepath.Join(t.TempDir(), "settings.json")
+	content := `{"aliases": {"work": {"host": "git.company.com", "provider": "other", "client-id": "abc123"}}}`
+
+	if err := os.WriteFile(settingsPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write settings: %v", err)
+	}
+
+	t.Setenv("NIX_AUTH_SETTINGS", settingsPath)
+
+	if err := loadSettings(nil, nil); err != nil {
+		t.Fatalf("loadSettings() error = %v", err)
+	}
+
+	loginDryRun = true
+
+	output, err := captureLoginOutput(t, []string{"work"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(output, "Authenticating with other (git.company.com)") {
+		t.Errorf("expected the alias to select its host and provider\nGot output:\n%s", output)
+	}
+
+	if got := loginProviderConfig("git.company.com").ClientID; got != "abc123" {
+		t.Errorf("ClientID = %q, want the alias's client ID", got)
+	}
+
+	if err := os.WriteFile(settingsPath, []byte(`{"aliases": {"work": {"host": "git.company.com", "provider": "nosuch"}}}`), 0o600); err != nil {
+		t.Fatalf("failed to write settings: %v", err)
+	}
+
+	if err := loadSettings(nil, nil); err == nil || !strings.Contains(err.Error(), "cannot detect provider 'nosuch'") {
+		t.Errorf("expected an unknown provider to be rejected, got %v", err)
+	}
+}
+
+// expiringProvider hands out a token that expires after ttl.
+type expiringProvider struct {
+	mockStatusProvider
+	ttl time.Duration
+}
+
+func (m *expiringProvider) Authenticate(_ context.Context) (string, error) {
+	return "glpat-shortlived123456", nil
+}
+
+func (m *expiringProvider) TokenExpiry() time.Time {
+	return time.Now().Add(m.ttl)
+}
+
+func TestLoginRecordsTokenExpiry(t *testing.T) {
+	setupLoginTest(t)
+	t.Setenv("NIX_AUTH_POST_HOOK", "")
+
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		wantWarning bool
+	}{
+		{name: "short-lived token warns", ttl: 2 * time.Hour, wantWarning: true},
+		{name: "long-lived token does not warn", ttl: 30 * 24 * time.Hour, wantWarning: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath = createTestConfig(t, "")
+
+			cfg, err := nixconf.New(configPath)
+			if err != nil {
+				t.Fatalf("failed to open config: %v", err)
+			}
+
+			prov := &expiringProvider{
+				mockStatusProvider: mockStatusProvider{name: "gitlab", host: "gitlab.com", valid: true},
+				ttl:                tt.ttl,
+			}
+
+			output := captureStdout(t, func() {
+				if err := authenticateAndSave(context.Background(), prov, "gitlab.com", cfg); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			})
+
+			if !strings.Contains(output, "Token expires in") {
+				t.Errorf("expected expiry in output, got:\n%s", output)
+			}
+
+			warned := strings.Contains(output, "consider a personal access token")
+			if warned != tt.wantWarning {
+				t.Errorf("warned = %v, want %v\nGot output:\n%s", warned, tt.wantWarning, output)
+			}
+
+			if tokenExpiry("gitlab.com").IsZero() {
+				t.Errorf("expected expiry to be recorded")
+			}
+
+			forgetTokenExpiry("gitlab.com")
+
+			if !tokenExpiry("gitlab.com").IsZero() {
+				t.Errorf("expected expiry to be forgotten")
+			}
+		})
+	}
+}
+
+func TestLoginRecordsClientID(t *testing.T) {
+	setupLoginTest(t)
+	t.Setenv("NIX_AUTH_POST_HOOK", "")
+
+	configPath = createTestConfig(t, "")
+
+	cfg, err := nixconf.New(configPath)
+	if err != nil {
+		t.Fatalf("failed to open config: %v", err)
+	}
+
+	prov := &expiringProvider{
+		mockStatusProvider: mockStatusProvider{name: "gitlab", host: "gitlab.company.com", valid: true},
+		ttl:                30 * 24 * time.Hour,
+	}
+
+	loginClientID = "0123abcd"
+
+	captureStdout(t, func() {
+		if err := authenticateAndSave(context.Background(), prov, "gitlab.company.com", cfg); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	loginClientID = ""
+
+	if got := clientIDForHost("gitlab.company.com"); got != "0123abcd" {
+		t.Errorf("clientIDForHost() = %q, want the client ID of the last login", got)
+	}
+
+	if got := providerConfig("gitlab.company.com").ClientID; got != "0123abcd" {
+		t.Errorf("providerConfig().ClientID = %q, want the client ID of the last login", got)
+	}
+
+	// Replacing the token keeps the client ID for the next login
+	forgetTokenExpiry("gitlab.company.com")
+
+	if got := clientIDForHost("gitlab.company.com"); got != "0123abcd" {
+		t.Errorf("clientIDForHost() after set-token = %q, want %q", got, "0123abcd")
+	}
+
+	loginClientID = "flag-wins"
+
+	if got := clientIDForHost("gitlab.company.com"); got != "flag-wins" {
+		t.Errorf("clientIDForHost() = %q, want --client-id to take precedence", got)
+	}
+}
+
+func TestLoginValidateOnly(t *testing.T) {
+	setupLoginTest(t)
+
+	configPath = createTestConfig(t, "")
+
+	prov := &expiringProvider{
+		mockStatusProvider: mockStatusProvider{
+			name: "gitlab", host: "gitlab.com", valid: true,
+			username: "octocat", fullName: "The Octocat", scopes: []string{"read_api"},
+		},
+		ttl: 2 * time.Hour,
+	}
+
+	output := captureStdout(t, func() {
+		if err := authenticateAndDiscard(context.Background(), prov, "gitlab.com"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	for _, want := range []string{"Successfully authenticated with gitlab.com", "octocat (The Octocat)", "read_api", "Expires", "nothing was saved"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	cfg, err := nixconf.New(configPath)
+	if err != nil {
+		t.Fatalf("failed to open config: %v", err)
+	}
+
+	if token, _ := cfg.GetToken("gitlab.com"); token != "" {
+		t.Errorf("expected no token to be saved, got %q", token)
+	}
+
+	if !tokenExpiry("gitlab.com").IsZero() {
+		t.Errorf("expected no expiry to be recorded")
+	}
+
+	loginValidateOnly = true
+	loginDryRun = true
+
+	if _, err := captureLoginOutput(t, []string{"gitlab"}); err == nil || !strings.Contains(err.Error(), "--validate-only") {
+		t.Errorf("expected --validate-only/--dry-run conflict, got %v", err)
+	}
+}
+
+func TestLoginToken(t *testing.T) {
+	setupLoginTest(t)
+	t.Setenv("NIX_AUTH_POST_HOOK", "")
+
+	originalStdin := os.Stdin
+	originalAssumeYes := assumeYes
+
+	t.Cleanup(func() {
+		os.Stdin = originalStdin
+		assumeYes = originalAssumeYes
+
+		ui.SetAssumeYes(originalAssumeYes)
+	})
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	provider.RegisterProvider("mock", provider.Registration{
+		New: func(cfg provider.Config) provider.Provider {
+			return &mockStatusProvider{name: "mock", host: cfg.Host, valid: cfg.Host != "invalid.example.com"}
+		},
+		Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+			return &mockStatusProvider{name: "mock", host: host, valid: host != "invalid.example.com"}, nil
+		},
+		DefaultHost: "mock.example.com",
+	})
+
+	tests := []struct {
+		name     string
+		args     []string
+		token    string
+		stdin    string
+		force    bool
+		yes      bool
+		existing string
+		want     string
+		errorMsg string
+	}{
+		{name: "token for a host", args: []string{"git.example.com"}, token: "tok_given", want: "tok_given"},
+		{name: "token for a provider alias", args: []string{"mock"}, token: "tok_alias", want: "tok_alias"},
+		{name: "token from stdin", args: []string{"git.example.com"}, token: "-", stdin: "tok_stdin\n", want: "tok_stdin"},
+		{name: "empty stdin", args: []string{"git.example.com"}, token: "-", errorMsg: "token cannot be empty"},
+		{
+			name: "stdin cannot confirm replacing", args: []string{"git.example.com"}, token: "-", stdin: "tok_stdin\n",
+			existing: "tok_old", want: "tok_old", errorMsg: "use --force or --yes to replace it",
+		},
+		{
+			name: "force replaces with the token from stdin", args: []string{"git.example.com"}, token: "-", stdin: "tok_stdin\n",
+			force: true, existing: "tok_old", want: "tok_stdin",
+		},
+		{
+			name: "yes replaces with the token from stdin", args: []string{"git.example.com"}, token: "-", stdin: "tok_stdin\n",
+			yes: true, existing: "tok_old", want: "tok_stdin",
+		},
+		{name: "invalid token", args: []string{"invalid.example.com"}, token: "tok_bad", errorMsg: "invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := tt.args[0]
+			if host == "mock" {
+				host = "mock.example.com"
+			}
+
+			content := ""
+			if tt.existing != "" {
+				content = "access-tokens = " + host + "=" + tt.existing + "\n"
+			}
+
+			configPath = createTestConfig(t, content)
+			loginToken = tt.token
+			loginForce = tt.force
+			assumeYes = tt.yes
+			ui.SetAssumeYes(tt.yes)
+
+			stdinR, stdinW, _ := os.Pipe()
+			os.Stdin = stdinR
+
+			_, _ = stdinW.WriteString(tt.stdin)
+			_ = stdinW.Close()
+
+			output, err := captureLoginOutput(t, tt.args)
+
+			if tt.errorMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Errorf("expected error containing %q, got %v", tt.errorMsg, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v\nGot output:\n%s", err, output)
+			}
+
+			// No authentication flow runs for a given token
+			if strings.Contains(output, "Authenticating") || strings.Contains(output, "authenticated") {
+				t.Errorf("expected no mention of authenticating\nGot output:\n%s", output)
+			}
+
+			cfg, err := nixconf.New(configPath)
+			if err != nil {
+				t.Fatalf("failed to open config: %v", err)
+			}
+
+			if token, _ := cfg.GetToken(host); token != tt.want {
+				t.Errorf("token = %q, want %q", token, tt.want)
+			}
+		})
+	}
+
+	loginToken = "tok_given"
+	loginDryRun = true
+
+	if _, err := captureLoginOutput(t, []string{"git.example.com"}); err == nil || !strings.Contains(err.Error(), "--dry-run") {
+		t.Errorf("expected --token/--dry-run conflict, got %v", err)
+	}
+}
+
+func TestLoginTokenGitLab(t *testing.T) {
+	setupLoginTest(t)
+	t.Setenv("NIX_AUTH_POST_HOOK", "")
+
+	originalAPIURL := loginAPIURL
+
+	t.Cleanup(func() { loginAPIURL = originalAPIURL })
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		value  string
+		want   string
+	}{
+		{
+			name: "personal access token", token: "glpat-given123456789012",
+			header: "PRIVATE-TOKEN", value: "glpat-given123456789012", want: "PAT:glpat-given123456789012",
+		},
+		{
+			name: "oauth token", token: "gloas-given",
+			header: "Authorization", value: "Bearer gloas-given", want: "OAuth2:gloas-given",
+		},
+		{
+			name: "prefixed token", token: "PAT:glpat-given123456789012",
+			header: "PRIVATE-TOKEN", value: "glpat-given123456789012", want: "PAT:glpat-given123456789012",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The real GitLab provider must send the token without the prefix Nix needs
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get(tt.header) != tt.value {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				if r.URL.Path != "/user" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"username": "tanuki"}`))
+			}))
+			t.Cleanup(server.Close)
+
+			configPath = createTestConfig(t, "")
+			loginToken = tt.token
+			loginAPIURL = server.URL
+
+			output, err := captureLoginOutput(t, []string{"gitlab"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v\nGot output:\n%s", err, output)
+			}
+
+			cfg, err := nixconf.New(configPath)
+			if err != nil {
+				t.Fatalf("failed to open config: %v", err)
+			}
+
+			if token, _ := cfg.GetToken("gitlab.com"); token != tt.want {
+				t.Errorf("token = %q, want %q", token, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginPollSettings(t *testing.T) {
+	setupLoginTest(t)
+
+	originalInterval := loginPollInterval
+	originalIncrement := loginSlowDownIncrement
+
+	t.Cleanup(func() {
+		loginPollInterval = originalInterval
+		loginSlowDownIncrement = originalIncrement
+	})
+
+	tests := []struct {
+		name         string
+		flag         time.Duration
+		env          string
+		wantInterval time.Duration
+		wantErr      bool
+	}{
+		{name: "default", wantInterval: 0},
+		{name: "from environment", env: "100ms", wantInterval: 100 * time.Millisecond},
+		{name: "flag wins", flag: time.Second, env: "100ms", wantInterval: time.Second},
+		{name: "invalid environment", env: "soon", wantErr: true},
+		{name: "negative flag", flag: -time.Second, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loginPollInterval = tt.flag
+			loginSlowDownIncrement = 0
+			t.Setenv(pollIntervalEnv, tt.env)
+			t.Setenv(slowDownIncrementEnv, "")
+
+			err := checkPollSettings()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := loginProviderConfig("gitlab.com").PollInterval; got != tt.wantInterval {
+				t.Errorf("PollInterval = %v, want %v", got, tt.wantInterval)
+			}
+		})
+	}
+}
+
+func TestLoginExpectProvider(t *testing.T) {
+	setupLoginTest(t)
+
+	configPath = filepath.Join(t.TempDir(), "nix.conf")
+	// Keep remembered detections out of the user's state directory
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+
+	// Only mock claims the host, so detection does not depend on the probe order
+	for _, name := range []string{"mock", "other"} {
+		provider.RegisterProvider(name, provider.Registration{
+			New: func(cfg provider.Config) provider.Provider {
+				return &mockStatusProvider{name: name, host: cfg.Host}
+			},
+			Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+				if name != "mock" {
+					return nil, nil
+				}
+
+				return &mockStatusProvider{name: name, host: host}, nil
+			},
+		})
+	}
+
+	loginDryRun = true
+
+	tests := []struct {
+		name     string
+		expect   string
+		errorMsg string
+	}{
+		{name: "matching provider", expect: "mock"},
+		{name: "different provider", expect: "other", errorMsg: "resolved to provider mock, but other was expected"},
+		{name: "unknown provider", expect: "nosuch", errorMsg: "unknown provider 'nosuch'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loginExpect = tt.expect
+
+			output, err := captureLoginOutput(t, []string{"git.example.com"})
+
+			if tt.errorMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Errorf("expected error containing %q, got %v", tt.errorMsg, err)
+				}
+
+				if strings.Contains(output, "Authenticating") {
+					t.Errorf("expected no authentication to start\nGot output:\n%s", output)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -12,20 +12,37 @@ import (
 )
 
 var logoutCmd = &cobra.Command{
-	Use:   "logout [provider|host]",
-	Short: "Remove an access token",
+	Use:         "logout [provider|host]",
+	Annotations: map[string]string{hostArgAnnotation: "true"},
+	Short:       "Remove an access token",
 	Long: `Remove an access token from your nix.conf.
-You can specify either a provider name (github, gitlab) or a full host.`,
+You can specify either a provider name (github, gitlab) or a full host.
+
+A provider name stands for its default host (e.g. gitlab.com). If that host has
+no token, you are asked which of the configured hosts to remove instead.
+
+Any other argument without a token of its own is matched against the configured
+hosts: if it is part of exactly one of them, you are asked to confirm removing
+that token, and if it is part of several, you pick among those. With --yes,
+which would confirm it unseen, or without a terminal to ask on, such an
+argument is refused and the matching hosts are listed instead.`,
 	Example: `  nix-auth logout github
   nix-auth logout github.com
-  nix-auth logout gitlab.company.com`,
+  nix-auth logout gitlab.company.com
+
+  # Pick among the configured hosts containing "company"
+  nix-auth logout company`,
 	Args:         cobra.MaximumNArgs(1),
 	RunE:         runLogout,
 	SilenceUsage: true,
 }
 
 func runLogout(_ *cobra.Command, args []string) error {
-	cfg, err := nixconf.New(configPath)
+	if err := requireWritableConfig(); err != nil {
+		return err
+	}
+
+	cfg, err := openNixConfig()
 	if err != nil {
 		return fmt.Errorf("failed to initialize config: %w", err)
 	}
@@ -36,15 +53,118 @@ func runLogout(_ *cobra.Command, args []string) error {
 	}
 
 	// Determine host from argument
-	arg := strings.ToLower(args[0])
+	arg, err := parseHost(args[0])
+	if err != nil {
+		return err
+	}
 
 	// Check if it's a provider name
 	if prov, ok := provider.Get(arg); ok {
-		return removeToken(cfg, prov.Host())
+		return logoutProvider(cfg, arg, prov.Host())
+	}
+
+	// Otherwise treat it as a host, or part of one
+	return logoutHost(cfg, arg)
+}
+
+// logoutPrompts reports whether logout may ask which token to remove
+// (replaced in tests).
+var logoutPrompts = ui.IsInteractive
+
+// logoutHost removes the token for host. If host has no token, it is matched
+// against the configured hosts instead: a single match is removed after
+// confirmation, and the user picks among several. Without anyone to ask, the
+// matches are only listed.
+func logoutHost(cfg *nixconf.NixConfig, host string) error {
+	entries, err := cfg.TokenEntries(host)
+	if err != nil {
+		return fmt.Errorf("failed to read tokens: %w", err)
+	}
+
+	if len(entries) > 0 {
+		return removeToken(cfg, host)
+	}
+
+	hosts, err := cfg.ListTokens()
+	if err != nil {
+		return fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	matches := matchingHosts(hosts, host)
+
+	// Matching is for picking a host interactively; --yes or a script must not pick one
+	if len(matches) > 0 && (assumeYes || !logoutPrompts()) {
+		return nixconf.NotConfiguredf("no token found for %s; give the exact host (configured hosts containing it: %s)",
+			host, strings.Join(matches, ", "))
+	}
+
+	switch len(matches) {
+	case 0:
+		return removeToken(cfg, host)
+	case 1:
+		confirm, err := ui.Confirm(fmt.Sprintf("No token found for %s. Remove the token for %s?", host, matches[0]), false)
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+
+		if !confirm {
+			fmt.Println("Logout cancelled.")
+			return nil
+		}
+
+		return removeToken(cfg, matches[0])
+	default:
+		fmt.Printf("No token found for %s, but %d configured hosts contain it.\n", host, len(matches))
+
+		return pickTokenToRemove(cfg, matches)
+	}
+}
+
+// matchingHosts returns the hosts containing part, ignoring case.
+func matchingHosts(hosts []string, part string) []string {
+	part = strings.ToLower(part)
+
+	var matches []string
+
+	for _, host := range hosts {
+		if strings.Contains(strings.ToLower(host), part) {
+			matches = append(matches, host)
+		}
 	}
 
-	// Otherwise treat it as a host
-	return removeToken(cfg, arg)
+	return matches
+}
+
+// logoutProvider removes the token for the default host of the provider alias
+// name. The token of a self-hosted instance is stored under its own host, so
+// if the default host has none, the user picks from the configured hosts.
+func logoutProvider(cfg *nixconf.NixConfig, name, host string) error {
+	entries, err := cfg.TokenEntries(host)
+	if err != nil {
+		return fmt.Errorf("failed to read tokens: %w", err)
+	}
+
+	if len(entries) > 0 {
+		return removeToken(cfg, host)
+	}
+
+	hosts, err := cfg.ListTokens()
+	if err != nil {
+		return fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	if len(hosts) == 0 {
+		return nixconf.NotConfiguredf("no token found for %s", host)
+	}
+
+	if !logoutPrompts() {
+		return nixconf.NotConfiguredf("no token found for %s, the default host for %s; give the host to remove (configured hosts: %s)",
+			host, name, strings.Join(hosts, ", "))
+	}
+
+	fmt.Printf("No token found for %s, the default host for %s.\n", host, name)
+
+	return pickTokenToRemove(cfg, hosts)
 }
 
 // logoutInteractive handles the interactive logout flow.
@@ -59,6 +179,11 @@ func logoutInteractive(cfg *nixconf.NixConfig) error {
 		return nil
 	}
 
+	return pickTokenToRemove(cfg, hosts)
+}
+
+// pickTokenToRemove asks which of hosts to remove the token for.
+func pickTokenToRemove(cfg *nixconf.NixConfig, hosts []string) error {
 	fmt.Println("Select a token to remove:")
 
 	for i, host := range hosts {
@@ -85,6 +210,34 @@ func logoutInteractive(cfg *nixconf.NixConfig) error {
 }
 
 func removeToken(cfg *nixconf.NixConfig, host string) error {
+	entries, err := cfg.TokenEntries(host)
+	if err != nil {
+		return fmt.Errorf("failed to read tokens: %w", err)
+	}
+
+	// Tokens only defined in read-only sources cannot be removed
+	var remaining []nixconf.TokenEntry
+
+	removable := false
+
+	for _, entry := range entries {
+		if entry.Source == nixconf.SourceConfigFile {
+			removable = true
+		} else {
+			remaining = append(remaining, entry)
+		}
+	}
+
+	if !removable && len(remaining) > 0 {
+		return readOnlyTokenError(remaining[0])
+	}
+
+	if len(entries) == 0 {
+		if suggestion := suggestConfiguredHost(cfg, host); suggestion != "" {
+			return nixconf.NotConfiguredf("no token found for %s; did you mean %s?", host, suggestion)
+		}
+	}
+
 	fmt.Printf("Removing token for %s...\n", host)
 
 	if err := cfg.RemoveToken(host); err != nil {
@@ -93,5 +246,58 @@ func removeToken(cfg *nixconf.NixConfig, host string) error {
 
 	fmt.Printf("✓ Successfully removed token for %s\n", host)
 
+	forgetTokenExpiry(host)
+
+	if len(remaining) > 0 {
+		noteRemainingToken(cfg, host)
+	}
+
+	printChangeSummary(cfg)
+
+	runPostChangeHook("logout", host, cfg)
+
 	return nil
 }
+
+// noteRemainingToken tells the user about a token for host left in a read-only
+// source after its token was removed from the managed config, and whether Nix uses it.
+func noteRemainingToken(cfg *nixconf.NixConfig, host string) {
+	entries, err := cfg.TokenEntries(host)
+	if err != nil || len(entries) == 0 {
+		return
+	}
+
+	if entries[0].Effective() {
+		fmt.Printf("Note: %s still has a token in %s, which Nix will use\n", host, entries[0].Origin())
+		return
+	}
+
+	fmt.Printf("Note: %s still has a token in %s, which Nix ignores because access-tokens in %s replaces it\n",
+		host, entries[0].Origin(), entries[0].OverriddenBy)
+}
+
+// readOnlyTokenError explains why a token from a read-only source cannot be removed.
+func readOnlyTokenError(entry nixconf.TokenEntry) error {
+	if entry.Source == nixconf.SourceEnv {
+		return fmt.Errorf("token for %s is defined in NIX_CONFIG and cannot be removed by nix-auth; "+
+			"remove it from the NIX_CONFIG environment variable instead", entry.Host)
+	}
+
+	if entry.Source == nixconf.SourceExtraConfig {
+		return fmt.Errorf("token for %s is defined in %s, which was added with --extra-config and is never modified by nix-auth",
+			entry.Host, entry.Origin())
+	}
+
+	return fmt.Errorf("token for %s is defined in %s and cannot be removed by nix-auth without --system",
+		entry.Host, entry.Origin())
+}
+
+// suggestConfiguredHost returns the configured host closest to host, if any.
+func suggestConfiguredHost(cfg *nixconf.NixConfig, host string) string {
+	hosts, err := cfg.ListTokens()
+	if err != nil {
+		return ""
+	}
+
+	return suggestHost(host, hosts)
+}
@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+)
+
+func TestRemoveTokenReadOnlySources(t *testing.T) {
+	t.Setenv("NIX_AUTH_POST_HOOK", "")
+
+	tests := []struct {
+		name          string
+		userConfig    string
+		systemConfig  string
+		nixConfig     string
+		extraConfig   string
+		errorContains string
+		wantOutput    string
+	}{
+		{
+			name:          "token only in system config",
+			systemConfig:  "access-tokens = github.com=gho_systemtoken1234567\n",
+			errorContains: "cannot be removed by nix-auth without --system",
+		},
+		{
+			name:          "token only in NIX_CONFIG",
+			nixConfig:     "access-tokens = github.com=gho_envtoken123456789",
+			errorContains: "defined in NIX_CONFIG and cannot be removed",
+		},
+		{
+			name:          "token only in an extra config",
+			extraConfig:   "access-tokens = github.com=gho_extratoken12345678\n",
+			errorContains: "added with --extra-config",
+		},
+		{
+			name:         "user token removed but system token remains",
+			userConfig:   "access-tokens = github.com=gho_usertoken123456789\n",
+			systemConfig: "access-tokens = github.com=gho_systemtoken1234567\n",
+			wantOutput:   "still has a token in",
+		},
+		{
+			name:          "no token anywhere",
+			errorContains: "no configuration file found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userDir := t.TempDir()
+			systemDir := t.TempDir()
+
+			t.Setenv("NIX_CONF_DIR", systemDir)
+			t.Setenv("NIX_CONFIG", tt.nixConfig)
+
+			if tt.systemConfig != "" {
+				if err := os.WriteFile(filepath.Join(systemDir, "nix.conf"), []byte(tt.systemConfig), 0o600); err != nil {
+					t.Fatalf("failed to write system config: %v", err)
+				}
+			}
+
+			userPath := filepath.Join(userDir, "nix.conf")
+			if tt.userConfig != "" {
+				if err := os.WriteFile(userPath, []byte(tt.userConfig), 0o600); err != nil {
+					t.Fatalf("failed to write user config: %v", err)
+				}
+			}
+
+			cfg, err := nixconf.New(userPath)
+			if err != nil {
+				t.Fatalf("failed to create config: %v", err)
+			}
+
+			if tt.extraConfig != "" {
+				extraPath := filepath.Join(userDir, "extra.conf")
+				if err := os.WriteFile(extraPath, []byte(tt.extraConfig), 0o600); err != nil {
+					t.Fatalf("failed to write extra config: %v", err)
+				}
+
+				if err := cfg.AddExtraConfig(extraPath); err != nil {
+					t.Fatalf("failed to add extra config: %v", err)
+				}
+			}
+
+			var removeErr error
+
+			output := captureStdout(t, func() {
+				removeErr = removeToken(cfg, "github.com")
+			})
+
+			if tt.errorContains != "" {
+				if removeErr == nil || !strings.Contains(removeErr.Error(), tt.errorContains) {
+					t.Errorf("expected error containing %q, got %v", tt.errorContains, removeErr)
+				}
+
+				return
+			}
+
+			if removeErr != nil {
+				t.Fatalf("unexpected error: %v", removeErr)
+			}
+
+			if !strings.Contains(output, tt.wantOutput) {
+				t.Errorf("output missing %q\nGot output:\n%s", tt.wantOutput, output)
+			}
+		})
+	}
+}
+
+func TestRemoveTokenSuggestsHost(t *testing.T) {
+	configPath := createTestConfig(t, "access-tokens = github.com=gho_testtoken123456789\n")
+
+	cfg, err := nixconf.New(configPath)
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	err = removeToken(cfg, "githubcom")
+	if err == nil || !strings.Contains(err.Error(), "did you mean github.com?") {
+		t.Errorf("expected suggestion for github.com, got %v", err)
+	}
+}
+
+func TestLogoutProviderPicksConfiguredHost(t *testing.T) {
+	t.Setenv("NIX_AUTH_POST_HOOK", "")
+
+	originalStdin := os.Stdin
+	originalPrompts := logoutPrompts
+
+	defer func() {
+		os.Stdin = originalStdin
+		logoutPrompts = originalPrompts
+	}()
+
+	logoutPrompts = func() bool { return true }
+
+	tests := []struct {
+		name       string
+		answer     string
+		wantOutput string
+		wantHosts  []string
+	}{
+		{name: "pick self-hosted host", answer: "2\n", wantOutput: "Successfully removed token for gitlab.company.com", wantHosts: []string{"github.com"}},
+		{name: "cancel", answer: "0\n", wantOutput: "Logout cancelled", wantHosts: []string{"github.com", "gitlab.company.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := createTestConfig(t, "")
+			t.Setenv("NIX_CONFIG", "")
+
+			cfg, err := nixconf.New(configPath)
+			if err != nil {
+				t.Fatalf("failed to create config: %v", err)
+			}
+
+			if err := cfg.SetTokens(map[string]string{
+				"github.com":         "gho_testtoken123456789",
+				"gitlab.company.com": "glpat-testtoken12345678",
+			}); err != nil {
+				t.Fatalf("failed to set tokens: %v", err)
+			}
+
+			stdinR, stdinW, _ := os.Pipe()
+			os.Stdin = stdinR
+
+			go func() {
+				defer stdinW.Close() //nolint:errcheck // cleanup in test goroutine
+				_, _ = stdinW.WriteString(tt.answer)
+			}()
+
+			var logoutErr error
+
+			output := captureStdout(t, func() {
+				logoutErr = logoutProvider(cfg, "gitlab", "gitlab.com")
+			})
+
+			if logoutErr != nil {
+				t.Fatalf("unexpected error: %v", logoutErr)
+			}
+
+			for _, want := range []string{"No token found for gitlab.com", "2. gitlab.company.com", tt.wantOutput} {
+				if !strings.Contains(output, want) {
+					t.Errorf("expected %q in output\nGot output:\n%s", want, output)
+				}
+			}
+
+			hosts, _ := cfg.ListTokens()
+			if strings.Join(hosts, ",") != strings.Join(tt.wantHosts, ",") {
+				t.Errorf("remaining hosts = %v, want %v", hosts, tt.wantHosts)
+			}
+		})
+	}
+}
+
+func TestLogoutHostPartialMatch(t *testing.T) {
+	t.Setenv("NIX_AUTH_POST_HOOK", "")
+	t.Setenv("NIX_CONFIG", "")
+
+	originalStdin := os.Stdin
+	originalAssumeYes := assumeYes
+	originalPrompts := logoutPrompts
+
+	defer func() {
+		os.Stdin = originalStdin
+		assumeYes = originalAssumeYes
+		logoutPrompts = originalPrompts
+	}()
+
+	tests := []struct {
+		name           string
+		arg            string
+		answer         string
+		assumeYes      bool
+		notInteractive bool
+		wantOutput     []string
+		wantHosts      []string
+		errorMsg       string
+	}{
+		{
+			name:       "single match confirmed",
+			arg:        "company",
+			answer:     "y\n",
+			wantOutput: []string{"Remove the token for git.company.com?", "Successfully removed token for git.company.com"},
+			wantHosts:  []string{"github.com", "gitlab.com"},
+		},
+		{
+			name:       "single match declined",
+			arg:        "company",
+			answer:     "n\n",
+			wantOutput: []string{"Logout cancelled"},
+			wantHosts:  []string{"git.company.com", "github.com", "gitlab.com"},
+		},
+		{
+			name:       "several matches narrow the picker",
+			arg:        "git",
+			answer:     "3\n",
+			wantOutput: []string{"3 configured hosts contain it", "3. gitlab.com", "Successfully removed token for gitlab.com"},
+			wantHosts:  []string{"git.company.com", "github.com"},
+		},
+		{
+			name:      "partial match refused with --yes",
+			arg:       "company",
+			assumeYes: true,
+			wantHosts: []string{"git.company.com", "github.com", "gitlab.com"},
+			errorMsg:  "give the exact host (configured hosts containing it: git.company.com)",
+		},
+		{
+			name:           "partial match refused without a terminal",
+			arg:            "git",
+			notInteractive: true,
+			wantHosts:      []string{"git.company.com", "github.com", "gitlab.com"},
+			errorMsg:       "give the exact host (configured hosts containing it: git.company.com, github.com, gitlab.com)",
+		},
+		{
+			name:       "exact host is removed with --yes",
+			arg:        "gitlab.com",
+			assumeYes:  true,
+			wantOutput: []string{"Successfully removed token for gitlab.com"},
+			wantHosts:  []string{"git.company.com", "github.com"},
+		},
+		{
+			name:       "exact host is removed directly",
+			arg:        "github.com",
+			wantOutput: []string{"Successfully removed token for github.com"},
+			wantHosts:  []string{"git.company.com", "gitlab.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := nixconf.New(createTestConfig(t, ""))
+			if err != nil {
+				t.Fatalf("failed to create config: %v", err)
+			}
+
+			if err := cfg.SetTokens(map[string]string{
+				"github.com":      "gho_testtoken123456789",
+				"gitlab.com":      "glpat-testtoken12345678",
+				"git.company.com": "company-token-123456",
+			}); err != nil {
+				t.Fatalf("failed to set tokens: %v", err)
+			}
+
+			stdinR, stdinW, _ := os.Pipe()
+			os.Stdin = stdinR
+
+			_, _ = stdinW.WriteString(tt.answer)
+			_ = stdinW.Close()
+
+			assumeYes = tt.assumeYes
+			logoutPrompts = func() bool { return !tt.notInteractive }
+
+			var logoutErr error
+
+			output := captureStdout(t, func() {
+				logoutErr = logoutHost(cfg, tt.arg)
+			})
+
+			switch {
+			case tt.errorMsg != "":
+				if logoutErr == nil || !strings.Contains(logoutErr.Error(), tt.errorMsg) {
+					t.Errorf("expected error containing %q, got %v", tt.errorMsg, logoutErr)
+				}
+
+				if code := ExitCode(logoutErr); code != ExitNotConfigured {
+					t.Errorf("exit code = %d, want %d", code, ExitNotConfigured)
+				}
+			case logoutErr != nil:
+				t.Fatalf("unexpected error: %v", logoutErr)
+			}
+
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(output, want) {
+					t.Errorf("expected %q in output\nGot output:\n%s", want, output)
+				}
+			}
+
+			hosts, _ := cfg.ListTokens()
+			if strings.Join(hosts, ",") != strings.Join(tt.wantHosts, ",") {
+				t.Errorf("remaining hosts = %v, want %v", hosts, tt.wantHosts)
+			}
+		})
+	}
+}
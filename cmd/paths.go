@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/spf13/cobra"
+)
+
+var pathsCmd = &cobra.Command{
+	Use:   "paths",
+	Short: "Show which nix.conf and token file nix-auth uses",
+	Long: `Show the nix.conf nix-auth reads and writes, the token file it writes tokens
+to, any configs added with --extra-config, and how the nix.conf was found:
+
+  --config             given with --config
+  --system             the system config, $NIX_CONF_DIR/nix.conf or /etc/nix/nix.conf
+  NIX_USER_CONF_FILES  the first file listed in NIX_USER_CONF_FILES
+  XDG_CONFIG_HOME      $XDG_CONFIG_HOME/nix/nix.conf
+  default              ~/.config/nix/nix.conf
+
+Use this to confirm that nix-auth targets the file you expect. Files that do
+not exist yet are marked; nix-auth creates them when it saves a token.`,
+	Example: `  nix-auth paths
+  nix-auth --system paths`,
+	Args:         cobra.NoArgs,
+	RunE:         runPaths,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(pathsCmd)
+}
+
+func runPaths(_ *cobra.Command, _ []string) error {
+	if configPath == nixconf.StdinPath {
+		return fmt.Errorf("--config - reads the configuration from stdin, which has no paths to show")
+	}
+
+	cfg, err := openNixConfig()
+	if err != nil {
+		return fmt.Errorf("failed to initialize config: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, tabPadding, ' ', 0)
+
+	writePath(w, "Config file", cfg.GetPath())
+	_, _ = fmt.Fprintf(w, "Found via\t%s\n", configDiscovery())
+	writePath(w, "Token file", cfg.GetTokenFilePath())
+
+	for _, path := range cfg.ExtraConfigPaths() {
+		writePath(w, "Extra config", path)
+	}
+
+	return w.Flush()
+}
+
+// configDiscovery returns how the nix.conf nix-auth uses was found.
+func configDiscovery() string {
+	switch {
+	case useSystemConfig && os.Getenv("NIX_CONF_DIR") != "":
+		return "--system (NIX_CONF_DIR)"
+	case useSystemConfig:
+		return "--system"
+	case configPath != "":
+		return "--config"
+	}
+
+	_, discovery := nixconf.UserConfigPath()
+
+	return discovery
+}
+
+// writePath writes a row with the absolute form of path, marking it if it does not exist.
+func writePath(w io.Writer, label, path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	missing := ""
+	if _, err := os.Stat(path); nixconf.IsNotExist(err) {
+		missing = " (does not exist yet)"
+	}
+
+	_, _ = fmt.Fprintf(w, "%s\t%s%s\n", label, path, missing)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+)
+
+func TestRunPaths(t *testing.T) {
+	originalConfigPath := configPath
+	originalSystem := useSystemConfig
+
+	defer func() {
+		configPath = originalConfigPath
+		useSystemConfig = originalSystem
+	}()
+
+	userDir := t.TempDir()
+	t.Setenv("NIX_USER_CONF_FILES", "")
+	t.Setenv("XDG_CONFIG_HOME", userDir)
+
+	explicitPath := createTestConfig(t, "")
+
+	tests := []struct {
+		name       string
+		configPath string
+		want       []string
+		errorMsg   string
+	}{
+		{
+			name: "user config from XDG_CONFIG_HOME",
+			want: []string{
+				filepath.Join(userDir, "nix", "nix.conf") + " (does not exist yet)",
+				"XDG_CONFIG_HOME\n",
+				filepath.Join(userDir, "nix", "access-tokens.conf") + " (does not exist yet)",
+			},
+		},
+		{
+			name:       "explicit config",
+			configPath: explicitPath,
+			want: []string{
+				explicitPath + "\n",
+				"--config\n",
+				filepath.Join(filepath.Dir(explicitPath), "access-tokens.conf"),
+			},
+		},
+		{
+			name:       "config from stdin",
+			configPath: nixconf.StdinPath,
+			errorMsg:   "no paths to show",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath = tt.configPath
+
+			var runErr error
+
+			output := captureStdout(t, func() {
+				runErr = runPaths(nil, nil)
+			})
+
+			if tt.errorMsg != "" {
+				if runErr == nil || !strings.Contains(runErr.Error(), tt.errorMsg) {
+					t.Errorf("expected error containing %q, got %v", tt.errorMsg, runErr)
+				}
+
+				return
+			}
+
+			if runErr != nil {
+				t.Fatalf("unexpected error: %v", runErr)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("expected %q in output\nGot output:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var printIncludeLineOnly bool
+
+var printIncludeCmd = &cobra.Command{
+	Use:   "print-include",
+	Short: "Print the nix.conf include line for a declaratively managed config",
+	Long: `Print the !include line that makes nix.conf read the token file written by
+nix-auth, and a snippet for adding it with NixOS or home-manager.
+
+Use this together with --no-include when nix.conf is generated from your Nix
+configuration and must not be modified by nix-auth. With --system, the line
+is for the system-wide nix.conf.`,
+	Example: `  # Snippet for home-manager
+  nix-auth print-include
+
+  # Snippet for a NixOS configuration
+  nix-auth --system print-include
+
+  # Just the directive, for scripts
+  nix-auth print-include --line`,
+	Args:         cobra.NoArgs,
+	RunE:         runPrintInclude,
+	SilenceUsage: true,
+}
+
+func init() {
+	printIncludeCmd.Flags().BoolVar(&printIncludeLineOnly, "line", false, "Print only the !include line")
+	rootCmd.AddCommand(printIncludeCmd)
+}
+
+func runPrintInclude(_ *cobra.Command, _ []string) error {
+	cfg, err := openNixConfig()
+	if err != nil {
+		return fmt.Errorf("failed to initialize config: %w", err)
+	}
+
+	line, err := cfg.IncludeLine()
+	if err != nil {
+		return err
+	}
+
+	if printIncludeLineOnly {
+		fmt.Println(line)
+		return nil
+	}
+
+	module := "home-manager (home.nix)"
+	if useSystemConfig {
+		module = "NixOS (configuration.nix)"
+	}
+
+	fmt.Printf("# Add to %s:\n", cfg.GetPath())
+	fmt.Println(line)
+	fmt.Println()
+	fmt.Printf("# Or in your %s:\n", module)
+	fmt.Println("nix.extraOptions = ''")
+	fmt.Printf("  %s\n", line)
+	fmt.Println("'';")
+
+	return nil
+}
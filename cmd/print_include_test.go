@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPrintInclude(t *testing.T) {
+	originalConfigPath := configPath
+	originalLineOnly := printIncludeLineOnly
+
+	defer func() {
+		configPath = originalConfigPath
+		printIncludeLineOnly = originalLineOnly
+	}()
+
+	configPath = createTestConfig(t, "experimental-features = nix-command flakes\n")
+	wantLine := "!include " + filepath.Join(filepath.Dir(configPath), "access-tokens.conf")
+
+	t.Run("snippet", func(t *testing.T) {
+		printIncludeLineOnly = false
+
+		var runErr error
+
+		output := captureStdout(t, func() {
+			runErr = runPrintInclude(nil, nil)
+		})
+
+		if runErr != nil {
+			t.Fatalf("runPrintInclude() error = %v", runErr)
+		}
+
+		for _, want := range []string{wantLine + "\n", "nix.extraOptions = ''\n  " + wantLine + "\n'';"} {
+			if !strings.Contains(output, want) {
+				t.Errorf("expected %q in output\nGot output:\n%s", want, output)
+			}
+		}
+	})
+
+	t.Run("line only", func(t *testing.T) {
+		printIncludeLineOnly = true
+
+		output := captureStdout(t, func() {
+			if err := runPrintInclude(nil, nil); err != nil {
+				t.Errorf("runPrintInclude() error = %v", err)
+			}
+		})
+
+		if output != wantLine+"\n" {
+			t.Errorf("output = %q, want %q", output, wantLine+"\n")
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/numtide/nix-auth/internal/provider"
+)
+
+// privateHostLookupTimeout bounds resolving a host for the --warn-private check.
+const privateHostLookupTimeout = 5 * time.Second
+
+// lookupNetIP resolves a host name to its addresses (replaced in tests).
+var lookupNetIP = func(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// warnPrivateHost warns on out before a token is sent to host if host is the
+// default host of a public provider, or a likely typo of one, and resolves to
+// a loopback, private or link-local address. That happens when /etc/hosts or a
+// DNS search domain points the name somewhere other than the real service,
+// which would then receive the token. Hosts that fail to resolve are left to
+// the request itself to report.
+func warnPrivateHost(ctx context.Context, out io.Writer, host string) {
+	if !warnPrivate {
+		return
+	}
+
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+
+	publicHost := publicProviderHost(name)
+	if publicHost == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, privateHostLookupTimeout)
+	defer cancel()
+
+	addrs, err := lookupNetIP(ctx, name)
+	if err != nil {
+		return
+	}
+
+	for _, addr := range addrs {
+		if !isPrivateAddr(addr) {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(out, "Warning: %s resolves to %s, a private address", name, addr.Unmap())
+
+		if strings.EqualFold(name, publicHost) {
+			_, _ = fmt.Fprintf(out, ", not the public %s; check /etc/hosts and your DNS before trusting it with a token\n", publicHost)
+		} else {
+			_, _ = fmt.Fprintf(out, "; did you mean %s?\n", publicHost)
+		}
+
+		return
+	}
+}
+
+// publicProviderHost returns the default host of the provider that host is,
+// or that it is a likely typo of, or "" if host does not look like one.
+func publicProviderHost(host string) string {
+	names := provider.List()
+	sort.Strings(names)
+
+	var defaultHosts []string
+
+	for _, name := range names {
+		if reg, ok := provider.GetRegistration(name); ok && reg.DefaultHost != "" {
+			if strings.EqualFold(reg.DefaultHost, host) {
+				return reg.DefaultHost
+			}
+
+			defaultHosts = append(defaultHosts, reg.DefaultHost)
+		}
+	}
+
+	return suggestHost(host, defaultHosts)
+}
+
+// isPrivateAddr reports whether addr cannot belong to a public service.
+func isPrivateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsUnspecified()
+}
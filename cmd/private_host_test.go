@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/provider"
+)
+
+func TestWarnPrivateHost(t *testing.T) {
+	originalRegistry := provider.GetRegistry()
+	originalLookup := lookupNetIP
+	originalWarn := warnPrivate
+
+	defer func() {
+		provider.SetRegistry(originalRegistry)
+
+		lookupNetIP = originalLookup
+		warnPrivate = originalWarn
+	}()
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	provider.RegisterProvider("github", provider.Registration{DefaultHost: "github.com"})
+
+	addrs := map[string]string{
+		"github.com":        "127.0.0.1",
+		"github.con":        "10.1.2.3",
+		"gitlab.corp.local": "192.168.1.10",
+		"github.co":         "140.82.112.4",
+	}
+	lookupNetIP = func(_ context.Context, host string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr(addrs[host])}, nil
+	}
+
+	tests := []struct {
+		name    string
+		host    string
+		disable bool
+		want    string
+	}{
+		{name: "public host resolving to loopback", host: "github.com", want: "github.com resolves to 127.0.0.1, a private address, not the public github.com"},
+		{name: "host with port", host: "github.com:443", want: "github.com resolves to 127.0.0.1"},
+		{name: "typo resolving to private address", host: "github.con", want: "github.con resolves to 10.1.2.3, a private address; did you mean github.com?"},
+		{name: "self-hosted instance", host: "gitlab.corp.local"},
+		{name: "public address", host: "github.co"},
+		{name: "disabled", host: "github.com", disable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnPrivate = !tt.disable
+
+			var out bytes.Buffer
+
+			warnPrivateHost(context.Background(), &out, tt.host)
+
+			if tt.want == "" {
+				if out.Len() > 0 {
+					t.Errorf("expected no warning, got %q", out.String())
+				}
+
+				return
+			}
+
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("expected %q in warning, got %q", tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestWarnPrivateHostBeforeValidating(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+	originalLookup := lookupNetIP
+	originalWarn := warnPrivate
+	originalValidate := statusValidate
+	originalPorcelain := statusPorcelain
+	originalFormat := statusFormat
+	originalMaxScopes := auditMaxScopes
+
+	defer func() {
+		configPath = originalConfigPath
+		lookupNetIP = originalLookup
+		warnPrivate = originalWarn
+		statusValidate = originalValidate
+		statusPorcelain = originalPorcelain
+		statusFormat = originalFormat
+		auditMaxScopes = originalMaxScopes
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	provider.RegisterProvider("github", provider.Registration{
+		DefaultHost: "github.com",
+		Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+			return &rawScopesProvider{
+				mockStatusProvider: mockStatusProvider{name: "github", host: host, valid: true, scopes: []string{"repo"}},
+				raw:                "repo",
+			}, nil
+		},
+	})
+
+	lookupNetIP = func(_ context.Context, _ string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("127.0.0.1")}, nil
+	}
+	warnPrivate = true
+	statusValidate = true
+	auditMaxScopes = []string{"repo"}
+
+	configPath = createTestConfig(t, "access-tokens = github.com=ghp_token123456789\n")
+
+	const warning = "Warning: github.com resolves to 127.0.0.1"
+
+	tests := []struct {
+		name      string
+		porcelain bool
+		format    string
+		run       func() error
+	}{
+		{name: "status", run: func() error { return runStatus(nil, nil) }},
+		{name: "status --porcelain", porcelain: true, run: func() error { return runStatus(nil, nil) }},
+		{name: "status --format prometheus", format: statusFormatPrometheus, run: func() error { return runStatus(nil, nil) }},
+		{name: "check", run: func() error { return runCheck(nil, nil) }},
+		{name: "check-readiness", run: func() error { return runCheckReadiness(nil, nil) }},
+		{name: "audit", run: func() error { return runAudit(nil, nil) }},
+		{name: "test", run: func() error { return runTestRepo(nil, []string{"github.com", "owner/repo"}) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusPorcelain = tt.porcelain
+			statusFormat = tt.format
+
+			if statusFormat == "" {
+				statusFormat = statusFormatText
+			}
+
+			var stdout string
+
+			stderr := captureStderr(t, func() {
+				stdout = captureStdout(t, func() { _ = tt.run() })
+			})
+
+			if n := strings.Count(stderr, warning); n != 1 {
+				t.Errorf("expected the warning once on stderr, got %d\nGot stderr:\n%s", n, stderr)
+			}
+
+			if strings.Contains(stdout, warning) {
+				t.Errorf("expected no warning on stdout\nGot stdout:\n%s", stdout)
+			}
+		})
+	}
+}
+
+// captureStderr returns everything fn writes to stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	oldStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	fn()
+
+	_ = w.Close()
+
+	os.Stderr = oldStderr
+
+	_, _ = buf.ReadFrom(r)
+
+	return buf.String()
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var providersCmd = &cobra.Command{
+	Use:   "providers",
+	Short: "List the supported providers and their capabilities",
+	Long: `List every supported provider with its default host, how it obtains tokens
+and the scopes it requests by default.
+
+Aliases such as codeberg are shown with the provider they stand for.`,
+	Args:         cobra.NoArgs,
+	RunE:         runProviders,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(providersCmd)
+}
+
+func runProviders(_ *cobra.Command, _ []string) error {
+	showProviders(os.Stdout)
+	return nil
+}
+
+// showProviders writes one row per registered provider, in detection order
+// followed by aliases.
+func showProviders(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, tabPadding, ' ', 0)
+	defer func() { _ = w.Flush() }()
+
+	_, _ = fmt.Fprintln(w, "NAME\tDEFAULT HOST\tAUTH METHOD\tDEFAULT SCOPES")
+
+	for _, name := range providerNames() {
+		prov, ok := provider.Get(name)
+		if !ok {
+			continue
+		}
+
+		displayName := name
+		if prov.Name() != name {
+			displayName = fmt.Sprintf("%s (alias for %s)", name, prov.Name())
+		}
+
+		defaultHost := prov.Host()
+		if defaultHost == "" {
+			defaultHost = "-"
+		}
+
+		scopes := "-"
+		if len(prov.GetScopes()) > 0 {
+			scopes = strings.Join(prov.GetScopes(), ", ")
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", displayName, defaultHost, provider.GetAuthMethod(prov), scopes)
+	}
+}
+
+// providerNames returns the registered provider names in detection order,
+// followed by the remaining ones such as aliases in alphabetical order.
+func providerNames() []string {
+	names := provider.ListForDetection()
+
+	listed := make(map[string]bool, len(names))
+	for _, name := range names {
+		listed[name] = true
+	}
+
+	var others []string
+
+	for _, name := range provider.List() {
+		if !listed[name] {
+			others = append(others, name)
+		}
+	}
+
+	sort.Strings(others)
+
+	return append(names, others...)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestShowProviders(t *testing.T) {
+	var buf bytes.Buffer
+
+	showProviders(&buf)
+
+	output := buf.String()
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+
+	if !strings.HasPrefix(lines[0], "NAME") {
+		t.Errorf("expected header line, got %q", lines[0])
+	}
+
+	expected := []string{
+		`(?m)^github\s+github\.com\s+oauth-device-flow\s+repo\b`,
+		`(?m)^gitlab\s+gitlab\.com\s+oauth-device-flow\s+`,
+		`(?m)^forgejo\s+-\s+personal-access-token\s+`,
+		`(?m)^codeberg \(alias for forgejo\)\s+codeberg\.org\s+personal-access-token\s+`,
+	}
+
+	for _, pattern := range expected {
+		if !regexp.MustCompile(pattern).MatchString(output) {
+			t.Errorf("output does not match %s\nGot output:\n%s", pattern, output)
+		}
+	}
+
+	// Aliases are listed after the providers they stand for
+	if strings.Index(output, "codeberg") < strings.Index(output, "forgejo") {
+		t.Errorf("expected codeberg alias after forgejo\nGot output:\n%s", output)
+	}
+}
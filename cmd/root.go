@@ -1,33 +1,428 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/numtide/nix-auth/internal/settings"
+	"github.com/numtide/nix-auth/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 var (
-	configPath string
-	rootCmd    = &cobra.Command{
+	configPath      string
+	extraConfigs    []string
+	useSystemConfig bool
+	noBackup        bool
+	noInclude       bool
+	quiet           bool
+	assumeYes       bool
+	errorFormat     string
+	strictParse     bool
+	tokenMode       string
+	tokenStore      string
+	storeRecipients []string
+	storeIdentity   string
+	detectOrder     []string
+	warnPrivate     bool
+	userSettings    *settings.Settings
+	rootCmd         = &cobra.Command{
 		Use:   "nix-auth",
 		Short: "Manage access tokens for Nix flakes",
 		Long: `nix-auth is a CLI tool that helps you configure access tokens
 for various Git providers (GitHub, GitLab, etc.) to avoid rate limits when
-using Nix flakes.`,
+using Nix flakes.
+
+Per-host provider settings (such as OAuth client IDs for self-hosted instances)
+are read from ` + settings.DefaultPath() + `,
+or from the file named by NIX_AUTH_SETTINGS.
+
+Use --system to manage the system-wide nix.conf read by the Nix daemon in
+multi-user installations. Changing it requires root.`,
+		PersistentPreRunE: persistentPreRun,
 	}
 )
 
-// Execute runs the root command and handles any errors.
+// Execute runs the root command. A failure is returned as an *ExitCodeError
+// carrying the process exit code for it.
 func Execute() error {
-	return rootCmd.Execute()
+	// Errors are printed by printError, in the format chosen with --error-format
+	rootCmd.SilenceErrors = true
+
+	executed, err := rootCmd.ExecuteC()
+	if err != nil {
+		printError(os.Stderr, executed, err)
+
+		return &ExitCodeError{Code: ExitCode(err), Err: err}
+	}
+
+	return nil
+}
+
+// persistentPreRun resolves the config path and loads settings before any command runs.
+func persistentPreRun(cmd *cobra.Command, args []string) error {
+	if err := checkErrorFormat(); err != nil {
+		return err
+	}
+
+	// Keep stderr parseable for tools
+	if errorFormat == errorFormatJSON {
+		cmd.Root().SilenceUsage = true
+	}
+
+	ui.SetAssumeYes(assumeYes)
+
+	if err := resolveConfigPath(); err != nil {
+		return err
+	}
+
+	if err := loadSettings(cmd, args); err != nil {
+		return err
+	}
+
+	return provider.CheckDetectOrder(detectionOrder())
+}
+
+// detectionOrder returns the providers to try when detecting a host's provider,
+// from --detect-order or "detect-order" in the settings file. It is nil if all
+// providers should be tried in the default order.
+func detectionOrder() []string {
+	if len(detectOrder) > 0 {
+		return detectOrder
+	}
+
+	if userSettings != nil {
+		return userSettings.DetectOrder
+	}
+
+	return nil
+}
+
+// resolveConfigPath points configPath at the system nix.conf when --system is set.
+func resolveConfigPath() error {
+	if !useSystemConfig {
+		return nil
+	}
+
+	if configPath != "" {
+		return fmt.Errorf("--system cannot be combined with --config")
+	}
+
+	configPath = nixconf.DefaultSystemConfigPath()
+
+	return nil
+}
+
+// geteuid returns the effective user ID (replaced in tests).
+var geteuid = os.Geteuid
+
+// requireWritableConfig returns an error when the selected config cannot be
+// changed: a config read from stdin with --config -, or the system config
+// (--system) without root, since it and its token file are owned by root.
+func requireWritableConfig() error {
+	if configPath == nixconf.StdinPath {
+		return fmt.Errorf("--config - reads the configuration from stdin and can only be used with read-only commands")
+	}
+
+	if useSystemConfig && geteuid() != 0 {
+		return fmt.Errorf("changing the system Nix configuration (%s) requires root; re-run with sudo", configPath)
+	}
+
+	return nil
+}
+
+// openNixConfig opens the nix.conf selected by --config or --system, or reads it
+// from stdin for --config -. Backups are disabled by --no-backup or "no-backup"
+// in the settings file, and changes to nix.conf itself by --no-include or
+// "no-include". --strict-parse rejects malformed configs.
+func openNixConfig() (*nixconf.NixConfig, error) {
+	if configPath == nixconf.StdinPath {
+		cfg, err := nixconf.NewFromReader(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+
+		if strictParse {
+			cfg.EnableStrictParsing()
+		}
+
+		return cfg, addExtraConfigs(cfg)
+	}
+
+	cfg, err := nixconf.New(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if noBackup || (userSettings != nil && userSettings.NoBackup) {
+		cfg.DisableBackups()
+	}
+
+	if noInclude || (userSettings != nil && userSettings.NoInclude) {
+		cfg.DisableMainConfigChanges()
+	}
+
+	if strictParse {
+		cfg.EnableStrictParsing()
+	}
+
+	if err := addExtraConfigs(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := applyTokenFileMode(cfg); err != nil {
+		return nil, err
+	}
+
+	enc, err := tokenEncryption()
+	if err != nil {
+		return nil, err
+	}
+
+	if enc != nil {
+		cfg.SetEncryption(enc)
+	}
+
+	return cfg, nil
+}
+
+// checkMainConfig reports whether tokens can be saved to cfg. If its nix.conf
+// links into the read-only Nix store, as NixOS and home-manager generate it,
+// it offers to replace the link with a writable copy that can include the
+// token file.
+func checkMainConfig(cfg *nixconf.NixConfig) error {
+	err := cfg.CheckMainConfig()
+	if !errors.Is(err, nixconf.ErrMainConfigInStore) {
+		return err
+	}
+
+	path := cfg.GetPath()
+
+	fmt.Printf("%s links to %s in the read-only Nix store,\n", path, cfg.StorePath())
+	fmt.Println("so it cannot include the token file nix-auth writes.")
+
+	confirm, confirmErr := ui.Confirm(fmt.Sprintf("Replace %s with a writable copy?", path), false)
+	if confirmErr != nil {
+		return fmt.Errorf("failed to read confirmation: %w", confirmErr)
+	}
+
+	if !confirm {
+		return err
+	}
+
+	if err := cfg.DetachMainConfig(); err != nil {
+		return fmt.Errorf("failed to create a writable config: %w", err)
+	}
+
+	fmt.Printf("Replaced %s with a writable copy.\n", path)
+	fmt.Println("NixOS or home-manager will try to restore the link on the next switch. To keep the tokens, either:")
+	fmt.Println("  - add the include to the generated config (see 'nix-auth print-include'),")
+	fmt.Println("  - stop generating nix.conf and keep the writable copy, or")
+	fmt.Printf("  - generate your settings into another file and set NIX_USER_CONF_FILES=%s:<that file>\n", path)
+
+	return nil
+}
+
+// addExtraConfigs adds the configs given with --extra-config, which tokens
+// are read from but never written to.
+func addExtraConfigs(cfg *nixconf.NixConfig) error {
+	for _, path := range extraConfigs {
+		if err := cfg.AddExtraConfig(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyTokenFileMode sets the token file permissions from --token-mode, or
+// "token-mode" in the settings file, as an octal mode such as 0640.
+func applyTokenFileMode(cfg *nixconf.NixConfig) error {
+	value := tokenMode
+	if value == "" && userSettings != nil {
+		value = userSettings.TokenMode
+	}
+
+	if value == "" {
+		return nil
+	}
+
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid token file mode %q: expected an octal mode such as 0640", value)
+	}
+
+	return cfg.SetTokenFileMode(os.FileMode(mode))
+}
+
+// tokenEncryption returns the token file encryption selected by --store, or by
+// "store" in the settings file. Flags take precedence over the settings file.
+func tokenEncryption() (nixconf.Encryption, error) {
+	store, recipients, identity := tokenStore, storeRecipients, storeIdentity
+
+	if userSettings != nil {
+		if store == "" {
+			store = userSettings.Store
+		}
+
+		if len(recipients) == 0 {
+			recipients = userSettings.Recipients
+		}
+
+		if identity == "" {
+			identity = userSettings.Identity
+		}
+	}
+
+	switch store {
+	case "", "plain":
+		return nil, nil
+	case "age":
+		return &nixconf.AgeEncryption{Recipients: recipients, Identity: identity}, nil
+	case "gpg":
+		return &nixconf.GPGEncryption{Recipients: recipients}, nil
+	default:
+		return nil, fmt.Errorf("unknown token store %q (expected plain, age or gpg)", store)
+	}
+}
+
+// loadSettings reads the nix-auth settings file before any command runs.
+func loadSettings(_ *cobra.Command, _ []string) error {
+	s, err := settings.Load(settings.DefaultPath())
+	if err != nil {
+		return err
+	}
+
+	for host, h := range s.Hosts {
+		if h.Provider == "" {
+			continue
+		}
+
+		if err := provider.CheckDetectOrder([]string{h.Provider}); err != nil {
+			return fmt.Errorf("provider for %s in the settings file: %w", host, err)
+		}
+	}
+
+	userSettings = s
+
+	return nil
+}
+
+// providerConfig returns the provider configuration for host from the settings file.
+func providerConfig(host string) provider.Config {
+	hostSettings := userSettings.Host(host)
+
+	return provider.Config{
+		Host:         host,
+		ClientID:     configuredClientID(host),
+		ClientSecret: hostSettings.ClientSecret,
+		APIURL:       hostSettings.APIURL,
+		Scopes:       hostSettings.Scopes,
+		DetectOrder:  hostDetectionOrder(host),
+	}
+}
+
+// hostDetectionOrder returns the providers to try when detecting host's
+// provider: only the one set for it in the settings file, if any.
+func hostDetectionOrder(host string) []string {
+	if name := userSettings.Host(host).Provider; name != "" {
+		return []string{name}
+	}
+
+	return detectionOrder()
+}
+
+// configuredProvider returns the provider named by a --provider flag, or if
+// that is auto, the one set for host in the settings file, such as by an alias.
+func configuredProvider(host, flag string) string {
+	if flag != "auto" && flag != "" {
+		return flag
+	}
+
+	if name := userSettings.Host(host).Provider; name != "" {
+		return name
+	}
+
+	return "auto"
+}
+
+// knownProvider returns the provider of host without probing it if the
+// settings file sets one for host or host is a provider's default host, such
+// as gitlab.com, and otherwise the detected one.
+func knownProvider(ctx context.Context, host string) provider.Provider {
+	name := userSettings.Host(host).Provider
+	if name == "" {
+		name = defaultHostProvider(host)
+	}
+
+	if reg, ok := provider.GetRegistration(name); ok && reg.New != nil {
+		return reg.New(providerConfig(host))
+	}
+
+	return provider.DetectCached(ctx, providerConfig(host))
+}
+
+// defaultHostProvider returns the name of the provider whose default host is
+// host, or "" if there is none.
+func defaultHostProvider(host string) string {
+	for _, name := range provider.List() {
+		if reg, ok := provider.GetRegistration(name); ok && reg.DefaultHost != "" && strings.EqualFold(reg.DefaultHost, host) {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// configuredClientID returns the OAuth client ID for host from the settings
+// file, or else the one recorded by its last login.
+func configuredClientID(host string) string {
+	if clientID := userSettings.Host(host).ClientID; clientID != "" {
+		return clientID
+	}
+
+	return savedClientID(host)
 }
 
 func init() {
 	// Add persistent flag for config path
 	defaultPath := nixconf.DefaultUserConfigPath()
-	flagDesc := fmt.Sprintf("Path to nix.conf file (default: %s)", defaultPath)
+	flagDesc := fmt.Sprintf("Path to nix.conf file, or - to read it from stdin for read-only commands (default: %s)", defaultPath)
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", flagDesc)
+	rootCmd.PersistentFlags().StringArrayVar(&extraConfigs, "extra-config", nil,
+		"Also read tokens from this nix.conf, overriding --config; tokens are never written to it (can be repeated)")
+	systemDesc := fmt.Sprintf("Use the system-wide nix.conf (%s) for multi-user Nix daemon setups", nixconf.DefaultSystemConfigPath())
+	rootCmd.PersistentFlags().BoolVar(&useSystemConfig, "system", false, systemDesc)
+	rootCmd.PersistentFlags().BoolVar(&noBackup, "no-backup", false,
+		"Do not back up nix.conf before modifying it (changes cannot be undone from a backup)")
+	rootCmd.PersistentFlags().BoolVar(&noInclude, "no-include", false,
+		"Only write the token file and never modify nix.conf, which must already include it (for declaratively managed configs)")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false,
+		"Answer yes to every confirmation prompt, e.g. to replace an existing token")
+	rootCmd.PersistentFlags().StringVar(&errorFormat, "error-format", errorFormatText,
+		"How to print an error: text, or json for an object with a stable code for wrapping tools")
+	rootCmd.PersistentFlags().BoolVar(&quiet, "quiet", false,
+		"Do not print the summary of changed files after modifying the configuration")
+	rootCmd.PersistentFlags().StringVar(&tokenMode, "token-mode", "",
+		"Octal permissions for the token file, e.g. 0640 to let its group read it (default 0600, never readable by others)")
+	rootCmd.PersistentFlags().BoolVar(&strictParse, "strict-parse", false,
+		"Fail on unparseable lines, duplicate settings or malformed tokens in nix.conf instead of ignoring them")
+	rootCmd.PersistentFlags().StringSliceVar(&detectOrder, "detect-order", nil,
+		"Comma-separated providers to try, in order, when detecting a host's provider (e.g., gitlab,forgejo)")
+	rootCmd.PersistentFlags().StringVar(&tokenStore, "store", "",
+		"How to store the token file: plain, age or gpg (encrypted copies are not read by Nix directly)")
+	rootCmd.PersistentFlags().StringArrayVar(&storeRecipients, "recipient", nil,
+		"Recipient to encrypt the token file to with --store age|gpg (can be repeated)")
+	rootCmd.PersistentFlags().StringVar(&storeIdentity, "identity", "",
+		"age identity file used to decrypt the token file with --store age")
+	rootCmd.PersistentFlags().BoolVar(&warnPrivate, "warn-private", true,
+		"Warn before sending a token to a public provider's host, or a typo of it, that resolves to a loopback or private address")
 
 	rootCmd.AddCommand(loginCmd)
 	rootCmd.AddCommand(statusCmd)
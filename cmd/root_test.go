@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/settings"
+)
+
+// TestMain keeps state such as the detection cache out of the user's state
+// directory for tests that do not set up their own, and keeps the
+// --warn-private check from resolving the hosts of tests.
+func TestMain(m *testing.M) {
+	lookupNetIP = func(context.Context, string) ([]netip.Addr, error) { return nil, nil }
+
+	stateDir, err := os.MkdirTemp("", "nix-auth-state")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create state directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	_ = os.Setenv("XDG_STATE_HOME", stateDir)
+
+	code := m.Run()
+
+	_ = os.RemoveAll(stateDir)
+
+	os.Exit(code)
+}
+
+func TestResolveConfigPathSystem(t *testing.T) {
+	originalConfigPath := configPath
+	originalSystem := useSystemConfig
+	originalGeteuid := geteuid
+
+	defer func() {
+		configPath = originalConfigPath
+		useSystemConfig = originalSystem
+		geteuid = originalGeteuid
+	}()
+
+	confDir := t.TempDir()
+	t.Setenv("NIX_CONF_DIR", confDir)
+
+	t.Run("system flag selects system config", func(t *testing.T) {
+		configPath = ""
+		useSystemConfig = true
+
+		if err := resolveConfigPath(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if want := filepath.Join(confDir, "nix.conf"); configPath != want {
+			t.Errorf("configPath = %q, want %q", configPath, want)
+		}
+	})
+
+	t.Run("system flag conflicts with config flag", func(t *testing.T) {
+		configPath = "/tmp/nix.conf"
+		useSystemConfig = true
+
+		err := resolveConfigPath()
+		if err == nil || !strings.Contains(err.Error(), "--config") {
+			t.Errorf("expected error about --config, got %v", err)
+		}
+	})
+
+	t.Run("changing system config requires root", func(t *testing.T) {
+		useSystemConfig = true
+
+		geteuid = func() int { return 1000 }
+		if err := requireWritableConfig(); err == nil || !strings.Contains(err.Error(), "requires root") {
+			t.Errorf("expected root requirement error, got %v", err)
+		}
+
+		if err := runLogout(nil, []string{"github.com"}); err == nil || !strings.Contains(err.Error(), "requires root") {
+			t.Errorf("expected logout to require root, got %v", err)
+		}
+
+		geteuid = func() int { return 0 }
+		if err := requireWritableConfig(); err != nil {
+			t.Errorf("unexpected error as root: %v", err)
+		}
+	})
+
+	t.Run("user config does not require root", func(t *testing.T) {
+		useSystemConfig = false
+		geteuid = func() int { return 1000 }
+
+		if err := requireWritableConfig(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestTokenEncryption(t *testing.T) {
+	originalStore, originalRecipients, originalSettings := tokenStore, storeRecipients, userSettings
+
+	defer func() {
+		tokenStore, storeRecipients, userSettings = originalStore, originalRecipients, originalSettings
+	}()
+
+	tests := []struct {
+		name       string
+		store      string
+		settings   *settings.Settings
+		wantType   string
+		wantErr    bool
+		recipients []string
+	}{
+		{name: "default is plain", wantType: "<nil>"},
+		{name: "age flag", store: "age", recipients: []string{"age1abc"}, wantType: "*nixconf.AgeEncryption"},
+		{name: "gpg from settings", settings: &settings.Settings{Store: "gpg"}, wantType: "*nixconf.GPGEncryption"},
+		{name: "flag overrides settings", store: "plain", settings: &settings.Settings{Store: "gpg"}, wantType: "<nil>"},
+		{name: "unknown store", store: "vault", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenStore, storeRecipients, userSettings = tt.store, tt.recipients, tt.settings
+
+			enc, err := tokenEncryption()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tokenEncryption() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && fmt.Sprintf("%T", enc) != tt.wantType {
+				t.Errorf("tokenEncryption() = %T, want %s", enc, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestStdinConfig(t *testing.T) {
+	originalConfigPath := configPath
+	originalStdin := os.Stdin
+
+	defer func() {
+		configPath = originalConfigPath
+		os.Stdin = originalStdin
+	}()
+
+	configPath = nixconf.StdinPath
+
+	stdinR, stdinW, _ := os.Pipe()
+	os.Stdin = stdinR
+
+	go func() {
+		defer stdinW.Close() //nolint:errcheck // cleanup in test goroutine
+		_, _ = stdinW.WriteString("access-tokens = github.com=ghp_inline\n")
+	}()
+
+	var lintErr error
+
+	output := captureStdout(t, func() {
+		lintErr = runLint(nil, nil)
+	})
+
+	if lintErr == nil || !strings.Contains(output, "<stdin>:1: access-tokens set directly") {
+		t.Errorf("expected lint to report the stdin config, got %v\nGot output:\n%s", lintErr, output)
+	}
+
+	if err := runLogout(nil, []string{"github.com"}); err == nil || !strings.Contains(err.Error(), "read-only commands") {
+		t.Errorf("expected logout to be refused for --config -, got %v", err)
+	}
+}
+
+func TestDetectionOrder(t *testing.T) {
+	originalOrder, originalSettings := detectOrder, userSettings
+
+	defer func() {
+		detectOrder, userSettings = originalOrder, originalSettings
+	}()
+
+	userSettings = &settings.Settings{DetectOrder: []string{"gitlab", "forgejo"}}
+
+	detectOrder = nil
+	if got := providerConfig("git.company.com").DetectOrder; strings.Join(got, ",") != "gitlab,forgejo" {
+		t.Errorf("DetectOrder = %v, want the settings order", got)
+	}
+
+	detectOrder = []string{"forgejo"}
+	if got := providerConfig("git.company.com").DetectOrder; strings.Join(got, ",") != "forgejo" {
+		t.Errorf("DetectOrder = %v, want the flag to win", got)
+	}
+}
+
+func TestApplyTokenFileMode(t *testing.T) {
+	originalMode, originalSettings := tokenMode, userSettings
+
+	defer func() {
+		tokenMode, userSettings = originalMode, originalSettings
+	}()
+
+	tests := []struct {
+		name     string
+		flag     string
+		settings *settings.Settings
+		wantErr  bool
+	}{
+		{name: "default"},
+		{name: "flag", flag: "0640"},
+		{name: "settings", settings: &settings.Settings{TokenMode: "640"}},
+		{name: "world readable", flag: "0644", wantErr: true},
+		{name: "not octal", flag: "rw-r-----", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenMode, userSettings = tt.flag, tt.settings
+
+			cfg, err := nixconf.New(filepath.Join(t.TempDir(), "nix.conf"))
+			if err != nil {
+				t.Fatalf("failed to create config: %v", err)
+			}
+
+			if err := applyTokenFileMode(cfg); (err != nil) != tt.wantErr {
+				t.Errorf("applyTokenFileMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
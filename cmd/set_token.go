@@ -1,10 +1,17 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/numtide/nix-auth/internal/nixconf"
 	"github.com/numtide/nix-auth/internal/provider"
 	"github.com/numtide/nix-auth/internal/ui"
 	"github.com/spf13/cobra"
@@ -13,38 +20,105 @@ import (
 const (
 	minSetTokenArgs = 1
 	maxSetTokenArgs = 2
+	// defaultSetTokenTimeout is the default deadline for validating one token.
+	defaultSetTokenTimeout = 30 * time.Second
 )
 
 var (
 	setTokenForce    bool
 	setTokenProvider string
+	setTokenAPIURL   string
+	setTokenFromFile string
+	setTokenFD       int
+	setTokenTimeout  time.Duration
+
+	setTokenRequireValid bool
 )
 
 var setTokenCmd = &cobra.Command{
-	Use:   "set-token <host> [token]",
-	Short: "Set an access token for a specific host",
+	Use:         "set-token <host> [token] | set-token --from-file <file>",
+	Annotations: map[string]string{hostArgAnnotation: "true"},
+	Short:       "Set an access token for a specific host",
 	Long: `Set an access token for a specific host.
 
-The token can be provided as an argument or entered interactively for security.
-If a provider is specified or detected, the token will be validated before saving.`,
+The token can be provided as an argument, entered interactively or read from
+an inherited file descriptor with --token-fd, which keeps it out of argv, the
+environment and the disk. The first line read from the descriptor is used.
+If a provider is specified or detected, the token will be validated before saving.
+
+Setting the token that is already stored is a no-op, so set-token can be run
+repeatedly from configuration management without rewriting files.
+
+With --require-valid, set-token never prompts and fails unless the token is
+validated: a detected provider that rejects the token, or a host whose provider
+cannot be detected in time, is an error instead of a warning. Combine it with
+--force to replace an existing token, for a strict "set and verify" in CI.
+
+With --from-file, tokens for several hosts are read from a file with one
+host=token pair per line (blank lines and # comments are ignored) and written
+at once, followed by a summary of which hosts succeeded and which failed.`,
 	Example: `  # Set token directly
   nix-auth set-token github.com ghp_xxxxxxxxxxxx
 
   # Prompt for token (more secure)
   nix-auth set-token github.com
 
+  # Read the token from file descriptor 3
+  nix-auth set-token github.com --token-fd 3 3< <(pass show github-token)
+
   # Force replace existing token
   nix-auth set-token github.com ghp_xxxxxxxxxxxx --force
 
   # Specify provider for validation
-  nix-auth set-token git.company.com --provider gitlab`,
-	Args: cobra.RangeArgs(minSetTokenArgs, maxSetTokenArgs),
+  nix-auth set-token git.company.com --provider gitlab
+
+  # In CI: replace the token without prompts and fail unless it is valid
+  nix-auth set-token github.com --token-fd 3 --require-valid --force 3<<<"$GITHUB_TOKEN"
+
+  # Set tokens for several hosts from a file of host=token lines
+  nix-auth set-token --from-file tokens.env`,
+	Args: setTokenArgs,
 	RunE: func(_ *cobra.Command, args []string) error {
 		ctx := context.Background()
-		host := args[0]
+
+		if setTokenFromFile != "" {
+			return runSetTokensFromFile(ctx, setTokenFromFile)
+		}
+
+		host, err := parseHost(args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := confirmArgumentOrder(args); err != nil {
+			return err
+		}
+
+		// Get the token from args or --token-fd now; prompting for it waits until replacing is confirmed
+		var token string
+
+		switch {
+		case len(args) == maxSetTokenArgs:
+			token = args[1]
+		case setTokenFD >= 0:
+			token, err = readTokenFD(setTokenFD)
+			if err != nil {
+				return err
+			}
+
+			if token == "" {
+				return fmt.Errorf("token cannot be empty")
+			}
+		case setTokenRequireValid:
+			return fmt.Errorf("--require-valid never prompts; pass the token as an argument or with --token-fd")
+		}
+
+		if err := requireWritableConfig(); err != nil {
+			return err
+		}
 
 		// Initialize config
-		cfg, err := nixconf.New(configPath)
+		cfg, err := openNixConfig()
 		if err != nil {
 			return fmt.Errorf("failed to initialize config: %w", err)
 		}
@@ -63,13 +137,26 @@ If a provider is specified or detected, the token will be validated before savin
 			}
 		}
 
+		existingToken := ""
+		if tokenExists {
+			existingToken, _ = cfg.GetToken(host)
+		}
+
+		// A token that is not prompted for can be compared before asking to replace it
+		if tokenUpToDate(host, token, existingToken) {
+			return nil
+		}
+
+		if tokenExists && !setTokenForce && setTokenRequireValid {
+			return fmt.Errorf("a token for %s already exists (use --force to replace it)", host)
+		}
+
 		if tokenExists && !setTokenForce {
-			existingToken, err := cfg.GetToken(host)
-			if err == nil && existingToken != "" {
+			if existingToken != "" {
 				maskedExisting := ui.MaskToken(existingToken)
 				fmt.Printf("Token already exists for %s: %s\n", host, maskedExisting)
 
-				confirm, err := ui.ReadYesNo("Replace it? (y/N): ")
+				confirm, err := ui.Confirm("Replace it?", false)
 				if err != nil {
 					return fmt.Errorf("failed to read confirmation: %w", err)
 				}
@@ -80,12 +167,8 @@ If a provider is specified or detected, the token will be validated before savin
 			}
 		}
 
-		// Get token from args or prompt
-		var token string
-		if len(args) == maxSetTokenArgs {
-			token = args[1]
-		} else {
-			var err error
+		prompted := len(args) < maxSetTokenArgs && setTokenFD < 0
+		if prompted {
 			token, err = ui.ReadSecureInput(fmt.Sprintf("Enter token for %s: ", host))
 			if err != nil {
 				return fmt.Errorf("failed to read token: %w", err)
@@ -97,41 +180,35 @@ If a provider is specified or detected, the token will be validated before savin
 			return fmt.Errorf("token cannot be empty")
 		}
 
-		// Determine provider
-		if setTokenProvider != "" {
-			// User specified provider
-			p, ok := provider.Get(setTokenProvider)
-			if !ok {
-				return fmt.Errorf("unknown provider: %s", setTokenProvider)
-			}
-			// Validate token if provider is available
-			fmt.Printf("Validating token with %s provider...\n", p.Name())
-			status, err := p.ValidateToken(ctx, token)
-			if err != nil {
-				return fmt.Errorf("token validation failed: %w", err)
-			}
-			if status != provider.ValidationStatusValid {
-				return fmt.Errorf("token is not valid")
-			}
-			fmt.Println("Token validated successfully")
-		} else {
-			// Try to detect provider from host
-			p, err := provider.Detect(ctx, host, "")
-			if err == nil && p.Name() != "unknown" {
-				// Validate token if provider was detected
-				fmt.Printf("Detected %s provider, validating token...\n", p.Name())
-				status, err := p.ValidateToken(ctx, token)
+		if warning := ui.TruncatedTokenWarning(token); warning != "" {
+			fmt.Println(warning)
+
+			// A pasted token can be re-entered, so confirm before saving it
+			if prompted {
+				confirm, err := ui.Confirm("Save it anyway?", false)
 				if err != nil {
-					// Just warn, don't fail
-					fmt.Printf("Warning: token validation failed: %v\n", err)
-				} else if status != provider.ValidationStatusValid {
-					fmt.Printf("Warning: token may not be valid\n")
-				} else {
-					fmt.Println("Token validated successfully")
+					return fmt.Errorf("failed to read confirmation: %w", err)
+				}
+
+				if !confirm {
+					fmt.Println("Token not saved.")
+					return nil
 				}
 			}
 		}
 
+		if tokenUpToDate(host, token, existingToken) {
+			return nil
+		}
+
+		if err := checkMainConfig(cfg); err != nil {
+			return err
+		}
+
+		if err := validateSetToken(ctx, host, token); err != nil {
+			return err
+		}
+
 		// Set the token
 		if err := cfg.SetToken(host, token); err != nil {
 			return fmt.Errorf("failed to set token: %w", err)
@@ -140,12 +217,210 @@ If a provider is specified or detected, the token will be validated before savin
 		maskedToken := ui.MaskToken(token)
 		fmt.Printf("Successfully set token for %s: %s\n", host, maskedToken)
 		fmt.Printf("Config saved to: %s\n", cfg.GetTokenFilePath())
+		printChangeSummary(cfg)
+
+		forgetTokenExpiry(host)
+
+		runPostChangeHook("set-token", host, cfg)
 
 		return nil
 	},
 }
 
+// hostPattern matches a DNS name with at least one dot and an alphabetic top-level label.
+var hostPattern = regexp.MustCompile(`^[a-z0-9-]+(\.[a-z0-9-]+)*\.[a-z]{2,}$`)
+
+// swappedArgsWarning returns a warning if the set-token arguments look like they
+// were given in the wrong order, or "" if they look fine. Neither argument is
+// included, since either may be a token.
+func swappedArgsWarning(args []string) string {
+	host := args[0]
+
+	if ui.HasKnownTokenPrefix(host) {
+		return "Warning: the host argument looks like a token; the usage is set-token <host> [token]"
+	}
+
+	if len(args) == maxSetTokenArgs && !ui.HasKnownTokenPrefix(args[1]) && hostPattern.MatchString(args[1]) {
+		return "Warning: the token argument looks like a host name; the usage is set-token <host> [token]"
+	}
+
+	return ""
+}
+
+// setTokenInteractive reports whether set-token may ask about the argument
+// order (replaced in tests).
+var setTokenInteractive = ui.IsInteractive
+
+// confirmArgumentOrder warns when the host and token look swapped and, when
+// interactive and unless --force or --require-valid is given, asks whether to
+// continue. Declining is an error, so that nothing is saved silently; without a
+// terminal to ask on it only warns. With --require-valid, validation catches
+// swapped arguments.
+func confirmArgumentOrder(args []string) error {
+	warning := swappedArgsWarning(args)
+	if warning == "" {
+		return nil
+	}
+
+	fmt.Println(warning)
+
+	if setTokenForce || setTokenRequireValid || !setTokenInteractive() {
+		return nil
+	}
+
+	confirm, err := ui.Confirm("Continue anyway?", false)
+	if err != nil {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+
+	if !confirm {
+		return errors.New("operation cancelled: check the order of the arguments")
+	}
+
+	return nil
+}
+
+// validateSetToken validates token for host before it is saved. With --provider
+// other than auto an invalid token is an error; otherwise the provider is
+// detected and a failed validation is only reported as a warning, unless
+// --require-valid makes it an error too. Both are bounded by --timeout. A host
+// that unexpectedly resolves to a private address is warned about first.
+func validateSetToken(ctx context.Context, host, token string) error {
+	if setTokenTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, setTokenTimeout)
+		defer cancel()
+	}
+
+	warnPrivateHost(ctx, os.Stdout, host)
+
+	if name := configuredProvider(host, setTokenProvider); name != "auto" {
+		// User specified provider
+		p, ok := provider.GetWithConfig(name, setTokenProviderConfig(host))
+		if !ok {
+			return fmt.Errorf("unknown provider: %s", name)
+		}
+		// Validate token if provider is available
+		fmt.Printf("Validating token with %s provider...\n", p.Name())
+		status, err := p.ValidateToken(ctx, token)
+		if err != nil {
+			return fmt.Errorf("token validation failed: %w", err)
+		}
+		if status != provider.ValidationStatusValid {
+			return fmt.Errorf("token is not valid: %w", provider.ErrInvalidToken)
+		}
+		fmt.Println("Token validated successfully")
+
+		return nil
+	}
+
+	// Try to detect provider from host
+	p := provider.DetectCached(ctx, setTokenProviderConfig(host))
+	if ctx.Err() != nil {
+		if setTokenRequireValid {
+			return fmt.Errorf("could not detect the provider for %s within %s to validate the token", host, setTokenTimeout)
+		}
+
+		fmt.Printf("Warning: could not detect the provider for %s within %s, the token was not validated\n", host, setTokenTimeout)
+
+		return nil
+	}
+
+	if p.Name() == "unknown" {
+		if setTokenRequireValid {
+			return fmt.Errorf("cannot validate the token: no provider detected for %s (use --provider)", host)
+		}
+
+		return nil
+	}
+
+	// Validate token if provider was detected
+	fmt.Printf("Detected %s provider, validating token...\n", p.Name())
+	status, err := p.ValidateToken(ctx, token)
+
+	switch {
+	case err != nil && setTokenRequireValid:
+		return fmt.Errorf("token validation failed: %w", err)
+	case err != nil:
+		// Just warn, don't fail
+		fmt.Printf("Warning: token validation failed: %v\n", err)
+	case status != provider.ValidationStatusValid && setTokenRequireValid:
+		return fmt.Errorf("token is not valid: %w", provider.ErrInvalidToken)
+	case status != provider.ValidationStatusValid:
+		fmt.Printf("Warning: token may not be valid\n")
+	default:
+		fmt.Println("Token validated successfully")
+	}
+
+	return nil
+}
+
+// setTokenProviderConfig returns the provider configuration used to validate the token.
+func setTokenProviderConfig(host string) provider.Config {
+	cfg := providerConfig(host)
+	if setTokenAPIURL != "" {
+		cfg.APIURL = setTokenAPIURL
+	}
+
+	return cfg
+}
+
+// tokenUpToDate reports whether token is already stored for host, in which case
+// nothing needs to be written. With --force the token is always rewritten.
+func tokenUpToDate(host, token, existingToken string) bool {
+	if setTokenForce || token == "" || token != existingToken {
+		return false
+	}
+
+	fmt.Printf("Token for %s already up to date\n", host)
+
+	return true
+}
+
+// maxTokenFDSize is how much readTokenFD reads at most while looking for the end of the line.
+const maxTokenFDSize = 64 * 1024
+
+// readTokenFD reads the token from the inherited file descriptor fd, closing it
+// unless it is one of the standard streams. Like a token entered at the prompt,
+// the token is the first line, or everything up to EOF if there is no newline,
+// with surrounding whitespace removed.
+func readTokenFD(fd int) (string, error) {
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+	if file == nil {
+		return "", fmt.Errorf("invalid --token-fd %d", fd)
+	}
+
+	if fd > syscall.Stderr {
+		defer func() { _ = file.Close() }()
+	}
+
+	if _, err := file.Stat(); err != nil {
+		return "", fmt.Errorf("file descriptor %d is not open: %w", fd, err)
+	}
+
+	return readTokenLine(file)
+}
+
+// readTokenLine reads a token from the first line of file, as readTokenFD does.
+func readTokenLine(file *os.File) (string, error) {
+	line, err := bufio.NewReader(io.LimitReader(file, maxTokenFDSize)).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read token from %s: %w", file.Name(), err)
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
 func init() {
-	setTokenCmd.Flags().BoolVarP(&setTokenForce, "force", "f", false, "Force replace existing token without confirmation")
-	setTokenCmd.Flags().StringVarP(&setTokenProvider, "provider", "p", "", "Specify provider for token validation (e.g., github, gitlab)")
+	setTokenCmd.Flags().BoolVarP(&setTokenForce, "force", "f", false, "Replace existing token without confirmation, even if it is unchanged")
+	setTokenCmd.Flags().StringVarP(&setTokenProvider, "provider", "p", "auto",
+		"Provider to validate the token with (auto, github, gitlab, gitea, forgejo, codeberg)")
+	setTokenCmd.Flags().StringVar(&setTokenFromFile, "from-file", "", "Read host=token lines from a file and set all of them")
+	setTokenCmd.Flags().IntVar(&setTokenFD, "token-fd", -1, "Read the token from this inherited file descriptor")
+	setTokenCmd.Flags().DurationVar(&setTokenTimeout, "timeout", defaultSetTokenTimeout,
+		"Deadline for detecting the provider and validating each token (0 for no deadline)")
+	setTokenCmd.Flags().BoolVar(&setTokenRequireValid, "require-valid", false,
+		"Never prompt, and fail unless the token is validated, even when the provider is detected")
+	setTokenCmd.Flags().StringVar(&setTokenAPIURL, "api-url", "", "API base URL for validation when it differs from the host (e.g., https://api.git.company.com)")
 }
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/numtide/nix-auth/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// setTokenArgs checks the arguments of set-token: a host and an optional token,
+// or none with --from-file.
+func setTokenArgs(cmd *cobra.Command, args []string) error {
+	if setTokenFromFile != "" {
+		if len(args) > 0 {
+			return fmt.Errorf("--from-file cannot be combined with host or token arguments")
+		}
+
+		if setTokenFD >= 0 {
+			return fmt.Errorf("--from-file cannot be combined with --token-fd")
+		}
+
+		return nil
+	}
+
+	if setTokenFD >= 0 {
+		if len(args) != minSetTokenArgs {
+			return fmt.Errorf("--token-fd takes the host as the only argument")
+		}
+
+		return nil
+	}
+
+	return cobra.RangeArgs(minSetTokenArgs, maxSetTokenArgs)(cmd, args)
+}
+
+// readTokenFile parses a file of host=token lines. Blank lines and lines starting
+// with # are ignored, and a later line for the same host replaces an earlier one.
+// Errors name the line but never echo its content, as it may contain a token.
+func readTokenFile(path string) (map[string]string, error) {
+	file, err := os.Open(path) //nolint:gosec // user-provided token file
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = file.Close() }()
+
+	tokens := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		host, token, found := strings.Cut(line, "=")
+		token = strings.TrimSpace(token)
+
+		if !found || strings.TrimSpace(host) == "" || token == "" {
+			return nil, fmt.Errorf("%s:%d: expected host=token", path, lineNum)
+		}
+
+		host, err := parseHost(host)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+
+		tokens[host] = token
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// runSetTokensFromFile validates and saves every token in path with a single
+// write, then prints a summary. Hosts that fail are skipped, and the command
+// fails if any did.
+func runSetTokensFromFile(ctx context.Context, path string) error {
+	if err := requireWritableConfig(); err != nil {
+		return err
+	}
+
+	tokens, err := readTokenFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read tokens: %w", err)
+	}
+
+	if len(tokens) == 0 {
+		return fmt.Errorf("no tokens found in %s", path)
+	}
+
+	cfg, err := openNixConfig()
+	if err != nil {
+		return fmt.Errorf("failed to initialize config: %w", err)
+	}
+
+	hosts := make([]string, 0, len(tokens))
+	for host := range tokens {
+		hosts = append(hosts, host)
+	}
+
+	sort.Strings(hosts)
+
+	if err := checkMainConfig(cfg); err != nil {
+		return err
+	}
+
+	toSave := make(map[string]string)
+	failures := make(map[string]error)
+	unchanged := 0
+
+	for _, host := range hosts {
+		token := tokens[host]
+
+		existingToken, err := cfg.GetToken(host)
+		if err != nil {
+			return fmt.Errorf("failed to read tokens: %w", err)
+		}
+
+		if tokenUpToDate(host, token, existingToken) {
+			unchanged++
+			continue
+		}
+
+		if existingToken != "" && !setTokenForce {
+			failures[host] = fmt.Errorf("a token already exists (use --force to replace it)")
+			continue
+		}
+
+		if warning := ui.TruncatedTokenWarning(token); warning != "" {
+			fmt.Printf("%s: %s\n", host, warning)
+		}
+
+		fmt.Printf("Setting token for %s\n", host)
+
+		if err := validateSetToken(ctx, host, token); err != nil {
+			failures[host] = err
+			continue
+		}
+
+		toSave[host] = token
+	}
+
+	if len(toSave) > 0 {
+		if err := cfg.SetTokens(toSave); err != nil {
+			return fmt.Errorf("failed to set tokens: %w", err)
+		}
+
+		for host := range toSave {
+			forgetTokenExpiry(host)
+			runPostChangeHook("set-token", host, cfg)
+		}
+	}
+
+	printSetTokensSummary(hosts, tokens, toSave, failures, unchanged)
+
+	if len(toSave) > 0 {
+		fmt.Printf("Config saved to: %s\n", cfg.GetTokenFilePath())
+		printChangeSummary(cfg)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to set %d of %d token(s)", len(failures), len(hosts))
+	}
+
+	return nil
+}
+
+// printSetTokensSummary lists the result for each host of a --from-file run.
+func printSetTokensSummary(hosts []string, tokens, saved map[string]string, failures map[string]error, unchanged int) {
+	fmt.Printf("\nSet %d token(s), %d unchanged, %d failed:\n", len(saved), unchanged, len(failures))
+
+	for _, host := range hosts {
+		switch {
+		case saved[host] != "":
+			fmt.Printf("  ✓ %s: %s\n", host, ui.MaskToken(tokens[host]))
+		case failures[host] != nil:
+			fmt.Printf("  ✗ %s: %v\n", host, failures[host])
+		default:
+			fmt.Printf("  - %s: unchanged\n", host)
+		}
+	}
+}
@@ -8,8 +8,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
 
+	"github.com/numtide/nix-auth/internal/nixconf"
 	"github.com/numtide/nix-auth/internal/provider"
 	"github.com/spf13/cobra"
 )
@@ -56,6 +58,11 @@ func setupSetTokenTest(t *testing.T) {
 	originalRegistry := provider.GetRegistry()
 	originalForce := setTokenForce
 	originalProvider := setTokenProvider
+	originalFromFile := setTokenFromFile
+	originalFD := setTokenFD
+	originalTimeout := setTokenTimeout
+	originalRequireValid := setTokenRequireValid
+	originalInteractive := setTokenInteractive
 
 	t.Cleanup(func() {
 		configPath = originalConfigPath
@@ -64,6 +71,11 @@ func setupSetTokenTest(t *testing.T) {
 
 		setTokenForce = originalForce
 		setTokenProvider = originalProvider
+		setTokenFromFile = originalFromFile
+		setTokenFD = originalFD
+		setTokenTimeout = originalTimeout
+		setTokenRequireValid = originalRequireValid
+		setTokenInteractive = originalInteractive
 	})
 }
 
@@ -84,7 +96,11 @@ func runSetTokenTest(t *testing.T, tc struct {
 
 	// Reset flags
 	setTokenForce = false
-	setTokenProvider = ""
+	setTokenProvider = "auto"
+	setTokenFromFile = ""
+	setTokenFD = -1
+	setTokenTimeout = 0
+	setTokenRequireValid = false
 
 	// Setup flags if provided
 	if tc.setupFlags != nil {
@@ -188,6 +204,40 @@ func TestSetTokenBasicOperations(t *testing.T) {
 				"Config saved to:",
 			},
 		},
+		{
+			name: "host given as URL is normalized",
+			args: []string{"https://Test.Example.com/", "test-token-123"},
+			setupConfig: func(t *testing.T) string {
+				t.Helper()
+				tmpDir := t.TempDir()
+				configFile := filepath.Join(tmpDir, "nix.conf")
+				if err := os.WriteFile(configFile, []byte(""), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				return configFile
+			},
+			expectedOutputs: []string{
+				"Successfully set token for test.example.com: test********",
+			},
+		},
+		{
+			name: "truncated pasted token is not saved without confirmation",
+			args: []string{"test.example.com"},
+			setupConfig: func(t *testing.T) string {
+				t.Helper()
+				tmpDir := t.TempDir()
+				configFile := filepath.Join(tmpDir, "nix.conf")
+				if err := os.WriteFile(configFile, []byte(""), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				return configFile
+			},
+			mockStdin: "ghp_truncated123\nn\n",
+			expectedOutputs: []string{
+				"may have been cut off while pasting",
+				"Token not saved.",
+			},
+		},
 		{
 			name: "set new token interactively",
 			args: []string{"test.example.com"},
@@ -242,7 +292,7 @@ func TestSetTokenBasicOperations(t *testing.T) {
 			mockStdin: "n\n",
 			expectedOutputs: []string{
 				"Token already exists for test.example.com: ********",
-				"Replace it? (y/N):",
+				"Replace it? [y/N]",
 				"Operation cancelled",
 			},
 			expectError: false,
@@ -263,7 +313,44 @@ func TestSetTokenBasicOperations(t *testing.T) {
 			mockStdin: "y\n",
 			expectedOutputs: []string{
 				"Token already exists for test.example.com: ********",
-				"Replace it? (y/N):",
+				"Replace it? [y/N]",
+				"Successfully set token for test.example.com: ********",
+			},
+		},
+		{
+			name: "unchanged token is a no-op",
+			args: []string{"test.example.com", "old-token-123"},
+			setupConfig: func(t *testing.T) string {
+				t.Helper()
+				tmpDir := t.TempDir()
+				configFile := filepath.Join(tmpDir, "nix.conf")
+				content := testExistingTokenConfig
+				if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				return configFile
+			},
+			expectedOutputs: []string{
+				"Token for test.example.com already up to date",
+			},
+		},
+		{
+			name: "unchanged token is rewritten with force flag",
+			args: []string{"test.example.com", "old-token-123"},
+			setupFlags: func() {
+				setTokenForce = true
+			},
+			setupConfig: func(t *testing.T) string {
+				t.Helper()
+				tmpDir := t.TempDir()
+				configFile := filepath.Join(tmpDir, "nix.conf")
+				content := testExistingTokenConfig
+				if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				return configFile
+			},
+			expectedOutputs: []string{
 				"Successfully set token for test.example.com: ********",
 			},
 		},
@@ -396,6 +483,114 @@ func TestSetTokenProviderValidation(t *testing.T) {
 	}
 }
 
+func TestSetTokenRequireValid(t *testing.T) {
+	setupSetTokenTest(t)
+
+	detecting := func() {
+		provider.SetRegistry(make(map[string]*provider.Registration))
+		provider.RegisterProvider("detected", provider.Registration{
+			Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+				switch host {
+				case "plain.example.com":
+					return nil, nil
+				case "bad.example.com":
+					return &mockSetTokenProvider{name: "detected", host: host, validateResult: provider.ValidationStatusInvalid}, nil
+				}
+
+				return &mockSetTokenProvider{name: "detected", host: host, validateResult: provider.ValidationStatusValid}, nil
+			},
+		})
+	}
+
+	requireValid := func() { setTokenRequireValid = true }
+	emptyConfig := func(t *testing.T) string { t.Helper(); return createTestConfig(t, "") }
+
+	tests := []struct {
+		name            string
+		args            []string
+		setupFlags      func()
+		setupConfig     func(t *testing.T) string
+		setupProviders  func()
+		mockStdin       string
+		expectedOutputs []string
+		expectError     bool
+		errorContains   string
+	}{
+		{
+			name:            "valid token is saved",
+			args:            []string{"test.example.com", "good-token-123"},
+			setupFlags:      requireValid,
+			setupConfig:     emptyConfig,
+			setupProviders:  detecting,
+			expectedOutputs: []string{"Token validated successfully", "Successfully set token for test.example.com"},
+		},
+		{
+			name:           "rejected token fails",
+			args:           []string{"bad.example.com", "bad-token-123"},
+			setupFlags:     requireValid,
+			setupConfig:    emptyConfig,
+			setupProviders: detecting,
+			expectError:    true,
+			errorContains:  "token is not valid",
+		},
+		{
+			name:           "undetected provider fails",
+			args:           []string{"plain.example.com", "some-token-123"},
+			setupFlags:     requireValid,
+			setupConfig:    emptyConfig,
+			setupProviders: detecting,
+			expectError:    true,
+			errorContains:  "no provider detected for plain.example.com",
+		},
+		{
+			name:           "existing token is not replaced without force",
+			args:           []string{"test.example.com", "new-token-456"},
+			setupFlags:     requireValid,
+			setupConfig:    func(t *testing.T) string { t.Helper(); return createTestConfig(t, testExistingTokenConfig) },
+			setupProviders: detecting,
+			mockStdin:      "y\n",
+			expectError:    true,
+			errorContains:  "a token for test.example.com already exists (use --force to replace it)",
+		},
+		{
+			name: "force replaces without a prompt",
+			args: []string{"test.example.com", "new-token-456"},
+			setupFlags: func() {
+				setTokenRequireValid = true
+				setTokenForce = true
+			},
+			setupConfig:     func(t *testing.T) string { t.Helper(); return createTestConfig(t, testExistingTokenConfig) },
+			setupProviders:  detecting,
+			expectedOutputs: []string{"Successfully set token for test.example.com"},
+		},
+		{
+			name:          "token must not be prompted for",
+			args:          []string{"test.example.com"},
+			setupFlags:    requireValid,
+			setupConfig:   emptyConfig,
+			expectError:   true,
+			errorContains: "--require-valid never prompts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runSetTokenTest(t, tt)
+
+			if tt.expectError {
+				cfg, err := openNixConfig()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if token, _ := cfg.GetToken(tt.args[0]); token != "" && token != "old-token-123" {
+					t.Errorf("expected the token not to be saved, got %q", token)
+				}
+			}
+		})
+	}
+}
+
 func TestSetTokenErrorCases(t *testing.T) {
 	setupSetTokenTest(t)
 
@@ -487,6 +682,230 @@ func TestSetTokenErrorCases(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name:       "explicit auto detects the provider",
+			args:       []string{"test.example.com", "valid-token-123"},
+			setupFlags: func() { setTokenProvider = "auto" },
+			setupConfig: func(t *testing.T) string {
+				t.Helper()
+				return createTestConfig(t, "")
+			},
+			setupProviders: func() {
+				provider.SetRegistry(make(map[string]*provider.Registration))
+				provider.RegisterProvider("test-provider", provider.Registration{
+					Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+						return &mockSetTokenProvider{
+							name:           "test-provider",
+							host:           host,
+							validateResult: provider.ValidationStatusValid,
+						}, nil
+					},
+				})
+			},
+			expectedOutputs: []string{
+				"Detected test-provider provider, validating token...",
+				"Token validated successfully",
+				"Successfully set token for test.example.com",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runSetTokenTest(t, tt)
+		})
+	}
+}
+
+func TestSetTokenFromFile(t *testing.T) {
+	setupSetTokenTest(t)
+
+	t.Setenv("NIX_AUTH_POST_HOOK", "")
+
+	tokenFile := filepath.Join(t.TempDir(), "tokens.env")
+	content := "# provisioning\ngithub.com=ghp_batch123\n\nhttps://GitLab.com/=glpat-batch456\ntest.example.com=new-token-789\n"
+
+	if err := os.WriteFile(tokenFile, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The config of the forced run outlives its subtest so the result can be checked
+	forcedConfig := createTestConfig(t, testExistingTokenConfig)
+
+	badFile := filepath.Join(t.TempDir(), "bad.env")
+	if err := os.WriteFile(badFile, []byte("github.com=ghp_ok\nghp_secret_without_host\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		args            []string
+		setupFlags      func()
+		setupConfig     func(t *testing.T) string
+		setupProviders  func()
+		mockStdin       string
+		expectedOutputs []string
+		expectError     bool
+		errorContains   string
+	}{
+		{
+			name:          "malformed line",
+			setupFlags:    func() { setTokenFromFile = badFile },
+			setupConfig:   func(t *testing.T) string { t.Helper(); return createTestConfig(t, "") },
+			expectError:   true,
+			errorContains: "bad.env:2: expected host=token",
+		},
+		{
+			name:        "existing token is not replaced without force",
+			setupFlags:  func() { setTokenFromFile = tokenFile },
+			setupConfig: func(t *testing.T) string { t.Helper(); return createTestConfig(t, testExistingTokenConfig) },
+			expectedOutputs: []string{
+				"Set 2 token(s), 0 unchanged, 1 failed:",
+				"✓ github.com",
+				"✓ gitlab.com",
+				"✗ test.example.com: a token already exists (use --force to replace it)",
+			},
+			expectError:   true,
+			errorContains: "failed to set 1 of 3 token(s)",
+		},
+		{
+			name: "force replaces existing token",
+			setupFlags: func() {
+				setTokenFromFile = tokenFile
+				setTokenForce = true
+			},
+			setupConfig:     func(_ *testing.T) string { return forcedConfig },
+			expectedOutputs: []string{"Set 3 token(s), 0 unchanged, 0 failed:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runSetTokenTest(t, tt)
+		})
+	}
+
+	configPath = forcedConfig
+
+	cfg, err := openNixConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for host, want := range map[string]string{"github.com": "ghp_batch123", "gitlab.com": "glpat-batch456", "test.example.com": "new-token-789"} {
+		if got, _ := cfg.GetToken(host); got != want {
+			t.Errorf("GetToken(%s) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestSetTokenFromFilePaddedToken(t *testing.T) {
+	setupSetTokenTest(t)
+
+	t.Setenv("NIX_AUTH_POST_HOOK", "")
+
+	// Only the first = separates the host, so base64 padding stays part of the token
+	tokenFile := filepath.Join(t.TempDir(), "tokens.env")
+	if err := os.WriteFile(tokenFile, []byte("test.example.com=bmV3LXRva2VuLTc4OQ==\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	runSetTokenTest(t, struct {
+		name            string
+		args            []string
+		setupFlags      func()
+		setupConfig     func(t *testing.T) string
+		setupProviders  func()
+		mockStdin       string
+		expectedOutputs []string
+		expectError     bool
+		errorContains   string
+	}{
+		name:            "padded token",
+		setupFlags:      func() { setTokenFromFile = tokenFile },
+		setupConfig:     func(t *testing.T) string { t.Helper(); return createTestConfig(t, "") },
+		expectedOutputs: []string{"Set 1 token(s), 0 unchanged, 0 failed:"},
+	})
+
+	cfg, err := openNixConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := cfg.GetToken("test.example.com"); got != "bmV3LXRva2VuLTc4OQ==" {
+		t.Errorf("GetToken(test.example.com) = %q, want %q", got, "bmV3LXRva2VuLTc4OQ==")
+	}
+}
+
+// tokenPipeFD returns a file descriptor to read content from, as passed with --token-fd.
+// The descriptor is a duplicate, since set-token closes it after reading.
+func tokenPipeFD(t *testing.T, content string) int {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = io.WriteString(w, content)
+	_ = w.Close()
+
+	fd, err := syscall.Dup(int(r.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = r.Close()
+
+	return fd
+}
+
+func TestSetTokenFromFD(t *testing.T) {
+	setupSetTokenTest(t)
+
+	t.Setenv("NIX_AUTH_POST_HOOK", "")
+
+	tests := []struct {
+		name            string
+		args            []string
+		setupFlags      func()
+		setupConfig     func(t *testing.T) string
+		setupProviders  func()
+		mockStdin       string
+		expectedOutputs []string
+		expectError     bool
+		errorContains   string
+	}{
+		{
+			name:            "first line is the token",
+			args:            []string{"test.example.com"},
+			setupFlags:      func() { setTokenFD = tokenPipeFD(t, "  fd-token-123  \nignored\n") },
+			setupConfig:     func(t *testing.T) string { t.Helper(); return createTestConfig(t, "") },
+			expectedOutputs: []string{"Successfully set token for test.example.com"},
+		},
+		{
+			name:            "token without newline",
+			args:            []string{"test.example.com"},
+			setupFlags:      func() { setTokenFD = tokenPipeFD(t, "old-token-123") },
+			setupConfig:     func(t *testing.T) string { t.Helper(); return createTestConfig(t, testExistingTokenConfig) },
+			expectedOutputs: []string{"Token for test.example.com already up to date"},
+		},
+		{
+			name:          "empty input",
+			args:          []string{"test.example.com"},
+			setupFlags:    func() { setTokenFD = tokenPipeFD(t, "\n") },
+			setupConfig:   func(t *testing.T) string { t.Helper(); return createTestConfig(t, "") },
+			expectError:   true,
+			errorContains: "token cannot be empty",
+		},
+		{
+			name:          "descriptor not open",
+			args:          []string{"test.example.com"},
+			setupFlags:    func() { setTokenFD = 999 },
+			setupConfig:   func(t *testing.T) string { t.Helper(); return createTestConfig(t, "") },
+			expectError:   true,
+			errorContains: "is not open",
+		},
 	}
 
 	for _, tt := range tests {
@@ -494,6 +913,12 @@ func TestSetTokenErrorCases(t *testing.T) {
 			runSetTokenTest(t, tt)
 		})
 	}
+
+	setTokenFD = 3
+
+	if err := setTokenCmd.Args(nil, []string{"test.example.com", "token"}); err == nil {
+		t.Error("expected --token-fd with a token argument to be rejected")
+	}
 }
 
 func TestSetTokenCommandFlags(t *testing.T) {
@@ -557,3 +982,194 @@ func TestSetTokenCommandArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestSwappedArgsWarning(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		warn bool
+	}{
+		{name: "correct order", args: []string{"github.com", "ghp_" + strings.Repeat("a", 36)}, warn: false},
+		{name: "host prompt", args: []string{"gitlab.company.com"}, warn: false},
+		{name: "host with port", args: []string{"git:8443", "secret"}, warn: false},
+		{name: "localhost", args: []string{"localhost", "secret"}, warn: false},
+		{name: "token as host", args: []string{"ghp_" + strings.Repeat("a", 36), "github.com"}, warn: true},
+		{name: "single-label host", args: []string{"gitserver", "secret"}, warn: false},
+		{name: "host as token", args: []string{"gitlab.company.com", "github.com"}, warn: true},
+		{name: "JWT-like token", args: []string{"git.company.com", "eyJhbGciOi.eyJzdWIiOi.SflKxwRJSMeKKF2QT4"}, warn: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warning := swappedArgsWarning(tt.args)
+			if (warning != "") != tt.warn {
+				t.Errorf("swappedArgsWarning(%q) = %q, want warning: %v", tt.args, warning, tt.warn)
+			}
+
+			for _, arg := range tt.args {
+				if warning != "" && strings.Contains(warning, arg) {
+					t.Errorf("warning %q must not include the arguments", warning)
+				}
+			}
+		})
+	}
+}
+
+func TestSetTokenSwappedArguments(t *testing.T) {
+	setupSetTokenTest(t)
+
+	token := "ghp_" + strings.Repeat("a", 36)
+
+	tests := []struct {
+		name            string
+		args            []string
+		setupFlags      func()
+		setupConfig     func(t *testing.T) string
+		setupProviders  func()
+		mockStdin       string
+		expectedOutputs []string
+		expectError     bool
+		errorContains   string
+	}{
+		{
+			name:        "declined",
+			args:        []string{token, "github.com"},
+			setupFlags:  func() { setTokenInteractive = func() bool { return true } },
+			setupConfig: func(t *testing.T) string { t.Helper(); return createTestConfig(t, "") },
+			mockStdin:   "n\n",
+			expectedOutputs: []string{
+				"host argument looks like a token",
+			},
+			expectError:   true,
+			errorContains: "operation cancelled",
+		},
+		{
+			name:        "confirmed",
+			args:        []string{"github.com", "gitlab.com"},
+			setupFlags:  func() { setTokenInteractive = func() bool { return true } },
+			setupConfig: func(t *testing.T) string { t.Helper(); return createTestConfig(t, "") },
+			mockStdin:   "y\n",
+			expectedOutputs: []string{
+				"token argument looks like a host name",
+				"Successfully set token for github.com",
+			},
+		},
+		{
+			name:        "not interactive",
+			args:        []string{"github.com", "gitlab.com"},
+			setupFlags:  func() { setTokenInteractive = func() bool { return false } },
+			setupConfig: func(t *testing.T) string { t.Helper(); return createTestConfig(t, "") },
+			expectedOutputs: []string{
+				"token argument looks like a host name",
+				"Successfully set token for github.com",
+			},
+		},
+		{
+			name:        "forced",
+			args:        []string{"github.com", "gitlab.com"},
+			setupFlags:  func() { setTokenForce = true },
+			setupConfig: func(t *testing.T) string { t.Helper(); return createTestConfig(t, "") },
+			expectedOutputs: []string{
+				"token argument looks like a host name",
+				"Successfully set token for github.com",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			runSetTokenTest(t, tc)
+
+			if tc.name == "declined" {
+				cfg, err := nixconf.New(configPath)
+				if err != nil {
+					t.Fatalf("failed to open config: %v", err)
+				}
+
+				if hosts, _ := cfg.ListTokens(); len(hosts) != 0 {
+					t.Errorf("expected no tokens to be saved, got %v", hosts)
+				}
+			}
+		})
+	}
+}
+
+func TestSetTokenStoreConfig(t *testing.T) {
+	setupSetTokenTest(t)
+
+	token := "ghp_" + strings.Repeat("a", 36)
+
+	// nix.conf links into a fake Nix store, as home-manager generates it
+	linkStoreConfig := func(t *testing.T) string {
+		t.Helper()
+
+		configPath := createTestConfig(t, "")
+
+		store := t.TempDir()
+		t.Setenv("NIX_STORE_DIR", store)
+
+		storeFile := filepath.Join(store, "abc123-nix.conf")
+		if err := os.WriteFile(storeFile, []byte("experimental-features = flakes\n"), 0o444); err != nil {
+			t.Fatalf("failed to write store config: %v", err)
+		}
+
+		if err := os.Remove(configPath); err != nil {
+			t.Fatalf("failed to remove config: %v", err)
+		}
+
+		if err := os.Symlink(storeFile, configPath); err != nil {
+			t.Fatalf("failed to link config: %v", err)
+		}
+
+		return configPath
+	}
+
+	tests := []struct {
+		name            string
+		args            []string
+		setupFlags      func()
+		setupConfig     func(t *testing.T) string
+		setupProviders  func()
+		mockStdin       string
+		expectedOutputs []string
+		expectError     bool
+		errorContains   string
+	}{
+		{
+			name:        "writable copy confirmed",
+			args:        []string{"github.com", token},
+			setupConfig: linkStoreConfig,
+			mockStdin:   "y\n",
+			expectedOutputs: []string{
+				"in the read-only Nix store",
+				"with a writable copy.",
+				"NIX_USER_CONF_FILES=",
+				"Successfully set token for github.com",
+			},
+		},
+		{
+			name:            "writable copy declined",
+			args:            []string{"github.com", token},
+			setupConfig:     linkStoreConfig,
+			mockStdin:       "n\n",
+			expectedOutputs: []string{"in the read-only Nix store"},
+			expectError:     true,
+			errorContains:   "nix-auth print-include",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			runSetTokenTest(t, tc)
+
+			info, err := os.Lstat(configPath)
+			if err != nil {
+				t.Fatalf("failed to stat config: %v", err)
+			}
+
+			if linked := info.Mode()&os.ModeSymlink != 0; linked != tc.expectError {
+				t.Errorf("config is a link = %v, want %v", linked, tc.expectError)
+			}
+		})
+	}
+}
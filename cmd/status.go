@@ -1,12 +1,19 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
 
+	"github.com/numtide/nix-auth/internal/fsutil"
 	"github.com/numtide/nix-auth/internal/nixconf"
 	"github.com/numtide/nix-auth/internal/provider"
 	"github.com/numtide/nix-auth/internal/ui"
@@ -19,53 +26,445 @@ const (
 )
 
 var statusCmd = &cobra.Command{
-	Use:   "status [host...]",
-	Short: "Show the status of configured access tokens",
+	Use:         "status [host...]",
+	Annotations: map[string]string{hostArgAnnotation: "true"},
+	Short:       "Show the status of configured access tokens",
 	Long: `Display all configured access tokens and validate them with their respective providers.
 
 If no hosts are specified, all configured tokens are shown.
-If one or more hosts are specified, only tokens for those hosts are displayed.`,
+If one or more hosts are specified, only tokens for those hosts are displayed.
+
+Hosts are validated in parallel. By default results are printed in order;
+use --stream to print each host as soon as its validation finishes.
+
+Use --exclude to show all configured tokens except the given hosts, for example
+to skip a host that is known to be unreachable.
+
+Use --all-providers to also list every provider that claims each host, which
+helps diagnose misdetection when more than one provider matches.
+
+Use --validate=false to list tokens without any network access. Providers are
+then guessed from the host name instead of being detected, and tokens are not
+checked.
+
+Tokens are read from the system nix.conf, the user nix.conf and NIX_CONFIG.
+When several of them define a token for the same host, the one Nix uses is
+shown, following Nix's precedence (NIX_CONFIG over user over system config);
+use --verbose to also list the shadowed tokens.
+
+Use --fingerprint to show a short SHA-256 hash of each token instead of its
+masked prefix. It reveals no part of the token, and comparing it across
+machines or over time shows whether a token changed.
+
+Use --porcelain for scripts. It prints one line per host with the tab-separated
+fields host, provider, status and username, and no header. The status is one of
+valid, invalid, unknown, missing, error or unvalidated (with --validate=false),
+and the username is empty unless the token is valid. This format is stable and
+will not change across versions.
+
+Use --format prometheus for node-exporter's textfile collector. It prints the
+gauges nix_auth_token_valid, 1 for an accepted token and 0 for a rejected or
+missing one, and nix_auth_token_expiry_seconds, the Unix time a token with a
+known expiry expires, labeled with host and provider. Hosts that could not be
+checked have no nix_auth_token_valid sample.
+
+When run in a terminal, status offers to log in again for each host whose
+stored token was rejected, such as after the OAuth grant was revoked.
+
+If the token file still holds tokens but nix.conf no longer includes it, Nix
+ignores them and status warns about it; in a terminal it offers to add the
+include back.`,
 	RunE:         runStatus,
 	SilenceUsage: true,
 }
 
+var (
+	statusStream       bool
+	statusAllProviders bool
+	statusExclude      []string
+	statusValidate     bool
+	statusVerbose      bool
+	statusPorcelain    bool
+	statusRawScopes    bool
+	statusFingerprint  bool
+	statusJobs         int
+	statusTimeout      time.Duration
+	statusOutputFile   string
+	statusFormat       string
+)
+
+const (
+	// defaultStatusJobs is how many hosts status checks at once by default.
+	defaultStatusJobs = 8
+	// defaultStatusTimeout is the default deadline for checking all hosts;
+	// zero means none.
+	defaultStatusTimeout = 0
+)
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusStream, "stream", false, "Print each host as soon as it is validated instead of in order")
+	statusCmd.Flags().StringSliceVar(&statusExclude, "exclude", nil, "Comma-separated hosts to leave out when showing all tokens")
+	statusCmd.Flags().BoolVar(&statusAllProviders, "all-providers", false, "Run all detectors and show every provider that claims each host")
+	statusCmd.Flags().BoolVar(&statusValidate, "validate", true, "Detect providers and validate tokens over the network")
+	statusCmd.Flags().BoolVarP(&statusVerbose, "verbose", "v", false, "List tokens shadowed by a higher-precedence source")
+	statusCmd.Flags().BoolVar(&statusPorcelain, "porcelain", false, "Print one stable tab-separated line per host for scripts")
+	statusCmd.Flags().BoolVar(&statusRawScopes, "raw-scopes", false, "Also show the scopes exactly as the provider's API reports them")
+	statusCmd.Flags().BoolVar(&statusFingerprint, "fingerprint", false,
+		"Show a short SHA-256 hash of each token instead of its masked prefix, to compare tokens without revealing any part")
+	statusCmd.Flags().IntVar(&statusJobs, "jobs", defaultStatusJobs, "Maximum number of hosts to check at once")
+	statusCmd.Flags().DurationVar(&statusTimeout, "timeout", defaultStatusTimeout,
+		"Deadline for checking all hosts; hosts not done by then are reported as timed out (0 for no deadline)")
+	statusCmd.Flags().StringVar(&statusOutputFile, "output-file", "",
+		"Write the report to this file instead of stdout, replacing it atomically so readers never see a partial report")
+	statusCmd.Flags().StringVar(&statusFormat, "format", statusFormatText, "Output format: text or prometheus")
+}
+
 func runStatus(_ *cobra.Command, args []string) error {
-	cfg, err := nixconf.New(configPath)
+	cfg, err := openNixConfig()
 	if err != nil {
 		return fmt.Errorf("failed to initialize config: %w", err)
 	}
 
+	args, err = parseHosts(args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 && len(statusExclude) > 0 {
+		return fmt.Errorf("cannot combine host arguments with --exclude")
+	}
+
+	if !statusValidate && statusAllProviders {
+		return fmt.Errorf("--all-providers requires --validate")
+	}
+
+	if statusPorcelain && statusAllProviders {
+		return fmt.Errorf("--porcelain cannot be combined with --all-providers")
+	}
+
+	switch statusFormat {
+	case statusFormatText:
+	case statusFormatPrometheus:
+		if statusPorcelain || statusAllProviders {
+			return fmt.Errorf("--format %s cannot be combined with --porcelain or --all-providers", statusFormat)
+		}
+	default:
+		return fmt.Errorf("unknown --format %q (expected %s or %s)", statusFormat, statusFormatText, statusFormatPrometheus)
+	}
+
+	if statusJobs < 1 {
+		return fmt.Errorf("--jobs must be at least 1, got %d", statusJobs)
+	}
+
+	if statusTimeout < 0 {
+		return fmt.Errorf("--timeout must not be negative, got %s", statusTimeout)
+	}
+
 	hosts, err := getHostsToShow(cfg, args)
 	if err != nil {
 		return err
 	}
 
+	hosts = excludeHosts(hosts, statusExclude)
+
+	ctx := context.Background()
+
+	checkCtx := ctx
+	if statusTimeout > 0 {
+		var cancel context.CancelFunc
+
+		checkCtx, cancel = context.WithTimeout(ctx, statusTimeout)
+		defer cancel()
+	}
+
+	if statusOutputFile != "" {
+		var report bytes.Buffer
+
+		writeStatusReport(checkCtx, &report, hosts, args, cfg)
+
+		if err := fsutil.WriteFileAtomic(statusOutputFile, report.Bytes(), statusReportMode); err != nil {
+			return fmt.Errorf("failed to write status report: %w", err)
+		}
+
+		return nil
+	}
+
+	invalid := writeStatusReport(checkCtx, os.Stdout, hosts, args, cfg)
+
+	if !statusPorcelain && statusFormat == statusFormatText && statusInteractive() {
+		// Before re-authenticating, which would replace the token file
+		if err := offerTokenIncludeRestore(cfg); err != nil {
+			return err
+		}
+	}
+
+	// Re-authentication is interactive and must not be cut short by the deadline
+	if len(invalid) > 0 && statusInteractive() {
+		return offerReauthentication(ctx, invalid, cfg)
+	}
+
+	return nil
+}
+
+// writeStatusReport writes the status of hosts to out, as text, with
+// --porcelain or in the --format, and returns the hosts whose tokens were
+// rejected if they may be offered a new login.
+func writeStatusReport(ctx context.Context, out io.Writer, hosts, args []string, cfg *nixconf.NixConfig) []invalidHost {
+	if statusFormat == statusFormatPrometheus {
+		writePrometheusReport(ctx, out, hosts, cfg)
+		return nil
+	}
+
+	if statusPorcelain {
+		showHostStatuses(ctx, out, hosts, cfg, statusStream)
+		return nil
+	}
+
 	if len(hosts) == 0 {
-		return showNoTokensMessage(cfg)
+		showNoTokensMessage(out, cfg)
+		showOrphanedTokenFile(out, cfg)
+
+		return nil
 	}
 
-	showHeader(hosts, args, cfg)
+	showHeader(out, hosts, args, cfg)
 
-	ctx := context.Background()
+	invalid, timedOut := showHostStatuses(ctx, out, hosts, cfg, statusStream)
 
-	for i, host := range hosts {
-		if i > 0 {
-			fmt.Println()
+	if len(timedOut) > 0 {
+		_, _ = fmt.Fprintf(out, "\nTimed out after %s: %s (check that they are reachable, or raise --timeout)\n",
+			statusTimeout, strings.Join(timedOut, ", "))
+	}
+
+	showHostSuggestions(out, cfg, args)
+	showOrphanedTokenFile(out, cfg)
+
+	return invalid
+}
+
+// statusInteractive reports whether status may prompt the user (replaced in tests).
+var statusInteractive = ui.IsInteractive
+
+// hostOutput is the rendered status block for the host at index.
+type hostOutput struct {
+	index  int
+	output []byte
+	// invalid is the host's provider if its stored token was rejected.
+	invalid provider.Provider
+	// timedOut is set if the host was not checked before the deadline.
+	timedOut bool
+}
+
+// invalidHost is a host whose stored token was rejected by its provider.
+type invalidHost struct {
+	host string
+	prov provider.Provider
+}
+
+// offerReauthentication asks whether to log in again for each host with an
+// invalid token, and runs the login flow for those the user confirms.
+func offerReauthentication(ctx context.Context, invalid []invalidHost, cfg *nixconf.NixConfig) error {
+	for _, entry := range invalid {
+		confirm, err := ui.Confirm(fmt.Sprintf("\nThe token for %s is invalid. Re-authenticate now?", entry.host), false)
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
 		}
 
-		showHostStatus(ctx, host, cfg)
+		if !confirm {
+			continue
+		}
+
+		fmt.Printf("Authenticating with %s (%s)...\n", entry.prov.Name(), entry.host)
+
+		if err := authenticateAndSave(ctx, entry.prov, entry.host, cfg); err != nil {
+			return fmt.Errorf("re-authentication for %s failed: %w", entry.host, err)
+		}
 	}
 
 	return nil
 }
 
+// offerTokenIncludeRestore asks whether to include an orphaned token file from
+// the main config again, and adds the include if the user confirms.
+func offerTokenIncludeRestore(cfg *nixconf.NixConfig) error {
+	path, err := cfg.OrphanedTokenFile()
+	if err != nil {
+		return fmt.Errorf("failed to check the token file: %w", err)
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	confirm, err := ui.Confirm(fmt.Sprintf("\nInclude %s from %s again so Nix reads its tokens?", path, cfg.GetPath()), false)
+	if err != nil {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+
+	if !confirm {
+		return nil
+	}
+
+	if err := cfg.RestoreTokenInclude(); err != nil {
+		return fmt.Errorf("failed to include the token file: %w", err)
+	}
+
+	fmt.Printf("✓ %s now includes %s\n", cfg.GetPath(), path)
+
+	return nil
+}
+
+// showHostStatuses validates up to --jobs hosts concurrently and writes one block
+// per host. Blocks are written in host order unless stream is set, in which case
+// each block is written as soon as its host has been validated. It returns the
+// hosts whose tokens were rejected and the hosts that could not be checked
+// before ctx expired, both in host order.
+func showHostStatuses(
+	ctx context.Context, out io.Writer, hosts []string, cfg *nixconf.NixConfig, stream bool,
+) ([]invalidHost, []string) {
+	results := make(chan hostOutput, len(hosts))
+
+	go forEachHost(ctx, hosts, statusJobs, func(i int, host string) {
+		results <- checkHost(ctx, i, host, cfg)
+	})
+
+	invalidByIndex := make([]provider.Provider, len(hosts))
+	timedOutByIndex := make([]bool, len(hosts))
+
+	pending := make(map[int][]byte, len(hosts))
+	next := 0
+
+	for written := 0; written < len(hosts); {
+		result := <-results
+		invalidByIndex[result.index] = result.invalid
+		timedOutByIndex[result.index] = result.timedOut
+
+		if stream {
+			writeHostBlock(out, result.output, written)
+			written++
+
+			continue
+		}
+
+		// Flush every block that is now contiguous with what was already written
+		pending[result.index] = result.output
+		for block, ok := pending[next]; ok; block, ok = pending[next] {
+			delete(pending, next)
+			writeHostBlock(out, block, written)
+			written++
+			next++
+		}
+	}
+
+	var (
+		invalid  []invalidHost
+		timedOut []string
+	)
+
+	for i, prov := range invalidByIndex {
+		if prov != nil {
+			invalid = append(invalid, invalidHost{host: hosts[i], prov: prov})
+		}
+
+		if timedOutByIndex[i] {
+			timedOut = append(timedOut, hosts[i])
+		}
+	}
+
+	return invalid, timedOut
+}
+
+// forEachHost calls fn for each of hosts concurrently, at most jobs at a time,
+// and returns once every call has returned. Hosts still waiting for their turn
+// when ctx expires are passed to fn right away, so fn must check ctx to report
+// them as timed out.
+func forEachHost(ctx context.Context, hosts []string, jobs int, fn func(index int, host string)) {
+	slots := make(chan struct{}, jobs)
+
+	var wg sync.WaitGroup
+
+	for i, host := range hosts {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+			}
+
+			fn(i, host)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// checkHost renders the status block of the host at index. A host that is not
+// done when ctx expires is reported as timed out instead, since the errors it
+// ran into say nothing about its token.
+func checkHost(ctx context.Context, index int, host string, cfg *nixconf.NixConfig) hostOutput {
+	if ctx.Err() != nil {
+		return timedOutHostOutput(index, host)
+	}
+
+	var (
+		buf     bytes.Buffer
+		invalid provider.Provider
+	)
+
+	switch {
+	case statusPorcelain:
+		showPorcelainHostStatus(ctx, &buf, host, cfg)
+	case statusValidate:
+		invalid = showHostStatus(ctx, &buf, host, cfg)
+	default:
+		showOfflineHostStatus(&buf, host, cfg)
+	}
+
+	if ctx.Err() != nil {
+		return timedOutHostOutput(index, host)
+	}
+
+	return hostOutput{index: index, output: buf.Bytes(), invalid: invalid}
+}
+
+// timedOutHostOutput is the status block of a host that was not checked in time.
+// The --porcelain format reports it as an error.
+func timedOutHostOutput(index int, host string) hostOutput {
+	var buf bytes.Buffer
+
+	if statusPorcelain {
+		_, _ = fmt.Fprintln(&buf, strings.Join([]string{host, provider.GuessFromHost(host), porcelainError, ""}, "\t"))
+	} else {
+		w := tabwriter.NewWriter(&buf, 0, 0, tabPadding, ' ', 0)
+		_, _ = fmt.Fprintf(w, "%s\n", host)
+		_, _ = fmt.Fprintf(w, "  Status\t⏱ Timed out\n")
+		_ = w.Flush()
+	}
+
+	return hostOutput{index: index, output: buf.Bytes(), timedOut: true}
+}
+
+// writeHostBlock writes a host's status block, separated from the previous one by a blank line
+// except in the line-oriented --porcelain format.
+func writeHostBlock(out io.Writer, block []byte, position int) {
+	if position > 0 && !statusPorcelain {
+		_, _ = fmt.Fprintln(out)
+	}
+
+	_, _ = out.Write(block)
+}
+
 // getHostsToShow returns the list of hosts to display status for.
 func getHostsToShow(cfg *nixconf.NixConfig, args []string) ([]string, error) {
 	if len(args) > 0 {
 		return args, nil
 	}
 
-	hosts, err := cfg.ListTokens()
+	hosts, err := cfg.ListAllTokens()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list tokens: %w", err)
 	}
@@ -73,50 +472,169 @@ func getHostsToShow(cfg *nixconf.NixConfig, args []string) ([]string, error) {
 	return hosts, nil
 }
 
+// excludeHosts returns hosts without any of the excluded ones.
+func excludeHosts(hosts, excluded []string) []string {
+	if len(excluded) == 0 {
+		return hosts
+	}
+
+	skip := make(map[string]bool, len(excluded))
+	for _, host := range excluded {
+		skip[normalizeHost(resolveAlias(host))] = true
+	}
+
+	result := make([]string, 0, len(hosts))
+
+	for _, host := range hosts {
+		if !skip[strings.ToLower(host)] {
+			result = append(result, host)
+		}
+	}
+
+	return result
+}
+
+// showHostSuggestions suggests a configured host for each requested host that has
+// no token but closely matches one, which is most likely a typo.
+func showHostSuggestions(out io.Writer, cfg *nixconf.NixConfig, args []string) {
+	for _, host := range args {
+		if token, _, err := cfg.LookupToken(host); err != nil || token != "" {
+			continue
+		}
+
+		if suggestion := suggestConfiguredHost(cfg, host); suggestion != "" {
+			_, _ = fmt.Fprintf(out, "\nNo token configured for %s; did you mean %s?\n", host, suggestion)
+		}
+	}
+}
+
 // showNoTokensMessage displays a message when no tokens are configured.
-func showNoTokensMessage(cfg *nixconf.NixConfig) error {
-	fmt.Println("No access tokens configured.")
-	fmt.Printf("Config file: %s\n", cfg.GetPath())
-	fmt.Println("\nRun 'nix-auth login' to add a token.")
+func showNoTokensMessage(out io.Writer, cfg *nixconf.NixConfig) {
+	_, _ = fmt.Fprintln(out, "No access tokens configured.")
+	_, _ = fmt.Fprintf(out, "Config file: %s\n", cfg.GetPath())
+	_, _ = fmt.Fprintln(out, "\nRun 'nix-auth login' to add a token.")
+}
 
-	return nil
+// showOrphanedTokenFile warns when the token file holds tokens that Nix does not
+// read because the main config no longer includes it. Status does not list them.
+func showOrphanedTokenFile(out io.Writer, cfg *nixconf.NixConfig) {
+	path, err := cfg.OrphanedTokenFile()
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "\nWarning: failed to check the token file: %v\n", err)
+		return
+	}
+
+	if path == "" {
+		return
+	}
+
+	_, _ = fmt.Fprintf(out, "\nWarning: %s has tokens, but %s does not include it, so Nix ignores them.\n", path, cfg.GetPath())
+	_, _ = fmt.Fprintf(out, "Add '!include %s' to %s, or run 'nix-auth status' in a terminal to add it.\n",
+		filepath.Base(path), cfg.GetPath())
 }
 
 // showHeader displays the header for the status output.
-func showHeader(hosts []string, args []string, cfg *nixconf.NixConfig) {
-	if len(args) > 0 {
-		fmt.Printf("Access Tokens (showing %d hosts from %s)\n\n", len(hosts), cfg.GetPath())
+func showHeader(out io.Writer, hosts []string, args []string, cfg *nixconf.NixConfig) {
+	if len(args) > 0 || len(statusExclude) > 0 {
+		_, _ = fmt.Fprintf(out, "Access Tokens (showing %d hosts from %s)\n\n", len(hosts), cfg.GetPath())
 	} else {
-		fmt.Printf("Access Tokens (%d configured in %s)\n\n", len(hosts), cfg.GetPath())
+		_, _ = fmt.Fprintf(out, "Access Tokens (%d configured in %s)\n\n", len(hosts), cfg.GetPath())
 	}
 }
 
 // showHostStatus displays the status information for a single host.
-func showHostStatus(ctx context.Context, host string, cfg *nixconf.NixConfig) {
-	fmt.Printf("%s\n", host)
+// It returns the host's provider if the stored token is invalid and could be
+// replaced by logging in again, or nil otherwise.
+func showHostStatus(ctx context.Context, out io.Writer, host string, cfg *nixconf.NixConfig) provider.Provider {
+	_, _ = fmt.Fprintf(out, "%s\n", host)
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, tabPadding, ' ', 0)
+	w := tabwriter.NewWriter(out, 0, 0, tabPadding, ' ', 0)
 	defer func() { _ = w.Flush() }()
 
-	prov, err := provider.Detect(ctx, host, "")
+	prov := provider.DetectCached(ctx, providerConfig(host))
+
+	providerName := provider.DisplayName(prov)
+
+	if statusAllProviders {
+		showAllDetectedProviders(ctx, w, host)
+	}
+
+	entries, err := cfg.TokenEntries(host)
 	if err != nil {
-		panic(fmt.Sprintf("impossible: Detect returned error for host %s: %v", host, err))
+		showTokenError(w, providerName, err)
+		return nil
+	}
+
+	if len(entries) == 0 {
+		showNoTokenConfigured(w, providerName)
+		return nil
+	}
+
+	if !entries[0].Effective() {
+		showOverriddenTokens(w, providerName, entries)
+		return nil
+	}
+
+	status := showTokenDetails(ctx, w, prov, host, providerName, entries)
+	if status != provider.ValidationStatusInvalid || entries[0].Source.ReadOnly() {
+		return nil
 	}
 
-	providerName := prov.Name()
+	return prov
+}
+
+// showOfflineHostStatus displays a host's token without detection or validation.
+func showOfflineHostStatus(out io.Writer, host string, cfg *nixconf.NixConfig) {
+	_, _ = fmt.Fprintf(out, "%s\n", host)
+
+	w := tabwriter.NewWriter(out, 0, 0, tabPadding, ' ', 0)
+	defer func() { _ = w.Flush() }()
+
+	providerName := provider.GuessFromHost(host) + " (guessed from host)"
 
-	token, err := cfg.GetToken(host)
+	entries, err := cfg.TokenEntries(host)
 	if err != nil {
 		showTokenError(w, providerName, err)
 		return
 	}
 
-	if token == "" {
+	if len(entries) == 0 {
 		showNoTokenConfigured(w, providerName)
 		return
 	}
 
-	showTokenDetails(ctx, w, prov, providerName, token)
+	if !entries[0].Effective() {
+		showOverriddenTokens(w, providerName, entries)
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "  Provider\t%s\n", providerName)
+	_, _ = fmt.Fprintf(w, "  Token\t%s\n", displayToken(entries[0].Token))
+
+	showTokenSource(w, entries)
+
+	_, _ = fmt.Fprintf(w, "  Status\t- Not validated\n")
+}
+
+// showAllDetectedProviders displays every provider that claims the host.
+func showAllDetectedProviders(ctx context.Context, w *tabwriter.Writer, host string) {
+	matches, err := provider.DetectAll(ctx, host)
+
+	names := make([]string, 0, len(matches))
+	for _, match := range matches {
+		names = append(names, match.Name())
+	}
+
+	detected := "none"
+	if len(names) > 0 {
+		detected = strings.Join(names, ", ")
+	}
+
+	_, _ = fmt.Fprintf(w, "  Detected\t%s\n", detected)
+
+	if err != nil {
+		_, _ = fmt.Fprintf(w, "  Detect errors\t%v\n", strings.ReplaceAll(err.Error(), "\n", "; "))
+	}
 }
 
 // showTokenError displays an error when getting a token fails.
@@ -131,58 +649,204 @@ func showNoTokenConfigured(w *tabwriter.Writer, providerName string) {
 	_, _ = fmt.Fprintf(w, "  Status\t✗ No token configured\n")
 }
 
-// showTokenDetails displays detailed information about a token.
-func showTokenDetails(ctx context.Context, w *tabwriter.Writer, prov provider.Provider, providerName, token string) {
+// showOverriddenTokens displays the tokens for a host that Nix ignores because
+// a source of higher precedence sets access-tokens without the host.
+func showOverriddenTokens(w *tabwriter.Writer, providerName string, entries []nixconf.TokenEntry) {
 	_, _ = fmt.Fprintf(w, "  Provider\t%s\n", providerName)
+	_, _ = fmt.Fprintf(w, "  Token\t%s\n", displayToken(entries[0].Token))
+	_, _ = fmt.Fprintf(w, "  Source\tfrom %s\n", entries[0].Origin())
+
+	showShadowedTokens(w, entries[1:])
+
+	_, _ = fmt.Fprintf(w, "  Status\t✗ Not used by Nix: access-tokens in %s replaces it\n", entries[0].OverriddenBy)
+}
+
+// showTokenDetails displays detailed information about the effective token, the
+// first of entries, and returns its validation status.
+func showTokenDetails(
+	ctx context.Context, w *tabwriter.Writer, prov provider.Provider, host, providerName string, entries []nixconf.TokenEntry,
+) provider.ValidationStatus {
+	token := entries[0].Token
+
+	_, _ = fmt.Fprintf(w, "  Provider\t%s\n", providerName)
+
+	warnPrivateHost(ctx, os.Stderr, host)
+
+	validationStatus, statusStr := getValidationStatus(ctx, prov, token, w)
+
+	_, _ = fmt.Fprintf(w, "  Token\t%s\n", displayToken(token))
 
-	statusStr := getValidationStatus(ctx, prov, token, w)
+	showTokenSource(w, entries)
 
-	maskedToken := ui.MaskToken(token)
-	_, _ = fmt.Fprintf(w, "  Token\t%s\n", maskedToken)
+	if validationStatus == provider.ValidationStatusValid {
+		showTokenInfo(ctx, w, prov, token)
+	}
+
+	if !entries[0].Source.ReadOnly() {
+		showTokenExpiry(w, host)
+	}
 
 	showTokenScopes(ctx, w, prov, token)
 
+	if statusRawScopes {
+		showRawTokenScopes(ctx, w, prov, token)
+	}
+
 	_, _ = fmt.Fprintf(w, "  Status\t%s\n", statusStr)
+
+	return validationStatus
+}
+
+// showTokenSource notes where the effective token, the first of entries, came from
+// when nix-auth cannot modify it or when it shadows tokens from other sources.
+// Shadowed tokens are listed individually with --verbose.
+func showTokenSource(w *tabwriter.Writer, entries []nixconf.TokenEntry) {
+	effective := entries[0]
+	shadowed := entries[1:]
+
+	switch {
+	case effective.Source.ReadOnly():
+		_, _ = fmt.Fprintf(w, "  Source\tfrom %s (read-only)\n", effective.Origin())
+	case len(shadowed) > 0:
+		_, _ = fmt.Fprintf(w, "  Source\tfrom %s\n", effective.Origin())
+	}
+
+	showShadowedTokens(w, shadowed)
+}
+
+// showShadowedTokens notes the tokens Nix does not use for a host, listing
+// them individually with --verbose.
+func showShadowedTokens(w *tabwriter.Writer, shadowed []nixconf.TokenEntry) {
+	if len(shadowed) == 0 {
+		return
+	}
+
+	if !statusVerbose {
+		_, _ = fmt.Fprintf(w, "  Shadowed\t%d other token(s), use --verbose to list\n", len(shadowed))
+		return
+	}
+
+	for _, entry := range shadowed {
+		_, _ = fmt.Fprintf(w, "  Shadowed\t%s from %s\n", displayToken(entry.Token), entry.Origin())
+	}
+}
+
+// displayToken returns how status shows token: masked, or as a fingerprint
+// with --fingerprint.
+func displayToken(token string) string {
+	if statusFingerprint {
+		return ui.TokenFingerprint(token)
+	}
+
+	return ui.MaskToken(token)
+}
+
+// showTokenExpiry shows when the token saved for host expires, if login recorded it.
+func showTokenExpiry(w *tabwriter.Writer, host string) {
+	expiresAt := tokenExpiry(host)
+	if expiresAt.IsZero() {
+		return
+	}
+
+	remaining := time.Until(expiresAt)
+	if remaining <= 0 {
+		_, _ = fmt.Fprintf(w, "  Expires\texpired %s\n", expiresAt.Local().Format(time.RFC1123))
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "  Expires\tin %s (%s)\n", formatDuration(remaining), expiresAt.Local().Format(time.RFC1123))
+}
+
+// showTokenInfo shows the name and creation date of token, for providers whose
+// API reports them. Failing to get them is not worth a row of its own.
+func showTokenInfo(ctx context.Context, w *tabwriter.Writer, prov provider.Provider, token string) {
+	info, err := provider.GetTokenInfo(ctx, prov, token)
+	if err != nil {
+		return
+	}
+
+	if info.Name != "" {
+		_, _ = fmt.Fprintf(w, "  Name\t%s\n", info.Name)
+	}
+
+	if !info.CreatedAt.IsZero() {
+		_, _ = fmt.Fprintf(w, "  Created\t%s\n", info.CreatedAt.Local().Format(time.DateOnly))
+	}
 }
 
-// getValidationStatus validates a token and returns the status string.
-func getValidationStatus(ctx context.Context, prov provider.Provider, token string, w *tabwriter.Writer) string {
+// getValidationStatus validates a token and returns the status and its display string.
+func getValidationStatus(ctx context.Context, prov provider.Provider, token string, w *tabwriter.Writer) (provider.ValidationStatus, string) {
 	validationStatus, validationErr := prov.ValidateToken(ctx, token)
 
+	// An unreachable host, e.g. on a private network without VPN, says nothing about the token
+	if provider.IsNetworkError(validationErr) {
+		return provider.ValidationStatusUnknown, fmt.Sprintf("⚠ Unreachable (network) - %v", validationErr)
+	}
+
 	switch validationStatus {
 	case provider.ValidationStatusValid:
 		showUserInfo(ctx, prov, token, w)
-		return "✓ Valid"
+		return validationStatus, "✓ Valid"
 	case provider.ValidationStatusInvalid:
 		if validationErr != nil {
-			return fmt.Sprintf("✗ Invalid - %v", validationErr)
+			return validationStatus, fmt.Sprintf("✗ Invalid - %v", validationErr)
 		}
 
-		return "✗ Invalid"
+		return validationStatus, "✗ Invalid"
 	case provider.ValidationStatusUnknown:
-		return "⚠ Unknown (unverified)"
+		return validationStatus, "⚠ Unknown (unverified)"
 	default:
-		return "⚠ Unknown"
+		return validationStatus, "⚠ Unknown"
 	}
 }
 
-// showUserInfo displays user information if available.
+// showUserInfo displays the user of a valid token. If it cannot be retrieved,
+// for example because SSO guards the user endpoint, the reason is shown
+// instead, so that it is clear the user was looked up.
 func showUserInfo(ctx context.Context, prov provider.Provider, token string, w *tabwriter.Writer) {
 	username, fullName, err := prov.GetUserInfo(ctx, token)
-	if err == nil {
-		if fullName != "" {
-			_, _ = fmt.Fprintf(w, "  User\t%s (%s)\n", username, fullName)
-		} else {
-			_, _ = fmt.Fprintf(w, "  User\t%s\n", username)
-		}
+
+	switch {
+	case err != nil:
+		_, _ = fmt.Fprintf(w, "  User\t(unavailable: %v)\n", err)
+	case fullName != "":
+		_, _ = fmt.Fprintf(w, "  User\t%s (%s)\n", username, fullName)
+	default:
+		_, _ = fmt.Fprintf(w, "  User\t%s\n", username)
 	}
 }
 
-// showTokenScopes displays the token scopes.
+// showRawTokenScopes displays the token scopes exactly as the provider's API
+// reported them, quoted so that empty values and stray whitespace are visible.
+func showRawTokenScopes(ctx context.Context, w *tabwriter.Writer, prov provider.Provider, token string) {
+	raw, err := provider.GetRawTokenScopes(ctx, prov, token)
+
+	var notExposed *provider.ScopesNotExposedError
+
+	switch {
+	case errors.Is(err, provider.ErrRawScopesUnsupported):
+		_, _ = fmt.Fprintf(w, "  Raw scopes\tN/A (not reported by %s)\n", prov.Name())
+	case errors.As(err, &notExposed):
+		_, _ = fmt.Fprintf(w, "  Raw scopes\tN/A (%v)\n", err)
+	case err != nil:
+		_, _ = fmt.Fprintf(w, "  Raw scopes\tUnable to retrieve: %v\n", err)
+	default:
+		_, _ = fmt.Fprintf(w, "  Raw scopes\t%q\n", raw)
+	}
+}
+
+// showTokenScopes displays the token scopes and, for providers with a
+// container registry, whether they let the token use it.
 func showTokenScopes(ctx context.Context, w *tabwriter.Writer, prov provider.Provider, token string) {
 	scopes, err := prov.GetTokenScopes(ctx, token)
 
+	var notExposed *provider.ScopesNotExposedError
+
 	switch {
+	case errors.As(err, &notExposed) && notExposed.TokenType != "":
+		_, _ = fmt.Fprintf(w, "  Scopes\tN/A (%s)\n", notExposed.TokenType)
+	case errors.As(err, &notExposed):
+		_, _ = fmt.Fprintf(w, "  Scopes\tN/A\n")
 	case err != nil:
 		_, _ = fmt.Fprintf(w, "  Scopes\tUnable to retrieve\n")
 	case len(scopes) == 0:
@@ -190,4 +854,25 @@ func showTokenScopes(ctx context.Context, w *tabwriter.Writer, prov provider.Pro
 	default:
 		_, _ = fmt.Fprintf(w, "  Scopes\t%s\n", strings.Join(scopes, ", "))
 	}
+
+	if err != nil {
+		return
+	}
+
+	registry, ok := provider.RegistryAccess(prov, scopes)
+	if !ok {
+		return
+	}
+
+	if len(registry) == 0 {
+		_, _ = fmt.Fprintf(w, "  Registry\tNo access (log in with --scopes +read_registry to pull images)\n")
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "  Registry\t✓ %s\n", strings.Join(registry, ", "))
 }
+
+// statusReportMode is the permissions of a report written with --output-file.
+// Reports only contain masked tokens, so other users, such as a monitoring
+// service, may read them.
+const statusReportMode = 0o644
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/provider"
+)
+
+// Porcelain status values. Like the field order, these are part of the stable
+// --porcelain format and must not change.
+const (
+	porcelainValid       = "valid"
+	porcelainInvalid     = "invalid"
+	porcelainUnknown     = "unknown"
+	porcelainMissing     = "missing"
+	porcelainError       = "error"
+	porcelainUnvalidated = "unvalidated"
+)
+
+// showPorcelainHostStatus writes the stable one-line status of host:
+//
+//	host<TAB>provider<TAB>status<TAB>username
+//
+// The username is empty unless the token was validated successfully.
+func showPorcelainHostStatus(ctx context.Context, out io.Writer, host string, cfg *nixconf.NixConfig) {
+	providerName, status, username := porcelainFields(ctx, host, cfg)
+
+	fields := []string{host, providerName, status, username}
+	for i, field := range fields {
+		fields[i] = strings.Map(porcelainSafe, field)
+	}
+
+	_, _ = fmt.Fprintln(out, strings.Join(fields, "\t"))
+}
+
+// porcelainFields determines the provider, status and username of host.
+func porcelainFields(ctx context.Context, host string, cfg *nixconf.NixConfig) (string, string, string) {
+	if !statusValidate {
+		providerName := provider.GuessFromHost(host)
+
+		token, _, err := cfg.LookupToken(host)
+
+		switch {
+		case err != nil:
+			return providerName, porcelainError, ""
+		case token == "":
+			return providerName, porcelainMissing, ""
+		default:
+			return providerName, porcelainUnvalidated, ""
+		}
+	}
+
+	prov := provider.DetectCached(ctx, providerConfig(host))
+
+	token, _, err := cfg.LookupToken(host)
+	if err != nil {
+		return prov.Name(), porcelainError, ""
+	}
+
+	if token == "" {
+		return prov.Name(), porcelainMissing, ""
+	}
+
+	warnPrivateHost(ctx, os.Stderr, host)
+
+	validationStatus, validationErr := prov.ValidateToken(ctx, token)
+	if provider.IsNetworkError(validationErr) {
+		return prov.Name(), porcelainUnknown, ""
+	}
+
+	switch validationStatus {
+	case provider.ValidationStatusValid:
+		username, _, err := prov.GetUserInfo(ctx, token)
+		if err != nil {
+			username = ""
+		}
+
+		return prov.Name(), porcelainValid, username
+	case provider.ValidationStatusInvalid:
+		return prov.Name(), porcelainInvalid, ""
+	default:
+		return prov.Name(), porcelainUnknown, ""
+	}
+}
+
+// porcelainSafe replaces characters that would break the line-and-tab format.
+func porcelainSafe(r rune) rune {
+	if r == '\t' || r == '\n' || r == '\r' {
+		return ' '
+	}
+
+	return r
+}
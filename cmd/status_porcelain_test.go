@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/provider"
+)
+
+func TestRunStatusPorcelain(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+	originalPorcelain := statusPorcelain
+	originalValidate := statusValidate
+
+	defer func() {
+		configPath = originalConfigPath
+		statusPorcelain = originalPorcelain
+		statusValidate = originalValidate
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	statusPorcelain = true
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	setupMockGitHubProvider(true)
+	setupMockGitLabProvider(false)
+
+	tests := []struct {
+		name     string
+		config   string
+		args     []string
+		validate bool
+		expected string
+	}{
+		{
+			name:     "configured tokens",
+			config:   "access-tokens = github.com=gho_testtoken123456789 gitlab.com=glpat-testtoken123456\n",
+			validate: true,
+			expected: "github.com\tgithub\tvalid\ttestuser\ngitlab.com\tgitlab\tinvalid\t\n",
+		},
+		{
+			name:     "host without token",
+			config:   "",
+			args:     []string{"example.org"},
+			validate: true,
+			expected: "example.org\tunknown\tmissing\t\n",
+		},
+		{
+			name:     "without validation",
+			config:   "access-tokens = github.com=gho_testtoken123456789\n",
+			validate: false,
+			expected: "github.com\tgithub\tunvalidated\t\n",
+		},
+		{
+			name:     "no tokens prints nothing",
+			config:   "",
+			validate: true,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath = createTestConfig(t, tt.config)
+			statusValidate = tt.validate
+
+			var runErr error
+
+			output := captureStdout(t, func() {
+				runErr = runStatus(nil, tt.args)
+			})
+
+			if runErr != nil {
+				t.Fatalf("unexpected error: %v", runErr)
+			}
+
+			if output != tt.expected {
+				t.Errorf("output = %q, want %q", output, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPorcelainSafe(t *testing.T) {
+	if got := strings.Map(porcelainSafe, "Jane\tDoe\n"); got != "Jane Doe " {
+		t.Errorf("porcelainSafe mapped to %q", got)
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/provider"
+)
+
+// Values of status --format.
+const (
+	statusFormatText       = "text"
+	statusFormatPrometheus = "prometheus"
+)
+
+// prometheusLabelEscaper escapes a label value for the Prometheus text format.
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// hostMetrics is what status reports to Prometheus about one host.
+type hostMetrics struct {
+	host     string
+	provider string
+	// status is one of the porcelain status values.
+	status string
+	// expiresAt is the Unix time the token expires, or 0 if not known.
+	expiresAt int64
+}
+
+// writePrometheusReport writes the status of hosts in the Prometheus text
+// exposition format, for node-exporter's textfile collector. Each host gets
+// nix_auth_token_valid if its token was checked and
+// nix_auth_token_expiry_seconds if its expiry was recorded.
+func writePrometheusReport(ctx context.Context, out io.Writer, hosts []string, cfg *nixconf.NixConfig) {
+	metrics := collectHostMetrics(ctx, hosts, cfg)
+
+	_, _ = fmt.Fprintln(out, "# HELP nix_auth_token_valid Whether the provider accepted the token for the host (1) or not (0).")
+	_, _ = fmt.Fprintln(out, "# TYPE nix_auth_token_valid gauge")
+
+	for _, m := range metrics {
+		switch m.status {
+		case porcelainValid:
+			_, _ = fmt.Fprintf(out, "nix_auth_token_valid%s 1\n", prometheusLabels(m))
+		case porcelainInvalid, porcelainMissing:
+			_, _ = fmt.Fprintf(out, "nix_auth_token_valid%s 0\n", prometheusLabels(m))
+		}
+	}
+
+	_, _ = fmt.Fprintln(out, "# HELP nix_auth_token_expiry_seconds Unix time at which the token for the host expires.")
+	_, _ = fmt.Fprintln(out, "# TYPE nix_auth_token_expiry_seconds gauge")
+
+	for _, m := range metrics {
+		if m.expiresAt != 0 {
+			_, _ = fmt.Fprintf(out, "nix_auth_token_expiry_seconds%s %d\n", prometheusLabels(m), m.expiresAt)
+		}
+	}
+}
+
+// collectHostMetrics checks up to --jobs hosts at once and returns their
+// metrics in host order. A host not checked before ctx expires is reported as
+// an error, like with --porcelain.
+func collectHostMetrics(ctx context.Context, hosts []string, cfg *nixconf.NixConfig) []hostMetrics {
+	metrics := make([]hostMetrics, len(hosts))
+
+	forEachHost(ctx, hosts, statusJobs, func(i int, host string) {
+		metrics[i] = hostMetrics{host: host, provider: provider.GuessFromHost(host), status: porcelainError}
+
+		if expiresAt := tokenExpiry(host); !expiresAt.IsZero() {
+			metrics[i].expiresAt = expiresAt.Unix()
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		providerName, status, _ := porcelainFields(ctx, host, cfg)
+		if ctx.Err() != nil {
+			return
+		}
+
+		metrics[i].provider = providerName
+		metrics[i].status = status
+	})
+
+	return metrics
+}
+
+// prometheusLabels renders the host and provider labels of m.
+func prometheusLabels(m hostMetrics) string {
+	return fmt.Sprintf(`{host="%s",provider="%s"}`,
+		prometheusLabelEscaper.Replace(m.host), prometheusLabelEscaper.Replace(m.provider))
+}
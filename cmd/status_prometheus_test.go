@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/numtide/nix-auth/internal/tokenmeta"
+)
+
+func TestRunStatusPrometheus(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+	originalFormat := statusFormat
+
+	defer func() {
+		configPath = originalConfigPath
+		statusFormat = originalFormat
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	statusFormat = statusFormatPrometheus
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	setupMockGitHubProvider(true)
+	setupMockGitLabProvider(false)
+
+	configPath = createTestConfig(t, "access-tokens = github.com=gho_testtoken123456789 gitlab.com=glpat-testtoken123456\n")
+
+	expiresAt := time.Unix(1893456000, 0)
+	if err := updateTokenMeta("github.com", func(m *tokenmeta.Metadata) { m.ExpiresAt = expiresAt }); err != nil {
+		t.Fatalf("failed to record expiry: %v", err)
+	}
+
+	var runErr error
+
+	output := captureStdout(t, func() {
+		runErr = runStatus(nil, []string{"github.com", "gitlab.com", "example.org"})
+	})
+
+	if runErr != nil {
+		t.Fatalf("unexpected error: %v", runErr)
+	}
+
+	expected := `# HELP nix_auth_token_valid Whether the provider accepted the token for the host (1) or not (0).
+# TYPE nix_auth_token_valid gauge
+nix_auth_token_valid{host="github.com",provider="github"} 1
+nix_auth_token_valid{host="gitlab.com",provider="gitlab"} 0
+nix_auth_token_valid{host="example.org",provider="unknown"} 0
+# HELP nix_auth_token_expiry_seconds Unix time at which the token for the host expires.
+# TYPE nix_auth_token_expiry_seconds gauge
+nix_auth_token_expiry_seconds{host="github.com",provider="github"} 1893456000
+`
+	if output != expected {
+		t.Errorf("output = %q, want %q", output, expected)
+	}
+
+	statusFormat = "json"
+	if err := runStatus(nil, nil); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+func TestPrometheusLabels(t *testing.T) {
+	got := prometheusLabels(hostMetrics{host: `a"b\c`, provider: "x\ny"})
+	if want := `{host="a\"b\\c",provider="x\ny"}`; got != want {
+		t.Errorf("prometheusLabels = %s, want %s", got, want)
+	}
+}
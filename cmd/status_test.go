@@ -3,14 +3,21 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"text/tabwriter"
+	"time"
 
+	"github.com/numtide/nix-auth/internal/nixconf"
 	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/numtide/nix-auth/internal/ui"
 )
 
 // captureStatusOutput captures the stdout output from running the status command.
@@ -100,6 +107,11 @@ func setupMockGitLabProvider(valid bool) {
 func createTestConfig(t *testing.T, content string) string {
 	t.Helper()
 	tmpDir := t.TempDir()
+
+	// Keep the host's system nix.conf out of the tests
+	t.Setenv("NIX_CONF_DIR", t.TempDir())
+	// Keep recorded token metadata out of the user's state directory
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
 	configFile := filepath.Join(tmpDir, "nix.conf")
 
 	err := os.WriteFile(configFile, []byte(content), 0o600)
@@ -213,6 +225,116 @@ func TestRunStatus(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "fine-grained token without scopes header",
+			setupConfig: func(t *testing.T) string {
+				t.Helper()
+				return createTestConfig(t, "access-tokens = github.com=github_pat_finegrained123456\n")
+			},
+			setupProviders: func() {
+				provider.SetRegistry(make(map[string]*provider.Registration))
+				provider.RegisterProvider("github", provider.Registration{
+					Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+						return &mockStatusProvider{
+							name:      "github",
+							host:      host,
+							valid:     true,
+							scopesErr: &provider.ScopesNotExposedError{TokenType: "fine-grained"},
+							username:  "ghuser",
+						}, nil
+					},
+				})
+			},
+			expectedOutput: []string{
+				"Scopes    N/A (fine-grained)",
+				"Status    ✓ Valid",
+			},
+			expectError: false,
+		},
+		{
+			name: "token from NIX_CONFIG",
+			setupConfig: func(t *testing.T) string {
+				t.Helper()
+				t.Setenv("NIX_CONFIG", "access-tokens = github.com=gho_envtoken123456789")
+				return createTestConfig(t, "")
+			},
+			setupProviders: func() {
+				provider.SetRegistry(make(map[string]*provider.Registration))
+				setupMockGitHubProvider(true)
+			},
+			expectedOutput: []string{
+				"Access Tokens (1 configured",
+				"github.com",
+				"Token     gho_******89",
+				"Source    from NIX_CONFIG (read-only)",
+				"Status    ✓ Valid",
+			},
+			expectError: false,
+		},
+		{
+			name: "unreachable host",
+			setupConfig: func(t *testing.T) string {
+				t.Helper()
+				return createTestConfig(t, "access-tokens = git.internal.example.com=glpat-internal123456\n")
+			},
+			setupProviders: func() {
+				provider.SetRegistry(make(map[string]*provider.Registration))
+				provider.RegisterProvider("gitlab", provider.Registration{
+					Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+						return &mockStatusProvider{
+							name: "gitlab",
+							host: host,
+							validError: fmt.Errorf("failed to validate token: %w",
+								&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: no route to host")}),
+						}, nil
+					},
+				})
+			},
+			expectedOutput: []string{
+				"Status    ⚠ Unreachable (network) - failed to validate token: dial tcp: connect: no route to host",
+			},
+			expectError: false,
+		},
+		{
+			name: "host unreachable during detection",
+			setupConfig: func(t *testing.T) string {
+				t.Helper()
+				return createTestConfig(t, "access-tokens = git.internal.example.com=glpat-internal123456\n")
+			},
+			setupProviders: func() {
+				provider.SetRegistry(make(map[string]*provider.Registration))
+				provider.RegisterProvider("gitlab", provider.Registration{
+					Detect: func(_ context.Context, _ *http.Client, _ string) (provider.Provider, error) {
+						return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: no route to host")}
+					},
+				})
+			},
+			expectedOutput: []string{
+				"Provider  unknown",
+				"Status    ⚠ Unreachable (network) - could not reach the host to detect its provider: dial tcp: connect: no route to host",
+			},
+			expectError: false,
+		},
+		{
+			name: "token replaced by NIX_CONFIG",
+			setupConfig: func(t *testing.T) string {
+				t.Helper()
+				t.Setenv("NIX_CONFIG", "access-tokens = github.com=gho_envtoken123456789")
+				return createTestConfig(t, "access-tokens = gitlab.com=glpat-filetoken123456\n")
+			},
+			setupProviders: func() {
+				provider.SetRegistry(make(map[string]*provider.Registration))
+				setupMockGitHubProvider(true)
+				setupMockGitLabProvider(true)
+			},
+			expectedOutput: []string{
+				"Access Tokens (2 configured",
+				"gitlab.com",
+				"Token     glpat-******56",
+				"Status    ✗ Not used by Nix: access-tokens in NIX_CONFIG replaces it",
+			},
+			expectError: false,
+		},
 		{
 			name: "unknown provider",
 			setupConfig: func(t *testing.T) string {
@@ -269,6 +391,8 @@ type mockStatusProvider struct {
 	valid      bool
 	validError error
 	scopes     []string
+	scopesErr  error
+	userErr    error
 	username   string
 	fullName   string
 }
@@ -297,6 +421,10 @@ func (m *mockStatusProvider) GetTokenScopes(_ context.Context, _ string) ([]stri
 		return nil, fmt.Errorf("invalid token")
 	}
 
+	if m.scopesErr != nil {
+		return nil, m.scopesErr
+	}
+
 	return m.scopes, nil
 }
 
@@ -305,9 +433,46 @@ func (m *mockStatusProvider) GetUserInfo(_ context.Context, _ string) (string, s
 		return "", "", fmt.Errorf("invalid token")
 	}
 
+	if m.userErr != nil {
+		return "", "", m.userErr
+	}
+
 	return m.username, m.fullName, nil
 }
 
+func TestShowUserInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		prov *mockStatusProvider
+		want string
+	}{
+		{
+			name: "user with full name",
+			prov: &mockStatusProvider{valid: true, username: "octocat", fullName: "The Octocat"},
+			want: "User  octocat (The Octocat)",
+		},
+		{
+			name: "user info unavailable",
+			prov: &mockStatusProvider{valid: true, userErr: fmt.Errorf("unexpected status code: 403")},
+			want: "User  (unavailable: unexpected status code: 403)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			w := tabwriter.NewWriter(&buf, 0, 0, tabPadding, ' ', 0)
+			showUserInfo(context.Background(), tt.prov, "token", w)
+			_ = w.Flush()
+
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, buf.String())
+			}
+		})
+	}
+}
+
 func TestStatusCommandIntegration(t *testing.T) {
 	// Test that the status command is properly registered
 	if statusCmd == nil {
@@ -322,3 +487,823 @@ func TestStatusCommandIntegration(t *testing.T) {
 		t.Error("statusCmd.RunE should not be nil")
 	}
 }
+
+// slowStatusProvider delays validation so tests can control completion order.
+type slowStatusProvider struct {
+	mockStatusProvider
+	delay time.Duration
+}
+
+func (s *slowStatusProvider) ValidateToken(ctx context.Context, token string) (provider.ValidationStatus, error) {
+	time.Sleep(s.delay)
+	return s.mockStatusProvider.ValidateToken(ctx, token)
+}
+
+func TestRunStatusStream(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+	originalStream := statusStream
+
+	defer func() {
+		configPath = originalConfigPath
+		statusStream = originalStream
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	configPath = createTestConfig(t, "access-tokens = github.com=gho_slowtoken1234567 gitlab.com=glpat-fasttoken123456\n")
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	provider.RegisterProvider("slow", provider.Registration{
+		Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+			delay := time.Duration(0)
+			if host == "github.com" {
+				delay = 200 * time.Millisecond
+			}
+
+			return &slowStatusProvider{
+				mockStatusProvider: mockStatusProvider{name: "slow", host: host, valid: true},
+				delay:              delay,
+			}, nil
+		},
+	})
+
+	tests := []struct {
+		name       string
+		stream     bool
+		firstHost  string
+		secondHost string
+	}{
+		{name: "ordered by default", stream: false, firstHost: "github.com", secondHost: "gitlab.com"},
+		{name: "stream in completion order", stream: true, firstHost: "gitlab.com", secondHost: "github.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusStream = tt.stream
+
+			output, err := captureStatusOutput(t)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			first := strings.Index(output, tt.firstHost+"\n")
+			second := strings.Index(output, tt.secondHost+"\n")
+
+			if first == -1 || second == -1 || first > second {
+				t.Errorf("expected %s before %s\nGot output:\n%s", tt.firstHost, tt.secondHost, output)
+			}
+		})
+	}
+}
+
+func TestRunStatusExclude(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+	originalExclude := statusExclude
+
+	defer func() {
+		configPath = originalConfigPath
+		statusExclude = originalExclude
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	configPath = createTestConfig(t, "access-tokens = github.com=gho_testtoken123456789 gitlab.com=glpat-testtoken123456\n")
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	setupMockGitHubProvider(true)
+	setupMockGitLabProvider(true)
+
+	t.Run("excluded host is not shown", func(t *testing.T) {
+		statusExclude = []string{"GitLab.com"}
+
+		output, err := captureStatusOutput(t)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !strings.Contains(output, "showing 1 hosts") || !strings.Contains(output, "github.com\n") {
+			t.Errorf("expected only github.com to be shown\nGot output:\n%s", output)
+		}
+
+		if strings.Contains(output, "gitlab.com\n") {
+			t.Errorf("expected gitlab.com to be excluded\nGot output:\n%s", output)
+		}
+	})
+
+	t.Run("exclude combined with host arguments", func(t *testing.T) {
+		statusExclude = []string{"gitlab.com"}
+
+		err := runStatus(nil, []string{"github.com"})
+		if err == nil || !strings.Contains(err.Error(), "--exclude") {
+			t.Errorf("expected error about combining hosts with --exclude, got %v", err)
+		}
+	})
+}
+
+func TestRunStatusNoValidate(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+	originalValidate := statusValidate
+	originalAllProviders := statusAllProviders
+
+	defer func() {
+		configPath = originalConfigPath
+		statusValidate = originalValidate
+		statusAllProviders = originalAllProviders
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	configPath = createTestConfig(t, "access-tokens = github.com=gho_testtoken123456789 git.example.com=token123456789012345\n")
+
+	var detectCalls atomic.Int32
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	provider.RegisterProvider("github", provider.Registration{
+		New: func(cfg provider.Config) provider.Provider {
+			return &mockStatusProvider{name: "github", host: cfg.Host}
+		},
+		Detect: func(_ context.Context, _ *http.Client, _ string) (provider.Provider, error) {
+			detectCalls.Add(1)
+			return nil, nil
+		},
+		DefaultHost: "github.com",
+	})
+
+	statusValidate = false
+
+	t.Run("tokens listed without network access", func(t *testing.T) {
+		output, err := captureStatusOutput(t)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, expected := range []string{
+			"Provider  github (guessed from host)",
+			"Token     gho_******89",
+			"Provider  unknown (guessed from host)",
+			"Status    - Not validated",
+		} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("output missing expected string %q\nGot output:\n%s", expected, output)
+			}
+		}
+
+		if calls := detectCalls.Load(); calls != 0 {
+			t.Errorf("expected no detection calls, got %d", calls)
+		}
+	})
+
+	t.Run("fingerprints instead of masked tokens", func(t *testing.T) {
+		statusFingerprint = true
+		defer func() { statusFingerprint = false }()
+
+		output, err := captureStatusOutput(t)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if want := "Token     " + ui.TokenFingerprint("gho_testtoken123456789"); !strings.Contains(output, want) {
+			t.Errorf("output missing %q\nGot output:\n%s", want, output)
+		}
+
+		if strings.Contains(output, "gho_") {
+			t.Errorf("expected no part of the token in the output\nGot output:\n%s", output)
+		}
+	})
+
+	t.Run("all-providers requires validation", func(t *testing.T) {
+		statusAllProviders = true
+		defer func() { statusAllProviders = false }()
+
+		err := runStatus(nil, nil)
+		if err == nil || !strings.Contains(err.Error(), "--validate") {
+			t.Errorf("expected error about --validate, got %v", err)
+		}
+	})
+}
+
+// reauthStatusProvider rejects every token except the one its Authenticate returns.
+type reauthStatusProvider struct {
+	mockStatusProvider
+	newToken string
+}
+
+func (m *reauthStatusProvider) Authenticate(_ context.Context) (string, error) {
+	return m.newToken, nil
+}
+
+func (m *reauthStatusProvider) ValidateToken(_ context.Context, token string) (provider.ValidationStatus, error) {
+	if token == m.newToken {
+		return provider.ValidationStatusValid, nil
+	}
+
+	return provider.ValidationStatusInvalid, fmt.Errorf("401 Unauthorized")
+}
+
+func TestRunStatusReauthenticate(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+	originalInteractive := statusInteractive
+	originalStdin := os.Stdin
+
+	defer func() {
+		configPath = originalConfigPath
+		statusInteractive = originalInteractive
+		os.Stdin = originalStdin
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	t.Setenv("NIX_AUTH_POST_HOOK", "")
+
+	const newToken = "gho_newtoken123456789"
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	provider.RegisterProvider("github", provider.Registration{
+		Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+			return &reauthStatusProvider{
+				mockStatusProvider: mockStatusProvider{name: "github", host: host},
+				newToken:           newToken,
+			}, nil
+		},
+	})
+
+	tests := []struct {
+		name        string
+		interactive bool
+		answer      string
+		wantToken   string
+	}{
+		{name: "confirmed re-auth replaces token", interactive: true, answer: "y\n", wantToken: newToken},
+		{name: "declined re-auth keeps token", interactive: true, answer: "n\n", wantToken: "gho_oldtoken123456789"},
+		{name: "no prompt when not interactive", interactive: false, answer: "y\n", wantToken: "gho_oldtoken123456789"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath = createTestConfig(t, "access-tokens = github.com=gho_oldtoken123456789\n")
+			statusInteractive = func() bool { return tt.interactive }
+
+			stdinR, stdinW, _ := os.Pipe()
+			os.Stdin = stdinR
+
+			go func() {
+				defer stdinW.Close() //nolint:errcheck // cleanup in test goroutine
+				_, _ = stdinW.WriteString(tt.answer)
+			}()
+
+			output, err := captureStatusOutput(t)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			prompted := strings.Contains(output, "Re-authenticate now?")
+			if prompted != tt.interactive {
+				t.Errorf("prompted = %v, want %v\nGot output:\n%s", prompted, tt.interactive, output)
+			}
+
+			cfg, err := nixconf.New(configPath)
+			if err != nil {
+				t.Fatalf("failed to open config: %v", err)
+			}
+
+			token, _ := cfg.GetToken("github.com")
+			if token != tt.wantToken {
+				t.Errorf("token = %q, want %q", token, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestRunStatusOrphanedTokenFile(t *testing.T) {
+	originalConfigPath := configPath
+	originalInteractive := statusInteractive
+	originalStdin := os.Stdin
+
+	defer func() {
+		configPath = originalConfigPath
+		statusInteractive = originalInteractive
+		os.Stdin = originalStdin
+	}()
+
+	const orphanToken = "gho_orphantoken12345678"
+
+	tests := []struct {
+		name        string
+		interactive bool
+		answer      string
+		wantToken   string
+	}{
+		{name: "confirmed restore includes the token file", interactive: true, answer: "y\n", wantToken: orphanToken},
+		{name: "declined restore leaves the config alone", interactive: true, answer: "n\n"},
+		{name: "no prompt when not interactive", interactive: false, answer: "y\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath = createTestConfig(t, "experimental-features = nix-command flakes\n")
+			statusInteractive = func() bool { return tt.interactive }
+
+			tokenFile := filepath.Join(filepath.Dir(configPath), "access-tokens.conf")
+			if err := os.WriteFile(tokenFile, []byte("access-tokens = github.com="+orphanToken+"\n"), 0o600); err != nil {
+				t.Fatalf("failed to write token file: %v", err)
+			}
+
+			stdinR, stdinW, _ := os.Pipe()
+			os.Stdin = stdinR
+
+			go func() {
+				defer stdinW.Close() //nolint:errcheck // cleanup in test goroutine
+				_, _ = stdinW.WriteString(tt.answer)
+			}()
+
+			output, err := captureStatusOutput(t)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !strings.Contains(output, "has tokens, but "+configPath+" does not include it") {
+				t.Errorf("expected a warning about the orphaned token file\nGot output:\n%s", output)
+			}
+
+			prompted := strings.Contains(output, "again so Nix reads its tokens?")
+			if prompted != tt.interactive {
+				t.Errorf("prompted = %v, want %v\nGot output:\n%s", prompted, tt.interactive, output)
+			}
+
+			cfg, err := nixconf.New(configPath)
+			if err != nil {
+				t.Fatalf("failed to open config: %v", err)
+			}
+
+			token, _ := cfg.GetToken("github.com")
+			if token != tt.wantToken {
+				t.Errorf("token = %q, want %q", token, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestRunStatusMissingNestedConfig(t *testing.T) {
+	originalConfigPath := configPath
+
+	defer func() {
+		configPath = originalConfigPath
+	}()
+
+	dir := t.TempDir()
+	t.Setenv("NIX_CONF_DIR", dir)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(dir, "state"))
+
+	plainFile := filepath.Join(dir, "plain")
+	if err := os.WriteFile(plainFile, nil, 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "missing parent directories", path: filepath.Join(dir, "a", "b", "c", "nix.conf")},
+		{name: "parent is a regular file", path: filepath.Join(plainFile, "nix", "nix.conf")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath = tt.path
+
+			output, err := captureStatusOutput(t)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !strings.Contains(output, "No access tokens configured") {
+				t.Errorf("expected no tokens to be reported\nGot output:\n%s", output)
+			}
+
+			if _, err := os.Stat(filepath.Dir(tt.path)); err == nil {
+				t.Errorf("status created %s", filepath.Dir(tt.path))
+			}
+		})
+	}
+}
+
+func TestRunStatusShadowedTokens(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+	originalVerbose := statusVerbose
+
+	defer func() {
+		configPath = originalConfigPath
+		statusVerbose = originalVerbose
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	configPath = createTestConfig(t, "access-tokens = github.com=gho_usertoken123456789\n")
+
+	systemDir := t.TempDir()
+	t.Setenv("NIX_CONF_DIR", systemDir)
+
+	systemConfig := "access-tokens = github.com=gho_systemtoken1234567\n"
+	if err := os.WriteFile(filepath.Join(systemDir, "nix.conf"), []byte(systemConfig), 0o600); err != nil {
+		t.Fatalf("failed to write system config: %v", err)
+	}
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	setupMockGitHubProvider(true)
+
+	tests := []struct {
+		name     string
+		verbose  bool
+		expected []string
+	}{
+		{
+			name: "shadowed tokens are counted",
+			expected: []string{
+				"Token     gho_******89",
+				"Source    from " + configPath,
+				"Shadowed  1 other token(s), use --verbose to list",
+			},
+		},
+		{
+			name:    "verbose lists shadowed tokens",
+			verbose: true,
+			expected: []string{
+				"Token     gho_******89",
+				"Shadowed  gho_******67 from " + filepath.Join(systemDir, "nix.conf"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusVerbose = tt.verbose
+
+			output, err := captureStatusOutput(t)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, expected := range tt.expected {
+				if !strings.Contains(output, expected) {
+					t.Errorf("output missing expected string %q\nGot output:\n%s", expected, output)
+				}
+			}
+		})
+	}
+}
+
+func TestRunStatusSuggestsHost(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+
+	defer func() {
+		configPath = originalConfigPath
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	configPath = createTestConfig(t, "access-tokens = github.com=gho_testtoken123456789\n")
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	setupMockGitHubProvider(true)
+
+	var runErr error
+
+	output := captureStdout(t, func() {
+		runErr = runStatus(nil, []string{"githubcom"})
+	})
+
+	if runErr != nil {
+		t.Fatalf("unexpected error: %v", runErr)
+	}
+
+	if !strings.Contains(output, "No token configured for githubcom; did you mean github.com?") {
+		t.Errorf("expected suggestion for github.com\nGot output:\n%s", output)
+	}
+}
+
+// rawScopesProvider reports its scopes header verbatim.
+type rawScopesProvider struct {
+	mockStatusProvider
+	raw string
+}
+
+func (m *rawScopesProvider) GetRawTokenScopes(_ context.Context, _ string) (string, error) {
+	return m.raw, nil
+}
+
+func TestShowRawTokenScopes(t *testing.T) {
+	tests := []struct {
+		name string
+		prov provider.Provider
+		want string
+	}{
+		{
+			name: "raw header is quoted",
+			prov: &rawScopesProvider{mockStatusProvider: mockStatusProvider{name: "github", valid: true}, raw: "repo,  read:org "},
+			want: `Raw scopes  "repo,  read:org "`,
+		},
+		{
+			name: "provider without scopes API",
+			prov: &mockStatusProvider{name: "gitea", valid: true},
+			want: "Raw scopes  N/A (not reported by gitea)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			w := tabwriter.NewWriter(&buf, 0, 0, tabPadding, ' ', 0)
+			showRawTokenScopes(context.Background(), w, tt.prov, "token")
+			_ = w.Flush()
+
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+// registryProvider has a container registry that needs registry scopes.
+type registryProvider struct {
+	mockStatusProvider
+}
+
+func (m *registryProvider) RegistryScopes() []string {
+	return []string{"read_registry", "write_registry", "api"}
+}
+
+func TestShowTokenScopesRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		prov    provider.Provider
+		want    string
+		notWant string
+	}{
+		{
+			name: "registry scope",
+			prov: &registryProvider{mockStatusProvider{name: "gitlab", valid: true, scopes: []string{"read_api", "read_registry"}}},
+			want: "Registry  ✓ read_registry",
+		},
+		{
+			name: "no registry scope",
+			prov: &registryProvider{mockStatusProvider{name: "gitlab", valid: true, scopes: []string{"read_api", "read_repository"}}},
+			want: "Registry  No access (log in with --scopes +read_registry to pull images)",
+		},
+		{
+			name:    "provider without a registry",
+			prov:    &mockStatusProvider{name: "github", valid: true, scopes: []string{"repo"}},
+			want:    "Scopes  repo",
+			notWant: "Registry",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			w := tabwriter.NewWriter(&buf, 0, 0, tabPadding, ' ', 0)
+			showTokenScopes(context.Background(), w, tt.prov, "token")
+			_ = w.Flush()
+
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, buf.String())
+			}
+
+			if tt.notWant != "" && strings.Contains(buf.String(), tt.notWant) {
+				t.Errorf("expected no %q, got %q", tt.notWant, buf.String())
+			}
+		})
+	}
+}
+
+// tokenInfoProvider describes its tokens with info.
+type tokenInfoProvider struct {
+	mockStatusProvider
+	info provider.TokenInfo
+}
+
+func (m *tokenInfoProvider) GetTokenInfo(_ context.Context, _ string) (provider.TokenInfo, error) {
+	return m.info, nil
+}
+
+func TestShowTokenInfo(t *testing.T) {
+	created := time.Date(2023, 1, 5, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name    string
+		prov    provider.Provider
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "name and creation date",
+			prov: &tokenInfoProvider{info: provider.TokenInfo{Name: "nix-auth", CreatedAt: created}},
+			want: []string{"Name     nix-auth", "Created  2023-01-05"},
+		},
+		{
+			name:    "creation date only",
+			prov:    &tokenInfoProvider{info: provider.TokenInfo{CreatedAt: created}},
+			want:    []string{"Created  2023-01-05"},
+			notWant: []string{"Name"},
+		},
+		{
+			name:    "provider without token details",
+			prov:    &mockStatusProvider{name: "gitea", valid: true},
+			notWant: []string{"Name", "Created"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			w := tabwriter.NewWriter(&buf, 0, 0, tabPadding, ' ', 0)
+			showTokenInfo(context.Background(), w, tt.prov, "token")
+			_ = w.Flush()
+
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("expected %q, got %q", want, buf.String())
+				}
+			}
+
+			for _, notWant := range tt.notWant {
+				if strings.Contains(buf.String(), notWant) {
+					t.Errorf("expected no %q, got %q", notWant, buf.String())
+				}
+			}
+		})
+	}
+}
+
+// limitStatusProvider records how many tokens are validated at once and never
+// finishes validating the token of hangHost before the context expires.
+type limitStatusProvider struct {
+	mockStatusProvider
+	hangHost  string
+	active    *atomic.Int32
+	maxActive *atomic.Int32
+}
+
+func (l *limitStatusProvider) ValidateToken(ctx context.Context, token string) (provider.ValidationStatus, error) {
+	active := l.active.Add(1)
+	defer l.active.Add(-1)
+
+	for {
+		seen := l.maxActive.Load()
+		if active <= seen || l.maxActive.CompareAndSwap(seen, active) {
+			break
+		}
+	}
+
+	if l.host == l.hangHost {
+		<-ctx.Done()
+		return provider.ValidationStatusInvalid, ctx.Err()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	return l.mockStatusProvider.ValidateToken(ctx, token)
+}
+
+func TestStatusTimeoutDefault(t *testing.T) {
+	// status waits for every host unless asked not to; check, run unattended, does not
+	if got := statusCmd.Flags().Lookup("timeout").DefValue; got != "0s" {
+		t.Errorf("status --timeout default = %s, want 0s", got)
+	}
+
+	if got := checkCmd.Flags().Lookup("timeout").DefValue; got != "1m0s" {
+		t.Errorf("check --timeout default = %s, want 1m0s", got)
+	}
+}
+
+func TestRunStatusJobsAndTimeout(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+	originalJobs := statusJobs
+	originalTimeout := statusTimeout
+	originalInteractive := statusInteractive
+
+	defer func() {
+		configPath = originalConfigPath
+		statusJobs = originalJobs
+		statusTimeout = originalTimeout
+		statusInteractive = originalInteractive
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	statusInteractive = func() bool { return true }
+
+	configPath = createTestConfig(t, "access-tokens = a.example.com=tok_a b.example.com=tok_b "+
+		"c.example.com=tok_c d.example.com=tok_d hang.example.com=tok_hang\n")
+
+	var active, maxActive atomic.Int32
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	provider.RegisterProvider("limit", provider.Registration{
+		Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+			return &limitStatusProvider{
+				mockStatusProvider: mockStatusProvider{name: "limit", host: host, valid: true},
+				hangHost:           "hang.example.com",
+				active:             &active,
+				maxActive:          &maxActive,
+			}, nil
+		},
+	})
+
+	statusJobs = 2
+	statusTimeout = 500 * time.Millisecond
+
+	output, err := captureStatusOutput(t)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := maxActive.Load(); got > 2 {
+		t.Errorf("expected at most 2 hosts checked at once, got %d", got)
+	}
+
+	if !strings.Contains(output, "hang.example.com\n  Status  ⏱ Timed out") {
+		t.Errorf("expected the hanging host to time out\nGot output:\n%s", output)
+	}
+
+	if !strings.Contains(output, "Timed out after 500ms: hang.example.com") {
+		t.Errorf("expected timed out hosts to be listed\nGot output:\n%s", output)
+	}
+
+	// A timed out host is not offered for re-authentication like an invalid token
+	if strings.Contains(output, "Re-authenticate") {
+		t.Errorf("expected no re-authentication prompt\nGot output:\n%s", output)
+	}
+
+	if strings.Count(output, "✓ Valid") != 4 {
+		t.Errorf("expected the other hosts to be valid\nGot output:\n%s", output)
+	}
+
+	statusJobs = 0
+
+	if _, err := captureStatusOutput(t); err == nil || !strings.Contains(err.Error(), "--jobs") {
+		t.Errorf("expected --jobs error, got %v", err)
+	}
+}
+
+func TestRunStatusOutputFile(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+	originalValidate := statusValidate
+	originalOutputFile := statusOutputFile
+
+	defer func() {
+		configPath = originalConfigPath
+		statusValidate = originalValidate
+		statusOutputFile = originalOutputFile
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	configPath = createTestConfig(t, "access-tokens = github.com=gho_testtoken123456789\n")
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+
+	reportDir := t.TempDir()
+	statusOutputFile = filepath.Join(reportDir, "report.txt")
+	statusValidate = false
+
+	if err := os.WriteFile(statusOutputFile, []byte("previous report\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := captureStatusOutput(t)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if output != "" {
+		t.Errorf("expected nothing on stdout, got:\n%s", output)
+	}
+
+	report, err := os.ReadFile(statusOutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"Access Tokens (1 configured", "Token     gho_******89"} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report missing %q\nGot report:\n%s", want, report)
+		}
+	}
+
+	// The temporary file was renamed over the report, not left behind
+	entries, err := os.ReadDir(reportDir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("expected only the report in %s, got %v, %v", reportDir, entries, err)
+	}
+}
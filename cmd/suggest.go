@@ -0,0 +1,59 @@
+package cmd
+
+import "strings"
+
+// maxSuggestionDistance is the largest edit distance for which a configured host
+// is suggested as the one the user meant.
+const maxSuggestionDistance = 2
+
+// suggestHost returns the configured host closest to input, or "" if none is
+// close enough to be a likely typo.
+func suggestHost(input string, hosts []string) string {
+	input = strings.ToLower(input)
+
+	best := ""
+	bestDistance := maxSuggestionDistance + 1
+
+	for _, host := range hosts {
+		distance := editDistance(input, strings.ToLower(host))
+		if distance == 0 {
+			return ""
+		}
+
+		if distance < bestDistance && distance < len(host)/2 {
+			best = host
+			bestDistance = distance
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"github.com", "github.com", 0},
+		{"githubcom", "github.com", 1},
+		{"gitlab.com", "github.com", 2},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestHost(t *testing.T) {
+	hosts := []string{"github.com", "gitlab.company.com", "codeberg.org"}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "githubcom", want: "github.com"},
+		{input: "GitHub.co", want: "github.com"},
+		{input: "gitlab.compnay.com", want: "gitlab.company.com"},
+		{input: "github.com", want: ""},
+		{input: "example.org", want: ""},
+		{input: "a", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := suggestHost(tt.input, hosts); got != tt.want {
+				t.Errorf("suggestHost(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+var testRepoCmd = &cobra.Command{
+	Use:         "test <host> <owner/repo>",
+	Annotations: map[string]string{hostArgAnnotation: "true"},
+	Short:       "Check that the token for a host can read a repository",
+	Long: `Check that the token Nix uses for a host can read a specific repository, such
+as a private flake input. This answers "can this token fetch my flake?" more
+directly than validating the token, which only shows that it belongs to a user.
+
+The repository is given as its path on the host: owner/repo on GitHub, or the
+full project path such as group/subgroup/project on GitLab. A repository that
+does not exist cannot be told apart from one the token has no access to.
+
+Exits with a non-zero status if the token cannot read the repository.
+Checking repository access is supported for GitHub and GitLab.`,
+	Example: `  nix-auth test github.com numtide/nix-auth
+  nix-auth test gitlab.company.com platform/nix/flakes`,
+	Args:         cobra.ExactArgs(2),
+	RunE:         runTestRepo,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(testRepoCmd)
+}
+
+func runTestRepo(_ *cobra.Command, args []string) error {
+	host, err := parseHost(args[0])
+	if err != nil {
+		return err
+	}
+
+	repo, err := parseRepoPath(args[1])
+	if err != nil {
+		return err
+	}
+
+	cfg, err := openNixConfig()
+	if err != nil {
+		return fmt.Errorf("failed to initialize config: %w", err)
+	}
+
+	token, _, err := cfg.LookupToken(host)
+	if err != nil {
+		return fmt.Errorf("failed to look up token: %w", err)
+	}
+
+	if token == "" {
+		return nixconf.NotConfiguredf("no token configured for %s", host)
+	}
+
+	ctx := context.Background()
+
+	prov := provider.DetectCached(ctx, providerConfig(host))
+
+	warnPrivateHost(ctx, os.Stderr, host)
+
+	ok, err := provider.CanAccessRepo(ctx, prov, token, repo)
+
+	switch {
+	case errors.Is(err, provider.ErrRepoAccessUnsupported):
+		return fmt.Errorf("cannot check repository access on %s: not supported by the %s provider", host, prov.Name())
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("the token for %s cannot read %s (the repository does not exist or is not visible to the token)", host, repo)
+	}
+
+	fmt.Printf("✓ The token for %s can read %s\n", host, repo)
+
+	return nil
+}
+
+// parseRepoPath normalizes a repository path such as "owner/repo" and rejects
+// one with fewer than two segments. Surrounding slashes and a .git suffix,
+// as in clone URLs, are removed.
+func parseRepoPath(input string) (string, error) {
+	repo := strings.Trim(strings.TrimSpace(input), "/")
+	repo = strings.TrimSuffix(repo, ".git")
+
+	segments := strings.Split(repo, "/")
+	if len(segments) < 2 {
+		return "", fmt.Errorf("invalid repository %q: expected a path such as owner/repo", input)
+	}
+
+	for _, segment := range segments {
+		if segment == "" {
+			return "", fmt.Errorf("invalid repository %q: expected a path such as owner/repo", input)
+		}
+	}
+
+	return repo, nil
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/nixconf"
+	"github.com/numtide/nix-auth/internal/provider"
+)
+
+// repoAccessProvider can read the repositories in repos.
+type repoAccessProvider struct {
+	mockStatusProvider
+	repos map[string]bool
+}
+
+func (m *repoAccessProvider) CanAccessRepo(_ context.Context, _, repoPath string) (bool, error) {
+	return m.repos[repoPath], nil
+}
+
+func TestRunTestRepo(t *testing.T) {
+	originalConfigPath := configPath
+	originalRegistry := provider.GetRegistry()
+
+	defer func() {
+		configPath = originalConfigPath
+
+		provider.SetRegistry(originalRegistry)
+	}()
+
+	provider.SetRegistry(make(map[string]*provider.Registration))
+	provider.RegisterProvider("repos", provider.Registration{
+		Detect: func(_ context.Context, _ *http.Client, host string) (provider.Provider, error) {
+			if host == "plain.example.com" {
+				return &mockStatusProvider{name: "plain", host: host, valid: true}, nil
+			}
+
+			return &repoAccessProvider{
+				mockStatusProvider: mockStatusProvider{name: "repos", host: host, valid: true},
+				repos:              map[string]bool{"owner/flake": true},
+			}, nil
+		},
+	})
+
+	configPath = createTestConfig(t, "access-tokens = git.example.com=tok_git plain.example.com=tok_plain\n")
+
+	tests := []struct {
+		name     string
+		args     []string
+		want     string
+		errorMsg string
+	}{
+		{name: "readable repository", args: []string{"git.example.com", "owner/flake"}, want: "✓ The token for git.example.com can read owner/flake"},
+		{name: "clone URL path", args: []string{"git.example.com", "/owner/flake.git"}, want: "can read owner/flake"},
+		{name: "unreadable repository", args: []string{"git.example.com", "owner/private"}, errorMsg: "cannot read owner/private"},
+		{name: "unsupported provider", args: []string{"plain.example.com", "owner/flake"}, errorMsg: "not supported by the plain provider"},
+		{name: "no token", args: []string{"other.example.com", "owner/flake"}, errorMsg: "no token configured for other.example.com"},
+		{name: "invalid repository", args: []string{"git.example.com", "flake"}, errorMsg: "expected a path such as owner/repo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runErr error
+
+			output := captureStdout(t, func() {
+				runErr = runTestRepo(nil, tt.args)
+			})
+
+			if tt.errorMsg != "" {
+				if runErr == nil || !strings.Contains(runErr.Error(), tt.errorMsg) {
+					t.Errorf("expected error containing %q, got %v", tt.errorMsg, runErr)
+				}
+
+				return
+			}
+
+			if runErr != nil {
+				t.Fatalf("unexpected error: %v", runErr)
+			}
+
+			if !strings.Contains(output, tt.want) {
+				t.Errorf("expected %q in output\nGot output:\n%s", tt.want, output)
+			}
+		})
+	}
+
+	if err := runTestRepo(nil, []string{"other.example.com", "owner/flake"}); !errors.Is(err, nixconf.ErrNotConfigured) {
+		t.Errorf("expected a missing token to be reported as not configured, got %v", err)
+	}
+}
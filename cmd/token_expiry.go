@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/numtide/nix-auth/internal/provider"
+	"github.com/numtide/nix-auth/internal/tokenmeta"
+)
+
+// shortLivedTokenThreshold is how soon a token must expire for login to warn about it.
+const shortLivedTokenThreshold = 7 * 24 * time.Hour
+
+// tokenMetaPath returns the token metadata file in the nix-auth state directory.
+func tokenMetaPath() (string, error) {
+	dir, err := provider.StateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return tokenmeta.Path(dir), nil
+}
+
+// recordTokenExpiry remembers when the token just saved for host expires, so
+// status can show it, and warns when the token will not last long. A zero
+// expiresAt forgets the expiry of any previous token. Failing to record the
+// expiry is not fatal; the token is already saved.
+func recordTokenExpiry(host string, expiresAt time.Time) {
+	err := updateTokenMeta(host, func(m *tokenmeta.Metadata) { m.ExpiresAt = expiresAt })
+	if err != nil {
+		fmt.Printf("Warning: failed to record token expiry: %v\n", err)
+	}
+
+	if expiresAt.IsZero() {
+		return
+	}
+
+	remaining := time.Until(expiresAt)
+	fmt.Printf("Token expires in %s (%s)\n", formatDuration(remaining), expiresAt.Local().Format(time.RFC1123))
+
+	if remaining < shortLivedTokenThreshold {
+		fmt.Printf("Warning: this token expires in %s; consider a personal access token "+
+			"(nix-auth set-token %s) for long-lived use\n", formatDuration(remaining), host)
+	}
+}
+
+// forgetTokenExpiry drops the recorded expiry for host after its token was
+// replaced or removed by something other than login. A recorded client ID is
+// kept for the next login.
+func forgetTokenExpiry(host string) {
+	recordTokenExpiry(host, time.Time{})
+}
+
+// recordClientID remembers the OAuth client ID a login for host used, so a
+// later login can reuse it without --client-id. Failing to record it is not
+// fatal; the token is already saved.
+func recordClientID(host, clientID string) {
+	if clientID == "" {
+		return
+	}
+
+	err := updateTokenMeta(host, func(m *tokenmeta.Metadata) { m.ClientID = clientID })
+	if err != nil {
+		fmt.Printf("Warning: failed to record client ID: %v\n", err)
+	}
+}
+
+// savedClientID returns the OAuth client ID recorded by an earlier login for
+// host, or "" if none was recorded.
+func savedClientID(host string) string {
+	path, err := tokenMetaPath()
+	if err != nil {
+		return ""
+	}
+
+	m, err := tokenmeta.Get(path, host)
+	if err != nil {
+		return ""
+	}
+
+	return m.ClientID
+}
+
+// updateTokenMeta applies update to the metadata recorded for host.
+// A missing state directory leaves nothing to record.
+func updateTokenMeta(host string, update func(*tokenmeta.Metadata)) error {
+	path, err := tokenMetaPath()
+	if err != nil {
+		return nil //nolint:nilerr // without a state directory there is nowhere to record metadata
+	}
+
+	return tokenmeta.Update(path, host, update)
+}
+
+// tokenExpiry returns the recorded expiry of the token saved for host,
+// or zero if it is not known.
+func tokenExpiry(host string) time.Time {
+	path, err := tokenMetaPath()
+	if err != nil {
+		return time.Time{}
+	}
+
+	m, err := tokenmeta.Get(path, host)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return m.ExpiresAt
+}
+
+// formatDuration renders d coarsely for humans, e.g. "2 hours" or "45 minutes".
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	case d >= 2*time.Minute:
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	case d >= time.Minute:
+		return "1 minute"
+	default:
+		return "less than a minute"
+	}
+}
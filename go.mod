@@ -7,6 +7,7 @@ toolchain go1.24.4
 require (
 	github.com/cli/browser v1.3.0
 	github.com/cli/oauth v1.2.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/spf13/cobra v1.10.2
 	golang.org/x/term v0.38.0
 )
@@ -0,0 +1,198 @@
+package nixconf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Encryption encrypts the token file at rest. Nix cannot read an encrypted token
+// file, so the encrypted file is the canonical copy managed by nix-auth, and the
+// tokens are handed to Nix on demand (see DecryptedTokenConfig).
+type Encryption interface {
+	// Extension is appended to the token file name, e.g. ".age".
+	Extension() string
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// AgeEncryption encrypts the token file with the age command.
+type AgeEncryption struct {
+	// Recipients are age public keys or paths to recipient files.
+	Recipients []string
+	// Identity is the path to the age identity file used for decryption.
+	Identity string
+}
+
+// Extension returns ".age".
+func (a *AgeEncryption) Extension() string { return ".age" }
+
+// Encrypt encrypts plaintext to all recipients.
+func (a *AgeEncryption) Encrypt(plaintext []byte) ([]byte, error) {
+	if len(a.Recipients) == 0 {
+		return nil, errors.New("at least one --recipient is required to encrypt with age")
+	}
+
+	args := []string{"--encrypt"}
+
+	for _, recipient := range a.Recipients {
+		recipient = expandTilde(recipient)
+		if _, err := os.Stat(recipient); err == nil {
+			args = append(args, "--recipients-file", recipient)
+		} else {
+			args = append(args, "--recipient", recipient)
+		}
+	}
+
+	return runFilter("age", args, plaintext)
+}
+
+// Decrypt decrypts ciphertext with the identity file.
+func (a *AgeEncryption) Decrypt(ciphertext []byte) ([]byte, error) {
+	if a.Identity == "" {
+		return nil, errors.New("an --identity is required to decrypt with age")
+	}
+
+	return runFilter("age", []string{"--decrypt", "--identity", expandTilde(a.Identity)}, ciphertext)
+}
+
+// GPGEncryption encrypts the token file with the gpg command.
+type GPGEncryption struct {
+	// Recipients are the key IDs or user IDs to encrypt to.
+	Recipients []string
+}
+
+// Extension returns ".gpg".
+func (g *GPGEncryption) Extension() string { return ".gpg" }
+
+// Encrypt encrypts plaintext to all recipients.
+func (g *GPGEncryption) Encrypt(plaintext []byte) ([]byte, error) {
+	if len(g.Recipients) == 0 {
+		return nil, errors.New("at least one --recipient is required to encrypt with gpg")
+	}
+
+	args := []string{"--batch", "--yes", "--encrypt"}
+	for _, recipient := range g.Recipients {
+		args = append(args, "--recipient", recipient)
+	}
+
+	return runFilter("gpg", args, plaintext)
+}
+
+// Decrypt decrypts ciphertext using the keys available to gpg (and its agent).
+func (g *GPGEncryption) Decrypt(ciphertext []byte) ([]byte, error) {
+	return runFilter("gpg", []string{"--batch", "--quiet", "--decrypt"}, ciphertext)
+}
+
+// runFilter runs name with args, feeding input on stdin and returning stdout.
+func runFilter(name string, args []string, input []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(name, args...) //nolint:gosec // fixed command name, arguments from the user's own flags
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %w: %s", name, err, msg)
+		}
+
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// SetEncryption stores the token file encrypted with enc. Existing plaintext
+// tokens are still read, and are moved into the encrypted file on the next write.
+func (n *NixConfig) SetEncryption(enc Encryption) {
+	n.encryption = enc
+}
+
+// plainTokenFilePath returns the path of the plaintext token file included from nix.conf.
+func (n *NixConfig) plainTokenFilePath() string {
+	return filepath.Join(filepath.Dir(n.mainPath), accessTokensFile)
+}
+
+// readEncryptedTokens decrypts the encrypted token file. A missing file has no tokens.
+func (n *NixConfig) readEncryptedTokens() (map[string]string, error) {
+	path := n.GetTokenFilePath()
+
+	ciphertext, err := os.ReadFile(path) //nolint:gosec // trusted config file path
+	if err != nil {
+		if IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+
+		return nil, err
+	}
+
+	plaintext, err := n.encryption.Decrypt(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+
+	config, err := n.parser.ParseString(string(plaintext), path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	tokens, err := ParseAccessTokens(config.Settings[accessTokensKey])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return tokens, nil
+}
+
+// parseFile parses the config at path. For the managed config with encryption
+// enabled, the decrypted tokens are merged into its access-tokens setting; the
+// config lines are left untouched so they can be written back safely.
+func (n *NixConfig) parseFile(path string) (*ParsedConfig, error) {
+	if n.readOnly() && path == n.mainPath {
+		return n.parser.Parse(bytes.NewReader(n.content), stdinSource, "")
+	}
+
+	config, err := n.parser.ParseFile(path)
+	if err != nil || n.encryption == nil || path != n.mainPath {
+		return config, err
+	}
+
+	stored, err := n.readEncryptedTokens()
+	if err != nil || len(stored) == 0 {
+		return config, err
+	}
+
+	tokens, err := ParseAccessTokens(config.Settings[accessTokensKey])
+	if err != nil {
+		return nil, err
+	}
+
+	for host, token := range stored {
+		tokens[host] = token
+	}
+
+	config.Settings[accessTokensKey] = FormatAccessTokens(tokens)
+
+	return config, nil
+}
+
+// DecryptedTokenConfig returns the decrypted token file as a config line, suitable
+// for passing to Nix via NIX_CONFIG. It returns "" if no tokens are stored.
+func (n *NixConfig) DecryptedTokenConfig() (string, error) {
+	if n.encryption == nil {
+		return "", errors.New("token file encryption is not enabled")
+	}
+
+	tokens, err := n.readEncryptedTokens()
+	if err != nil {
+		return "", err
+	}
+
+	return formatTokenFile(tokens), nil
+}
@@ -0,0 +1,109 @@
+package nixconf
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// base64Encryption stands in for age/gpg in tests.
+type base64Encryption struct{}
+
+func (base64Encryption) Extension() string { return ".b64" }
+
+func (base64Encryption) Encrypt(plaintext []byte) ([]byte, error) {
+	return []byte(base64.StdEncoding.EncodeToString(plaintext)), nil
+}
+
+func (base64Encryption) Decrypt(ciphertext []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(string(ciphertext))
+}
+
+func TestNixConfig_Encryption(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "nix.conf")
+	plainPath := filepath.Join(tmpDir, "access-tokens.conf")
+
+	if err := os.WriteFile(configPath, []byte("!include access-tokens.conf\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := os.WriteFile(plainPath, []byte("access-tokens = github.com=ghp_plain\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cfg, err := New(configPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	cfg.SetEncryption(base64Encryption{})
+
+	encryptedPath := cfg.GetTokenFilePath()
+	if encryptedPath != plainPath+".b64" {
+		t.Errorf("GetTokenFilePath() = %q, want %q", encryptedPath, plainPath+".b64")
+	}
+
+	// Plaintext tokens are still visible before the first write
+	if token, _ := cfg.GetToken("github.com"); token != "ghp_plain" {
+		t.Errorf("GetToken() = %q, want ghp_plain", token)
+	}
+
+	if err := cfg.SetToken("gitlab.com", "glpat-secret"); err != nil {
+		t.Fatalf("SetToken() error = %v", err)
+	}
+
+	if _, err := os.Stat(plainPath); !os.IsNotExist(err) {
+		t.Errorf("plaintext token file should be removed, stat error = %v", err)
+	}
+
+	ciphertext, err := os.ReadFile(encryptedPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if strings.Contains(string(ciphertext), "glpat-secret") {
+		t.Error("encrypted token file contains a plaintext token")
+	}
+
+	hosts, err := cfg.ListTokens()
+	if err != nil || strings.Join(hosts, ",") != "github.com,gitlab.com" {
+		t.Errorf("ListTokens() = %v, %v; want github.com and gitlab.com", hosts, err)
+	}
+
+	_, source, err := cfg.LookupToken("gitlab.com")
+	if err != nil || source != SourceConfigFile {
+		t.Errorf("LookupToken() source = %v, %v; want config file", source, err)
+	}
+
+	entries, _ := cfg.TokenEntries("gitlab.com")
+	if len(entries) != 1 || entries[0].Path != encryptedPath {
+		t.Errorf("TokenEntries() = %+v, want path %s", entries, encryptedPath)
+	}
+
+	content, err := cfg.DecryptedTokenConfig()
+	if err != nil {
+		t.Fatalf("DecryptedTokenConfig() error = %v", err)
+	}
+
+	if content != "access-tokens = github.com=ghp_plain gitlab.com=glpat-secret\n" {
+		t.Errorf("DecryptedTokenConfig() = %q", content)
+	}
+
+	for _, host := range []string{"github.com", "gitlab.com"} {
+		if err := cfg.RemoveToken(host); err != nil {
+			t.Fatalf("RemoveToken(%s) error = %v", host, err)
+		}
+	}
+
+	if _, err := os.Stat(encryptedPath); !os.IsNotExist(err) {
+		t.Errorf("encrypted token file should be removed with the last token, stat error = %v", err)
+	}
+
+	mainConfig, _ := os.ReadFile(configPath)
+	if strings.Contains(string(mainConfig), "access-tokens =") {
+		t.Errorf("main config should not contain tokens:\n%s", mainConfig)
+	}
+}
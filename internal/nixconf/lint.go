@@ -0,0 +1,132 @@
+package nixconf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LintIssue is a problem with how access tokens are stored.
+type LintIssue struct {
+	Path string
+	// Line is the 1-based line number, or 0 if the issue concerns the whole file.
+	Line    int
+	Message string
+	// Warning marks a surprising but legal configuration rather than a violation.
+	Warning bool
+	// Syntax marks a malformed line rejected by strict parsing.
+	Syntax bool
+}
+
+// String formats the issue as path:line: message.
+func (i LintIssue) String() string {
+	message := i.Message
+	if i.Warning {
+		message = "warning: " + message
+	}
+
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s", i.Path, message)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", i.Path, i.Line, message)
+}
+
+// Lint checks that tokens are only stored in the token file and that the token
+// file is only accessible by its owner, which is the layout SetToken migrates to.
+// Includes resolving outside the config directory are reported as warnings, as
+// tokens may be read from an unexpected place. A missing config has no issues.
+// With EnableStrictParsing, a config the strict parser rejects has its parse
+// problems as issues.
+func (n *NixConfig) Lint() ([]LintIssue, error) {
+	config, err := n.parseFile(n.mainPath)
+	if err != nil {
+		var strictErr *StrictParseError
+
+		switch {
+		case IsNotExist(err):
+			return nil, nil
+		case errors.As(err, &strictErr):
+			return strictErr.Problems, nil
+		}
+
+		return nil, err
+	}
+
+	mainSource, err := n.mainSource()
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+
+	for _, line := range config.Lines {
+		if line.Key == accessTokensKey && line.SourceFile == mainSource {
+			issues = append(issues, LintIssue{
+				Path:    n.GetPath(),
+				Line:    line.LineNum,
+				Message: fmt.Sprintf("access-tokens set directly in the main config; move them to %s", accessTokensFile),
+			})
+		}
+	}
+
+	// The files of a config read from stdin are not on this machine
+	if n.readOnly() {
+		return issues, nil
+	}
+
+	issues = append(issues, n.externalIncludes(config)...)
+
+	tokenFilePath := n.GetTokenFilePath()
+
+	info, err := os.Stat(tokenFilePath)
+
+	switch {
+	case IsNotExist(err):
+	case err != nil:
+		return nil, err
+	case info.Mode().Perm() != n.tokenMode():
+		issues = append(issues, LintIssue{
+			Path:    tokenFilePath,
+			Message: fmt.Sprintf("permissions are %04o, want %04o", info.Mode().Perm(), n.tokenMode()),
+		})
+	}
+
+	return issues, nil
+}
+
+// externalIncludes warns about include directives that resolve to a path outside
+// the directory of the main config.
+func (n *NixConfig) externalIncludes(config *ParsedConfig) []LintIssue {
+	configDir, err := filepath.Abs(filepath.Dir(n.mainPath))
+	if err != nil {
+		return nil
+	}
+
+	var issues []LintIssue
+
+	for _, line := range config.Lines {
+		if !line.IsInclude || line.IncludePath == "" {
+			continue
+		}
+
+		includePath := line.IncludePath
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(line.SourceFile), includePath)
+		}
+
+		rel, err := filepath.Rel(configDir, filepath.Clean(includePath))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			issues = append(issues, LintIssue{
+				Path:    line.SourceFile,
+				Line:    line.LineNum,
+				Message: fmt.Sprintf("include %s resolves outside the config directory %s", includePath, configDir),
+				Warning: true,
+			})
+		}
+	}
+
+	return issues
+}
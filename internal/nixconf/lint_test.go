@@ -0,0 +1,86 @@
+package nixconf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNixConfig_Lint(t *testing.T) {
+	tests := []struct {
+		name       string
+		mainConfig string
+		tokenPerms os.FileMode
+		wantIssues []string
+	}{
+		{
+			name:       "secure layout",
+			mainConfig: "experimental-features = nix-command flakes\n!include access-tokens.conf\n",
+			tokenPerms: 0o600,
+		},
+		{
+			name:       "tokens in main config",
+			mainConfig: "experimental-features = nix-command flakes\naccess-tokens = github.com=ghp_inline\n",
+			wantIssues: []string{"nix.conf:2: access-tokens set directly in the main config"},
+		},
+		{
+			name:       "include outside the config directory",
+			mainConfig: "!include access-tokens.conf\n!include /nonexistent/tokens.conf\n!include ../shared.conf\n",
+			tokenPerms: 0o600,
+			wantIssues: []string{
+				"nix.conf:2: warning: include /nonexistent/tokens.conf resolves outside the config directory",
+				"nix.conf:3: warning: include",
+			},
+		},
+		{
+			name:       "token file readable by others",
+			mainConfig: "!include access-tokens.conf\n",
+			tokenPerms: 0o644,
+			wantIssues: []string{"access-tokens.conf: permissions are 0644, want 0600"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "nix.conf")
+
+			if err := os.WriteFile(configPath, []byte(tt.mainConfig), 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			if tt.tokenPerms != 0 {
+				tokenPath := filepath.Join(tmpDir, "access-tokens.conf")
+				if err := os.WriteFile(tokenPath, []byte("access-tokens = github.com=ghp_file\n"), tt.tokenPerms); err != nil {
+					t.Fatalf("WriteFile() error = %v", err)
+				}
+
+				// WriteFile is subject to the umask
+				if err := os.Chmod(tokenPath, tt.tokenPerms); err != nil {
+					t.Fatalf("Chmod() error = %v", err)
+				}
+			}
+
+			cfg, err := New(configPath)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			issues, err := cfg.Lint()
+			if err != nil {
+				t.Fatalf("Lint() error = %v", err)
+			}
+
+			if len(issues) != len(tt.wantIssues) {
+				t.Fatalf("Lint() = %v, want %d issues", issues, len(tt.wantIssues))
+			}
+
+			for i, want := range tt.wantIssues {
+				if !strings.Contains(issues[i].String(), want) {
+					t.Errorf("issue %d = %q, want it to contain %q", i, issues[i].String(), want)
+				}
+			}
+		})
+	}
+}
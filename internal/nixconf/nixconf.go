@@ -2,31 +2,119 @@
 package nixconf
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 )
 
 const (
 	// accessTokensFile is the default name for the separate tokens file.
 	accessTokensFile = "access-tokens.conf"
-	// tokenFilePermissions is the permission mode for the tokens file.
+	// tokenFilePermissions is the default permission mode for the tokens file.
 	tokenFilePermissions = 0o600
+	// ownerReadWrite are the permission bits the owner of the tokens file always needs.
+	ownerReadWrite = 0o600
+	// otherPermissions are the permission bits for users outside the file's group.
+	otherPermissions = 0o007
 	// dirPermissions is the permission mode for configuration directories.
 	dirPermissions = 0o755
 	// backupTimeFormat is the time format used for backup file names.
 	backupTimeFormat = "20060102-150405"
+	// maxBackupAttempts bounds the numbered names tried for backups made within the same second.
+	maxBackupAttempts = 100
 	// accessTokensKey is the config key for access tokens.
 	accessTokensKey = "access-tokens"
 )
 
+// ErrNotConfigured is matched by errors reporting a missing config file or token.
+var ErrNotConfigured = errors.New("not configured")
+
+// ErrMainConfigUnmanaged is returned when saving a token would have to modify
+// the main config after DisableMainConfigChanges.
+var ErrMainConfigUnmanaged = errors.New("main config is not managed by nix-auth")
+
+// notConfiguredError is an error message that matches ErrNotConfigured.
+type notConfiguredError struct {
+	msg string
+}
+
+func (e *notConfiguredError) Error() string { return e.msg }
+
+func (e *notConfiguredError) Is(target error) bool { return target == ErrNotConfigured }
+
+// NotConfiguredf formats an error that matches ErrNotConfigured.
+func NotConfiguredf(format string, args ...any) error {
+	return &notConfiguredError{msg: fmt.Sprintf(format, args...)}
+}
+
 // NixConfig manages the nix.conf file with minimal modifications.
 type NixConfig struct {
 	mainPath string
 	parser   *Parser
+	noBackup bool
+	// keepMain stops the main config from being created or modified.
+	keepMain bool
+	// tokenFileMode overrides tokenFilePermissions if non-zero.
+	tokenFileMode os.FileMode
+	// encryption, if set, encrypts the token file at rest.
+	encryption Encryption
+	// extraPaths are further configs tokens are read from, lowest precedence first.
+	extraPaths []string
+	// content is the configuration read by NewFromReader; such a config is read-only.
+	content []byte
+	// changes records the files modified through this NixConfig.
+	changes Changes
+}
+
+// Changes lists the files a NixConfig has modified, in the order they were
+// first touched.
+type Changes struct {
+	// Files were written or removed.
+	Files []string
+	// Backups were created before the main config was rewritten.
+	Backups []string
+}
+
+// Changes returns the files modified so far.
+func (n *NixConfig) Changes() Changes {
+	return n.changes
+}
+
+// recordChange adds path to the modified files unless it is already listed.
+func (n *NixConfig) recordChange(path string) {
+	if !slices.Contains(n.changes.Files, path) {
+		n.changes.Files = append(n.changes.Files, path)
+	}
+}
+
+// IsNotExist reports whether err means that a config file does not exist.
+// Like Nix, it counts a path below a regular file as missing, so a --config
+// path whose parent is a file reads as an empty config rather than failing.
+func IsNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR)
+}
+
+// removeFile removes path, ignoring a missing file, and records the change.
+func (n *NixConfig) removeFile(path string) error {
+	err := os.Remove(path)
+	if IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	n.recordChange(path)
+
+	return nil
 }
 
 // New creates a new NixConfig instance
@@ -41,42 +129,164 @@ func New(configPath string) (*NixConfig, error) {
 
 	configPath = expandTilde(configPath)
 
+	// Catch a mistyped path now rather than with an opaque error mid-operation
+	if info, err := os.Stat(configPath); err == nil && !info.Mode().IsRegular() {
+		if info.IsDir() {
+			return nil, fmt.Errorf("config path %s is a directory, not a nix.conf file", configPath)
+		}
+
+		return nil, fmt.Errorf("config path %s is not a regular file", configPath)
+	}
+
 	return &NixConfig{
 		mainPath: configPath,
 		parser:   NewParser(),
 	}, nil
 }
 
+// How UserConfigPath found the user's nix.conf.
+const (
+	DiscoveryNixUserConfFiles = "NIX_USER_CONF_FILES"
+	DiscoveryXDGConfigHome    = "XDG_CONFIG_HOME"
+	DiscoveryDefault          = "default"
+)
+
 // DefaultUserConfigPath returns the default path for the user's nix.conf based on environment variables.
 func DefaultUserConfigPath() string {
+	path, _ := UserConfigPath()
+	return path
+}
+
+// UserConfigPath returns the default path for the user's nix.conf and which of
+// the Discovery* mechanisms found it.
+func UserConfigPath() (string, string) {
 	// Check NIX_USER_CONF_FILES first (colon-separated list)
 	if nixUserConfFiles := os.Getenv("NIX_USER_CONF_FILES"); nixUserConfFiles != "" {
 		// Use the first file in the list
 		files := strings.Split(nixUserConfFiles, ":")
 		if len(files) > 0 && files[0] != "" {
-			return files[0]
+			return files[0], DiscoveryNixUserConfFiles
 		}
 	}
 
 	// Check XDG_CONFIG_HOME
 	if xdgConfigHome := os.Getenv("XDG_CONFIG_HOME"); xdgConfigHome != "" {
-		return filepath.Join(xdgConfigHome, "nix", "nix.conf")
+		return filepath.Join(xdgConfigHome, "nix", "nix.conf"), DiscoveryXDGConfigHome
 	}
 
 	// Default to ~/.config/nix/nix.conf
-	return "~/.config/nix/nix.conf"
+	return "~/.config/nix/nix.conf", DiscoveryDefault
+}
+
+// DefaultSystemConfigPath returns the path of the system-wide nix.conf read by the
+// Nix daemon: $NIX_CONF_DIR/nix.conf if NIX_CONF_DIR is set, /etc/nix/nix.conf otherwise.
+func DefaultSystemConfigPath() string {
+	if nixConfDir := os.Getenv("NIX_CONF_DIR"); nixConfDir != "" {
+		return filepath.Join(nixConfDir, "nix.conf")
+	}
+
+	return "/etc/nix/nix.conf"
+}
+
+// DisableBackups stops the main config from being backed up before it is modified.
+// Without a backup, a faulty update of nix.conf cannot be undone.
+func (n *NixConfig) DisableBackups() {
+	n.noBackup = true
+}
+
+// CheckTokenFileMode rejects permissions unsuitable for the token file: the
+// owner must be able to read and write it, and it must never be accessible to
+// users outside its group. Group read access, e.g. 0640, is allowed for
+// setups where a group such as a CI runner group needs the tokens.
+func CheckTokenFileMode(mode os.FileMode) error {
+	switch {
+	case mode&^os.ModePerm != 0:
+		return fmt.Errorf("invalid token file mode %04o: only permission bits are allowed", mode)
+	case mode&otherPermissions != 0:
+		return fmt.Errorf("invalid token file mode %04o: the token file must not be accessible to others", mode)
+	case mode&ownerReadWrite != ownerReadWrite:
+		return fmt.Errorf("invalid token file mode %04o: the owner must be able to read and write the token file", mode)
+	}
+
+	return nil
 }
 
-// GetPath returns the config file path being used.
+// SetTokenFileMode sets the permissions the token file is written with,
+// instead of the default 0600. The mode must pass CheckTokenFileMode.
+func (n *NixConfig) SetTokenFileMode(mode os.FileMode) error {
+	if err := CheckTokenFileMode(mode); err != nil {
+		return err
+	}
+
+	n.tokenFileMode = mode
+
+	return nil
+}
+
+// tokenMode returns the permissions the token file is written with.
+func (n *NixConfig) tokenMode() os.FileMode {
+	if n.tokenFileMode != 0 {
+		return n.tokenFileMode
+	}
+
+	return tokenFilePermissions
+}
+
+// EnableStrictParsing makes every read of the config fail with a
+// *StrictParseError if it contains unparseable lines, duplicate settings or
+// malformed tokens, instead of making the best of them.
+func (n *NixConfig) EnableStrictParsing() {
+	n.parser = NewStrictParser()
+}
+
+// AddExtraConfig adds a config file that tokens are read from but never
+// written to. Its tokens override those of the main config, and those of
+// extra configs added later override it, while NIX_CONFIG still overrides all.
+func (n *NixConfig) AddExtraConfig(path string) error {
+	path = expandTilde(path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("extra config %s: %w", path, err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("extra config %s is not a regular file", path)
+	}
+
+	n.extraPaths = append(n.extraPaths, path)
+
+	return nil
+}
+
+// ExtraConfigPaths returns the paths of the configs added with AddExtraConfig.
+func (n *NixConfig) ExtraConfigPaths() []string {
+	return n.extraPaths
+}
+
+// DisableMainConfigChanges stops nix-auth from creating or modifying the main
+// config, for a nix.conf managed declaratively (e.g. by NixOS or home-manager).
+// Only the token file is written; saving tokens fails unless the main config
+// already includes it.
+func (n *NixConfig) DisableMainConfigChanges() {
+	n.keepMain = true
+}
+
+// GetPath returns the config file path being used, or "<stdin>" for a
+// configuration read by NewFromReader.
 func (n *NixConfig) GetPath() string {
+	if n.readOnly() {
+		return stdinSource
+	}
+
 	return n.mainPath
 }
 
 // GetToken retrieves the access token for a given host.
 func (n *NixConfig) GetToken(host string) (string, error) {
-	config, err := n.parser.ParseFile(n.mainPath)
+	config, err := n.parseFile(n.mainPath)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if IsNotExist(err) {
 			return "", nil
 		}
 
@@ -97,42 +307,52 @@ func (n *NixConfig) GetToken(host string) (string, error) {
 
 // SetToken sets or updates the access token for a given host.
 func (n *NixConfig) SetToken(host, token string) error {
+	return n.SetTokens(map[string]string{host: token})
+}
+
+// SetTokens sets or updates the access tokens for several hosts in a single
+// write, so the main config is backed up at most once.
+func (n *NixConfig) SetTokens(tokens map[string]string) error {
+	if n.readOnly() {
+		return ErrReadOnlyConfig
+	}
+
 	// Ensure directory exists
 	dir := filepath.Dir(n.mainPath)
 	if err := os.MkdirAll(dir, dirPermissions); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	if err := n.checkWritable(); err != nil {
+		return err
+	}
+
 	// Parse existing configuration
-	config, err := n.parser.ParseFile(n.mainPath)
+	config, err := n.parseFile(n.mainPath)
 	mainFileExists := err == nil
 
 	if err != nil {
-		if !os.IsNotExist(err) {
+		if !IsNotExist(err) {
 			return fmt.Errorf("failed to parse config: %w", err)
 		}
 		// File doesn't exist - create empty config
 		config = NewParsedConfig()
 	}
 
-	// Get all existing tokens
-	existingTokens := make(map[string]string)
-
-	if tokenValue, exists := config.Settings[accessTokensKey]; exists {
-		parsedTokens, err := ParseAccessTokens(tokenValue)
-		if err != nil {
-			return fmt.Errorf("failed to parse existing tokens: %w", err)
-		}
-
-		existingTokens = parsedTokens
+	existingTokens, err := mergeTokens(config, tokens)
+	if err != nil {
+		return err
 	}
 
-	// Add/update the token
-	existingTokens[host] = token
+	// Refuse before writing anything if the main config needs changes it must not get
+	lines, changed := n.mainConfigLines(config, mainFileExists)
+	if changed && n.keepMain {
+		return n.mainConfigUnmanagedError(config, mainFileExists)
+	}
 
-	// Check if tokens are in main config file
-	tokenLine := config.FindSettingLine(accessTokensKey)
-	tokensInMainFile := tokenLine != nil && strings.HasSuffix(tokenLine.SourceFile, filepath.Base(n.mainPath))
+	if target := n.StorePath(); changed && target != "" {
+		return n.storeConfigError(target)
+	}
 
 	// First, write all tokens to the token file
 	tokenFilePath := n.GetTokenFilePath()
@@ -142,22 +362,23 @@ func (n *NixConfig) SetToken(host, token string) error {
 
 	// Then update main config if needed
 	if !mainFileExists {
-		// New config file - create with include
-		lines := []ConfigLine{
-			{Raw: "# Nix configuration", SourceFile: n.mainPath},
-			{Raw: "!include " + accessTokensFile, SourceFile: n.mainPath},
-		}
 		if err := config.WriteToFile(n.mainPath, lines); err != nil {
 			return fmt.Errorf("failed to create main config: %w", err)
 		}
-	} else if tokensInMainFile || !config.HasInclude(accessTokensFile) {
-		if tokensInMainFile {
+
+		n.recordChange(n.mainPath)
+	} else if changed {
+		if n.tokensInMainFile(config) {
 			tokenFilePath := n.GetTokenFilePath()
 			fmt.Printf("Migrating tokens to secure file: %s\n", tokenFilePath)
 		}
 
+		if duplicates := n.duplicateTokenIncludes(config); duplicates > 0 {
+			fmt.Printf("Warning: removing %d duplicate '!include %s' line(s) from %s\n", duplicates, accessTokensFile, n.mainPath)
+		}
+
 		// Need to update existing file: either migrate tokens or add missing include
-		if err := n.updateMainConfig(config); err != nil {
+		if err := n.updateMainConfig(config, lines); err != nil {
 			return err
 		}
 	}
@@ -165,33 +386,320 @@ func (n *NixConfig) SetToken(host, token string) error {
 	return nil
 }
 
-// updateMainConfig updates the main config to include the token file and remove any access-tokens.
-func (n *NixConfig) updateMainConfig(config *ParsedConfig) error {
-	// Create backup
-	backupPath := fmt.Sprintf("%s.backup-%s", n.mainPath, time.Now().Format(backupTimeFormat))
-	if err := n.createBackup(n.mainPath, backupPath); err != nil {
-		return fmt.Errorf("failed to create backup: %w", err)
+// mergeTokens returns the tokens configured in config updated with tokens.
+func mergeTokens(config *ParsedConfig, tokens map[string]string) (map[string]string, error) {
+	existingTokens := make(map[string]string)
+
+	if tokenValue, exists := config.Settings[accessTokensKey]; exists {
+		parsedTokens, err := ParseAccessTokens(tokenValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse existing tokens: %w", err)
+		}
+
+		existingTokens = parsedTokens
+	}
+
+	for host, token := range tokens {
+		existingTokens[host] = token
+	}
+
+	return existingTokens, nil
+}
+
+// tokensInMainFile reports whether the access tokens are set in the main config itself.
+func (n *NixConfig) tokensInMainFile(config *ParsedConfig) bool {
+	tokenLine := config.FindSettingLine(accessTokensKey)
+
+	return tokenLine != nil && strings.HasSuffix(tokenLine.SourceFile, filepath.Base(n.mainPath))
+}
+
+// mainConfigLines returns the lines of the main config after saving tokens and
+// whether they differ from the current ones: a new config including the token
+// file, or the existing one with its tokens migrated or the include added.
+// Duplicate includes of the token file are collapsed into the first one, unless
+// DisableMainConfigChanges forbids changes that saving tokens does not need.
+func (n *NixConfig) mainConfigLines(config *ParsedConfig, mainFileExists bool) ([]ConfigLine, bool) {
+	if !mainFileExists {
+		return []ConfigLine{
+			{Raw: "# Nix configuration", SourceFile: n.mainPath},
+			{Raw: "!include " + accessTokensFile, SourceFile: n.mainPath},
+		}, true
+	}
+
+	if n.tokensInMainFile(config) || !config.HasInclude(accessTokensFile) {
+		lines, _ := n.collapseTokenIncludes(n.replaceTokensWithInclude(config))
+		return lines, true
+	}
+
+	if n.keepMain {
+		return nil, false
+	}
+
+	lines, removed := n.collapseTokenIncludes(n.mainLines(config))
+	if removed == 0 {
+		return nil, false
+	}
+
+	return lines, true
+}
+
+// duplicateTokenIncludes returns how many includes of the token file the main
+// config has beyond the first.
+func (n *NixConfig) duplicateTokenIncludes(config *ParsedConfig) int {
+	_, removed := n.collapseTokenIncludes(n.mainLines(config))
+	return removed
+}
+
+// collapseTokenIncludes drops every include of the token file from lines but
+// the first, and returns how many it dropped.
+func (n *NixConfig) collapseTokenIncludes(lines []ConfigLine) ([]ConfigLine, int) {
+	collapsed := make([]ConfigLine, 0, len(lines))
+	seen := false
+	removed := 0
+
+	for _, line := range lines {
+		if n.includesTokenFile(line) {
+			if seen {
+				removed++
+				continue
+			}
+
+			seen = true
+		}
+
+		collapsed = append(collapsed, line)
+	}
+
+	return collapsed, removed
+}
+
+// includesTokenFile reports whether line is an include directive for the
+// plaintext token file, by relative or absolute path.
+func (n *NixConfig) includesTokenFile(line ConfigLine) bool {
+	path := line.IncludePath
+
+	if !line.IsInclude {
+		// Lines created by nix-auth itself are not parsed
+		fields := strings.Fields(line.Raw)
+		if len(fields) != 2 || (fields[0] != "!include" && fields[0] != "include") {
+			return false
+		}
+
+		path = fields[1]
+	}
+
+	if path == "" {
+		return false
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(n.mainPath), path)
+	}
+
+	return filepath.Clean(path) == filepath.Clean(n.plainTokenFilePath())
+}
+
+// mainLines returns the lines of config that belong to the main config itself.
+func (n *NixConfig) mainLines(config *ParsedConfig) []ConfigLine {
+	mainSource, err := n.mainSource()
+
+	lines := make([]ConfigLine, 0, len(config.Lines))
+	for _, line := range config.Lines {
+		if err == nil && line.SourceFile != mainSource {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// IncludeLine returns the directive that makes the main config read the token
+// file, with the token file's absolute path so it can be pasted into a config
+// generated elsewhere, such as by NixOS or home-manager.
+func (n *NixConfig) IncludeLine() (string, error) {
+	if n.readOnly() {
+		return "", ErrReadOnlyConfig
+	}
+
+	if n.encryption != nil {
+		return "", fmt.Errorf("nix cannot include an encrypted token file; pass the tokens with NIX_CONFIG=\"$(nix-auth decrypt)\" instead")
+	}
+
+	path, err := filepath.Abs(n.plainTokenFilePath())
+	if err != nil {
+		return "", err
+	}
+
+	return "!include " + path, nil
+}
+
+// OrphanedTokenFile returns the path of the token file if it holds tokens that
+// Nix does not read because no config includes it, such as after the include was
+// removed from the main config by hand, or "" otherwise.
+func (n *NixConfig) OrphanedTokenFile() (string, error) {
+	if n.readOnly() || n.encryption != nil {
+		return "", nil
+	}
+
+	path, err := filepath.Abs(n.plainTokenFilePath())
+	if err != nil {
+		return "", err
+	}
+
+	tokens, err := n.orphanedTokens(path)
+	if err != nil || len(tokens) == 0 {
+		return "", err
+	}
+
+	config, err := n.parseFile(n.mainPath)
+	if err != nil {
+		if IsNotExist(err) {
+			return path, nil
+		}
+
+		return "", err
+	}
+
+	for _, line := range config.Lines {
+		if line.SourceFile == path {
+			return "", nil
+		}
+	}
+
+	return path, nil
+}
+
+// orphanedTokens returns the tokens in the token file at path. A missing file has no tokens.
+func (n *NixConfig) orphanedTokens(path string) (map[string]string, error) {
+	config, err := n.parser.ParseFile(path)
+	if err != nil {
+		if IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	tokens, err := ParseAccessTokens(config.Settings[accessTokensKey])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return tokens, nil
+}
+
+// RestoreTokenInclude includes an orphaned token file from the main config
+// again, so Nix reads its tokens. Tokens the configs already set take precedence
+// over those in the file, as they are the ones Nix currently uses.
+func (n *NixConfig) RestoreTokenInclude() error {
+	path, err := n.OrphanedTokenFile()
+	if err != nil || path == "" {
+		return err
+	}
+
+	tokens, err := n.orphanedTokens(path)
+	if err != nil {
+		return err
+	}
+
+	config, err := n.parseFile(n.mainPath)
+	if err != nil && !IsNotExist(err) {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if err == nil {
+		existing, err := ParseAccessTokens(config.Settings[accessTokensKey])
+		if err != nil {
+			return fmt.Errorf("failed to parse existing tokens: %w", err)
+		}
+
+		for host := range existing {
+			delete(tokens, host)
+		}
+	}
+
+	return n.SetTokens(tokens)
+}
+
+// CheckMainConfig reports whether tokens can be saved without changes to the
+// main config that DisableMainConfigChanges forbids or that a main config in the
+// Nix store cannot take, so that a login can fail before the user authenticates.
+func (n *NixConfig) CheckMainConfig() error {
+	target := n.StorePath()
+	if !n.keepMain && target == "" {
+		return nil
+	}
+
+	config, err := n.parseFile(n.mainPath)
+	mainFileExists := err == nil
+
+	if err != nil {
+		if !IsNotExist(err) {
+			return fmt.Errorf("failed to parse config: %w", err)
+		}
+
+		config = NewParsedConfig()
+	}
+
+	if _, changed := n.mainConfigLines(config, mainFileExists); !changed {
+		return nil
+	}
+
+	if n.keepMain {
+		return n.mainConfigUnmanagedError(config, mainFileExists)
+	}
+
+	return n.storeConfigError(target)
+}
+
+// mainConfigUnmanagedError explains the change to the main config that saving
+// tokens needs but DisableMainConfigChanges forbids.
+func (n *NixConfig) mainConfigUnmanagedError(config *ParsedConfig, mainFileExists bool) error {
+	switch {
+	case !mainFileExists:
+		return fmt.Errorf("%w: %s does not exist; create it with '!include %s' manually or via home-manager",
+			ErrMainConfigUnmanaged, n.mainPath, accessTokensFile)
+	case n.tokensInMainFile(config):
+		return fmt.Errorf("%w: %s sets access-tokens itself; replace that line with '!include %s' manually or via home-manager",
+			ErrMainConfigUnmanaged, n.mainPath, accessTokensFile)
+	default:
+		return fmt.Errorf("%w: add '!include %s' to %s manually or via home-manager",
+			ErrMainConfigUnmanaged, accessTokensFile, n.mainPath)
 	}
+}
+
+// updateMainConfig backs up the main config and replaces it with lines.
+func (n *NixConfig) updateMainConfig(config *ParsedConfig, lines []ConfigLine) error {
+	if !n.noBackup {
+		backupPath, err := n.backupMainConfig(time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
+		}
 
-	fmt.Printf("Created backup: %s\n", backupPath)
+		fmt.Printf("Created backup: %s\n", backupPath)
 
-	// Replace access-tokens line with include directive (or just add include if no tokens)
-	newLines := n.replaceTokensWithInclude(config)
+		n.changes.Backups = append(n.changes.Backups, backupPath)
+	}
 
 	// Write updated main config
-	if err := config.WriteToFile(n.mainPath, newLines); err != nil {
+	if err := config.WriteToFile(n.mainPath, lines); err != nil {
 		return fmt.Errorf("failed to update main config: %w", err)
 	}
 
+	n.recordChange(n.mainPath)
+
 	return nil
 }
 
 // replaceTokensWithInclude replaces access-tokens lines with include directive, or appends it if no tokens found.
+// Only lines of the main config are returned; lines parsed from included files stay in those files.
 func (n *NixConfig) replaceTokensWithInclude(config *ParsedConfig) []ConfigLine {
 	newLines := make([]ConfigLine, 0, len(config.Lines))
 	tokenLineFound := false
 
-	for _, line := range config.Lines {
+	for _, line := range n.mainLines(config) {
 		// Replace access-tokens line with include directive
 		if line.Key == accessTokensKey && strings.HasSuffix(line.SourceFile, filepath.Base(n.mainPath)) {
 			// Replace this line with include directive
@@ -222,10 +730,14 @@ func (n *NixConfig) replaceTokensWithInclude(config *ParsedConfig) []ConfigLine
 
 // RemoveToken removes the access token for a given host.
 func (n *NixConfig) RemoveToken(host string) error {
-	config, err := n.parser.ParseFile(n.mainPath)
+	if n.readOnly() {
+		return ErrReadOnlyConfig
+	}
+
+	config, err := n.parseFile(n.mainPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("no configuration file found")
+		if IsNotExist(err) {
+			return NotConfiguredf("no configuration file found")
 		}
 
 		return err
@@ -233,7 +745,7 @@ func (n *NixConfig) RemoveToken(host string) error {
 
 	tokenValue, exists := config.Settings[accessTokensKey]
 	if !exists {
-		return fmt.Errorf("no tokens configured")
+		return NotConfiguredf("no tokens configured")
 	}
 
 	tokens, err := ParseAccessTokens(tokenValue)
@@ -242,7 +754,11 @@ func (n *NixConfig) RemoveToken(host string) error {
 	}
 
 	if _, exists := tokens[host]; !exists {
-		return fmt.Errorf("no token found for %s", host)
+		return NotConfiguredf("no token found for %s", host)
+	}
+
+	if err := n.checkWritable(); err != nil {
+		return err
 	}
 
 	// Remove the token
@@ -252,10 +768,14 @@ func (n *NixConfig) RemoveToken(host string) error {
 	tokenFilePath := n.GetTokenFilePath()
 	if len(tokens) == 0 {
 		// Remove token file if empty
-		if err := os.Remove(tokenFilePath); err != nil && !os.IsNotExist(err) {
+		if err := n.removeFile(tokenFilePath); err != nil {
 			return err
 		}
 
+		if n.encryption != nil {
+			return n.removePlainTokenFile()
+		}
+
 		return nil
 	}
 
@@ -264,9 +784,9 @@ func (n *NixConfig) RemoveToken(host string) error {
 
 // ListTokens returns all configured access tokens (hosts only).
 func (n *NixConfig) ListTokens() ([]string, error) {
-	config, err := n.parser.ParseFile(n.mainPath)
+	config, err := n.parseFile(n.mainPath)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if IsNotExist(err) {
 			return []string{}, nil
 		}
 
@@ -293,22 +813,115 @@ func (n *NixConfig) ListTokens() ([]string, error) {
 	return hosts, nil
 }
 
-// GetTokenFilePath returns the path to the token file.
+// checkWritable verifies up front that the config directory and token file can be
+// written, so permission problems surface as a clear error before anything changes.
+func (n *NixConfig) checkWritable() error {
+	dir := filepath.Dir(n.mainPath)
+
+	probe, err := os.CreateTemp(dir, ".nix-auth-write-check-*")
+	if err != nil {
+		return fmt.Errorf("config directory %s is not writable; check ownership/permissions: %w", dir, err)
+	}
+
+	_ = probe.Close()
+	_ = os.Remove(probe.Name())
+
+	tokenFilePath := n.GetTokenFilePath()
+
+	file, err := os.OpenFile(tokenFilePath, os.O_WRONLY, 0) //nolint:gosec // trusted config file path
+	if err != nil {
+		if IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("token file %s is not writable; check ownership/permissions: %w", tokenFilePath, err)
+	}
+
+	return file.Close()
+}
+
+// GetTokenFilePath returns the path to the token file, which is the encrypted
+// file when encryption is enabled.
 func (n *NixConfig) GetTokenFilePath() string {
-	return filepath.Join(filepath.Dir(n.mainPath), accessTokensFile)
+	if n.encryption != nil {
+		return n.plainTokenFilePath() + n.encryption.Extension()
+	}
+
+	return n.plainTokenFilePath()
 }
 
 // writeTokenFile writes tokens to the token file with restricted permissions.
+// With encryption enabled, the plaintext token file is removed afterwards.
 func (n *NixConfig) writeTokenFile(path string, tokens map[string]string) error {
+	content := []byte(formatTokenFile(tokens))
+
+	if n.encryption == nil {
+		return n.writeRestricted(path, content)
+	}
+
+	ciphertext, err := n.encryption.Encrypt(content)
+	if err != nil {
+		return err
+	}
+
+	if err := n.writeRestricted(path, ciphertext); err != nil {
+		return err
+	}
+
+	return n.removePlainTokenFile()
+}
+
+// writeRestricted writes content to path and sets the token file permissions,
+// which os.WriteFile alone leaves unchanged for an existing file and subject to
+// the umask for a new one.
+func (n *NixConfig) writeRestricted(path string, content []byte) error {
+	if err := os.WriteFile(path, content, n.tokenMode()); err != nil {
+		return err
+	}
+
+	n.recordChange(path)
+
+	return os.Chmod(path, n.tokenMode())
+}
+
+// removePlainTokenFile removes the plaintext token file once its tokens are encrypted.
+func (n *NixConfig) removePlainTokenFile() error {
+	return n.removeFile(n.plainTokenFilePath())
+}
+
+// formatTokenFile formats tokens as the content of the token file.
+func formatTokenFile(tokens map[string]string) string {
 	content := FormatAccessTokens(tokens)
 	if content != "" {
 		content = accessTokensKey + " = " + content + "\n"
 	}
 
-	return os.WriteFile(path, []byte(content), tokenFilePermissions)
+	return content
 }
 
-// createBackup creates a backup of a file preserving permissions.
+// backupMainConfig copies the main config to a new backup named after now and
+// returns its path. A backup made within the same second as another gets a
+// numbered suffix, so an existing backup is never overwritten.
+func (n *NixConfig) backupMainConfig(now time.Time) (string, error) {
+	base := fmt.Sprintf("%s.backup-%s", n.mainPath, now.Format(backupTimeFormat))
+
+	for attempt := 1; attempt <= maxBackupAttempts; attempt++ {
+		path := base
+		if attempt > 1 {
+			path = fmt.Sprintf("%s-%d", base, attempt)
+		}
+
+		err := n.createBackup(n.mainPath, path)
+		if !errors.Is(err, fs.ErrExist) {
+			return path, err
+		}
+	}
+
+	return "", fmt.Errorf("%s and %d numbered backups after it already exist", base, maxBackupAttempts-1)
+}
+
+// createBackup creates a backup of a file preserving permissions. It fails
+// with an error matching fs.ErrExist rather than overwrite dst.
 func (n *NixConfig) createBackup(src, dst string) error {
 	input, err := os.ReadFile(src) //nolint:gosec // trusted config file path
 	if err != nil {
@@ -321,7 +934,19 @@ func (n *NixConfig) createBackup(src, dst string) error {
 		perms = info.Mode()
 	}
 
-	return os.WriteFile(dst, input, perms)
+	file, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perms) //nolint:gosec // trusted config file path
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(input); err != nil {
+		_ = file.Close()
+		_ = os.Remove(dst)
+
+		return err
+	}
+
+	return file.Close()
 }
 
 // expandTilde expands ~ to the user's home directory.
@@ -1,6 +1,7 @@
 package nixconf
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,42 +12,48 @@ import (
 
 func TestDefaultUserConfigPath(t *testing.T) {
 	tests := []struct {
-		name     string
-		envVars  map[string]string
-		expected string
+		name      string
+		envVars   map[string]string
+		expected  string
+		discovery string
 	}{
 		{
-			name:     "default path when no env vars set",
-			envVars:  map[string]string{},
-			expected: "~/.config/nix/nix.conf",
+			name:      "default path when no env vars set",
+			envVars:   map[string]string{},
+			expected:  "~/.config/nix/nix.conf",
+			discovery: DiscoveryDefault,
 		},
 		{
 			name: "NIX_USER_CONF_FILES single file",
 			envVars: map[string]string{
 				"NIX_USER_CONF_FILES": "/custom/path/nix.conf",
 			},
-			expected: "/custom/path/nix.conf",
+			expected:  "/custom/path/nix.conf",
+			discovery: DiscoveryNixUserConfFiles,
 		},
 		{
 			name: "NIX_USER_CONF_FILES multiple files",
 			envVars: map[string]string{
 				"NIX_USER_CONF_FILES": "/first/path.conf:/second/path.conf",
 			},
-			expected: "/first/path.conf",
+			expected:  "/first/path.conf",
+			discovery: DiscoveryNixUserConfFiles,
 		},
 		{
 			name: "NIX_USER_CONF_FILES empty first element",
 			envVars: map[string]string{
 				"NIX_USER_CONF_FILES": ":/second/path.conf",
 			},
-			expected: "~/.config/nix/nix.conf",
+			expected:  "~/.config/nix/nix.conf",
+			discovery: DiscoveryDefault,
 		},
 		{
 			name: "XDG_CONFIG_HOME set",
 			envVars: map[string]string{
 				"XDG_CONFIG_HOME": "/custom/config",
 			},
-			expected: "/custom/config/nix/nix.conf",
+			expected:  "/custom/config/nix/nix.conf",
+			discovery: DiscoveryXDGConfigHome,
 		},
 		{
 			name: "NIX_USER_CONF_FILES takes precedence over XDG_CONFIG_HOME",
@@ -54,7 +61,8 @@ func TestDefaultUserConfigPath(t *testing.T) {
 				"NIX_USER_CONF_FILES": "/priority/path.conf",
 				"XDG_CONFIG_HOME":     "/custom/config",
 			},
-			expected: "/priority/path.conf",
+			expected:  "/priority/path.conf",
+			discovery: DiscoveryNixUserConfFiles,
 		},
 	}
 
@@ -83,6 +91,10 @@ func TestDefaultUserConfigPath(t *testing.T) {
 				t.Errorf("DefaultUserConfigPath() = %v, want %v", got, tt.expected)
 			}
 
+			if _, discovery := UserConfigPath(); discovery != tt.discovery {
+				t.Errorf("UserConfigPath() discovery = %v, want %v", discovery, tt.discovery)
+			}
+
 			// Restore env vars
 			for key, value := range savedEnvVars {
 				if value == "" {
@@ -167,6 +179,47 @@ func TestNixConfig_SetAndGetToken(t *testing.T) {
 	}
 }
 
+func TestNixConfig_TokenWithEquals(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "nix.conf")
+
+	cfg, err := New(configPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Base64 tokens end with padding, which must survive a write and re-read
+	const padded = "ZW50ZXJwcmlzZS10b2tlbg=="
+
+	if err := cfg.SetToken("git.company.com", padded); err != nil {
+		t.Fatalf("SetToken() error = %v", err)
+	}
+
+	if err := cfg.SetToken("github.com", "ghp_testtoken123"); err != nil {
+		t.Fatalf("SetToken() error = %v", err)
+	}
+
+	reopened, err := New(configPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if got, _ := reopened.GetToken("git.company.com"); got != padded {
+		t.Errorf("GetToken() = %q, want %q", got, padded)
+	}
+
+	if err := reopened.SetToken("git.company.com", "bmV3LXRva2Vu="); err != nil {
+		t.Fatalf("SetToken() error = %v", err)
+	}
+
+	if got, _ := reopened.GetToken("git.company.com"); got != "bmV3LXRva2Vu=" {
+		t.Errorf("GetToken() after update = %q, want %q", got, "bmV3LXRva2Vu=")
+	}
+
+	if got, _ := reopened.GetToken("github.com"); got != "ghp_testtoken123" {
+		t.Errorf("GetToken() of the other host = %q, want it unchanged", got)
+	}
+}
+
 func TestNixConfig_UpdateExistingToken(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "nix.conf")
@@ -328,6 +381,159 @@ access-tokens = github.com=only_token
 	}
 }
 
+func TestNixConfig_DuplicateIncludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		keepMain bool
+		want     string
+	}{
+		{
+			name:    "identical includes are collapsed",
+			content: "experimental-features = flakes\n!include access-tokens.conf\nsubstituters = https://cache.nixos.org\n!include access-tokens.conf\n",
+			want:    "experimental-features = flakes\n!include access-tokens.conf\nsubstituters = https://cache.nixos.org\n",
+		},
+		{
+			name:    "relative and absolute includes are collapsed",
+			content: "!include access-tokens.conf\n!include ABS\n",
+			want:    "!include access-tokens.conf\n",
+		},
+		{
+			name:    "include next to migrated tokens is not duplicated",
+			content: "!include access-tokens.conf\naccess-tokens = existing.com=token\n",
+			want:    "!include access-tokens.conf\n",
+		},
+		{
+			name:     "declaratively managed config is left alone",
+			content:  "!include access-tokens.conf\n!include access-tokens.conf\n",
+			keepMain: true,
+			want:     "!include access-tokens.conf\n!include access-tokens.conf\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "nix.conf")
+			content := strings.ReplaceAll(tt.content, "ABS", filepath.Join(tmpDir, "access-tokens.conf"))
+
+			if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			cfg, err := New(configPath)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			cfg.DisableBackups()
+
+			if tt.keepMain {
+				cfg.DisableMainConfigChanges()
+			}
+
+			if err := cfg.SetToken("github.com", "token"); err != nil {
+				t.Fatalf("SetToken() error = %v", err)
+			}
+
+			got, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("nix.conf = %q, want %q", got, tt.want)
+			}
+
+			if token, _ := cfg.GetToken("github.com"); token != "token" {
+				t.Errorf("GetToken() = %q, want %q", token, "token")
+			}
+		})
+	}
+}
+
+func TestNixConfig_OrphanedTokenFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		tokenFile    string
+		wantOrphaned bool
+		want         map[string]string
+	}{
+		{
+			name:         "include removed by hand",
+			content:      "experimental-features = flakes\n",
+			tokenFile:    "access-tokens = github.com=ghp_orphan\n",
+			wantOrphaned: true,
+			want:         map[string]string{"github.com": "ghp_orphan"},
+		},
+		{
+			name:      "included token file",
+			content:   "!include access-tokens.conf\n",
+			tokenFile: "access-tokens = github.com=ghp_orphan\n",
+			want:      map[string]string{"github.com": "ghp_orphan"},
+		},
+		{
+			name:      "empty token file",
+			content:   "experimental-features = flakes\n",
+			tokenFile: "\n",
+			want:      map[string]string{},
+		},
+		{
+			name:         "tokens in the main config take precedence",
+			content:      "access-tokens = github.com=ghp_inline\n",
+			tokenFile:    "access-tokens = github.com=ghp_orphan gitlab.com=glpat_orphan\n",
+			wantOrphaned: true,
+			want:         map[string]string{"github.com": "ghp_inline", "gitlab.com": "glpat_orphan"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "nix.conf")
+
+			if err := os.WriteFile(configPath, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			if err := os.WriteFile(filepath.Join(tmpDir, "access-tokens.conf"), []byte(tt.tokenFile), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			cfg, err := New(configPath)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			cfg.DisableBackups()
+
+			path, err := cfg.OrphanedTokenFile()
+			if err != nil {
+				t.Fatalf("OrphanedTokenFile() error = %v", err)
+			}
+
+			if (path != "") != tt.wantOrphaned {
+				t.Errorf("OrphanedTokenFile() = %q, want orphaned %v", path, tt.wantOrphaned)
+			}
+
+			if err := cfg.RestoreTokenInclude(); err != nil {
+				t.Fatalf("RestoreTokenInclude() error = %v", err)
+			}
+
+			if path, _ := cfg.OrphanedTokenFile(); path != "" {
+				t.Errorf("OrphanedTokenFile() after restoring = %q, want none", path)
+			}
+
+			for host, want := range tt.want {
+				if got, _ := cfg.GetToken(host); got != want {
+					t.Errorf("GetToken(%q) = %q, want %q", host, got, want)
+				}
+			}
+		})
+	}
+}
+
 func TestNixConfig_Backup(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "nix.conf")
@@ -378,6 +584,266 @@ func TestNixConfig_Backup(t *testing.T) {
 	}
 }
 
+func TestNixConfig_BackupNamesAreUnique(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "nix.conf")
+
+	cfg, err := New(configPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	base := configPath + ".backup-20250101-120000"
+
+	// Backups within the same second, each of a different version of nix.conf
+	for i, want := range []string{base, base + "-2", base + "-3"} {
+		content := fmt.Sprintf("# version %d\n", i)
+		if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+
+		got, err := cfg.backupMainConfig(now)
+		if err != nil {
+			t.Fatalf("backupMainConfig() error = %v", err)
+		}
+
+		if got != want {
+			t.Errorf("backupMainConfig() = %q, want %q", got, want)
+		}
+	}
+
+	// No backup was overwritten by a later one
+	for i, path := range []string{base, base + "-2", base + "-3"} {
+		content, err := os.ReadFile(path) //nolint:gosec // test file path
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+
+		if want := fmt.Sprintf("# version %d\n", i); string(content) != want {
+			t.Errorf("%s = %q, want %q", path, content, want)
+		}
+	}
+}
+
+func TestNixConfig_DisableBackups(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "nix.conf")
+
+	// Tokens in the main file trigger a migration, which normally creates a backup
+	if err := os.WriteFile(configPath, []byte("access-tokens = existing.com=token\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cfg, err := New(configPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	cfg.DisableBackups()
+
+	if err := cfg.SetToken("github.com", "token"); err != nil {
+		t.Fatalf("SetToken() error = %v", err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(tmpDir, "nix.conf.backup-*"))
+	if err != nil {
+		t.Fatalf("Glob() error = %v", err)
+	}
+
+	if len(matches) != 0 {
+		t.Errorf("expected no backup files, found %v", matches)
+	}
+
+	if token, _ := cfg.GetToken("existing.com"); token != "token" {
+		t.Errorf("migrated token = %q, want %q", token, "token")
+	}
+}
+
+func TestNixConfig_MigrationKeepsIncludedSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "tokens migrated",
+			content: "include extra.conf\naccess-tokens = existing.com=token\n",
+			want:    "include extra.conf\n!include access-tokens.conf\n",
+		},
+		{
+			name:    "include added",
+			content: "include extra.conf\nexperimental-features = flakes\n",
+			want:    "include extra.conf\nexperimental-features = flakes\n!include access-tokens.conf\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "nix.conf")
+			extraPath := filepath.Join(tmpDir, "extra.conf")
+			extraContent := "substituters = https://cache.example.com\n"
+
+			if err := os.WriteFile(configPath, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			if err := os.WriteFile(extraPath, []byte(extraContent), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			cfg, err := New(configPath)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			cfg.DisableBackups()
+
+			changes, err := cfg.PreviewSetToken("github.com", "token")
+			if err != nil {
+				t.Fatalf("PreviewSetToken() error = %v", err)
+			}
+
+			if len(changes) != 2 || changes[1].New != tt.want {
+				t.Errorf("PreviewSetToken() = %+v, want main config %q", changes, tt.want)
+			}
+
+			if err := cfg.SetToken("github.com", "token"); err != nil {
+				t.Fatalf("SetToken() error = %v", err)
+			}
+
+			// Settings read from an included file stay there rather than being copied into nix.conf
+			content, err := os.ReadFile(configPath) //nolint:gosec // test file path
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+
+			if string(content) != tt.want {
+				t.Errorf("main config = %q, want %q", content, tt.want)
+			}
+
+			extra, err := os.ReadFile(extraPath) //nolint:gosec // test file path
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+
+			if string(extra) != extraContent {
+				t.Errorf("included config = %q, want %q", extra, extraContent)
+			}
+		})
+	}
+}
+
+func TestNixConfig_Changes(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "nix.conf")
+	tokenPath := filepath.Join(tmpDir, "access-tokens.conf")
+
+	if err := os.WriteFile(configPath, []byte("access-tokens = existing.com=token\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cfg, err := New(configPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := cfg.SetToken("github.com", "token"); err != nil {
+		t.Fatalf("SetToken() error = %v", err)
+	}
+
+	changes := cfg.Changes()
+	if got, want := strings.Join(changes.Files, ","), tokenPath+","+configPath; got != want {
+		t.Errorf("Files = %s, want %s", got, want)
+	}
+
+	if len(changes.Backups) != 1 || !strings.HasPrefix(changes.Backups[0], configPath+".backup-") {
+		t.Errorf("Backups = %v, want one backup of %s", changes.Backups, configPath)
+	}
+
+	// Without tokens in the main file, only the token file changes
+	cfg, err = New(configPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := cfg.RemoveToken("github.com"); err != nil {
+		t.Fatalf("RemoveToken() error = %v", err)
+	}
+
+	changes = cfg.Changes()
+	if got := strings.Join(changes.Files, ","); got != tokenPath || len(changes.Backups) != 0 {
+		t.Errorf("Changes() = %+v, want only %s", changes, tokenPath)
+	}
+}
+
+func TestNixConfig_DisableMainConfigChanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		missing     bool
+		expectError bool
+	}{
+		{name: "include present", content: "experimental-features = flakes\n!include access-tokens.conf\n"},
+		{name: "include missing", content: "experimental-features = flakes\n", expectError: true},
+		{name: "tokens in main file", content: "access-tokens = existing.com=token\n", expectError: true},
+		{name: "main file missing", missing: true, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "nix.conf")
+
+			if !tt.missing {
+				if err := os.WriteFile(configPath, []byte(tt.content), 0o600); err != nil {
+					t.Fatalf("WriteFile() error = %v", err)
+				}
+			}
+
+			cfg, err := New(configPath)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			cfg.DisableMainConfigChanges()
+
+			checkErr := cfg.CheckMainConfig()
+			setErr := cfg.SetToken("github.com", "token")
+
+			if !tt.expectError {
+				if checkErr != nil || setErr != nil {
+					t.Fatalf("CheckMainConfig() = %v, SetToken() = %v; want no errors", checkErr, setErr)
+				}
+
+				if token, _ := cfg.GetToken("github.com"); token != "token" {
+					t.Errorf("GetToken() = %q, want %q", token, "token")
+				}
+
+				return
+			}
+
+			if !errors.Is(checkErr, ErrMainConfigUnmanaged) || !errors.Is(setErr, ErrMainConfigUnmanaged) {
+				t.Fatalf("CheckMainConfig() = %v, SetToken() = %v; want ErrMainConfigUnmanaged", checkErr, setErr)
+			}
+
+			if !strings.Contains(setErr.Error(), "!include access-tokens.conf") {
+				t.Errorf("error %q does not say how to add the include", setErr)
+			}
+
+			if _, err := os.Stat(filepath.Join(tmpDir, "access-tokens.conf")); !os.IsNotExist(err) {
+				t.Errorf("token file was written despite the error")
+			}
+
+			after, err := os.ReadFile(configPath)
+			if tt.missing != os.IsNotExist(err) || string(after) != tt.content {
+				t.Errorf("main config was modified:\n%s", after)
+			}
+		})
+	}
+}
+
 func TestNixConfig_EmptyFile(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "nix.conf")
@@ -438,6 +904,30 @@ func TestNixConfig_NonExistentFile(t *testing.T) {
 	}
 }
 
+func TestNew_RejectsDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	_, err := New(tmpDir)
+	if err == nil || !strings.Contains(err.Error(), "is a directory") {
+		t.Errorf("New() error = %v, want a directory error", err)
+	}
+
+	// A symlink to a regular file is fine
+	target := filepath.Join(tmpDir, "real.conf")
+	if err := os.WriteFile(target, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(tmpDir, "nix.conf")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(link); err != nil {
+		t.Errorf("New() with a symlink to a file error = %v", err)
+	}
+}
+
 func TestNixConfig_InvalidTokenFormat(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "nix.conf")
@@ -491,6 +981,64 @@ func TestNixConfig_InvalidTokenFormat(t *testing.T) {
 	}
 }
 
+func TestNixConfig_TokenLineWithTrailingComment(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "nix.conf")
+	tokenPath := filepath.Join(tmpDir, "access-tokens.conf")
+
+	if err := os.WriteFile(configPath, []byte("!include access-tokens.conf\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile(main) error = %v", err)
+	}
+
+	// The comment contains '=' so it would be read as a token pair if it reached ParseAccessTokens
+	tokenContent := "access-tokens = github.com=token1 gitlab.com=token2 # work tokens, owner=ops\n"
+	if err := os.WriteFile(tokenPath, []byte(tokenContent), 0o600); err != nil {
+		t.Fatalf("WriteFile(token) error = %v", err)
+	}
+
+	cfg, err := New(configPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	hosts, err := cfg.ListTokens()
+	if err != nil {
+		t.Fatalf("ListTokens() error = %v", err)
+	}
+
+	if strings.Join(hosts, ",") != "github.com,gitlab.com" {
+		t.Errorf("ListTokens() = %v, want [github.com gitlab.com]", hosts)
+	}
+
+	token, err := cfg.GetToken("gitlab.com")
+	if err != nil {
+		t.Fatalf("GetToken() error = %v", err)
+	}
+
+	if token != "token2" {
+		t.Errorf("GetToken() = %q, want %q", token, "token2")
+	}
+
+	// Writes and removals work from the comment-stripped value too
+	if err := cfg.SetToken("gitea.com", "token3"); err != nil {
+		t.Fatalf("SetToken() error = %v", err)
+	}
+
+	if err := cfg.RemoveToken("github.com"); err != nil {
+		t.Fatalf("RemoveToken() error = %v", err)
+	}
+
+	written, err := os.ReadFile(tokenPath) //nolint:gosec // test file path
+	if err != nil {
+		t.Fatalf("ReadFile(token) error = %v", err)
+	}
+
+	expected := "access-tokens = gitea.com=token3 gitlab.com=token2\n"
+	if string(written) != expected {
+		t.Errorf("token file = %q, want %q", string(written), expected)
+	}
+}
+
 func TestNixConfig_PreservesWhitespaceAndComments(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "nix.conf")
@@ -563,6 +1111,135 @@ trusted-users = root user
 	}
 }
 
+func TestNixConfig_TokenFileMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    os.FileMode
+		wantErr bool
+	}{
+		{name: "default", mode: 0},
+		{name: "group readable", mode: 0o640},
+		{name: "world readable", mode: 0o644, wantErr: true},
+		{name: "owner cannot write", mode: 0o400, wantErr: true},
+		{name: "setuid", mode: os.ModeSetuid | 0o600, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "nix.conf")
+
+			cfg, err := New(configPath)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			want := os.FileMode(tokenFilePermissions)
+
+			if tt.mode != 0 {
+				err := cfg.SetTokenFileMode(tt.mode)
+				if tt.wantErr {
+					if err == nil {
+						t.Errorf("SetTokenFileMode(%04o) expected error", tt.mode)
+					}
+
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("SetTokenFileMode() error = %v", err)
+				}
+
+				want = tt.mode
+			}
+
+			// An existing token file with other permissions is corrected
+			tokenFile := filepath.Join(tmpDir, accessTokensFile)
+			if err := os.WriteFile(tokenFile, nil, 0o604); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			if err := cfg.SetToken("github.com", "token"); err != nil {
+				t.Fatalf("SetToken() error = %v", err)
+			}
+
+			info, err := os.Stat(tokenFile)
+			if err != nil {
+				t.Fatalf("Stat() error = %v", err)
+			}
+
+			if info.Mode().Perm() != want {
+				t.Errorf("token file mode = %04o, want %04o", info.Mode().Perm(), want)
+			}
+
+			if issues, err := cfg.Lint(); err != nil || len(issues) != 0 {
+				t.Errorf("Lint() = %v, %v; want no issues", issues, err)
+			}
+		})
+	}
+}
+
+func TestNixConfig_NotWritable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permission checks do not apply to root")
+	}
+
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T, dir string)
+		errContains string
+	}{
+		{
+			name: "read-only directory",
+			setup: func(t *testing.T, dir string) {
+				t.Helper()
+
+				if err := os.Chmod(dir, 0o500); err != nil { //nolint:gosec // test directory
+					t.Fatalf("Chmod() error = %v", err)
+				}
+
+				t.Cleanup(func() { _ = os.Chmod(dir, 0o700) }) //nolint:gosec // test directory
+			},
+			errContains: "is not writable; check ownership/permissions",
+		},
+		{
+			name: "read-only token file",
+			setup: func(t *testing.T, dir string) {
+				t.Helper()
+
+				tokenPath := filepath.Join(dir, "access-tokens.conf")
+				if err := os.WriteFile(tokenPath, []byte("access-tokens = github.com=token1\n"), 0o400); err != nil {
+					t.Fatalf("WriteFile() error = %v", err)
+				}
+			},
+			errContains: "access-tokens.conf is not writable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "nix.conf")
+
+			if err := os.WriteFile(configPath, []byte("!include access-tokens.conf\n"), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			tt.setup(t, tmpDir)
+
+			cfg, err := New(configPath)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			err = cfg.SetToken("gitlab.com", "token2")
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("SetToken() error = %v, want error containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
 func TestNixConfig_ConcurrentAccess(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "nix.conf")
@@ -3,6 +3,7 @@ package nixconf
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -11,7 +12,30 @@ import (
 
 // Parser parses nix config files while preserving formatting and comments.
 type Parser struct {
+	// visited holds the files on the current include chain. A file included
+	// again outside that chain is read again, as Nix does.
 	visited map[string]bool
+	// strict rejects configs with unparseable lines, duplicate settings or
+	// malformed tokens instead of making the best of them.
+	strict bool
+	// firstSet records where each setting was first set, for strict parsing.
+	firstSet map[string]string
+	// problems collects what strict parsing rejects.
+	problems []LintIssue
+}
+
+// StrictParseError lists every problem a strict parser found in a config.
+type StrictParseError struct {
+	Problems []LintIssue
+}
+
+func (e *StrictParseError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		lines = append(lines, problem.String())
+	}
+
+	return "strict parsing failed:\n" + strings.Join(lines, "\n")
 }
 
 // ConfigLine represents a single line in the config with metadata.
@@ -48,16 +72,60 @@ func NewParser() *Parser {
 	}
 }
 
+// NewStrictParser creates a parser that, unlike NewParser, fails with a
+// *StrictParseError on lines it cannot parse, settings set more than once
+// (other than additive extra-* settings) and malformed access-tokens.
+func NewStrictParser() *Parser {
+	p := NewParser()
+	p.strict = true
+
+	return p
+}
+
+// walker returns a parser for a single Parse or ParseFile call.
+func (p *Parser) walker() *Parser {
+	w := NewParser()
+	w.strict = p.strict
+	w.firstSet = make(map[string]string)
+
+	return w
+}
+
+// result returns config, or the problems found if parsing strictly.
+func (p *Parser) result(config *ParsedConfig) (*ParsedConfig, error) {
+	if len(p.problems) > 0 {
+		return nil, &StrictParseError{Problems: p.problems}
+	}
+
+	return config, nil
+}
+
+// Parse parses configuration read from r preserving all formatting. sourceName is
+// recorded as the SourceFile of every line. Includes with relative paths are
+// resolved against baseDir and followed; if baseDir is empty, includes are
+// recorded but not followed.
+func (p *Parser) Parse(r io.Reader, sourceName, baseDir string) (*ParsedConfig, error) {
+	config := NewParsedConfig()
+
+	walker := p.walker()
+	if err := walker.parseReader(r, sourceName, baseDir, config); err != nil {
+		return nil, err
+	}
+
+	return walker.result(config)
+}
+
 // ParseFile parses a config file preserving all formatting.
+// It is safe to call concurrently; each call tracks visited includes separately.
 func (p *Parser) ParseFile(path string) (*ParsedConfig, error) {
 	config := NewParsedConfig()
 
-	p.visited = make(map[string]bool)
-	if err := p.parseFileRecursive(path, config); err != nil {
+	walker := p.walker()
+	if err := walker.parseFileRecursive(path, config); err != nil {
 		return nil, err
 	}
 
-	return config, nil
+	return walker.result(config)
 }
 
 func (p *Parser) parseFileRecursive(path string, config *ParsedConfig) error {
@@ -71,6 +139,7 @@ func (p *Parser) parseFileRecursive(path string, config *ParsedConfig) error {
 	}
 
 	p.visited[absPath] = true
+	defer delete(p.visited, absPath)
 
 	file, err := os.Open(absPath) //nolint:gosec // trusted config file path
 	if err != nil {
@@ -79,7 +148,12 @@ func (p *Parser) parseFileRecursive(path string, config *ParsedConfig) error {
 
 	defer func() { _ = file.Close() }()
 
-	scanner := bufio.NewScanner(file)
+	return p.parseReader(file, absPath, filepath.Dir(absPath), config)
+}
+
+// parseReader parses the lines read from r into config.
+func (p *Parser) parseReader(r io.Reader, source, baseDir string, config *ParsedConfig) error {
+	scanner := bufio.NewScanner(r)
 	lineNum := 0
 
 	for scanner.Scan() {
@@ -88,16 +162,22 @@ func (p *Parser) parseFileRecursive(path string, config *ParsedConfig) error {
 
 		line := ConfigLine{
 			Raw:        rawLine,
-			SourceFile: absPath,
+			SourceFile: source,
 			LineNum:    lineNum,
 		}
 
 		// Parse the line without modifying it
 		p.parseLine(&line)
 
+		if p.strict {
+			p.checkLine(&line)
+		}
+
 		// Handle includes and settings
 		if line.IsInclude {
-			if err := p.handleInclude(&line, rawLine, absPath, lineNum, config); err != nil {
+			if baseDir == "" {
+				config.Includes[line.IncludePath] = true
+			} else if err := p.handleInclude(&line, rawLine, source, baseDir, lineNum, config); err != nil {
 				return err
 			}
 		} else if line.Key != "" {
@@ -112,11 +192,12 @@ func (p *Parser) parseFileRecursive(path string, config *ParsedConfig) error {
 	return scanner.Err()
 }
 
-// handleInclude processes an include directive.
-func (p *Parser) handleInclude(line *ConfigLine, rawLine, absPath string, lineNum int, config *ParsedConfig) error {
+// handleInclude processes an include directive found in source, resolving
+// relative paths against baseDir.
+func (p *Parser) handleInclude(line *ConfigLine, rawLine, source, baseDir string, lineNum int, config *ParsedConfig) error {
 	includePath := line.IncludePath
 	if !filepath.IsAbs(includePath) {
-		includePath = filepath.Join(filepath.Dir(absPath), includePath)
+		includePath = filepath.Join(baseDir, includePath)
 	}
 
 	// Track that we have this include
@@ -126,14 +207,54 @@ func (p *Parser) handleInclude(line *ConfigLine, rawLine, absPath string, lineNu
 	err := p.parseFileRecursive(includePath, config)
 	if err != nil {
 		// !include ignores missing files
-		if !strings.HasPrefix(strings.TrimSpace(rawLine), "!include") || !os.IsNotExist(err) {
-			return fmt.Errorf("failed to include %s from %s:%d: %w", includePath, absPath, lineNum, err)
+		if !strings.HasPrefix(strings.TrimSpace(rawLine), "!include") || !IsNotExist(err) {
+			return fmt.Errorf("failed to include %s from %s:%d: %w", includePath, source, lineNum, err)
 		}
 	}
 
 	return nil
 }
 
+// checkLine records what strict parsing rejects about a parsed line.
+func (p *Parser) checkLine(line *ConfigLine) {
+	content, _, _ := strings.Cut(line.Raw, "#")
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	location := fmt.Sprintf("%s:%d", line.SourceFile, line.LineNum)
+	problem := func(format string, args ...any) {
+		p.problems = append(p.problems, LintIssue{
+			Path:    line.SourceFile,
+			Line:    line.LineNum,
+			Message: fmt.Sprintf(format, args...),
+			Syntax:  true,
+		})
+	}
+
+	switch {
+	case line.IsInclude:
+		// Missing files are reported by handleInclude for include, and
+		// deliberately ignored for !include
+	case line.Key != "":
+		if first, ok := p.firstSet[line.Key]; ok && !strings.HasPrefix(line.Key, "extra-") {
+			problem("%s is already set at %s", line.Key, first)
+		} else if !ok {
+			p.firstSet[line.Key] = location
+		}
+
+		if line.Key == accessTokensKey {
+			if _, err := ParseAccessTokens(line.Value); err != nil {
+				problem("%v", err)
+			}
+		}
+	case strings.Contains(content, "="):
+		problem("setting without a name")
+	default:
+		problem("cannot parse line")
+	}
+}
+
 // parseLine extracts key/value from a line without modifying it.
 func (p *Parser) parseLine(line *ConfigLine) {
 	// Find content before any comment
@@ -214,6 +335,8 @@ func (c *ParsedConfig) WriteToFile(path string, lines []ConfigLine) error {
 }
 
 // ParseAccessTokens parses the access-tokens setting value into a map.
+// The value must already have any trailing # comment removed, as done for
+// every setting by the parser, since comment text may itself contain '='.
 func ParseAccessTokens(value string) (map[string]string, error) {
 	tokens := make(map[string]string)
 
@@ -230,14 +353,14 @@ func ParseAccessTokens(value string) (map[string]string, error) {
 
 		parts := strings.SplitN(pair, "=", expectedParts)
 		if len(parts) != expectedParts {
-			return nil, fmt.Errorf("invalid token format: %s", pair)
+			return nil, fmt.Errorf("invalid token format: %s", maskTokenPair(pair))
 		}
 
 		host := parts[0]
 		token := parts[1]
 
 		if host == "" || token == "" {
-			return nil, fmt.Errorf("invalid token format: empty host or token in %s", pair)
+			return nil, fmt.Errorf("invalid token format: empty host or token in %s", maskTokenPair(pair))
 		}
 
 		tokens[host] = token
@@ -246,6 +369,21 @@ func ParseAccessTokens(value string) (map[string]string, error) {
 	return tokens, nil
 }
 
+// maskTokenPair hides the token of a host=token pair for error messages. A pair
+// without '=' may be a bare token, so it is hidden completely.
+func maskTokenPair(pair string) string {
+	host, token, found := strings.Cut(pair, "=")
+	if !found {
+		return "****"
+	}
+
+	if token == "" {
+		return pair
+	}
+
+	return host + "=****"
+}
+
 // FormatAccessTokens formats a token map into the access-tokens value format.
 func FormatAccessTokens(tokens map[string]string) string {
 	if len(tokens) == 0 {
@@ -1,8 +1,11 @@
 package nixconf
 
 import (
+	"errors"
+	"maps"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -124,6 +127,56 @@ include sub.conf`
 		}
 	})
 
+	t.Run("reads a file included twice each time", func(t *testing.T) {
+		subPath := filepath.Join(tmpDir, "twice.conf")
+		if err := os.WriteFile(subPath, []byte("bar = twice"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		mainPath := filepath.Join(tmpDir, "twice-main.conf")
+
+		mainContent := "include twice.conf\ninclude " + subPath + "\n"
+		if err := os.WriteFile(mainPath, []byte(mainContent), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		config, err := NewParser().ParseFile(mainPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var subLines int
+
+		for _, line := range config.Lines {
+			if line.SourceFile == subPath {
+				subLines++
+			}
+		}
+
+		// Nix reads a repeated include again, so a setting it holds is applied twice
+		if subLines != 2 {
+			t.Errorf("expected 2 lines from the included file, got %d", subLines)
+		}
+	})
+
+	t.Run("rejects circular includes", func(t *testing.T) {
+		aPath := filepath.Join(tmpDir, "cycle-a.conf")
+		bPath := filepath.Join(tmpDir, "cycle-b.conf")
+
+		if err := os.WriteFile(aPath, []byte("include cycle-b.conf"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(bPath, []byte("include cycle-a.conf"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := NewParser().ParseFile(aPath)
+		if err == nil || !strings.Contains(err.Error(), "circular include") {
+			t.Errorf("expected a circular include error, got %v", err)
+		}
+	})
+
 	t.Run("finds setting lines", func(t *testing.T) {
 		content := `foo = first
 bar = value
@@ -156,3 +209,195 @@ foo = second  # This should win`
 		}
 	})
 }
+
+func TestParser_Parse(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "tokens.conf"), []byte("access-tokens = github.com=ghp_included\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	content := "foo = bar\n!include tokens.conf\n"
+
+	t.Run("follows includes relative to baseDir", func(t *testing.T) {
+		config, err := NewParser().Parse(strings.NewReader(content), "snippet", tmpDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if config.Settings["access-tokens"] != "github.com=ghp_included" {
+			t.Errorf("included setting not read: %q", config.Settings["access-tokens"])
+		}
+
+		if config.Lines[0].SourceFile != "snippet" {
+			t.Errorf("SourceFile = %q, want snippet", config.Lines[0].SourceFile)
+		}
+	})
+
+	t.Run("records includes without baseDir", func(t *testing.T) {
+		config, err := NewParser().Parse(strings.NewReader(content), "snippet", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !config.HasInclude("tokens.conf") {
+			t.Error("include not tracked")
+		}
+
+		if _, exists := config.Settings["access-tokens"]; exists {
+			t.Error("include should not be followed without baseDir")
+		}
+
+		if config.Settings["foo"] != "bar" {
+			t.Errorf("foo setting wrong: %q", config.Settings["foo"])
+		}
+	})
+}
+
+func TestAccessTokens_TokensWithEquals(t *testing.T) {
+	tokens := map[string]string{
+		"github.com":      "ghp_plain123",
+		"git.company.com": "c2VjcmV0LXRva2Vu==",
+		"gitlab.com":      "a=b=c",
+		"other.com":       "dG9rZW4=",
+	}
+
+	value := FormatAccessTokens(tokens)
+
+	want := "git.company.com=c2VjcmV0LXRva2Vu== github.com=ghp_plain123 gitlab.com=a=b=c other.com=dG9rZW4="
+	if value != want {
+		t.Errorf("FormatAccessTokens() = %q, want %q", value, want)
+	}
+
+	got, err := ParseAccessTokens(value)
+	if err != nil {
+		t.Fatalf("ParseAccessTokens() error = %v", err)
+	}
+
+	if !maps.Equal(got, tokens) {
+		t.Errorf("ParseAccessTokens() = %v, want %v", got, tokens)
+	}
+}
+
+func TestParseAccessTokens_MasksTokensInErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		secret  string
+		wantErr string
+	}{
+		{
+			name:    "empty host",
+			value:   "github.com=ghp_valid =ghp_secret123",
+			secret:  "ghp_secret123",
+			wantErr: "invalid token format: empty host or token in =****",
+		},
+		{
+			name:    "bare token",
+			value:   "ghp_secret123",
+			secret:  "ghp_secret123",
+			wantErr: "invalid token format: ****",
+		},
+		{
+			name:    "empty token",
+			value:   "github.com=",
+			wantErr: "invalid token format: empty host or token in github.com=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAccessTokens(tt.value)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+
+			if err.Error() != tt.wantErr {
+				t.Errorf("error = %q, want %q", err.Error(), tt.wantErr)
+			}
+
+			if tt.secret != "" && strings.Contains(err.Error(), tt.secret) {
+				t.Errorf("error leaks the token: %q", err.Error())
+			}
+		})
+	}
+}
+
+func TestStrictParser(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		wantProblems []string
+	}{
+		{
+			name:    "clean config",
+			content: "# comment\nexperimental-features = nix-command flakes\nextra-substituters = a\nextra-substituters = b\n",
+		},
+		{
+			name:         "unparseable line",
+			content:      "experimental-features = flakes\nthis is not a setting\n",
+			wantProblems: []string{"nix.conf:2: cannot parse line"},
+		},
+		{
+			name:         "setting without a name",
+			content:      " = value # comment\n",
+			wantProblems: []string{"nix.conf:1: setting without a name"},
+		},
+		{
+			name:         "duplicate setting",
+			content:      "max-jobs = 4\nmax-jobs = 8\n",
+			wantProblems: []string{"nix.conf:2: max-jobs is already set at nix.conf:1"},
+		},
+		{
+			name:         "malformed tokens",
+			content:      "access-tokens = github.com=ghp_ok ghp_secret123\n",
+			wantProblems: []string{"nix.conf:1: invalid token format: ****"},
+		},
+		{
+			name:         "include without a path",
+			content:      "!include\n",
+			wantProblems: []string{"nix.conf:1: cannot parse line"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := NewStrictParser().Parse(strings.NewReader(tt.content), "nix.conf", "")
+
+			if len(tt.wantProblems) == 0 {
+				if err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+
+				// The lenient parser accepts everything the strict one does
+				if _, err := NewParser().Parse(strings.NewReader(tt.content), "nix.conf", ""); err != nil || config == nil {
+					t.Errorf("lenient Parse() error = %v", err)
+				}
+
+				return
+			}
+
+			var strictErr *StrictParseError
+			if !errors.As(err, &strictErr) {
+				t.Fatalf("Parse() error = %v, want *StrictParseError", err)
+			}
+
+			got := make([]string, 0, len(strictErr.Problems))
+			for _, problem := range strictErr.Problems {
+				got = append(got, problem.String())
+			}
+
+			if strings.Join(got, "\n") != strings.Join(tt.wantProblems, "\n") {
+				t.Errorf("problems = %q, want %q", got, tt.wantProblems)
+			}
+
+			if strings.Contains(err.Error(), "ghp_secret123") {
+				t.Errorf("error leaks the token: %q", err.Error())
+			}
+
+			if _, err := NewParser().Parse(strings.NewReader(tt.content), "nix.conf", ""); err != nil {
+				t.Errorf("lenient Parse() error = %v, want the config to be accepted", err)
+			}
+		})
+	}
+}
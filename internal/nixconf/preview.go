@@ -0,0 +1,149 @@
+package nixconf
+
+import (
+	"os"
+	"strings"
+)
+
+// FileChange describes how an operation would change one file.
+type FileChange struct {
+	Path string
+	// Old is the current content, empty if the file does not exist.
+	Old string
+	// New is the content after the operation, empty if the file is removed.
+	New string
+	// Removed is set if the operation deletes the file.
+	Removed bool
+}
+
+// PreviewSetToken returns the changes SetToken would make for host without
+// applying them. An encrypted token file is shown decrypted.
+func (n *NixConfig) PreviewSetToken(host, token string) ([]FileChange, error) {
+	config, err := n.parseFile(n.mainPath)
+	mainFileExists := err == nil
+
+	if err != nil {
+		if !IsNotExist(err) {
+			return nil, err
+		}
+
+		config = NewParsedConfig()
+	}
+
+	tokens, err := mergeTokens(config, map[string]string{host: token})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FileChange
+
+	tokenChange, err := n.tokenFileChange(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	changes = appendChange(changes, tokenChange)
+
+	if lines, changed := n.mainConfigLines(config, mainFileExists); changed {
+		if n.keepMain {
+			return nil, n.mainConfigUnmanagedError(config, mainFileExists)
+		}
+
+		old, err := readFileOrEmpty(n.mainPath)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = appendChange(changes, FileChange{Path: n.mainPath, Old: old, New: formatLines(lines)})
+	}
+
+	return changes, nil
+}
+
+// PreviewRemoveToken returns the changes RemoveToken would make for host
+// without applying them. An encrypted token file is shown decrypted.
+func (n *NixConfig) PreviewRemoveToken(host string) ([]FileChange, error) {
+	config, err := n.parseFile(n.mainPath)
+	if err != nil {
+		if IsNotExist(err) {
+			return nil, NotConfiguredf("no configuration file found")
+		}
+
+		return nil, err
+	}
+
+	tokens, err := mergeTokens(config, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, exists := tokens[host]; !exists {
+		return nil, NotConfiguredf("no token found for %s", host)
+	}
+
+	delete(tokens, host)
+
+	change, err := n.tokenFileChange(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		change.New = ""
+		change.Removed = true
+	}
+
+	return appendChange(nil, change), nil
+}
+
+// tokenFileChange returns the change of the token file when it is rewritten with tokens.
+func (n *NixConfig) tokenFileChange(tokens map[string]string) (FileChange, error) {
+	change := FileChange{Path: n.GetTokenFilePath(), New: formatTokenFile(tokens)}
+
+	if n.encryption != nil {
+		current, err := n.readEncryptedTokens()
+		if err != nil {
+			return change, err
+		}
+
+		change.Old = formatTokenFile(current)
+
+		return change, nil
+	}
+
+	old, err := readFileOrEmpty(change.Path)
+	change.Old = old
+
+	return change, err
+}
+
+// appendChange appends change to changes unless it changes nothing.
+func appendChange(changes []FileChange, change FileChange) []FileChange {
+	if change.Old == change.New && !change.Removed {
+		return changes
+	}
+
+	return append(changes, change)
+}
+
+// readFileOrEmpty returns the content of path, or "" if it does not exist.
+func readFileOrEmpty(path string) (string, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // trusted config file path
+	if IsNotExist(err) {
+		return "", nil
+	}
+
+	return string(data), err
+}
+
+// formatLines joins lines as WriteToFile writes them.
+func formatLines(lines []ConfigLine) string {
+	var b strings.Builder
+
+	for _, line := range lines {
+		b.WriteString(line.Raw)
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package nixconf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNixConfig_PreviewRemoveToken(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "nix.conf")
+	tokenPath := filepath.Join(tmpDir, "access-tokens.conf")
+
+	if err := os.WriteFile(configPath, []byte("!include access-tokens.conf\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(tokenPath, []byte("access-tokens = github.com=ghp_one gitlab.com=glpat-two\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := New(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := cfg.PreviewRemoveToken("github.com")
+	if err != nil {
+		t.Fatalf("PreviewRemoveToken() error = %v", err)
+	}
+
+	if len(changes) != 1 || changes[0].Path != tokenPath || changes[0].New != "access-tokens = gitlab.com=glpat-two\n" {
+		t.Errorf("PreviewRemoveToken() = %+v", changes)
+	}
+
+	if err := cfg.RemoveToken("github.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing the last token removes the file
+	changes, err = cfg.PreviewRemoveToken("gitlab.com")
+	if err != nil {
+		t.Fatalf("PreviewRemoveToken() error = %v", err)
+	}
+
+	if len(changes) != 1 || !changes[0].Removed || changes[0].New != "" {
+		t.Errorf("PreviewRemoveToken() = %+v, want the token file removed", changes)
+	}
+
+	if _, err := cfg.PreviewRemoveToken("example.com"); err == nil {
+		t.Error("expected error for a host without token")
+	}
+}
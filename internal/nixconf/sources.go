@@ -0,0 +1,396 @@
+package nixconf
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// nixConfigEnv is the environment variable Nix reads inline configuration from.
+const nixConfigEnv = "NIX_CONFIG"
+
+// TokenSource describes where an access token was read from.
+// Sources are ordered by Nix precedence: a later source overrides an earlier one.
+// Like Nix, a source that sets access-tokens replaces the tokens of every earlier
+// source rather than merging with them.
+type TokenSource int
+
+const (
+	// SourceNone means no token was found.
+	SourceNone TokenSource = iota
+	// SourceSystemConfig is the system-wide nix.conf, when it is not the managed config.
+	SourceSystemConfig
+	// SourceConfigFile is the managed nix.conf and its includes.
+	SourceConfigFile
+	// SourceExtraConfig is a file added with AddExtraConfig; it is never written.
+	// Nix itself does not read it, so its tokens are merged per host instead of
+	// replacing those of earlier sources.
+	SourceExtraConfig
+	// SourceEnv is the NIX_CONFIG environment variable.
+	SourceEnv
+)
+
+// String returns a human-readable name for the source.
+func (s TokenSource) String() string {
+	switch s {
+	case SourceNone:
+		return "none"
+	case SourceSystemConfig:
+		return "system config"
+	case SourceConfigFile:
+		return "config file"
+	case SourceExtraConfig:
+		return "extra config"
+	case SourceEnv:
+		return nixConfigEnv
+	default:
+		return "unknown"
+	}
+}
+
+// ReadOnly reports whether nix-auth cannot modify tokens from this source.
+func (s TokenSource) ReadOnly() bool {
+	return s == SourceEnv || s == SourceSystemConfig || s == SourceExtraConfig
+}
+
+// TokenEntry is the access token one source defines for a host.
+type TokenEntry struct {
+	Host   string
+	Token  string
+	Source TokenSource
+	// Path is the file defining the token; empty for NIX_CONFIG.
+	Path string
+	// OverriddenBy names the source, such as a file or NIX_CONFIG, whose
+	// access-tokens setting replaces this entry's, so Nix ignores it. It is
+	// empty for entries from the source Nix reads its tokens from.
+	OverriddenBy string
+}
+
+// Effective reports whether Nix uses the token of the entry's source.
+func (e TokenEntry) Effective() bool {
+	return e.OverriddenBy == ""
+}
+
+// Origin returns the file defining the token, or the source name if there is none.
+func (e TokenEntry) Origin() string {
+	if e.Path != "" {
+		return e.Path
+	}
+
+	return e.Source.String()
+}
+
+// ParseString parses inline configuration such as the contents of NIX_CONFIG.
+// Include directives are recorded but not followed.
+func (p *Parser) ParseString(content, source string) (*ParsedConfig, error) {
+	return p.Parse(strings.NewReader(content), source, "")
+}
+
+// EnvTokens returns the access tokens defined in the NIX_CONFIG environment variable.
+func (n *NixConfig) EnvTokens() (map[string]string, error) {
+	tokens, _, err := n.envTokens()
+
+	return tokens, err
+}
+
+// envTokens returns the access tokens defined in NIX_CONFIG and whether it
+// sets access-tokens at all.
+func (n *NixConfig) envTokens() (map[string]string, bool, error) {
+	content := os.Getenv(nixConfigEnv)
+	if content == "" {
+		return map[string]string{}, false, nil
+	}
+
+	config, err := n.parser.ParseString(content, nixConfigEnv)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse %s: %w", nixConfigEnv, err)
+	}
+
+	tokenValue, exists := config.Settings[accessTokensKey]
+	if !exists {
+		return map[string]string{}, false, nil
+	}
+
+	tokens, err := ParseAccessTokens(tokenValue)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse %s: %w", nixConfigEnv, err)
+	}
+
+	return tokens, true, nil
+}
+
+// TokenEntries returns every token defined for host across the system config,
+// the managed config, any extra configs and NIX_CONFIG, highest precedence first.
+// The first entry is the one Nix uses if it is Effective, and any further
+// entries are shadowed by it. If the first entry is not Effective, a source of
+// higher precedence sets access-tokens without host, and Nix uses no token for
+// it at all. It returns nil if no source defines host.
+func (n *NixConfig) TokenEntries(host string) ([]TokenEntry, error) {
+	sources, err := n.tokenSources()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		entries    []TokenEntry
+		overrider  string
+		overridden bool
+	)
+
+	for i := len(sources) - 1; i >= 0; i-- {
+		src := sources[i]
+
+		if token, ok := src.tokens[host]; ok {
+			entries = append(entries, TokenEntry{
+				Host:         host,
+				Token:        token,
+				Source:       src.source,
+				Path:         src.path,
+				OverriddenBy: overrider,
+			})
+		}
+
+		// Nix replaces rather than merges access-tokens, so the highest
+		// source that sets it is the only one Nix reads tokens from
+		if src.defines && !overridden {
+			overridden = true
+			overrider = src.origin()
+		}
+	}
+
+	return entries, nil
+}
+
+// LookupToken returns the token Nix would use for host and where it came from.
+// The tokens of the highest-precedence source that sets access-tokens are used:
+// NIX_CONFIG over the extra configs, over the managed config, over the system
+// config. It returns SourceNone if Nix uses no token for host.
+func (n *NixConfig) LookupToken(host string) (string, TokenSource, error) {
+	entries, err := n.TokenEntries(host)
+	if err != nil || len(entries) == 0 || !entries[0].Effective() {
+		return "", SourceNone, err
+	}
+
+	return entries[0].Token, entries[0].Source, nil
+}
+
+// ListAllTokens returns the hosts with tokens in any source.
+func (n *NixConfig) ListAllTokens() ([]string, error) {
+	sources, err := n.tokenSources()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	hosts := []string{}
+
+	for _, src := range sources {
+		for host := range src.tokens {
+			if !seen[host] {
+				seen[host] = true
+
+				hosts = append(hosts, host)
+			}
+		}
+	}
+
+	sort.Strings(hosts)
+
+	return hosts, nil
+}
+
+// sourceTokens are the tokens read from one source.
+type sourceTokens struct {
+	source TokenSource
+	path   string
+	tokens map[string]string
+	// defines is set if the source sets access-tokens, even to no tokens.
+	defines bool
+}
+
+// origin returns the file defining the source's tokens, or the source name.
+func (s sourceTokens) origin() string {
+	return TokenEntry{Source: s.source, Path: s.path}.Origin()
+}
+
+// tokenSources reads the tokens of every source, lowest precedence first.
+func (n *NixConfig) tokenSources() ([]sourceTokens, error) {
+	var sources []sourceTokens
+
+	if systemPath := n.systemConfigPath(); systemPath != "" {
+		tokens, path, err := n.readTokens(systemPath)
+
+		// The system token file is usually only readable by root
+		if err != nil && !errors.Is(err, fs.ErrPermission) {
+			return nil, fmt.Errorf("failed to read system config: %w", err)
+		}
+
+		sources = append(sources, sourceTokens{source: SourceSystemConfig, path: path, tokens: tokens, defines: path != ""})
+	}
+
+	tokens, path, err := n.readTokens(n.mainPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sources = append(sources, sourceTokens{source: SourceConfigFile, path: path, tokens: tokens, defines: path != ""})
+
+	for _, extraPath := range n.extraPaths {
+		tokens, path, err := n.readTokens(extraPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read extra config %s: %w", extraPath, err)
+		}
+
+		sources = append(sources, sourceTokens{source: SourceExtraConfig, path: path, tokens: tokens})
+	}
+
+	envTokens, envDefines, err := n.envTokens()
+	if err != nil {
+		return nil, err
+	}
+
+	sources = append(sources, sourceTokens{source: SourceEnv, tokens: envTokens, defines: envDefines})
+
+	return sources, nil
+}
+
+// systemConfigPath returns the system nix.conf path, or "" if it is the managed config.
+func (n *NixConfig) systemConfigPath() string {
+	systemPath := DefaultSystemConfigPath()
+
+	systemAbs, err1 := filepath.Abs(systemPath)
+	mainAbs, err2 := filepath.Abs(n.mainPath)
+
+	if err1 == nil && err2 == nil && systemAbs == mainAbs {
+		return ""
+	}
+
+	return systemPath
+}
+
+// readTokens parses the config at path and returns its access tokens together
+// with the file that defines them. The file is empty if the config does not
+// set access-tokens; a missing config has no tokens.
+func (n *NixConfig) readTokens(path string) (map[string]string, string, error) {
+	config, err := n.parseFile(path)
+	if err != nil {
+		if IsNotExist(err) {
+			return map[string]string{}, "", nil
+		}
+
+		return map[string]string{}, "", err
+	}
+
+	tokenValue, exists := config.Settings[accessTokensKey]
+	if !exists {
+		return map[string]string{}, "", nil
+	}
+
+	tokens, err := ParseAccessTokens(tokenValue)
+	if err != nil {
+		return map[string]string{}, "", err
+	}
+
+	definingFile := path
+	if line := config.FindSettingLine(accessTokensKey); line != nil {
+		definingFile = line.SourceFile
+	}
+
+	if n.encryption != nil && path == n.mainPath {
+		if _, err := os.Stat(n.GetTokenFilePath()); err == nil {
+			definingFile = n.GetTokenFilePath()
+		}
+	}
+
+	return tokens, definingFile, nil
+}
+
+// SourceInfo describes what was read from one token source, for diagnostics.
+type SourceInfo struct {
+	Source TokenSource
+	// Path is the main file of the source; empty for NIX_CONFIG.
+	Path string
+	// Files lists every file read for the source, including followed includes.
+	Files []string
+	// Tokens are the access tokens the source defines.
+	Tokens map[string]string
+	// Err is set if the source could not be read completely.
+	Err error
+}
+
+// DescribeSources reports every token source in precedence order, lowest first,
+// with the files read for it and the tokens it defines. Unlike the token lookups,
+// it does not stop at the first unreadable source.
+func (n *NixConfig) DescribeSources() []SourceInfo {
+	var infos []SourceInfo
+
+	if systemPath := n.systemConfigPath(); systemPath != "" {
+		infos = append(infos, n.describeFile(SourceSystemConfig, systemPath))
+	}
+
+	infos = append(infos, n.describeFile(SourceConfigFile, n.mainPath))
+
+	for _, extraPath := range n.extraPaths {
+		infos = append(infos, n.describeFile(SourceExtraConfig, extraPath))
+	}
+
+	env := SourceInfo{Source: SourceEnv}
+	env.Tokens, env.Err = n.EnvTokens()
+
+	return append(infos, env)
+}
+
+// describeFile reads the config at path for DescribeSources.
+func (n *NixConfig) describeFile(source TokenSource, path string) SourceInfo {
+	info := SourceInfo{Source: source, Path: path, Tokens: map[string]string{}}
+
+	config, err := n.parseFile(path)
+	if err != nil {
+		info.Err = err
+		return info
+	}
+
+	// Included files are parsed before the rest of the including file, so
+	// list the main file first explicitly
+	seen := make(map[string]bool)
+
+	mainFile, err := filepath.Abs(path)
+	if path == n.mainPath {
+		mainFile, err = n.mainSource()
+	}
+
+	if err == nil {
+		seen[mainFile] = true
+
+		info.Files = append(info.Files, mainFile)
+	}
+
+	for _, line := range config.Lines {
+		if !seen[line.SourceFile] {
+			seen[line.SourceFile] = true
+
+			info.Files = append(info.Files, line.SourceFile)
+		}
+	}
+
+	if n.encryption != nil && path == n.mainPath {
+		if _, err := os.Stat(n.GetTokenFilePath()); err == nil {
+			info.Files = append(info.Files, n.GetTokenFilePath())
+		}
+	}
+
+	if tokenValue, exists := config.Settings[accessTokensKey]; exists {
+		tokens, err := ParseAccessTokens(tokenValue)
+		if err != nil {
+			info.Err = err
+		} else {
+			info.Tokens = tokens
+		}
+	}
+
+	return info
+}
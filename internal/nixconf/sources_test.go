@@ -0,0 +1,271 @@
+package nixconf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNixConfig_EnvTokens(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "nix.conf")
+
+	if err := os.WriteFile(configPath, []byte("access-tokens = github.com=file_token gitlab.com=file_gitlab\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	t.Setenv("NIX_CONF_DIR", t.TempDir())
+	t.Setenv("NIX_CONFIG", "experimental-features = nix-command flakes\naccess-tokens = github.com=env_token example.com=env_example # inline\n")
+
+	cfg, err := New(configPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	envTokens, err := cfg.EnvTokens()
+	if err != nil {
+		t.Fatalf("EnvTokens() error = %v", err)
+	}
+
+	if len(envTokens) != 2 || envTokens["github.com"] != "env_token" || envTokens["example.com"] != "env_example" {
+		t.Errorf("EnvTokens() = %v", envTokens)
+	}
+
+	hosts, err := cfg.ListAllTokens()
+	if err != nil {
+		t.Fatalf("ListAllTokens() error = %v", err)
+	}
+
+	if got := strings.Join(hosts, ","); got != "example.com,github.com,gitlab.com" {
+		t.Errorf("ListAllTokens() = %s", got)
+	}
+
+	tests := []struct {
+		host      string
+		wantToken string
+		wantSrc   TokenSource
+	}{
+		{host: "github.com", wantToken: "env_token", wantSrc: SourceEnv},
+		// NIX_CONFIG replaces the access-tokens of the config file
+		{host: "gitlab.com", wantToken: "", wantSrc: SourceNone},
+		{host: "example.com", wantToken: "env_example", wantSrc: SourceEnv},
+		{host: "missing.com", wantToken: "", wantSrc: SourceNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			token, src, err := cfg.LookupToken(tt.host)
+			if err != nil {
+				t.Fatalf("LookupToken() error = %v", err)
+			}
+
+			if token != tt.wantToken || src != tt.wantSrc {
+				t.Errorf("LookupToken() = %q, %v; want %q, %v", token, src, tt.wantToken, tt.wantSrc)
+			}
+		})
+	}
+
+	// The file-only view must not include NIX_CONFIG tokens
+	fileToken, err := cfg.GetToken("example.com")
+	if err != nil || fileToken != "" {
+		t.Errorf("GetToken() = %q, %v; want no file token", fileToken, err)
+	}
+}
+
+func TestNixConfig_EnvTokensUnset(t *testing.T) {
+	t.Setenv("NIX_CONFIG", "")
+
+	cfg, err := New(filepath.Join(t.TempDir(), "nix.conf"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tokens, err := cfg.EnvTokens()
+	if err != nil || len(tokens) != 0 {
+		t.Errorf("EnvTokens() = %v, %v; want empty", tokens, err)
+	}
+}
+
+func TestNixConfig_TokenPrecedence(t *testing.T) {
+	systemDir := t.TempDir()
+	userDir := t.TempDir()
+
+	t.Setenv("NIX_CONF_DIR", systemDir)
+	t.Setenv("NIX_CONFIG", "access-tokens = github.com=env_github")
+
+	files := map[string]string{
+		filepath.Join(systemDir, "nix.conf"):           "!include access-tokens.conf\n",
+		filepath.Join(systemDir, "access-tokens.conf"): "access-tokens = github.com=system_github gitlab.com=system_gitlab system.com=system_only\n",
+		filepath.Join(userDir, "nix.conf"):             "access-tokens = github.com=user_github gitlab.com=user_gitlab\n",
+	}
+
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	cfg, err := New(filepath.Join(userDir, "nix.conf"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Like Nix, NIX_CONFIG setting access-tokens replaces the tokens of the
+	// config files instead of merging with them
+	tests := []struct {
+		host          string
+		want          []string
+		wantSrc       []TokenSource
+		wantEffective bool
+	}{
+		{
+			host:          "github.com",
+			want:          []string{"env_github", "user_github", "system_github"},
+			wantSrc:       []TokenSource{SourceEnv, SourceConfigFile, SourceSystemConfig},
+			wantEffective: true,
+		},
+		{
+			host:    "gitlab.com",
+			want:    []string{"user_gitlab", "system_gitlab"},
+			wantSrc: []TokenSource{SourceConfigFile, SourceSystemConfig},
+		},
+		{
+			host:    "system.com",
+			want:    []string{"system_only"},
+			wantSrc: []TokenSource{SourceSystemConfig},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			entries, err := cfg.TokenEntries(tt.host)
+			if err != nil {
+				t.Fatalf("TokenEntries() error = %v", err)
+			}
+
+			if len(entries) != len(tt.want) {
+				t.Fatalf("TokenEntries() = %+v, want tokens %v", entries, tt.want)
+			}
+
+			for i, entry := range entries {
+				if entry.Token != tt.want[i] || entry.Source != tt.wantSrc[i] {
+					t.Errorf("entry %d = %q from %v, want %q from %v", i, entry.Token, entry.Source, tt.want[i], tt.wantSrc[i])
+				}
+			}
+
+			if entries[0].Effective() != tt.wantEffective {
+				t.Errorf("Effective() = %v, want %v (overridden by %q)", entries[0].Effective(), tt.wantEffective, entries[0].OverriddenBy)
+			}
+
+			token, _, err := cfg.LookupToken(tt.host)
+			if err != nil || (token != "") != tt.wantEffective {
+				t.Errorf("LookupToken() = %q, %v; want a token: %v", token, err, tt.wantEffective)
+			}
+		})
+	}
+
+	entries, _ := cfg.TokenEntries("gitlab.com")
+	if len(entries) == 0 || entries[0].OverriddenBy != "NIX_CONFIG" {
+		t.Errorf("OverriddenBy = %+v, want NIX_CONFIG", entries)
+	}
+
+	// Without NIX_CONFIG, the user config replaces the system config
+	t.Setenv("NIX_CONFIG", "")
+
+	token, source, err := cfg.LookupToken("gitlab.com")
+	if err != nil || token != "user_gitlab" || source != SourceConfigFile {
+		t.Errorf("LookupToken() = %q, %v, %v; want user_gitlab from config file", token, source, err)
+	}
+
+	entries, _ = cfg.TokenEntries("system.com")
+	if want := filepath.Join(userDir, "nix.conf"); len(entries) != 1 || entries[0].OverriddenBy != want {
+		t.Errorf("OverriddenBy = %+v, want %s", entries, want)
+	}
+
+	entries, _ = cfg.TokenEntries("system.com")
+	if want := filepath.Join(systemDir, "access-tokens.conf"); len(entries) != 1 || entries[0].Origin() != want {
+		t.Errorf("Origin() = %+v, want %s", entries, want)
+	}
+
+	hosts, err := cfg.ListAllTokens()
+	if err != nil || strings.Join(hosts, ",") != "github.com,gitlab.com,system.com" {
+		t.Errorf("ListAllTokens() = %v, %v", hosts, err)
+	}
+
+	// Managing the system config directly makes its tokens the writable source
+	systemCfg, err := New(filepath.Join(systemDir, "nix.conf"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	token, source, err = systemCfg.LookupToken("gitlab.com")
+	if err != nil || token != "system_gitlab" || source != SourceConfigFile {
+		t.Errorf("LookupToken() = %q, %v, %v; want system_gitlab from config file", token, source, err)
+	}
+}
+
+func TestNixConfig_ExtraConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Setenv("NIX_CONF_DIR", t.TempDir())
+	t.Setenv("NIX_CONFIG", "")
+
+	files := map[string]string{
+		"nix.conf":    "access-tokens = github.com=main_github gitlab.com=main_gitlab\n",
+		"first.conf":  "access-tokens = github.com=first_github extra.com=first_extra\n",
+		"second.conf": "access-tokens = extra.com=second_extra\n",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	cfg, err := New(filepath.Join(dir, "nix.conf"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for _, name := range []string{"first.conf", "second.conf"} {
+		if err := cfg.AddExtraConfig(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("AddExtraConfig(%s) error = %v", name, err)
+		}
+	}
+
+	if err := cfg.AddExtraConfig(filepath.Join(dir, "missing.conf")); err == nil {
+		t.Error("AddExtraConfig() accepted a missing file")
+	}
+
+	if err := cfg.AddExtraConfig(dir); err == nil {
+		t.Error("AddExtraConfig() accepted a directory")
+	}
+
+	tests := []struct {
+		host    string
+		want    string
+		wantSrc TokenSource
+	}{
+		{host: "github.com", want: "first_github", wantSrc: SourceExtraConfig},
+		{host: "gitlab.com", want: "main_gitlab", wantSrc: SourceConfigFile},
+		{host: "extra.com", want: "second_extra", wantSrc: SourceExtraConfig},
+	}
+
+	for _, tt := range tests {
+		token, source, err := cfg.LookupToken(tt.host)
+		if err != nil || token != tt.want || source != tt.wantSrc {
+			t.Errorf("LookupToken(%s) = %q, %v, %v; want %q from %v", tt.host, token, source, err, tt.want, tt.wantSrc)
+		}
+	}
+
+	// Writes only ever go to the main config
+	if err := cfg.SetToken("extra.com", "written"); err != nil {
+		t.Fatalf("SetToken() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "second.conf"))
+	if err != nil || string(content) != files["second.conf"] {
+		t.Errorf("extra config was modified: %q, %v", content, err)
+	}
+}
@@ -0,0 +1,52 @@
+package nixconf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+const (
+	// StdinPath is the config path that reads the configuration from standard input.
+	StdinPath = "-"
+	// stdinSource is the source name recorded for lines read from standard input.
+	stdinSource = "<stdin>"
+)
+
+// ErrReadOnlyConfig is returned when modifying a configuration read from stdin.
+var ErrReadOnlyConfig = errors.New("a configuration read from stdin cannot be modified")
+
+// NewFromReader creates a read-only NixConfig from the content of r, for
+// inspecting a config that is not on disk. Includes are not followed.
+func NewFromReader(r io.Reader) (*NixConfig, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	return &NixConfig{
+		mainPath: StdinPath,
+		parser:   NewParser(),
+		content:  content,
+	}, nil
+}
+
+// readOnly reports whether the config was read from stdin.
+func (n *NixConfig) readOnly() bool {
+	return n.content != nil
+}
+
+// mainSource returns the SourceFile recorded for lines of the main config.
+func (n *NixConfig) mainSource() (string, error) {
+	if n.readOnly() {
+		return stdinSource, nil
+	}
+
+	absPath, err := filepath.Abs(n.mainPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", n.mainPath, err)
+	}
+
+	return absPath, nil
+}
@@ -0,0 +1,42 @@
+package nixconf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewFromReader(t *testing.T) {
+	t.Setenv("NIX_CONF_DIR", t.TempDir())
+	t.Setenv("NIX_CONFIG", "")
+
+	cfg, err := NewFromReader(strings.NewReader("# remote\naccess-tokens = github.com=ghp_remote gitlab.com=glpat-remote\n"))
+	if err != nil {
+		t.Fatalf("NewFromReader() error = %v", err)
+	}
+
+	if cfg.GetPath() != "<stdin>" {
+		t.Errorf("GetPath() = %q, want <stdin>", cfg.GetPath())
+	}
+
+	// The config can be read repeatedly
+	for range 2 {
+		token, err := cfg.GetToken("github.com")
+		if err != nil || token != "ghp_remote" {
+			t.Errorf("GetToken() = %q, %v; want ghp_remote", token, err)
+		}
+	}
+
+	entries, err := cfg.TokenEntries("gitlab.com")
+	if err != nil || len(entries) != 1 || entries[0].Path != "<stdin>" {
+		t.Errorf("TokenEntries() = %+v, %v; want one entry from <stdin>", entries, err)
+	}
+
+	if err := cfg.SetToken("github.com", "ghp_new"); !errors.Is(err, ErrReadOnlyConfig) {
+		t.Errorf("SetToken() error = %v, want ErrReadOnlyConfig", err)
+	}
+
+	if err := cfg.RemoveToken("github.com"); !errors.Is(err, ErrReadOnlyConfig) {
+		t.Errorf("RemoveToken() error = %v, want ErrReadOnlyConfig", err)
+	}
+}
@@ -0,0 +1,79 @@
+package nixconf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// defaultStoreDir is the Nix store unless NIX_STORE_DIR points elsewhere.
+	defaultStoreDir = "/nix/store"
+	// writableConfigPermissions is the permission mode of a writable copy of a store config.
+	writableConfigPermissions = 0o644
+)
+
+// ErrMainConfigInStore is returned when saving a token would have to modify a
+// main config that links into the read-only Nix store, as NixOS and
+// home-manager generate it.
+var ErrMainConfigInStore = errors.New("main config is in the read-only Nix store")
+
+// storeDir returns the directory of the Nix store.
+func storeDir() string {
+	if dir := os.Getenv("NIX_STORE_DIR"); dir != "" {
+		return filepath.Clean(dir)
+	}
+
+	return defaultStoreDir
+}
+
+// StorePath returns the Nix store path the main config links to, or "" if it
+// is not a link into the store.
+func (n *NixConfig) StorePath() string {
+	if n.readOnly() {
+		return ""
+	}
+
+	target, err := filepath.EvalSymlinks(n.mainPath)
+	if err != nil || !strings.HasPrefix(target, storeDir()+string(filepath.Separator)) {
+		return ""
+	}
+
+	return target
+}
+
+// storeConfigError explains that the main config linking to target cannot get
+// the include of the token file.
+func (n *NixConfig) storeConfigError(target string) error {
+	return fmt.Errorf("%w: %s links to %s; add '!include %s' to the generated config (see 'nix-auth print-include') "+
+		"or replace the link with a writable copy", ErrMainConfigInStore, n.mainPath, target, accessTokensFile)
+}
+
+// DetachMainConfig replaces a main config that links into the Nix store with a
+// writable copy of it, so that saving tokens can add the include of the token
+// file. Whatever generated the link will try to restore it on its next switch.
+func (n *NixConfig) DetachMainConfig() error {
+	target := n.StorePath()
+	if target == "" {
+		return fmt.Errorf("%s does not link into the Nix store", n.mainPath)
+	}
+
+	content, err := os.ReadFile(target) //nolint:gosec // trusted config file path
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(n.mainPath); err != nil {
+		return fmt.Errorf("failed to remove the link %s: %w", n.mainPath, err)
+	}
+
+	if err := os.WriteFile(n.mainPath, content, writableConfigPermissions); err != nil {
+		return fmt.Errorf("failed to write %s: %w", n.mainPath, err)
+	}
+
+	n.recordChange(n.mainPath)
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package nixconf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// linkStoreConfig writes content to a fake Nix store and links nix.conf to it,
+// as home-manager does. It returns the path of nix.conf and of the store file.
+func linkStoreConfig(t *testing.T, content string) (string, string) {
+	t.Helper()
+
+	store := t.TempDir()
+	t.Setenv("NIX_STORE_DIR", store)
+
+	storeFile := filepath.Join(store, "abc123-nix.conf")
+	if err := os.WriteFile(storeFile, []byte(content), 0o444); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	configPath := filepath.Join(t.TempDir(), "nix.conf")
+	if err := os.Symlink(storeFile, configPath); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	return configPath, storeFile
+}
+
+func TestNixConfig_StoreConfig(t *testing.T) {
+	configPath, storeFile := linkStoreConfig(t, "experimental-features = flakes\n")
+
+	cfg, err := New(configPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	cfg.DisableBackups()
+
+	if got := cfg.StorePath(); got != storeFile {
+		t.Errorf("StorePath() = %q, want %q", got, storeFile)
+	}
+
+	if err := cfg.CheckMainConfig(); !errors.Is(err, ErrMainConfigInStore) {
+		t.Errorf("CheckMainConfig() error = %v, want ErrMainConfigInStore", err)
+	}
+
+	if err := cfg.SetToken("github.com", "token"); !errors.Is(err, ErrMainConfigInStore) {
+		t.Fatalf("SetToken() error = %v, want ErrMainConfigInStore", err)
+	}
+
+	if err := cfg.DetachMainConfig(); err != nil {
+		t.Fatalf("DetachMainConfig() error = %v", err)
+	}
+
+	if got := cfg.StorePath(); got != "" {
+		t.Errorf("StorePath() after detaching = %q, want none", got)
+	}
+
+	if err := cfg.SetToken("github.com", "token"); err != nil {
+		t.Fatalf("SetToken() error = %v", err)
+	}
+
+	got, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if want := "experimental-features = flakes\n!include access-tokens.conf\n"; string(got) != want {
+		t.Errorf("nix.conf = %q, want %q", got, want)
+	}
+
+	if stored, _ := os.ReadFile(storeFile); strings.Contains(string(stored), "include") {
+		t.Errorf("expected the store file to be left alone, got %q", stored)
+	}
+}
+
+func TestNixConfig_StoreConfigWithInclude(t *testing.T) {
+	configPath, _ := linkStoreConfig(t, "!include access-tokens.conf\n")
+
+	cfg, err := New(configPath)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// The generated config already includes the token file, so it needs no change
+	if err := cfg.CheckMainConfig(); err != nil {
+		t.Errorf("CheckMainConfig() error = %v", err)
+	}
+
+	if err := cfg.SetToken("github.com", "token"); err != nil {
+		t.Fatalf("SetToken() error = %v", err)
+	}
+
+	if token, _ := cfg.GetToken("github.com"); token != "token" {
+		t.Errorf("GetToken() = %q, want %q", token, "token")
+	}
+}
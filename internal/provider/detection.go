@@ -3,51 +3,205 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	// detectionTimeout is the timeout for provider detection requests.
 	detectionTimeout = 3 * time.Second
+	// maxDetectionRedirects is the number of redirects a detection request follows.
+	maxDetectionRedirects = 3
 )
 
 // Detect attempts to identify the provider type by querying various API endpoints.
 func Detect(ctx context.Context, host, clientID string) (Provider, error) {
-	// Create a client with timeout
-	client := &http.Client{
-		Timeout: detectionTimeout,
+	return DetectWithConfig(ctx, Config{
+		Host:     host,
+		ClientID: clientID,
+	})
+}
+
+// DetectWithConfig identifies the provider type for cfg.Host like Detect, trying
+// only the providers in cfg.DetectOrder if set, and once detected, configures the
+// provider with the rest of cfg. Unless cfg.HTTPClient is set, the provider
+// makes its requests through a client sharing the detection client's transport.
+func DetectWithConfig(ctx context.Context, cfg Config) (Provider, error) {
+	provider, _ := detect(ctx, cfg)
+
+	return provider, nil
+}
+
+// detect identifies the provider for cfg.Host like DetectWithConfig and also
+// returns the name it is registered under, or "" if no provider matched.
+func detect(ctx context.Context, cfg Config) (Provider, string) {
+	host := cfg.Host
+	client := newDetectionClient()
+
+	order := cfg.DetectOrder
+	if len(order) == 0 {
+		order = ListForDetection()
 	}
 
 	// Try each registered provider in preferred order
-	for _, name := range ListForDetection() {
+	for _, name := range order {
 		reg, ok := registry[name]
 		if !ok || reg.Detect == nil {
 			continue
 		}
 
-		provider, err := reg.Detect(ctx, client, host)
+		provider, err := detectProbe(ctx, reg, client, host)
 		if err != nil {
 			// Network error - return unknown provider with the host set
-			return NewUnknownProvider(host), nil //nolint:nilerr // Network errors during detection are not fatal
+			return newUnreachableProvider(host, err), ""
 		}
 
 		if provider != nil {
-			// Found a matching provider
-			// If clientID is provided, recreate with proper config
-			if clientID != "" {
-				cfg := Config{
-					Host:     host,
-					ClientID: clientID,
-				}
-
-				return reg.New(cfg), nil
+			return configureDetected(reg, provider, cfg), name
+		}
+	}
+
+	// If no specific provider matched, use the unknown provider
+	return NewUnknownProvider(host), ""
+}
+
+// detectProbe runs reg's detector for host with a deadline of at most
+// detectionTimeout. The deadline is layered on ctx, so a host that accepts
+// connections but never answers cannot stall a probe beyond it, even with a
+// client that has no timeout of its own, and the caller's deadline still applies.
+func detectProbe(ctx context.Context, reg *Registration, client *http.Client, host string) (Provider, error) {
+	ctx, cancel := context.WithTimeout(ctx, detectionTimeout)
+	defer cancel()
+
+	return reg.Detect(ctx, client, host)
+}
+
+// unreachableError is why the provider of a host could not be detected: a
+// probe failed to reach the host or got no answer in time.
+type unreachableError struct {
+	err error
+}
+
+func (e *unreachableError) Error() string {
+	return fmt.Sprintf("could not reach the host to detect its provider: %v", e.err)
+}
+
+func (e *unreachableError) Unwrap() error {
+	return e.err
+}
+
+// configureDetected recreates a provider returned by reg's detector with the
+// full cfg, using a default provider client unless cfg.HTTPClient is set.
+func configureDetected(reg *Registration, detected Provider, cfg Config) Provider {
+	if reg.New == nil {
+		return detected
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = newProviderClient()
+	}
+
+	return reg.New(cfg)
+}
+
+// DetectAll runs every registered detector against the host without stopping at
+// the first match, returning all providers that claim it in detection order.
+// It is meant for diagnosing misdetection when more than one provider matches.
+// Network errors from individual detectors are joined into the returned error;
+// matches from the remaining detectors are still returned.
+func DetectAll(ctx context.Context, host string) ([]Provider, error) {
+	client := newDetectionClient()
+
+	var (
+		matches []Provider
+		errs    []error
+	)
+
+	for _, name := range ListForDetection() {
+		reg, ok := registry[name]
+		if !ok || reg.Detect == nil {
+			continue
+		}
+
+		provider, err := detectProbe(ctx, reg, client, host)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+
+		if provider != nil {
+			matches = append(matches, provider)
+		}
+	}
+
+	return matches, errors.Join(errs...)
+}
+
+// GuessFromHost guesses the provider name for host without any network access.
+// A host matching a provider's default host, or containing a provider's name as
+// one of its labels (e.g. "gitlab.example.com"), is attributed to that provider.
+// It returns "unknown" when nothing matches.
+func GuessFromHost(host string) string {
+	host = strings.ToLower(host)
+
+	names := List()
+	sort.Strings(names)
+
+	for _, name := range names {
+		reg := registry[name]
+		if reg.DefaultHost != "" && strings.EqualFold(reg.DefaultHost, host) {
+			if reg.New != nil {
+				return reg.New(Config{Host: host}).Name()
 			}
 
-			return provider, nil
+			return name
 		}
 	}
 
-	// If no specific provider matched, use the unknown provider
-	return NewUnknownProvider(host), nil
+	for _, label := range strings.Split(host, ".") {
+		for _, name := range ListForDetection() {
+			if label == name {
+				return name
+			}
+		}
+	}
+
+	return NewUnknownProvider(host).Name()
+}
+
+// sharedDetectionClient is the HTTP client used for detection requests. It
+// shares its connections with the provider client.
+var sharedDetectionClient = sync.OnceValue(func() *http.Client {
+	return &http.Client{
+		Timeout:       detectionTimeout,
+		Transport:     sharedTransport(),
+		CheckRedirect: detectionRedirectPolicy,
+	}
+})
+
+// newDetectionClient returns the HTTP client used for detection requests.
+func newDetectionClient() *http.Client {
+	return sharedDetectionClient()
+}
+
+// detectionRedirectPolicy only follows a few redirects that stay on the same
+// scheme and host, such as one adding a trailing slash. Otherwise the redirect
+// response itself is returned, which no detector accepts, so a proxy or login
+// portal answering with 200 on another host is not mistaken for the provider.
+func detectionRedirectPolicy(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxDetectionRedirects {
+		return http.ErrUseLastResponse
+	}
+
+	origin := via[0].URL
+	if req.URL.Scheme != origin.Scheme || !strings.EqualFold(req.URL.Host, origin.Host) {
+		return http.ErrUseLastResponse
+	}
+
+	return nil
 }
@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// detectionCacheTTL is how long a detected provider is remembered before the
+	// host is detected from scratch again.
+	detectionCacheTTL = 7 * 24 * time.Hour
+	// detectionCacheFile is the name of the detection cache in the state directory.
+	detectionCacheFile = "detected-providers.json"
+)
+
+// detectionCacheMu serializes updates of the detection cache, since hosts are
+// detected concurrently.
+var detectionCacheMu sync.Mutex
+
+// detectionCacheEntry records which provider a host was detected as.
+type detectionCacheEntry struct {
+	Provider   string    `json:"provider"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// DetectCached identifies the provider for cfg.Host like DetectWithConfig, but
+// remembers the result in the state directory for detectionCacheTTL.
+//
+// A remembered provider is only confirmed with its own detector, which saves
+// probing the providers before it. If the host no longer answers like that
+// provider, for example after migrating to other forge software, the entry is
+// dropped and the host is detected from scratch. Hosts that match no provider,
+// or could not be reached, are never remembered.
+//
+// Detection cannot fail: a host that matches no provider is unknown, and one
+// that could not be reached reports the network error when validating a token.
+func DetectCached(ctx context.Context, cfg Config) Provider {
+	path, err := detectionCachePath()
+	if err != nil {
+		provider, _ := detect(ctx, cfg)
+		return provider
+	}
+
+	host := strings.ToLower(cfg.Host)
+
+	if name, ok := cachedDetection(path, host, cfg.DetectOrder, time.Now()); ok {
+		reg := registry[name]
+
+		provider, err := detectProbe(ctx, reg, newDetectionClient(), cfg.Host)
+		if err != nil {
+			// Network error - the entry may still be right, so keep it
+			return newUnreachableProvider(cfg.Host, err)
+		}
+
+		if provider != nil {
+			return configureDetected(reg, provider, cfg)
+		}
+
+		updateDetectionCache(path, host, "")
+	}
+
+	provider, name := detect(ctx, cfg)
+	if name != "" {
+		updateDetectionCache(path, host, name)
+	}
+
+	return provider
+}
+
+// detectionCachePath returns the detection cache file in the state directory.
+func detectionCachePath() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, detectionCacheFile), nil
+}
+
+// cachedDetection returns the provider host was detected as, if that is recent
+// enough, still registered and allowed by order.
+func cachedDetection(path, host string, order []string, now time.Time) (string, bool) {
+	entry, ok := loadDetectionCache(path)[host]
+	if !ok || now.Sub(entry.DetectedAt) > detectionCacheTTL {
+		return "", false
+	}
+
+	if reg, ok := registry[entry.Provider]; !ok || reg.Detect == nil {
+		return "", false
+	}
+
+	if len(order) > 0 && !slices.Contains(order, entry.Provider) {
+		return "", false
+	}
+
+	return entry.Provider, true
+}
+
+// loadDetectionCache reads the detection cache; a missing or unreadable cache is empty.
+func loadDetectionCache(path string) map[string]detectionCacheEntry {
+	entries := make(map[string]detectionCacheEntry)
+
+	data, err := os.ReadFile(path) //nolint:gosec // path is in the nix-auth state directory
+	if err != nil {
+		return entries
+	}
+
+	_ = json.Unmarshal(data, &entries)
+
+	return entries
+}
+
+// updateDetectionCache remembers host as detected by provider, or forgets it
+// if provider is empty. Failing to save is not fatal; the host is simply
+// detected again next time.
+func updateDetectionCache(path, host, provider string) {
+	detectionCacheMu.Lock()
+	defer detectionCacheMu.Unlock()
+
+	entries := loadDetectionCache(path)
+	if provider == "" {
+		delete(entries, host)
+	} else {
+		entries[host] = detectionCacheEntry{Provider: provider, DetectedAt: time.Now()}
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), stateDirPermissions); err != nil {
+		return
+	}
+
+	_ = os.WriteFile(path, data, stateFilePermissions)
+}
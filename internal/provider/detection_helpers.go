@@ -35,9 +35,19 @@ func detectGiteaOrForgejo(ctx context.Context, client *http.Client, host string)
 		}, nil
 	}
 
-	// For other hosts, check the version endpoint
+	// For other hosts, check the version endpoints
 	baseURL := fmt.Sprintf("https://%s", host)
 
+	// Forgejo has its own version endpoint, as its Gitea-compatible version may not mention it
+	forgejo, err := hasForgejoAPI(ctx, client, baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if forgejo {
+		return detectedForgejo(host), nil
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v1/version", baseURL), nil)
 	if err != nil {
 		return nil, err
@@ -60,13 +70,7 @@ func detectGiteaOrForgejo(ctx context.Context, client *http.Client, host string)
 
 		// Check if it's Forgejo (includes "forgejo" in version string)
 		if strings.Contains(strings.ToLower(data.Version), "forgejo") {
-			return &ForgejoProvider{
-				PersonalAccessTokenProvider: PersonalAccessTokenProvider{
-					providerName: "forgejo",
-					defaultHost:  "",
-					host:         host,
-				},
-			}, nil
+			return detectedForgejo(host), nil
 		}
 
 		// Otherwise it's Gitea
@@ -83,3 +87,44 @@ func detectGiteaOrForgejo(ctx context.Context, client *http.Client, host string)
 
 	return nil, nil // Not a Gitea/Forgejo instance
 }
+
+// hasForgejoAPI reports whether the instance at baseURL answers Forgejo's own
+// version endpoint, which Gitea does not have.
+func hasForgejoAPI(ctx context.Context, client *http.Client, baseURL string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/api/forgejo/v1/version", nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close() //nolint:errcheck // cleanup
+
+	if resp.StatusCode != http.StatusOK {
+		return false, nil
+	}
+
+	var data struct {
+		Version string `json:"version"`
+	}
+
+	// A page served for any path, such as a login portal, is not Forgejo
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return false, nil //nolint:nilerr // not a Forgejo instance
+	}
+
+	return data.Version != "", nil
+}
+
+// detectedForgejo returns the provider for a self-hosted Forgejo instance at host.
+func detectedForgejo(host string) *ForgejoProvider {
+	return &ForgejoProvider{
+		PersonalAccessTokenProvider: PersonalAccessTokenProvider{
+			providerName: "forgejo",
+			defaultHost:  "",
+			host:         host,
+		},
+	}
+}
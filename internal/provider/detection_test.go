@@ -2,10 +2,14 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDetect_Integration(t *testing.T) {
@@ -81,3 +85,422 @@ func TestDetect_Integration(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectAll(t *testing.T) {
+	originalRegistry := registry
+	defer func() {
+		registry = originalRegistry
+	}()
+
+	claimAll := func(name string) Registration {
+		return Registration{
+			New: func(cfg Config) Provider {
+				return &mockProvider{name: name, host: cfg.Host}
+			},
+			Detect: func(_ context.Context, _ *http.Client, host string) (Provider, error) {
+				return &mockProvider{name: name, host: host}, nil
+			},
+		}
+	}
+
+	registry = make(map[string]*Registration)
+	RegisterProvider("github", claimAll("github"))
+	RegisterProvider("gitlab", claimAll("gitlab"))
+	RegisterProvider("gitea", Registration{
+		Detect: func(_ context.Context, _ *http.Client, _ string) (Provider, error) {
+			return nil, fmt.Errorf("connection refused")
+		},
+	})
+	RegisterProvider("forgejo", Registration{
+		Detect: func(_ context.Context, _ *http.Client, _ string) (Provider, error) {
+			return nil, nil
+		},
+	})
+
+	matches, err := DetectAll(context.Background(), "proxy.example.com")
+
+	if len(matches) != 2 || matches[0].Name() != "github" || matches[1].Name() != "gitlab" {
+		names := make([]string, 0, len(matches))
+		for _, m := range matches {
+			names = append(names, m.Name())
+		}
+
+		t.Errorf("expected matches [github gitlab], got %v", names)
+	}
+
+	if err == nil || !strings.Contains(err.Error(), "gitea: connection refused") {
+		t.Errorf("expected gitea detection error, got %v", err)
+	}
+
+	// Detect still short-circuits on the first match in preferred order
+	p, err := Detect(context.Background(), "proxy.example.com", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.Name() != "github" {
+		t.Errorf("expected Detect to pick github, got %q", p.Name())
+	}
+}
+
+func TestDetectHangingHost(t *testing.T) {
+	originalRegistry := registry
+	defer func() {
+		registry = originalRegistry
+	}()
+
+	// The server accepts the request but never answers until the test ends
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+
+	defer server.Close()
+	defer close(release)
+
+	var probeDeadline bool
+
+	registry = make(map[string]*Registration)
+	RegisterProvider("github", Registration{
+		Detect: func(ctx context.Context, client *http.Client, _ string) (Provider, error) {
+			_, probeDeadline = ctx.Deadline()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+
+			_ = resp.Body.Close()
+
+			return &mockProvider{name: "github", host: server.URL}, nil
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	p, err := DetectWithConfig(ctx, Config{Host: "hanging.example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed >= detectionTimeout {
+		t.Errorf("detection took %s, want it bounded by the caller's deadline", elapsed)
+	}
+
+	if p.Name() != "unknown" {
+		t.Errorf("expected an unknown provider for a hanging host, got %q", p.Name())
+	}
+
+	// Without a caller deadline, each probe still gets one
+	probeDeadline = false
+
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+
+	_, _ = DetectWithConfig(cancelled, Config{Host: "hanging.example.com"})
+
+	if !probeDeadline {
+		t.Error("expected the detection probe to have a deadline")
+	}
+}
+
+func TestGuessFromHost(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{host: "github.com", expected: "github"},
+		{host: "GitHub.com", expected: "github"},
+		{host: "gitlab.com", expected: "gitlab"},
+		{host: "gitea.com", expected: "gitea"},
+		{host: "codeberg.org", expected: "forgejo"},
+		{host: "gitlab.company.com", expected: "gitlab"},
+		{host: "git.example.com", expected: "unknown"},
+		{host: "mygithub.example.com", expected: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := GuessFromHost(tt.host); got != tt.expected {
+				t.Errorf("GuessFromHost(%q) = %q, want %q", tt.host, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDetectionRedirectPolicy(t *testing.T) {
+	// A login portal on another host that answers every request with 200
+	portal := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"version": "1.21.0"}`))
+	}))
+	t.Cleanup(portal.Close)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/version":
+			http.Redirect(w, r, "/api/v1/version/", http.StatusMovedPermanently)
+		case "/api/v1/version/":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"version": "1.21.0"}`))
+		case "/sso/api/v1/version":
+			http.Redirect(w, r, portal.URL+"/login", http.StatusFound)
+		case "/loop/api/v1/version":
+			http.Redirect(w, r, r.URL.Path, http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	// httptest TLS servers share a certificate, so this client trusts both
+	client := server.Client()
+	client.CheckRedirect = detectionRedirectPolicy
+
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	tests := []struct {
+		name      string
+		host      string
+		wantMatch bool
+	}{
+		{name: "same-host redirect is followed", host: host, wantMatch: true},
+		{name: "redirect to another host is not a match", host: host + "/sso"},
+		{name: "redirect loop is not a match", host: host + "/loop"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := detectGiteaOrForgejo(context.Background(), client, tt.host)
+			if err != nil {
+				t.Fatalf("detectGiteaOrForgejo() error = %v", err)
+			}
+
+			if (p != nil) != tt.wantMatch {
+				t.Errorf("detectGiteaOrForgejo() = %v, want match %v", p, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestDetectGiteaOrForgejoVersionEndpoints(t *testing.T) {
+	tests := []struct {
+		name         string
+		forgejoAPI   bool
+		giteaVersion string
+		wantProvider string
+		wantNone     bool
+	}{
+		{name: "forgejo endpoint with gitea-compatible version", forgejoAPI: true, giteaVersion: "1.21.11+gitea-1.22.0", wantProvider: "forgejo"},
+		{name: "forgejo in the version string", giteaVersion: "7.0.0+gitea-1.22.0-forgejo", wantProvider: "forgejo"},
+		{name: "gitea", giteaVersion: "1.22.0", wantProvider: "gitea"},
+		{name: "neither", wantNone: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/api/forgejo/v1/version" && tt.forgejoAPI:
+					_, _ = w.Write([]byte(`{"version": "7.0.0+gitea-1.22.0"}`))
+				case r.URL.Path == "/api/v1/version" && tt.giteaVersion != "":
+					_, _ = fmt.Fprintf(w, `{"version": %q}`, tt.giteaVersion)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			p, err := detectGiteaOrForgejo(context.Background(), server.Client(), strings.TrimPrefix(server.URL, "https://"))
+			if err != nil {
+				t.Fatalf("detectGiteaOrForgejo() error = %v", err)
+			}
+
+			if tt.wantNone {
+				if p != nil {
+					t.Errorf("detectGiteaOrForgejo() = %v, want no match", p.Name())
+				}
+
+				return
+			}
+
+			if p == nil || p.Name() != tt.wantProvider {
+				t.Errorf("detectGiteaOrForgejo() = %v, want %s", p, tt.wantProvider)
+			}
+		})
+	}
+}
+
+func TestDetectWithConfigDetectOrder(t *testing.T) {
+	originalRegistry := registry
+	defer func() {
+		registry = originalRegistry
+	}()
+
+	var tried []string
+
+	claimAll := func(name string) Registration {
+		return Registration{
+			Detect: func(_ context.Context, _ *http.Client, host string) (Provider, error) {
+				tried = append(tried, name)
+				return &mockProvider{name: name, host: host}, nil
+			},
+		}
+	}
+
+	registry = make(map[string]*Registration)
+	RegisterProvider("github", claimAll("github"))
+	RegisterProvider("gitlab", claimAll("gitlab"))
+	RegisterProvider("codeberg", Registration{DefaultHost: "codeberg.org"})
+
+	p, err := DetectWithConfig(context.Background(), Config{Host: "git.company.com", DetectOrder: []string{"gitlab", "github"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.Name() != "gitlab" || strings.Join(tried, ",") != "gitlab" {
+		t.Errorf("expected only gitlab to be tried, got %q after trying %v", p.Name(), tried)
+	}
+
+	if err := CheckDetectOrder([]string{"gitlab", "github"}); err != nil {
+		t.Errorf("CheckDetectOrder() unexpected error: %v", err)
+	}
+
+	for _, name := range []string{"bitbucket", "codeberg"} {
+		if err := CheckDetectOrder([]string{"gitlab", name}); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("CheckDetectOrder(%s) error = %v, want error naming it", name, err)
+		}
+	}
+}
+
+func TestDetectCached(t *testing.T) {
+	originalRegistry := registry
+	defer func() {
+		registry = originalRegistry
+	}()
+
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	var (
+		tried  []string
+		answer = "gitlab"
+	)
+
+	claimIfAnswer := func(name string) Registration {
+		return Registration{
+			Detect: func(_ context.Context, _ *http.Client, host string) (Provider, error) {
+				tried = append(tried, name)
+				if name != answer {
+					return nil, nil
+				}
+
+				return &mockProvider{name: name, host: host}, nil
+			},
+		}
+	}
+
+	registry = make(map[string]*Registration)
+	RegisterProvider("github", claimIfAnswer("github"))
+	RegisterProvider("gitlab", claimIfAnswer("gitlab"))
+
+	cfg := Config{Host: "Git.Company.com", DetectOrder: []string{"github", "gitlab"}}
+
+	steps := []struct {
+		name      string
+		answer    string
+		wantName  string
+		wantTried string
+	}{
+		{name: "first detection tries in order", answer: "gitlab", wantName: "gitlab", wantTried: "github,gitlab"},
+		{name: "cached provider is confirmed directly", answer: "gitlab", wantName: "gitlab", wantTried: "gitlab"},
+		{name: "migrated host is detected again", answer: "github", wantName: "github", wantTried: "gitlab,github"},
+		{name: "new provider is cached", answer: "github", wantName: "github", wantTried: "github"},
+		{name: "unknown host is not cached", answer: "none", wantName: "unknown", wantTried: "github,github,gitlab"},
+		{name: "nothing cached after unknown", answer: "none", wantName: "unknown", wantTried: "github,gitlab"},
+	}
+
+	for _, step := range steps {
+		tried = nil
+		answer = step.answer
+
+		p := DetectCached(context.Background(), cfg)
+
+		if p.Name() != step.wantName || strings.Join(tried, ",") != step.wantTried {
+			t.Errorf("%s: got %q after trying %v, want %q after trying %s",
+				step.name, p.Name(), tried, step.wantName, step.wantTried)
+		}
+	}
+}
+
+func TestCachedDetectionExpires(t *testing.T) {
+	originalRegistry := registry
+	defer func() {
+		registry = originalRegistry
+	}()
+
+	registry = make(map[string]*Registration)
+	RegisterProvider("gitlab", Registration{
+		Detect: func(_ context.Context, _ *http.Client, _ string) (Provider, error) { return nil, nil },
+	})
+
+	path := filepath.Join(t.TempDir(), detectionCacheFile)
+	updateDetectionCache(path, "git.company.com", "gitlab")
+
+	now := time.Now()
+
+	if _, ok := cachedDetection(path, "git.company.com", nil, now); !ok {
+		t.Errorf("expected a fresh entry to be used")
+	}
+
+	if _, ok := cachedDetection(path, "git.company.com", nil, now.Add(detectionCacheTTL+time.Minute)); ok {
+		t.Errorf("expected an expired entry to be ignored")
+	}
+
+	if _, ok := cachedDetection(path, "git.company.com", []string{"github"}, now); ok {
+		t.Errorf("expected an entry outside the detection order to be ignored")
+	}
+}
+
+func TestDetectUnreachable(t *testing.T) {
+	originalRegistry := registry
+
+	defer func() {
+		registry = originalRegistry
+	}()
+
+	registry = make(map[string]*Registration)
+	RegisterProvider("gitlab", Registration{
+		Detect: func(_ context.Context, _ *http.Client, _ string) (Provider, error) {
+			return nil, &net.DNSError{Err: "no such host", Name: "git.internal.example.com", IsNotFound: true}
+		},
+	})
+
+	p, err := Detect(context.Background(), "git.internal.example.com", "")
+	if err != nil || p.Name() != "unknown" {
+		t.Fatalf("Detect() = %v, %v; want the unknown provider", p, err)
+	}
+
+	status, err := p.ValidateToken(context.Background(), "token")
+	if status != ValidationStatusUnknown || !IsNetworkError(err) {
+		t.Errorf("ValidateToken() = %v, %v; want unknown with a network error", status, err)
+	}
+
+	// A host that was reached but matched no provider is not reported as unreachable
+	registry = make(map[string]*Registration)
+
+	p, _ = Detect(context.Background(), "git.example.com", "")
+	if _, err := p.ValidateToken(context.Background(), "token"); err != nil {
+		t.Errorf("ValidateToken() error = %v, want nil for an unmatched host", err)
+	}
+}
@@ -2,28 +2,108 @@ package provider
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/cli/browser"
 	"github.com/numtide/nix-auth/internal/ui"
+	"github.com/skip2/go-qrcode"
 )
 
-// DisplayDeviceCode shows the device code and prompts the user to copy it.
+const (
+	// defaultPollInterval is the shortest time between device flow token requests.
+	defaultPollInterval = 5 * time.Second
+	// defaultSlowDownIncrement is added to the polling interval on slow_down,
+	// as recommended by RFC 8628.
+	defaultSlowDownIncrement = 5 * time.Second
+)
+
+// durationOr returns d, or fallback if d is zero.
+func durationOr(d, fallback time.Duration) time.Duration {
+	if d != 0 {
+		return d
+	}
+
+	return fallback
+}
+
+// openBrowser opens a URL in the user's browser (replaced in tests).
+var openBrowser = browser.OpenURL
+
+// isInteractive reports whether the user can be prompted (replaced in tests).
+var isInteractive = ui.IsInteractive
+
+// deviceCodeDisplay controls how the device flow code and authorization URL
+// are presented, as set by Config.NoBrowser, Config.WaitForEnter and Config.QRCode.
+type deviceCodeDisplay struct {
+	noBrowser    bool
+	waitForEnter bool
+	qrCode       bool
+}
+
+// newDeviceCodeDisplay returns the device code display configured by cfg.
+func newDeviceCodeDisplay(cfg Config) deviceCodeDisplay {
+	return deviceCodeDisplay{noBrowser: cfg.NoBrowser, waitForEnter: cfg.WaitForEnter, qrCode: cfg.QRCode}
+}
+
+// show displays code and the authorization URL, then opens the URL in the
+// browser unless noBrowser or qrCode is set. With qrCode, the URL is also shown
+// as a QR code to scan with another device. With waitForEnter, it first waits
+// for Enter so the code can be copied, but only when the user can be prompted.
+func (d deviceCodeDisplay) show(code, url string) {
+	DisplayDeviceCode(code)
+
+	if d.qrCode {
+		DisplayQRCode(url)
+	}
+
+	if d.noBrowser || d.qrCode {
+		DisplayURL(url)
+		return
+	}
+
+	if d.waitForEnter && isInteractive() {
+		_, _ = ui.ReadInput("Copy the code above and press Enter to open the browser...")
+	}
+
+	DisplayURLAndOpenBrowser(url)
+}
+
+// DisplayDeviceCode shows the device code the user enters on the authorization page.
 func DisplayDeviceCode(code string) {
 	fmt.Println()
 	fmt.Printf("One-time code: %s\n", code)
 	fmt.Println()
+}
 
-	_, _ = ui.ReadInput("Copy the code above and press Enter to continue...")
+// DisplayURL shows the authorization URL for the user to visit themselves.
+func DisplayURL(url string) {
+	fmt.Printf("Authorization URL: %s\n", url)
+	fmt.Println()
+	fmt.Println("Visit the URL above and enter your code.")
+}
+
+// DisplayQRCode shows url as a QR code drawn with block characters, light on
+// dark as most terminals are, so it can be scanned with a phone.
+func DisplayQRCode(url string) {
+	code, err := qrcode.New(url, qrcode.Low)
+	if err != nil {
+		fmt.Printf("Could not create a QR code: %v\n", err)
+		return
+	}
+
+	fmt.Println("Scan this QR code to open the authorization URL on another device:")
+	fmt.Println()
+	fmt.Print(code.ToSmallString(false))
+	fmt.Println()
 }
 
 // DisplayURLAndOpenBrowser shows the authorization URL and attempts to open it in the browser.
 func DisplayURLAndOpenBrowser(url string) {
-	fmt.Println()
 	fmt.Printf("Authorization URL: %s\n", url)
 	fmt.Println()
 	fmt.Println("Opening browser...")
 
-	if err := browser.OpenURL(url); err != nil {
+	if err := openBrowser(url); err != nil {
 		fmt.Println("Could not open browser automatically.")
 		fmt.Println("Please manually visit the URL above and enter your code.")
 	}
@@ -34,3 +114,13 @@ func ShowWaitingMessage() {
 	fmt.Println()
 	fmt.Println("Waiting for authorization...")
 }
+
+// resume tells the user a previously started authorization is being resumed,
+// then displays its code and authorization URL like show, since the page the
+// code was entered on may have been closed.
+func (d deviceCodeDisplay) resume(code, url string, expiresAt time.Time) {
+	fmt.Println()
+	fmt.Printf("Resuming earlier authorization; the code expires in %s.\n", time.Until(expiresAt).Round(time.Second))
+
+	d.show(code, url)
+}
@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeviceCodeDisplayShow(t *testing.T) {
+	originalOpen := openBrowser
+	originalInteractive := isInteractive
+
+	defer func() {
+		openBrowser = originalOpen
+		isInteractive = originalInteractive
+	}()
+
+	tests := []struct {
+		name        string
+		display     deviceCodeDisplay
+		interactive bool
+		wantOpened  bool
+		wantRead    bool
+		wantQRCode  bool
+	}{
+		{name: "opens the browser right away", wantOpened: true},
+		{name: "no browser", display: deviceCodeDisplay{noBrowser: true, waitForEnter: true}, interactive: true},
+		{name: "waits for enter in a terminal", display: deviceCodeDisplay{waitForEnter: true}, interactive: true, wantOpened: true, wantRead: true},
+		{name: "no pause without a terminal", display: deviceCodeDisplay{waitForEnter: true}, wantOpened: true},
+		{name: "qr code instead of the browser", display: deviceCodeDisplay{qrCode: true, waitForEnter: true}, interactive: true, wantQRCode: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opened string
+
+			openBrowser = func(url string) error {
+				opened = url
+				return nil
+			}
+			isInteractive = func() bool { return tt.interactive }
+
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, _ = w.WriteString("\n")
+			_ = w.Close()
+
+			originalStdin := os.Stdin
+			os.Stdin = r
+
+			defer func() {
+				os.Stdin = originalStdin
+
+				_ = r.Close()
+			}()
+
+			output := captureOutput(t, func() { tt.display.show("ABCD-1234", "https://example.com/device") })
+
+			if !strings.Contains(output, "One-time code: ABCD-1234") {
+				t.Errorf("expected the code to be shown\nGot output:\n%s", output)
+			}
+
+			// The QR code is drawn with half-block characters, and the URL stays readable below it
+			if hasQRCode := strings.ContainsAny(output, "▀▄█"); hasQRCode != tt.wantQRCode {
+				t.Errorf("QR code shown: %v, want %v\nGot output:\n%s", hasQRCode, tt.wantQRCode, output)
+			}
+
+			if tt.wantQRCode && !strings.Contains(output, "Authorization URL: https://example.com/device") {
+				t.Errorf("expected the URL below the QR code\nGot output:\n%s", output)
+			}
+
+			if (opened != "") != tt.wantOpened {
+				t.Errorf("browser opened with %q, want opened: %v", opened, tt.wantOpened)
+			}
+
+			// An unread Enter is still waiting in the pipe
+			buf := make([]byte, 1)
+			n, _ := r.Read(buf)
+
+			if read := n == 0; read != tt.wantRead {
+				t.Errorf("Enter was read: %v, want %v", read, tt.wantRead)
+			}
+		})
+	}
+}
+
+// captureOutput returns everything fn writes to stdout.
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	originalStdout := os.Stdout
+	os.Stdout = w
+
+	fn()
+
+	_ = w.Close()
+	os.Stdout = originalStdout
+
+	output, _ := io.ReadAll(r)
+	_ = r.Close()
+
+	return string(output)
+}
+
+func TestDeviceCodeDisplayResume(t *testing.T) {
+	originalOpen := openBrowser
+
+	defer func() { openBrowser = originalOpen }()
+
+	var opened string
+
+	openBrowser = func(url string) error {
+		opened = url
+		return nil
+	}
+
+	expiresAt := time.Now().Add(10 * time.Minute)
+
+	deviceCodeDisplay{}.resume("ABCD-1234", "https://example.com/device", expiresAt)
+
+	if opened != "https://example.com/device" {
+		t.Errorf("resumed flow opened %q, want the saved verification URI", opened)
+	}
+
+	opened = ""
+
+	deviceCodeDisplay{noBrowser: true}.resume("ABCD-1234", "https://example.com/device", expiresAt)
+
+	if opened != "" {
+		t.Errorf("resumed flow opened %q despite --no-browser", opened)
+	}
+}
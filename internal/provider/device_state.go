@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// stateDirPermissions is the permission mode for the nix-auth state directory.
+	stateDirPermissions = 0o700
+	// stateFilePermissions is the permission mode for saved device codes.
+	stateFilePermissions = 0o600
+)
+
+// pendingDeviceCode is a device code saved while waiting for authorization,
+// so that a login interrupted by a network failure can resume polling it.
+type pendingDeviceCode struct {
+	Provider                string    `json:"provider"`
+	Host                    string    `json:"host"`
+	ClientID                string    `json:"client_id"`
+	Scopes                  []string  `json:"scopes"`
+	DeviceCode              string    `json:"device_code"`
+	UserCode                string    `json:"user_code"`
+	VerificationURI         string    `json:"verification_uri"`
+	VerificationURIComplete string    `json:"verification_uri_complete,omitempty"`
+	Interval                int       `json:"interval"`
+	ExpiresAt               time.Time `json:"expires_at"`
+}
+
+// StateDir returns the directory holding nix-auth runtime state:
+// XDG_STATE_HOME/nix-auth, or ~/.local/state/nix-auth by default.
+func StateDir() (string, error) {
+	if xdgStateHome := os.Getenv("XDG_STATE_HOME"); xdgStateHome != "" {
+		return filepath.Join(xdgStateHome, "nix-auth"), nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".local", "state", "nix-auth"), nil
+}
+
+// pendingDeviceCodePath returns the file a device code for providerName and host is saved in.
+func pendingDeviceCodePath(providerName, host string) (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("device-%s-%s.json", providerName, strings.ReplaceAll(strings.ToLower(host), string(filepath.Separator), "_"))
+
+	return filepath.Join(dir, name), nil
+}
+
+// savePendingDeviceCode stores a device code so a later login can resume it.
+// Failing to save is not fatal; the login simply cannot be resumed.
+func savePendingDeviceCode(code *pendingDeviceCode) {
+	path, err := pendingDeviceCodePath(code.Provider, code.Host)
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(code)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), stateDirPermissions); err != nil {
+		return
+	}
+
+	_ = os.WriteFile(path, data, stateFilePermissions)
+}
+
+// loadPendingDeviceCode returns a saved device code for the provider, host, client ID
+// and scopes, or nil if there is none or it has expired. Expired codes are removed.
+// A code requested for other scopes would authorize a token without the ones
+// asked for now, so it is not resumed.
+func loadPendingDeviceCode(providerName, host, clientID string, scopes []string) *pendingDeviceCode {
+	path, err := pendingDeviceCodePath(providerName, host)
+	if err != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // path built from the state directory
+	if err != nil {
+		return nil
+	}
+
+	var code pendingDeviceCode
+	if err := json.Unmarshal(data, &code); err != nil {
+		_ = os.Remove(path)
+		return nil
+	}
+
+	if !time.Now().Before(code.ExpiresAt) {
+		_ = os.Remove(path)
+		return nil
+	}
+
+	if code.Provider != providerName || !strings.EqualFold(code.Host, host) || code.ClientID != clientID ||
+		!sameScopes(code.Scopes, scopes) {
+		return nil
+	}
+
+	return &code
+}
+
+// sameScopes reports whether a and b hold the same scopes, in any order.
+func sameScopes(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// clearPendingDeviceCode removes any saved device code for the provider and host.
+func clearPendingDeviceCode(providerName, host string) {
+	path, err := pendingDeviceCodePath(providerName, host)
+	if err != nil {
+		return
+	}
+
+	_ = os.Remove(path)
+}
+
+// finishDeviceFlow clears the saved device code unless err is a transient network
+// failure, in which case the code is kept so the next login can resume polling.
+func finishDeviceFlow(providerName, host string, err error) {
+	var urlErr *url.Error
+	if err != nil && errors.As(err, &urlErr) {
+		fmt.Println("\nThe device code is still valid. Run the same login command again to resume waiting for authorization.")
+		return
+	}
+
+	clearPendingDeviceCode(providerName, host)
+}
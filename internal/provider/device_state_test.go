@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPendingDeviceCode(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	saved := &pendingDeviceCode{
+		Provider:        "gitlab",
+		Host:            "gitlab.company.com",
+		ClientID:        "client-1",
+		Scopes:          []string{"read_api", "read_repository"},
+		DeviceCode:      "device-123",
+		UserCode:        "ABCD-EFGH",
+		VerificationURI: "https://gitlab.company.com/oauth/device",
+		Interval:        5,
+		ExpiresAt:       time.Now().Add(10 * time.Minute),
+	}
+	savePendingDeviceCode(saved)
+
+	tests := []struct {
+		name      string
+		provider  string
+		host      string
+		clientID  string
+		scopes    []string
+		expectHit bool
+	}{
+		{name: "same provider, host and client", provider: "gitlab", host: "gitlab.company.com", clientID: "client-1", expectHit: true},
+		{name: "host matched case-insensitively", provider: "gitlab", host: "GitLab.Company.com", clientID: "client-1", expectHit: true},
+		{name: "scopes in another order", provider: "gitlab", host: "gitlab.company.com", clientID: "client-1", scopes: []string{"read_repository", "read_api"}, expectHit: true},
+		{name: "different client ID", provider: "gitlab", host: "gitlab.company.com", clientID: "client-2", expectHit: false},
+		{name: "different host", provider: "gitlab", host: "gitlab.com", clientID: "client-1", expectHit: false},
+		{name: "different provider", provider: "github", host: "gitlab.company.com", clientID: "client-1", expectHit: false},
+		{name: "additional scope", provider: "gitlab", host: "gitlab.company.com", clientID: "client-1", scopes: []string{"read_api", "read_repository", "read_registry"}, expectHit: false},
+		{name: "fewer scopes", provider: "gitlab", host: "gitlab.company.com", clientID: "client-1", scopes: []string{"read_api"}, expectHit: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scopes := tt.scopes
+			if scopes == nil {
+				scopes = saved.Scopes
+			}
+
+			code := loadPendingDeviceCode(tt.provider, tt.host, tt.clientID, scopes)
+			if tt.expectHit && (code == nil || code.DeviceCode != "device-123") {
+				t.Errorf("expected saved device code, got %+v", code)
+			}
+
+			if !tt.expectHit && code != nil {
+				t.Errorf("expected no device code, got %+v", code)
+			}
+		})
+	}
+}
+
+func TestPendingDeviceCodeExpired(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	savePendingDeviceCode(&pendingDeviceCode{
+		Provider:   "github",
+		Host:       "github.com",
+		DeviceCode: "device-123",
+		ExpiresAt:  time.Now().Add(-time.Second),
+	})
+
+	if code := loadPendingDeviceCode("github", "github.com", "", nil); code != nil {
+		t.Errorf("expected expired device code to be ignored, got %+v", code)
+	}
+
+	path, err := pendingDeviceCodePath("github", "github.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected expired device code file to be removed, stat error: %v", err)
+	}
+}
+
+func TestFinishDeviceFlow(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		expectKept bool
+	}{
+		{name: "success clears", err: nil, expectKept: false},
+		{name: "network failure keeps", err: fmt.Errorf("failed: %w", &url.Error{Op: "Post", URL: "https://github.com", Err: fmt.Errorf("connection reset")}), expectKept: true},
+		{name: "definitive failure clears", err: fmt.Errorf("access denied by user"), expectKept: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+			savePendingDeviceCode(&pendingDeviceCode{
+				Provider:   "github",
+				Host:       "github.com",
+				DeviceCode: "device-123",
+				ExpiresAt:  time.Now().Add(10 * time.Minute),
+			})
+
+			finishDeviceFlow("github", "github.com", tt.err)
+
+			kept := loadPendingDeviceCode("github", "github.com", "", nil) != nil
+			if kept != tt.expectKept {
+				t.Errorf("expected kept=%v, got %v", tt.expectKept, kept)
+			}
+		})
+	}
+}
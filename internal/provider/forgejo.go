@@ -13,6 +13,9 @@ func init() {
 					providerName: "forgejo",
 					defaultHost:  "", // No default host for Forgejo
 					host:         cfg.Host,
+					apiURL:       cfg.apiURLOverride(),
+					scopes:       cfg.Scopes,
+					client:       cfg.HTTPClient,
 				},
 			}
 		},
@@ -28,6 +31,9 @@ func init() {
 					providerName: "forgejo",
 					defaultHost:  "codeberg.org",
 					host:         cfg.Host,
+					apiURL:       cfg.apiURLOverride(),
+					scopes:       cfg.Scopes,
+					client:       cfg.HTTPClient,
 				},
 			}
 		},
@@ -13,6 +13,9 @@ func init() {
 					providerName: "gitea",
 					defaultHost:  "gitea.com",
 					host:         cfg.Host,
+					apiURL:       cfg.apiURLOverride(),
+					scopes:       cfg.Scopes,
+					client:       cfg.HTTPClient,
 				},
 			}
 		},
@@ -3,9 +3,13 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cli/oauth/device"
 )
@@ -14,8 +18,13 @@ func init() {
 	RegisterProvider("github", Registration{
 		New: func(cfg Config) Provider {
 			return &GitHubProvider{
-				host:     cfg.Host,
-				clientID: cfg.ClientID,
+				host:         cfg.Host,
+				clientID:     cfg.ClientID,
+				clientSecret: cfg.ClientSecret,
+				apiURL:       cfg.apiURLOverride(),
+				scopes:       cfg.Scopes,
+				client:       cfg.HTTPClient,
+				display:      newDeviceCodeDisplay(cfg),
 			}
 		},
 		Detect:      NewGitHubProviderForHost,
@@ -67,6 +76,28 @@ func NewGitHubProviderForHost(ctx context.Context, client *http.Client, host str
 type GitHubProvider struct {
 	host     string
 	clientID string
+	// clientSecret is sent when exchanging the device code for confidential OAuth apps.
+	clientSecret string
+	apiURL       string
+	// scopes overrides the default scopes requested during authentication.
+	scopes []string
+	client *http.Client
+	// display controls how the device code is shown to the user.
+	display deviceCodeDisplay
+
+	// viewerMu guards viewer, what the last query reported about a token, so
+	// that validating a token and showing its user and scopes is one request.
+	viewerMu sync.Mutex
+	viewer   *githubViewer
+}
+
+// githubViewer is what GitHub reports about the user of a token.
+type githubViewer struct {
+	token    string
+	login    string
+	name     string
+	scopes   []string // the X-OAuth-Scopes header values
+	hasScope bool     // whether the header was sent at all
 }
 
 // getBaseURL returns the base URL for web URLs
@@ -77,8 +108,21 @@ func (g *GitHubProvider) getBaseURL() string {
 	return "https://github.com"
 }
 
+// getGraphQLURL returns the URL of the GraphQL API.
+func (g *GitHubProvider) getGraphQLURL() string {
+	// GitHub Enterprise serves REST at /api/v3 and GraphQL at /api/graphql
+	if apiURL, ok := strings.CutSuffix(g.getAPIURL(), "/api/v3"); ok {
+		return apiURL + "/api/graphql"
+	}
+
+	return g.getAPIURL() + "/graphql"
+}
+
 // getAPIURL returns the base URL for API calls
 func (g *GitHubProvider) getAPIURL() string {
+	if g.apiURL != "" {
+		return g.apiURL
+	}
 	if g.host != "" && g.host != "github.com" {
 		// GitHub Enterprise uses {host}/api/v3
 		return fmt.Sprintf("https://%s/api/v3", g.host)
@@ -92,7 +136,7 @@ func (g *GitHubProvider) makeGitHubAPIRequest(ctx context.Context, token string,
 	headers := map[string]string{
 		"Accept": "application/vnd.github.v3+json",
 	}
-	return makeAuthenticatedRequest(ctx, "GET", endpoint, "token "+token, headers)
+	return makeAuthenticatedRequest(ctx, g.client, "GET", endpoint, "token "+token, headers)
 }
 
 func (g *GitHubProvider) Name() string {
@@ -106,9 +150,22 @@ func (g *GitHubProvider) Host() string {
 	return "github.com"
 }
 
+// Edition reports GitHub Enterprise for any host other than github.com.
+func (g *GitHubProvider) Edition() string {
+	if !strings.EqualFold(g.Host(), "github.com") {
+		return "Enterprise"
+	}
+
+	return ""
+}
+
+func (g *GitHubProvider) AuthMethod() AuthMethod {
+	return AuthMethodDeviceFlow
+}
+
 func (g *GitHubProvider) GetScopes() []string {
 	// Minimal scope needed for private repo access
-	return []string{"repo"}
+	return scopesOr(g.scopes, []string{"repo"})
 }
 
 func (g *GitHubProvider) Authenticate(ctx context.Context) (string, error) {
@@ -134,25 +191,42 @@ func (g *GitHubProvider) Authenticate(ctx context.Context) (string, error) {
 	}
 
 	scopes := g.GetScopes()
-	httpClient := &http.Client{}
+	httpClient := httpClientOrDefault(g.client)
+
+	// Resume a device code from an interrupted login, or request a new one
+	code := g.resumeDeviceCode(clientID, scopes)
+	if code == nil {
+		deviceCodeURL := fmt.Sprintf("%s/login/device/code", g.getBaseURL())
+		var err error
+		code, err = device.RequestCode(httpClient, deviceCodeURL, clientID, scopes)
+		if err != nil {
+			return "", fmt.Errorf("failed to request device code: %w", err)
+		}
 
-	// Request device code
-	deviceCodeURL := fmt.Sprintf("%s/login/device/code", g.getBaseURL())
-	code, err := device.RequestCode(httpClient, deviceCodeURL, clientID, scopes)
-	if err != nil {
-		return "", fmt.Errorf("failed to request device code: %w", err)
+		savePendingDeviceCode(&pendingDeviceCode{
+			Provider:        g.Name(),
+			Host:            g.Host(),
+			ClientID:        clientID,
+			Scopes:          scopes,
+			DeviceCode:      code.DeviceCode,
+			UserCode:        code.UserCode,
+			VerificationURI: code.VerificationURI,
+			Interval:        code.Interval,
+			ExpiresAt:       time.Now().Add(time.Duration(code.ExpiresIn) * time.Second),
+		})
+
+		g.display.show(code.UserCode, code.VerificationURI)
 	}
-
-	DisplayDeviceCode(code.UserCode)
-	DisplayURLAndOpenBrowser(code.VerificationURI)
 	ShowWaitingMessage()
 
 	// Wait for user to authorize
 	accessTokenURL := fmt.Sprintf("%s/login/oauth/access_token", g.getBaseURL())
 	accessToken, err := device.Wait(ctx, httpClient, accessTokenURL, device.WaitOptions{
-		ClientID:   clientID,
-		DeviceCode: code,
+		ClientID:     clientID,
+		ClientSecret: g.clientSecret,
+		DeviceCode:   code,
 	})
+	finishDeviceFlow(g.Name(), g.Host(), err)
 	if err != nil {
 		return "", fmt.Errorf("failed to get access token: %w", err)
 	}
@@ -160,22 +234,138 @@ func (g *GitHubProvider) Authenticate(ctx context.Context) (string, error) {
 	return accessToken.Token, nil
 }
 
+// resumeDeviceCode returns a saved device code for scopes that is still valid, or nil.
+func (g *GitHubProvider) resumeDeviceCode(clientID string, scopes []string) *device.CodeResponse {
+	pending := loadPendingDeviceCode(g.Name(), g.Host(), clientID, scopes)
+	if pending == nil {
+		return nil
+	}
+
+	g.display.resume(pending.UserCode, pending.VerificationURI, pending.ExpiresAt)
+
+	return &device.CodeResponse{
+		DeviceCode:      pending.DeviceCode,
+		UserCode:        pending.UserCode,
+		VerificationURI: pending.VerificationURI,
+		ExpiresIn:       int(time.Until(pending.ExpiresAt).Seconds()),
+		Interval:        pending.Interval,
+	}
+}
+
 func (g *GitHubProvider) ValidateToken(ctx context.Context, token string) (ValidationStatus, error) {
-	userURL := fmt.Sprintf("%s/user", g.getAPIURL())
-	resp, err := g.makeGitHubAPIRequest(ctx, token, userURL)
-	if err != nil {
+	if _, err := g.queryViewer(ctx, token); err != nil {
 		return ValidationStatusInvalid, fmt.Errorf("failed to validate token: %w", err)
 	}
-	defer resp.Body.Close()
 
 	return ValidationStatusValid, nil
 }
 
 func (g *GitHubProvider) GetUserInfo(ctx context.Context, token string) (username, fullName string, err error) {
+	viewer, err := g.queryViewer(ctx, token)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get user info: %w", err)
+	}
+
+	return viewer.login, viewer.name, nil
+}
+
+// CanAccessRepo reports whether token can read the repository owner/repo.
+func (g *GitHubProvider) CanAccessRepo(ctx context.Context, token, repoPath string) (bool, error) {
+	return repoAccessResult(g.makeGitHubAPIRequest(ctx, token, fmt.Sprintf("%s/repos/%s", g.getAPIURL(), repoPath)))
+}
+
+// queryViewer returns the user and scopes of token. They are fetched with a
+// single GraphQL query, falling back to the REST API on instances where the
+// query fails for another reason than the token being rejected, such as older
+// GitHub Enterprise versions. The result is kept for later calls with the
+// same token.
+func (g *GitHubProvider) queryViewer(ctx context.Context, token string) (*githubViewer, error) {
+	g.viewerMu.Lock()
+	defer g.viewerMu.Unlock()
+
+	if g.viewer != nil && g.viewer.token == token {
+		return g.viewer, nil
+	}
+
+	viewer, err := g.queryViewerGraphQL(ctx, token)
+	if err != nil && restMayAnswer(ctx, err) {
+		viewer, err = g.queryViewerREST(ctx, token)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	g.viewer = viewer
+
+	return viewer, nil
+}
+
+// restMayAnswer reports whether the REST API may succeed where the GraphQL
+// query failed with err. A rejected token, an unreachable host or a request
+// that ran out of time would fail there too, only later.
+func restMayAnswer(ctx context.Context, err error) bool {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, ErrInvalidToken), IsNetworkError(err), ctx.Err() != nil:
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return false
+	}
+
+	return true
+}
+
+// queryViewerGraphQL fetches the user of token from the GraphQL API.
+func (g *GitHubProvider) queryViewerGraphQL(ctx context.Context, token string) (*githubViewer, error) {
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	resp, err := makeAuthenticatedRequestWithBody(ctx, g.client, "POST", g.getGraphQLURL(),
+		strings.NewReader(`{"query": "query { viewer { login name } }"}`), "token "+token, headers)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data struct {
+			Viewer *struct {
+				Login string `json:"login"`
+				Name  string `json:"name"`
+			} `json:"viewer"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("graphql query failed: %s", result.Errors[0].Message)
+	}
+
+	if result.Data.Viewer == nil {
+		return nil, fmt.Errorf("graphql query returned no viewer")
+	}
+
+	return newGitHubViewer(token, result.Data.Viewer.Login, result.Data.Viewer.Name, resp.Header), nil
+}
+
+// queryViewerREST fetches the user of token from the REST API.
+func (g *GitHubProvider) queryViewerREST(ctx context.Context, token string) (*githubViewer, error) {
 	userURL := fmt.Sprintf("%s/user", g.getAPIURL())
+
 	resp, err := g.makeGitHubAPIRequest(ctx, token, userURL)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get user info: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -185,22 +375,31 @@ func (g *GitHubProvider) GetUserInfo(ctx context.Context, token string) (usernam
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		return "", "", fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return user.Login, user.Name, nil
+	return newGitHubViewer(token, user.Login, user.Name, resp.Header), nil
+}
+
+// newGitHubViewer records the user of token with the scopes from the response header.
+func newGitHubViewer(token, login, name string, header http.Header) *githubViewer {
+	scopes := header.Values("X-OAuth-Scopes")
+
+	return &githubViewer{
+		token:    token,
+		login:    login,
+		name:     name,
+		scopes:   scopes,
+		hasScope: len(scopes) > 0,
+	}
 }
 
 func (g *GitHubProvider) GetTokenScopes(ctx context.Context, token string) ([]string, error) {
-	userURL := fmt.Sprintf("%s/user", g.getAPIURL())
-	resp, err := g.makeGitHubAPIRequest(ctx, token, userURL)
+	scopesHeader, err := g.GetRawTokenScopes(ctx, token)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check token scopes: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	// GitHub returns OAuth scopes in the X-OAuth-Scopes header
-	scopesHeader := resp.Header.Get("X-OAuth-Scopes")
 	if scopesHeader == "" {
 		return []string{}, nil
 	}
@@ -216,3 +415,24 @@ func (g *GitHubProvider) GetTokenScopes(ctx context.Context, token string) ([]st
 
 	return scopes, nil
 }
+
+// GetRawTokenScopes returns the X-OAuth-Scopes header GitHub sends for token,
+// unparsed. Classic tokens without scopes get an empty header, while
+// fine-grained tokens get none, which is a ScopesNotExposedError.
+func (g *GitHubProvider) GetRawTokenScopes(ctx context.Context, token string) (string, error) {
+	viewer, err := g.queryViewer(ctx, token)
+	if err != nil {
+		return "", fmt.Errorf("failed to check token scopes: %w", err)
+	}
+
+	if !viewer.hasScope {
+		notExposed := &ScopesNotExposedError{}
+		if strings.HasPrefix(token, "github_pat_") {
+			notExposed.TokenType = "fine-grained"
+		}
+
+		return "", notExposed
+	}
+
+	return strings.Join(viewer.scopes, ", "), nil
+}
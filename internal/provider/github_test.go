@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitHubProvider_API(t *testing.T) {
+	const validToken = "ghp_validtoken"
+
+	server := newProviderTestServer(t, "token "+validToken, map[string]http.HandlerFunc{
+		"/user": jsonResponse(`{"login": "octocat", "name": "The Octocat"}`, map[string]string{
+			"X-OAuth-Scopes": "repo, read:org",
+		}),
+	})
+	p := newTestProvider(t, "github", server)
+	ctx := context.Background()
+
+	t.Run("valid token", func(t *testing.T) {
+		status, err := p.ValidateToken(ctx, validToken)
+		if err != nil || status != ValidationStatusValid {
+			t.Errorf("ValidateToken() = %v, %v; want valid", status, err)
+		}
+
+		username, fullName, err := p.GetUserInfo(ctx, validToken)
+		if err != nil || username != "octocat" || fullName != "The Octocat" {
+			t.Errorf("GetUserInfo() = %q, %q, %v", username, fullName, err)
+		}
+
+		scopes, err := p.GetTokenScopes(ctx, validToken)
+		if err != nil || strings.Join(scopes, ",") != "repo,read:org" {
+			t.Errorf("GetTokenScopes() = %v, %v; want [repo read:org]", scopes, err)
+		}
+
+		raw, err := GetRawTokenScopes(ctx, p, validToken)
+		if err != nil || raw != "repo, read:org" {
+			t.Errorf("GetRawTokenScopes() = %q, %v; want the unparsed header", raw, err)
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		status, err := p.ValidateToken(ctx, "ghp_revoked")
+		if status != ValidationStatusInvalid || err == nil || !strings.Contains(err.Error(), "invalid or expired") {
+			t.Errorf("ValidateToken() = %v, %v; want invalid or expired", status, err)
+		}
+
+		if _, _, err := p.GetUserInfo(ctx, "ghp_revoked"); err == nil {
+			t.Error("GetUserInfo() expected error for invalid token")
+		}
+
+		if _, err := p.GetTokenScopes(ctx, "ghp_revoked"); err == nil {
+			t.Error("GetTokenScopes() expected error for invalid token")
+		}
+	})
+}
+
+func TestGitHubProvider_NotFound(t *testing.T) {
+	server := newProviderTestServer(t, "token ghp_validtoken", map[string]http.HandlerFunc{})
+	p := newTestProvider(t, "github", server)
+
+	status, err := p.ValidateToken(context.Background(), "ghp_validtoken")
+	if status != ValidationStatusInvalid || err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("ValidateToken() = %v, %v; want unexpected status 404", status, err)
+	}
+}
+
+func TestGitHubProvider_ScopesNotExposed(t *testing.T) {
+	const fineGrainedToken = "github_pat_finegrained"
+
+	t.Run("fine-grained token without header", func(t *testing.T) {
+		server := newProviderTestServer(t, "token "+fineGrainedToken, map[string]http.HandlerFunc{
+			"/user": jsonResponse(`{"login": "octocat"}`, nil),
+		})
+		p := newTestProvider(t, "github", server)
+
+		_, err := p.GetTokenScopes(context.Background(), fineGrainedToken)
+
+		var notExposed *ScopesNotExposedError
+		if !errors.As(err, &notExposed) || notExposed.TokenType != "fine-grained" {
+			t.Errorf("GetTokenScopes() error = %v; want ScopesNotExposedError for fine-grained", err)
+		}
+	})
+
+	t.Run("classic token with empty header", func(t *testing.T) {
+		server := newProviderTestServer(t, "token ghp_noscopes", map[string]http.HandlerFunc{
+			"/user": jsonResponse(`{"login": "octocat"}`, map[string]string{"X-OAuth-Scopes": ""}),
+		})
+		p := newTestProvider(t, "github", server)
+
+		scopes, err := p.GetTokenScopes(context.Background(), "ghp_noscopes")
+		if err != nil || len(scopes) != 0 {
+			t.Errorf("GetTokenScopes() = %v, %v; want no scopes and no error", scopes, err)
+		}
+	})
+}
+
+func TestGitHubProvider_GraphQL(t *testing.T) {
+	const validToken = "ghp_validtoken"
+
+	var requests []string
+
+	server := newProviderTestServer(t, "token "+validToken, map[string]http.HandlerFunc{
+		"/graphql": func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			jsonResponse(`{"data": {"viewer": {"login": "octocat", "name": "The Octocat"}}}`, map[string]string{
+				"X-OAuth-Scopes": "repo",
+			})(w, r)
+		},
+		"/user": func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			jsonResponse(`{"login": "rest"}`, nil)(w, r)
+		},
+	})
+	p := newTestProvider(t, "github", server)
+	ctx := context.Background()
+
+	if status, err := p.ValidateToken(ctx, validToken); err != nil || status != ValidationStatusValid {
+		t.Fatalf("ValidateToken() = %v, %v; want valid", status, err)
+	}
+
+	username, fullName, err := p.GetUserInfo(ctx, validToken)
+	if err != nil || username != "octocat" || fullName != "The Octocat" {
+		t.Errorf("GetUserInfo() = %q, %q, %v", username, fullName, err)
+	}
+
+	scopes, err := p.GetTokenScopes(ctx, validToken)
+	if err != nil || strings.Join(scopes, ",") != "repo" {
+		t.Errorf("GetTokenScopes() = %v, %v; want [repo]", scopes, err)
+	}
+
+	if strings.Join(requests, ",") != "POST /graphql" {
+		t.Errorf("expected a single GraphQL request, got %v", requests)
+	}
+}
+
+func TestGitHubProvider_GraphQLFallback(t *testing.T) {
+	server := newProviderTestServer(t, "token ghp_validtoken", map[string]http.HandlerFunc{
+		"/graphql": jsonResponse(`{"errors": [{"message": "not supported"}]}`, nil),
+		"/user":    jsonResponse(`{"login": "rest"}`, map[string]string{"X-OAuth-Scopes": "repo"}),
+	})
+	p := newTestProvider(t, "github", server)
+
+	username, _, err := p.GetUserInfo(context.Background(), "ghp_validtoken")
+	if err != nil || username != "rest" {
+		t.Errorf("GetUserInfo() = %q, %v; want the REST user", username, err)
+	}
+}
+
+func TestGitHubProvider_NoFallbackAfterTimeout(t *testing.T) {
+	restRequests := 0
+	release := make(chan struct{})
+
+	server := newProviderTestServer(t, "token ghp_validtoken", map[string]http.HandlerFunc{
+		"/graphql": func(http.ResponseWriter, *http.Request) { <-release },
+		"/user": func(w http.ResponseWriter, r *http.Request) {
+			restRequests++
+			jsonResponse(`{"login": "rest"}`, nil)(w, r)
+		},
+	})
+	// Cleanups run in reverse, so the handler returns before the server closes
+	t.Cleanup(func() { close(release) })
+
+	client := server.Client()
+	client.Timeout = 50 * time.Millisecond
+
+	p, _ := GetWithConfig("github", Config{Host: "git.example.com", APIURL: server.URL, HTTPClient: client})
+
+	if _, _, err := p.GetUserInfo(context.Background(), "ghp_validtoken"); err == nil {
+		t.Error("GetUserInfo() expected a timeout error")
+	}
+
+	if restRequests != 0 {
+		t.Errorf("expected no REST request after the GraphQL request timed out, got %d", restRequests)
+	}
+}
+
+func TestRestMayAnswer(t *testing.T) {
+	ctx := context.Background()
+	dialErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "graphql error", err: errors.New("graphql query failed: not supported"), want: true},
+		{name: "invalid token", err: ErrInvalidToken},
+		{name: "unreachable host", err: &url.Error{Op: "Post", URL: "https://git.example.com", Err: dialErr}},
+		{name: "deadline", err: fmt.Errorf("request failed: %w", context.DeadlineExceeded)},
+	}
+
+	for _, tt := range tests {
+		if got := restMayAnswer(ctx, tt.err); got != tt.want {
+			t.Errorf("%s: restMayAnswer() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGitHubProvider_GraphQLURL(t *testing.T) {
+	tests := []struct {
+		provider *GitHubProvider
+		want     string
+	}{
+		{provider: &GitHubProvider{host: "github.com"}, want: "https://api.github.com/graphql"},
+		{provider: &GitHubProvider{host: "github.company.com"}, want: "https://github.company.com/api/graphql"},
+		{provider: &GitHubProvider{host: "github.company.com", apiURL: "https://gateway.company.com/github"}, want: "https://gateway.company.com/github/graphql"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.provider.getGraphQLURL(); got != tt.want {
+			t.Errorf("getGraphQLURL() for %s = %q, want %q", tt.provider.host, got, tt.want)
+		}
+	}
+}
@@ -10,14 +10,31 @@ import (
 	"time"
 )
 
-const tokenPrefix = "OAuth2"
+const (
+	// tokenPrefix marks OAuth tokens in nix.conf, which Nix sends as a Bearer token.
+	tokenPrefix = "OAuth2"
+	// patTokenPrefix marks personal access tokens in nix.conf, which Nix sends as PRIVATE-TOKEN.
+	patTokenPrefix = "PAT"
+	// personalAccessTokenPrefix starts the value of GitLab personal access tokens.
+	personalAccessTokenPrefix = "glpat-"
+	// oauthTokenType describes the tokens whose scopes GitLab does not expose.
+	oauthTokenType = "OAuth"
+)
 
 func init() {
 	RegisterProvider("gitlab", Registration{
 		New: func(cfg Config) Provider {
 			return &GitLabProvider{
-				host:     cfg.Host,
-				clientID: cfg.ClientID,
+				host:         cfg.Host,
+				clientID:     cfg.ClientID,
+				clientSecret: cfg.ClientSecret,
+				apiURL:       cfg.apiURLOverride(),
+				scopes:       cfg.Scopes,
+				client:       cfg.HTTPClient,
+
+				pollInterval:      cfg.PollInterval,
+				slowDownIncrement: cfg.SlowDownIncrement,
+				display:           newDeviceCodeDisplay(cfg),
 			}
 		},
 		Detect:      NewGitLabProviderForHost,
@@ -66,6 +83,27 @@ func NewGitLabProviderForHost(ctx context.Context, client *http.Client, host str
 type GitLabProvider struct {
 	host     string
 	clientID string
+	// clientSecret authenticates the client for confidential OAuth apps.
+	clientSecret string
+	apiURL       string
+	// scopes overrides the default scopes requested during authentication.
+	scopes []string
+	client *http.Client
+	// pollInterval and slowDownIncrement tune device flow polling;
+	// zero values use the defaults.
+	pollInterval      time.Duration
+	slowDownIncrement time.Duration
+	// display controls how the device code is shown to the user.
+	display deviceCodeDisplay
+	// expiresAt is when the token from the last device flow expires,
+	// or zero if GitLab did not say.
+	expiresAt time.Time
+}
+
+// TokenExpiry returns when the token obtained by Authenticate expires,
+// or zero if it does not expire or GitLab did not report it.
+func (g *GitLabProvider) TokenExpiry() time.Time {
+	return g.expiresAt
 }
 
 // getBaseURL returns the base URL for API calls
@@ -76,6 +114,14 @@ func (g *GitLabProvider) getBaseURL() string {
 	return "https://gitlab.com"
 }
 
+// getAPIURL returns the base URL for REST API calls
+func (g *GitLabProvider) getAPIURL() string {
+	if g.apiURL != "" {
+		return g.apiURL
+	}
+	return fmt.Sprintf("%s/api/v4", g.getBaseURL())
+}
+
 // GitLab OAuth device flow response structures
 type gitLabDeviceCodeResponse struct {
 	DeviceCode              string `json:"device_code"`
@@ -100,10 +146,21 @@ type gitLabErrorResponse struct {
 
 // makeGitLabAPIRequest is a helper function to make authenticated requests to GitLab API
 func (g *GitLabProvider) makeGitLabAPIRequest(ctx context.Context, token string, endpoint string) (*http.Response, error) {
-	headers := map[string]string{
-		"Accept": "application/json",
+	headers := gitLabAuthHeaders(token)
+	headers["Accept"] = "application/json"
+
+	return makeAuthenticatedRequest(ctx, g.client, "GET", endpoint, "", headers)
+}
+
+// gitLabAuthHeaders returns the authentication header for a raw GitLab token.
+// Personal access tokens are sent as PRIVATE-TOKEN, which some instances require
+// for them, and OAuth tokens as a Bearer token.
+func gitLabAuthHeaders(rawToken string) map[string]string {
+	if strings.HasPrefix(rawToken, personalAccessTokenPrefix) {
+		return map[string]string{"PRIVATE-TOKEN": rawToken}
 	}
-	return makeAuthenticatedRequest(ctx, "GET", endpoint, "Bearer "+token, headers)
+
+	return map[string]string{"Authorization": "Bearer " + rawToken}
 }
 
 func (g *GitLabProvider) Name() string {
@@ -117,9 +174,42 @@ func (g *GitLabProvider) Host() string {
 	return "gitlab.com"
 }
 
+// Edition reports a self-hosted instance for any host other than gitlab.com.
+func (g *GitLabProvider) Edition() string {
+	if !strings.EqualFold(g.Host(), "gitlab.com") {
+		return "self-hosted"
+	}
+
+	return ""
+}
+
+func (g *GitLabProvider) AuthMethod() AuthMethod {
+	return AuthMethodDeviceFlow
+}
+
 func (g *GitLabProvider) GetScopes() []string {
 	// read_api scope allows read access to the API, including private repositories
-	return []string{"read_api", "read_repository"}
+	return scopesOr(g.scopes, []string{"read_api", "read_repository"})
+}
+
+// ImpliedScopes reports the narrower scopes that scope grants: api grants all
+// read and write access, including to the container registry, and
+// write_repository includes reading.
+func (g *GitLabProvider) ImpliedScopes(scope string) []string {
+	switch scope {
+	case "api":
+		return []string{"read_api", "read_repository", "write_repository", "read_registry", "write_registry"}
+	case "write_repository":
+		return []string{"read_repository"}
+	}
+
+	return nil
+}
+
+// RegistryScopes returns the scopes that let a token use the container
+// registry, such as for images that Nix fetches from it.
+func (g *GitLabProvider) RegistryScopes() []string {
+	return []string{"read_registry", "write_registry", "api"}
 }
 
 func (g *GitLabProvider) Authenticate(ctx context.Context) (string, error) {
@@ -148,18 +238,17 @@ func (g *GitLabProvider) Authenticate(ctx context.Context) (string, error) {
 		}
 	}
 
-	// Start device flow
-	deviceCode, err := g.requestDeviceCode(ctx, clientID)
+	// Resume a device code from an interrupted login, or start a new device flow
+	deviceCode, err := g.startDeviceFlow(ctx, clientID)
 	if err != nil {
 		return "", fmt.Errorf("failed to request device code: %w", err)
 	}
 
-	DisplayDeviceCode(deviceCode.UserCode)
-	DisplayURLAndOpenBrowser(deviceCode.VerificationURIComplete)
 	ShowWaitingMessage()
 
 	// Poll for token
 	token, err := g.pollForToken(ctx, clientID, deviceCode)
+	finishDeviceFlow(g.Name(), g.Host(), err)
 	if err != nil {
 		return "", fmt.Errorf("failed to get access token: %w", err)
 	}
@@ -167,9 +256,65 @@ func (g *GitLabProvider) Authenticate(ctx context.Context) (string, error) {
 	return token, nil
 }
 
-func (g *GitLabProvider) requestDeviceCode(ctx context.Context, clientID string) (*gitLabDeviceCodeResponse, error) {
+// startDeviceFlow resumes a saved device code that is still valid, or requests
+// and displays a new one, saving it in case polling is interrupted.
+func (g *GitLabProvider) startDeviceFlow(ctx context.Context, clientID string) (*gitLabDeviceCodeResponse, error) {
+	if pending := loadPendingDeviceCode(g.Name(), g.Host(), clientID, g.GetScopes()); pending != nil {
+		verificationURI := pending.VerificationURIComplete
+		if verificationURI == "" {
+			verificationURI = pending.VerificationURI
+		}
+
+		g.display.resume(pending.UserCode, verificationURI, pending.ExpiresAt)
+
+		return &gitLabDeviceCodeResponse{
+			DeviceCode:              pending.DeviceCode,
+			UserCode:                pending.UserCode,
+			VerificationURI:         pending.VerificationURI,
+			VerificationURIComplete: pending.VerificationURIComplete,
+			ExpiresIn:               int(time.Until(pending.ExpiresAt).Seconds()),
+			Interval:                pending.Interval,
+		}, nil
+	}
+
+	deviceCode, err := g.requestDeviceCode(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	savePendingDeviceCode(&pendingDeviceCode{
+		Provider:                g.Name(),
+		Host:                    g.Host(),
+		ClientID:                clientID,
+		Scopes:                  g.GetScopes(),
+		DeviceCode:              deviceCode.DeviceCode,
+		UserCode:                deviceCode.UserCode,
+		VerificationURI:         deviceCode.VerificationURI,
+		VerificationURIComplete: deviceCode.VerificationURIComplete,
+		Interval:                deviceCode.Interval,
+		ExpiresAt:               time.Now().Add(time.Duration(deviceCode.ExpiresIn) * time.Second),
+	})
+
+	g.display.show(deviceCode.UserCode, deviceCode.VerificationURIComplete)
+
+	return deviceCode, nil
+}
+
+// clientCredentials returns the form values identifying the OAuth client,
+// including the client secret for confidential apps.
+func (g *GitLabProvider) clientCredentials(clientID string) url.Values {
 	data := url.Values{}
 	data.Set("client_id", clientID)
+
+	if g.clientSecret != "" {
+		data.Set("client_secret", g.clientSecret)
+	}
+
+	return data
+}
+
+func (g *GitLabProvider) requestDeviceCode(ctx context.Context, clientID string) (*gitLabDeviceCodeResponse, error) {
+	data := g.clientCredentials(clientID)
 	data.Set("scope", strings.Join(g.GetScopes(), " "))
 
 	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/oauth/authorize_device", g.getBaseURL()), strings.NewReader(data.Encode()))
@@ -180,8 +325,7 @@ func (g *GitLabProvider) requestDeviceCode(ctx context.Context, clientID string)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClientOrDefault(g.client).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -204,17 +348,13 @@ func (g *GitLabProvider) requestDeviceCode(ctx context.Context, clientID string)
 }
 
 func (g *GitLabProvider) pollForToken(ctx context.Context, clientID string, deviceCode *gitLabDeviceCodeResponse) (string, error) {
-	interval := time.Duration(deviceCode.Interval) * time.Second
-	if interval < 5*time.Second {
-		interval = 5 * time.Second
-	}
+	interval := max(time.Duration(deviceCode.Interval)*time.Second, durationOr(g.pollInterval, defaultPollInterval))
 
-	data := url.Values{}
+	data := g.clientCredentials(clientID)
 	data.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
-	data.Set("client_id", clientID)
 	data.Set("device_code", deviceCode.DeviceCode)
 
-	client := &http.Client{}
+	client := httpClientOrDefault(g.client)
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -243,6 +383,7 @@ func (g *GitLabProvider) pollForToken(ctx context.Context, clientID string, devi
 					return "", fmt.Errorf("failed to decode token response: %w", err)
 				}
 				resp.Body.Close()
+				g.expiresAt = expiryFromExpiresIn(tokenResp.ExpiresIn, time.Now())
 				return fmt.Sprintf("%s:%s", tokenPrefix, tokenResp.AccessToken), nil
 			}
 
@@ -259,7 +400,8 @@ func (g *GitLabProvider) pollForToken(ctx context.Context, clientID string, devi
 				continue
 			case "slow_down":
 				// Increase polling interval
-				ticker.Reset(interval + 5*time.Second)
+				interval += durationOr(g.slowDownIncrement, defaultSlowDownIncrement)
+				ticker.Reset(interval)
 				continue
 			case "expired_token":
 				return "", fmt.Errorf("device code expired, please try again")
@@ -277,7 +419,7 @@ func (g *GitLabProvider) ValidateToken(ctx context.Context, token string) (Valid
 	if err != nil {
 		return ValidationStatusInvalid, err
 	}
-	resp, err := g.makeGitLabAPIRequest(ctx, rawToken, fmt.Sprintf("%s/api/v4/user", g.getBaseURL()))
+	resp, err := g.makeGitLabAPIRequest(ctx, rawToken, fmt.Sprintf("%s/user", g.getAPIURL()))
 	if err != nil {
 		return ValidationStatusInvalid, fmt.Errorf("failed to validate token: %w", err)
 	}
@@ -287,15 +429,52 @@ func (g *GitLabProvider) ValidateToken(ctx context.Context, token string) (Valid
 }
 
 func (g *GitLabProvider) rawToken(token string) (string, error) {
-	splitToken := strings.SplitN(token, ":", 2)
-	if len(splitToken) != 2 || splitToken[0] != tokenPrefix {
-		return "", fmt.Errorf("invalid token, expected it to start with '%s:'", tokenPrefix)
+	rawToken, ok := cutTokenPrefix(token)
+	if !ok {
+		return "", fmt.Errorf("invalid token, expected it to start with '%s:' or '%s:'", tokenPrefix, patTokenPrefix)
+	}
+	return rawToken, nil
+}
+
+// cutTokenPrefix returns token without the OAuth2: or PAT: prefix that GitLab
+// tokens carry in nix.conf, and whether it had one.
+func cutTokenPrefix(token string) (string, bool) {
+	prefix, rawToken, ok := strings.Cut(token, ":")
+	if !ok || (prefix != tokenPrefix && prefix != patTokenPrefix) {
+		return token, false
+	}
+
+	return rawToken, true
+}
+
+// StoredToken adds the prefix Nix needs to a GitLab token that lacks it: PAT:
+// for a personal access token, which Nix sends as PRIVATE-TOKEN, and OAuth2:
+// for any other token.
+func (g *GitLabProvider) StoredToken(token string) string {
+	if _, ok := cutTokenPrefix(token); ok {
+		return token
+	}
+
+	if strings.HasPrefix(token, personalAccessTokenPrefix) {
+		return patTokenPrefix + ":" + token
 	}
-	return splitToken[1], nil
+
+	return tokenPrefix + ":" + token
+}
+
+// IssuedToken returns a GitLab token as stored in nix.conf without the OAuth2:
+// or PAT: prefix Nix needs, as GitLab issued it.
+func (g *GitLabProvider) IssuedToken(token string) string {
+	rawToken, _ := cutTokenPrefix(token)
+	return rawToken
 }
 
 func (g *GitLabProvider) GetUserInfo(ctx context.Context, token string) (username, fullName string, err error) {
-	resp, err := g.makeGitLabAPIRequest(ctx, token, fmt.Sprintf("%s/api/v4/user", g.getBaseURL()))
+	rawToken, err := g.rawToken(token)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := g.makeGitLabAPIRequest(ctx, rawToken, fmt.Sprintf("%s/user", g.getAPIURL()))
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get user info: %w", err)
 	}
@@ -313,45 +492,133 @@ func (g *GitLabProvider) GetUserInfo(ctx context.Context, token string) (usernam
 	return user.Username, user.Name, nil
 }
 
+// CanAccessRepo reports whether token can read the project at repoPath, the
+// project's full path such as "group/subgroup/project".
+func (g *GitLabProvider) CanAccessRepo(ctx context.Context, token, repoPath string) (bool, error) {
+	rawToken, err := g.rawToken(token)
+	if err != nil {
+		return false, err
+	}
+
+	endpoint := fmt.Sprintf("%s/projects/%s", g.getAPIURL(), url.PathEscape(repoPath))
+
+	return repoAccessResult(g.makeGitLabAPIRequest(ctx, rawToken, endpoint))
+}
+
 func (g *GitLabProvider) GetTokenScopes(ctx context.Context, token string) ([]string, error) {
-	// GitLab provides token info through a specific endpoint
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/v4/personal_access_tokens/self", g.getBaseURL()), nil)
+	rawScopes, found, err := g.tokenInfoScopes(ctx, token)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
+	if !found {
+		// GitLab does not expose the scopes of OAuth tokens through its API
+		return nil, &ScopesNotExposedError{TokenType: oauthTokenType}
+	}
+
+	var scopes []string
+	if len(rawScopes) > 0 {
+		if err := json.Unmarshal(rawScopes, &scopes); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return scopes, nil
+}
+
+// GetRawTokenScopes returns the scopes field of GitLab's token info for token
+// as JSON, unparsed. Tokens without token info, such as OAuth tokens, get a
+// ScopesNotExposedError.
+func (g *GitLabProvider) GetRawTokenScopes(ctx context.Context, token string) (string, error) {
+	rawScopes, found, err := g.tokenInfoScopes(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	if !found {
+		return "", &ScopesNotExposedError{TokenType: oauthTokenType}
+	}
+
+	return string(rawScopes), nil
+}
+
+// GetTokenInfo returns the name and creation time of a personal, project or
+// group access token. OAuth tokens are not described by GitLab, so their
+// TokenInfo is empty.
+func (g *GitLabProvider) GetTokenInfo(ctx context.Context, token string) (TokenInfo, error) {
+	info, found, err := g.tokenInfo(ctx, token)
+	if err != nil || !found {
+		return TokenInfo{}, err
+	}
+
+	return TokenInfo{Name: info.Name, CreatedAt: info.CreatedAt}, nil
+}
+
+// gitLabTokenInfo is the part of personal_access_tokens/self nix-auth uses.
+type gitLabTokenInfo struct {
+	Name      string          `json:"name"`
+	Scopes    json.RawMessage `json:"scopes"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// tokenInfoScopes returns the raw scopes field of personal_access_tokens/self
+// for token. found is false if the endpoint is not available for the token.
+func (g *GitLabProvider) tokenInfoScopes(ctx context.Context, token string) (rawScopes json.RawMessage, found bool, err error) {
+	info, found, err := g.tokenInfo(ctx, token)
+	if err != nil || !found {
+		return nil, found, err
+	}
+
+	return info.Scopes, true, nil
+}
+
+// tokenInfo queries personal_access_tokens/self for token. found is false if
+// the endpoint is not available for the token.
+func (g *GitLabProvider) tokenInfo(ctx context.Context, token string) (info *gitLabTokenInfo, found bool, err error) {
+	rawToken, err := g.rawToken(token)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// GitLab provides token info through a specific endpoint
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/personal_access_tokens/self", g.getAPIURL()), nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for key, value := range gitLabAuthHeaders(rawToken) {
+		req.Header.Set(key, value)
+	}
+
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClientOrDefault(g.client).Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check token info: %w", err)
+		return nil, false, fmt.Errorf("failed to check token info: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusUnauthorized {
-		return nil, fmt.Errorf("token is invalid or expired")
+		return nil, false, ErrInvalidToken
 	}
 
-	// If the endpoint is not available (404), try to parse from OAuth token info
+	// The endpoint is not available (404) for OAuth tokens
 	if resp.StatusCode == http.StatusNotFound {
-		// For OAuth tokens, scopes might be included in the token response
-		// but GitLab doesn't expose them via API, so we return what we requested
-		return g.GetScopes(), nil
+		return nil, false, nil
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, false, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	var tokenInfo struct {
-		Scopes []string `json:"scopes"`
+	if isHTMLResponse(resp) {
+		return nil, false, ErrHTMLResponse
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&tokenInfo); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	info = &gitLabTokenInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(info); err != nil {
+		return nil, false, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return tokenInfo.Scopes, nil
+	return info, true, nil
 }
@@ -0,0 +1,253 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitLabProvider_API(t *testing.T) {
+	const (
+		rawToken    = "gloas-validtoken"
+		storedToken = tokenPrefix + ":" + rawToken
+	)
+
+	server := newProviderTestServer(t, "Bearer "+rawToken, map[string]http.HandlerFunc{
+		"/user": jsonResponse(`{"username": "tanuki", "name": "GitLab Tanuki"}`, nil),
+		"/personal_access_tokens/self": jsonResponse(
+			`{"name": "nix-auth", "created_at": "2023-01-05T10:00:00.000Z", "scopes": ["read_api", "read_repository"]}`, nil),
+	})
+	p := newTestProvider(t, "gitlab", server)
+	ctx := context.Background()
+
+	t.Run("valid token", func(t *testing.T) {
+		status, err := p.ValidateToken(ctx, storedToken)
+		if err != nil || status != ValidationStatusValid {
+			t.Errorf("ValidateToken() = %v, %v; want valid", status, err)
+		}
+
+		username, fullName, err := p.GetUserInfo(ctx, storedToken)
+		if err != nil || username != "tanuki" || fullName != "GitLab Tanuki" {
+			t.Errorf("GetUserInfo() = %q, %q, %v", username, fullName, err)
+		}
+
+		scopes, err := p.GetTokenScopes(ctx, storedToken)
+		if err != nil || strings.Join(scopes, ",") != "read_api,read_repository" {
+			t.Errorf("GetTokenScopes() = %v, %v; want [read_api read_repository]", scopes, err)
+		}
+
+		raw, err := GetRawTokenScopes(ctx, p, storedToken)
+		if err != nil || raw != `["read_api", "read_repository"]` {
+			t.Errorf("GetRawTokenScopes() = %q, %v; want the unparsed scopes field", raw, err)
+		}
+
+		info, err := GetTokenInfo(ctx, p, storedToken)
+		if err != nil || info.Name != "nix-auth" || !info.CreatedAt.Equal(time.Date(2023, 1, 5, 10, 0, 0, 0, time.UTC)) {
+			t.Errorf("GetTokenInfo() = %+v, %v; want nix-auth created 2023-01-05", info, err)
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		status, err := p.ValidateToken(ctx, tokenPrefix+":gloas-revoked")
+		if status != ValidationStatusInvalid || err == nil || !strings.Contains(err.Error(), "invalid or expired") {
+			t.Errorf("ValidateToken() = %v, %v; want invalid or expired", status, err)
+		}
+
+		if _, err := p.GetTokenScopes(ctx, tokenPrefix+":gloas-revoked"); err == nil {
+			t.Error("GetTokenScopes() expected error for invalid token")
+		}
+	})
+
+	t.Run("token without prefix", func(t *testing.T) {
+		status, err := p.ValidateToken(ctx, rawToken)
+		if status != ValidationStatusInvalid || err == nil || !strings.Contains(err.Error(), tokenPrefix) {
+			t.Errorf("ValidateToken() = %v, %v; want prefix error", status, err)
+		}
+	})
+}
+
+func TestGitLabProvider_ScopesEndpointNotFound(t *testing.T) {
+	const rawToken = "gloas-validtoken"
+
+	server := newProviderTestServer(t, "Bearer "+rawToken, map[string]http.HandlerFunc{
+		"/user": jsonResponse(`{"username": "tanuki"}`, nil),
+	})
+	p := newTestProvider(t, "gitlab", server)
+
+	// Without the token info endpoint the scopes are unknown, not the requested ones
+	var notExposed *ScopesNotExposedError
+	if scopes, err := p.GetTokenScopes(context.Background(), tokenPrefix+":"+rawToken); !errors.As(err, &notExposed) {
+		t.Errorf("GetTokenScopes() = %v, %v; want ScopesNotExposedError", scopes, err)
+	}
+
+	// OAuth tokens are not described
+	if info, err := GetTokenInfo(context.Background(), p, tokenPrefix+":"+rawToken); err != nil || info != (TokenInfo{}) {
+		t.Errorf("GetTokenInfo() = %+v, %v; want no details", info, err)
+	}
+
+	// Neither do the raw scopes
+	if _, err := GetRawTokenScopes(context.Background(), p, tokenPrefix+":"+rawToken); !errors.As(err, &notExposed) {
+		t.Errorf("GetRawTokenScopes() error = %v; want ScopesNotExposedError", err)
+	}
+}
+
+func TestGitLabProvider_PrivateTokenHeader(t *testing.T) {
+	const patToken = "glpat-validtoken"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Personal access tokens must not fall back to the Bearer scheme
+		if r.Header.Get("PRIVATE-TOKEN") != patToken || r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/user":
+			jsonResponse(`{"username": "tanuki"}`, nil)(w, r)
+		case "/personal_access_tokens/self":
+			jsonResponse(`{"scopes": ["read_api"]}`, nil)(w, r)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	p := newTestProvider(t, "gitlab", server)
+	ctx := context.Background()
+
+	for _, stored := range []string{tokenPrefix + ":" + patToken, patTokenPrefix + ":" + patToken} {
+		t.Run(stored, func(t *testing.T) {
+			status, err := p.ValidateToken(ctx, stored)
+			if err != nil || status != ValidationStatusValid {
+				t.Errorf("ValidateToken() = %v, %v; want valid", status, err)
+			}
+
+			scopes, err := p.GetTokenScopes(ctx, stored)
+			if err != nil || strings.Join(scopes, ",") != "read_api" {
+				t.Errorf("GetTokenScopes() = %v, %v; want [read_api]", scopes, err)
+			}
+		})
+	}
+}
+
+func TestGitLabProvider_PollForToken(t *testing.T) {
+	responses := []string{
+		`{"error": "authorization_pending"}`,
+		`{"error": "slow_down"}`,
+		`{"access_token": "gloas-polled", "expires_in": 7200}`,
+	}
+
+	var requests int
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" || r.FormValue("client_secret") != "s3cr3t" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body := responses[min(requests, len(responses)-1)]
+		requests++
+
+		if strings.Contains(body, "error") {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	p := &GitLabProvider{
+		host:              strings.TrimPrefix(server.URL, "https://"),
+		clientSecret:      "s3cr3t",
+		client:            server.Client(),
+		pollInterval:      time.Millisecond,
+		slowDownIncrement: time.Millisecond,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+
+	token, err := p.pollForToken(ctx, "client", &gitLabDeviceCodeResponse{DeviceCode: "device"})
+	if err != nil {
+		t.Fatalf("pollForToken() error = %v", err)
+	}
+
+	if token != tokenPrefix+":gloas-polled" {
+		t.Errorf("pollForToken() = %q, want %q", token, tokenPrefix+":gloas-polled")
+	}
+
+	if requests != len(responses) {
+		t.Errorf("expected %d token requests, got %d", len(responses), requests)
+	}
+
+	if elapsed := time.Since(start); elapsed >= defaultPollInterval {
+		t.Errorf("polling took %v, expected the configured interval to be used", elapsed)
+	}
+
+	if expiry := p.TokenExpiry(); time.Until(expiry) <= time.Hour || time.Until(expiry) > 2*time.Hour {
+		t.Errorf("TokenExpiry() = %v, want about 2 hours from now", expiry)
+	}
+}
+
+func TestIssuedToken(t *testing.T) {
+	gitlab := &GitLabProvider{host: "gitlab.com"}
+	github := &GitHubProvider{host: "github.com"}
+
+	tests := []struct {
+		prov  Provider
+		token string
+		want  string
+	}{
+		{prov: gitlab, token: "PAT:glpat-abc", want: "glpat-abc"},
+		{prov: gitlab, token: "OAuth2:gloas-abc", want: "gloas-abc"},
+		{prov: gitlab, token: "glpat-abc", want: "glpat-abc"},
+		{prov: gitlab, token: "Basic:dXNlcjpwdw==", want: "Basic:dXNlcjpwdw=="},
+		{prov: github, token: "ghp_abc", want: "ghp_abc"},
+		{prov: github, token: "OAuth2:abc", want: "OAuth2:abc"},
+	}
+
+	for _, tt := range tests {
+		if got := IssuedToken(tt.prov, tt.token); got != tt.want {
+			t.Errorf("IssuedToken(%s, %q) = %q, want %q", tt.prov.Name(), tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestGitLabProvider_StoredToken(t *testing.T) {
+	const oauthToken = "gloas-validtoken"
+
+	server := newProviderTestServer(t, "Bearer "+oauthToken, map[string]http.HandlerFunc{
+		"/user": jsonResponse(`{"username": "tanuki"}`, nil),
+	})
+	p := newTestProvider(t, "gitlab", server)
+
+	tests := map[string]string{
+		oauthToken:                     tokenPrefix + ":" + oauthToken,
+		"glpat-abc":                    patTokenPrefix + ":glpat-abc",
+		tokenPrefix + ":" + oauthToken: tokenPrefix + ":" + oauthToken,
+		patTokenPrefix + ":glpat-abc":  patTokenPrefix + ":glpat-abc",
+	}
+
+	for token, want := range tests {
+		if got := StoredToken(p, token); got != want {
+			t.Errorf("StoredToken(%q) = %q, want %q", token, got, want)
+		}
+	}
+
+	// The raw token as GitLab issued it validates once stored
+	status, err := p.ValidateToken(context.Background(), StoredToken(p, oauthToken))
+	if err != nil || status != ValidationStatusValid {
+		t.Errorf("ValidateToken() = %v, %v; want valid", status, err)
+	}
+
+	// Other providers' tokens are stored as issued
+	if got := StoredToken(NewUnknownProvider("git.example.com"), oauthToken); got != oauthToken {
+		t.Errorf("StoredToken() for the unknown provider = %q, want it unchanged", got)
+	}
+}
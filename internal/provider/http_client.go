@@ -2,29 +2,119 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"sync"
+	"time"
+
+	"github.com/numtide/nix-auth/internal/version"
 )
 
+// requestTimeout is the timeout for requests made by providers, such as token
+// validation and device flow polling.
+const requestTimeout = 30 * time.Second
+
+// ErrInvalidToken is returned when a provider rejects a token as invalid or expired.
+var ErrInvalidToken = errors.New("token is invalid or expired")
+
+// ErrHTMLResponse is returned when an API request is answered with a web page,
+// typically the login page of an SSO portal or proxy in front of the host.
+var ErrHTMLResponse = errors.New("received a web page instead of an API response; " +
+	"the host may be behind an SSO login portal or proxy that did not accept the request")
+
+// userAgent is sent with every request, as some hosts reject requests without one.
+func userAgent() string {
+	return "nix-auth/" + version.Version
+}
+
+// userAgentTransport sets the User-Agent header on requests that do not have one.
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", userAgent())
+	}
+
+	return base.RoundTrip(req)
+}
+
+// sharedTransport is the transport of all detection and provider requests, so
+// that they go through the same proxy and TLS settings and reuse kept-alive
+// connections, which matters when checking many hosts at once. It sets the
+// nix-auth User-Agent.
+var sharedTransport = sync.OnceValue(func() http.RoundTripper {
+	return &userAgentTransport{base: http.DefaultTransport.(*http.Transport).Clone()}
+})
+
+// sharedProviderClient is the HTTP client providers use when none is configured.
+var sharedProviderClient = sync.OnceValue(func() *http.Client {
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: sharedTransport(),
+	}
+})
+
+// newProviderClient returns the HTTP client providers use when none is configured.
+func newProviderClient() *http.Client {
+	return sharedProviderClient()
+}
+
+// httpClientOrDefault returns a copy of client, or a default client if it is nil,
+// that sets the nix-auth User-Agent on all requests.
+func httpClientOrDefault(client *http.Client) *http.Client {
+	if client == nil {
+		return newProviderClient()
+	}
+
+	if _, ok := client.Transport.(*userAgentTransport); ok {
+		return client
+	}
+
+	withUserAgent := *client
+	withUserAgent.Transport = &userAgentTransport{base: client.Transport}
+
+	return &withUserAgent
+}
+
 // makeAuthenticatedRequest creates and executes an authenticated HTTP request
-// with common error handling for authentication providers.
-func makeAuthenticatedRequest(ctx context.Context, method, url, authHeader string, headers map[string]string) (*http.Response, error) {
-	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+// with common error handling for authentication providers. An empty authHeader
+// leaves authentication to headers, for providers using a different header.
+func makeAuthenticatedRequest(ctx context.Context, client *http.Client, method, url, authHeader string, headers map[string]string) (*http.Response, error) {
+	return makeAuthenticatedRequestWithBody(ctx, client, method, url, nil, authHeader, headers)
+}
+
+// makeAuthenticatedRequestWithBody is makeAuthenticatedRequest for requests with a body.
+func makeAuthenticatedRequestWithBody(
+	ctx context.Context, client *http.Client, method, url string, body io.Reader, authHeader string, headers map[string]string,
+) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
 
-	// Set authentication header
-	req.Header.Set("Authorization", authHeader)
+	// Set authentication header, unless it is passed in headers
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
 
 	// Set additional headers
 	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := httpClientOrDefault(client).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -33,11 +123,57 @@ func makeAuthenticatedRequest(ctx context.Context, method, url, authHeader strin
 	switch resp.StatusCode {
 	case http.StatusUnauthorized:
 		_ = resp.Body.Close()
-		return nil, fmt.Errorf("token is invalid or expired")
+		return nil, ErrInvalidToken
 	case http.StatusOK:
+		// SSO portals answer unauthenticated requests with a login page, not a 401
+		if isHTMLResponse(resp) {
+			_ = resp.Body.Close()
+			return nil, ErrHTMLResponse
+		}
+
 		return resp, nil
 	default:
 		_ = resp.Body.Close()
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &unexpectedStatusError{code: resp.StatusCode}
 	}
 }
+
+// unexpectedStatusError is returned by makeAuthenticatedRequest for a status
+// code other than 200 or 401, so that callers can tell some apart.
+type unexpectedStatusError struct {
+	code int
+}
+
+func (e *unexpectedStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.code)
+}
+
+// isHTMLResponse reports whether resp is a web page according to its Content-Type.
+func isHTMLResponse(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// IsNetworkError reports whether err means the host could not be reached at
+// all, such as a failed DNS lookup, a refused connection or no route to the
+// host, rather than that the host answered and rejected the request. This
+// includes a host that did not answer while its provider was being detected.
+func IsNetworkError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+
+	var unreachable *unreachableError
+	if errors.As(err, &unreachable) {
+		return true
+	}
+
+	var opErr *net.OpError
+
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/numtide/nix-auth/internal/version"
+)
+
+func TestUserAgent(t *testing.T) {
+	want := "nix-auth/" + version.Version
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("User-Agent"); got != want {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Run("authenticated requests", func(t *testing.T) {
+		resp, err := makeAuthenticatedRequest(context.Background(), server.Client(), "GET", server.URL, "token x", nil)
+		if err != nil {
+			t.Fatalf("makeAuthenticatedRequest() error = %v; want User-Agent %q", err, want)
+		}
+
+		_ = resp.Body.Close()
+	})
+
+	t.Run("detection client", func(t *testing.T) {
+		resp, err := newDetectionClient().Get(server.URL)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status = %d; want User-Agent %q", resp.StatusCode, want)
+		}
+	})
+
+	t.Run("injected client is not modified", func(t *testing.T) {
+		client := server.Client()
+		transport := client.Transport
+
+		_ = httpClientOrDefault(client)
+
+		if client.Transport != transport {
+			t.Error("httpClientOrDefault() modified the injected client")
+		}
+	})
+}
+
+func TestDetectedProviderHTTPClient(t *testing.T) {
+	p, err := DetectWithConfig(context.Background(), Config{Host: "github.com"})
+	if err != nil {
+		t.Fatalf("DetectWithConfig() error = %v", err)
+	}
+
+	gh, ok := p.(*GitHubProvider)
+	if !ok {
+		t.Fatalf("DetectWithConfig() = %T, want *GitHubProvider", p)
+	}
+
+	if gh.client == nil {
+		t.Fatal("detected provider has no HTTP client")
+	}
+
+	if gh.client.Timeout != requestTimeout {
+		t.Errorf("client timeout = %v, want %v", gh.client.Timeout, requestTimeout)
+	}
+
+	if _, ok := gh.client.Transport.(*userAgentTransport); !ok {
+		t.Errorf("client transport = %T, want the nix-auth transport", gh.client.Transport)
+	}
+}
+
+func TestMakeAuthenticatedRequestHTMLResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		wantErr     error
+	}{
+		{name: "json", contentType: "application/json; charset=utf-8"},
+		{name: "no content type"},
+		{name: "sso login page", contentType: "text/html; charset=utf-8", wantErr: ErrHTMLResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+
+				w.WriteHeader(http.StatusOK)
+			}))
+			t.Cleanup(server.Close)
+
+			resp, err := makeAuthenticatedRequest(context.Background(), server.Client(), "GET", server.URL, "token x", nil)
+			if resp != nil {
+				_ = resp.Body.Close()
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("makeAuthenticatedRequest() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsNetworkError(t *testing.T) {
+	// A listener that was closed again refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	_, refusedErr := makeAuthenticatedRequest(context.Background(), nil, http.MethodGet, "http://"+addr+"/user", "", nil)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connection refused", err: fmt.Errorf("failed to validate token: %w", refusedErr), want: true},
+		{name: "DNS failure", err: &net.DNSError{Err: "no such host", Name: "git.internal"}, want: true},
+		{name: "rejected token", err: ErrInvalidToken},
+		{name: "unexpected status", err: &unexpectedStatusError{code: http.StatusInternalServerError}},
+		{name: "no error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsNetworkError(tt.err); got != tt.want {
+				t.Errorf("IsNetworkError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSharedConnections(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		connections int
+	)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			connections++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	// Detection and provider requests, like those for one host after another
+	for _, client := range []*http.Client{newDetectionClient(), newProviderClient(), httpClientOrDefault(nil), newDetectionClient()} {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if connections != 1 {
+		t.Errorf("opened %d connections, want 1 reused by all clients", connections)
+	}
+}
@@ -3,8 +3,10 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
 	"strings"
 
 	"github.com/cli/browser"
@@ -16,6 +18,10 @@ type PersonalAccessTokenProvider struct {
 	host         string
 	providerName string
 	defaultHost  string
+	apiURL       string
+	// scopes overrides the default scopes suggested for new tokens.
+	scopes []string
+	client *http.Client
 }
 
 // Name returns the name of the provider.
@@ -32,9 +38,14 @@ func (p *PersonalAccessTokenProvider) Host() string {
 	return p.defaultHost
 }
 
+// AuthMethod reports that tokens are created manually as personal access tokens.
+func (p *PersonalAccessTokenProvider) AuthMethod() AuthMethod {
+	return AuthMethodPersonalAccessToken
+}
+
 // GetScopes returns the required scopes for authentication.
 func (p *PersonalAccessTokenProvider) GetScopes() []string {
-	return []string{"read:repository", "read:user"}
+	return scopesOr(p.scopes, []string{"read:repository", "read:user"})
 }
 
 func (p *PersonalAccessTokenProvider) getBaseURL() string {
@@ -47,15 +58,48 @@ func (p *PersonalAccessTokenProvider) getBaseURL() string {
 }
 
 func (p *PersonalAccessTokenProvider) getAPIURL() string {
+	if p.apiURL != "" {
+		return p.apiURL
+	}
+
 	return fmt.Sprintf("%s/api/v1", p.getBaseURL())
 }
 
+// classicTokenPattern matches the 40 hex character access tokens Gitea and
+// Forgejo generate, optionally with the gitea_ prefix.
+var classicTokenPattern = regexp.MustCompile(`^(gitea_)?[0-9a-f]{40}$`)
+
+// authSchemes returns the Authorization schemes to try for token, most likely
+// first. Classic access tokens are sent as "token", OAuth tokens (JWTs) as
+// "Bearer"; both instances and proxies in front of them differ in which they
+// accept, so the other scheme is always tried as well.
+func authSchemes(token string) []string {
+	if classicTokenPattern.MatchString(token) {
+		return []string{"token", "Bearer"}
+	}
+
+	return []string{"Bearer", "token"}
+}
+
+// makeAPIRequest sends an authenticated GET to endpoint, retrying with the
+// next Authorization scheme when the server answers 401.
 func (p *PersonalAccessTokenProvider) makeAPIRequest(ctx context.Context, token string, endpoint string) (*http.Response, error) {
 	headers := map[string]string{
 		"Accept": "application/json",
 	}
 
-	return makeAuthenticatedRequest(ctx, "GET", endpoint, "token "+token, headers)
+	var err error
+
+	for _, scheme := range authSchemes(token) {
+		var resp *http.Response
+
+		resp, err = makeAuthenticatedRequest(ctx, p.client, "GET", endpoint, scheme+" "+token, headers)
+		if !errors.Is(err, ErrInvalidToken) {
+			return resp, err
+		}
+	}
+
+	return nil, err
 }
 
 // Authenticate prompts the user for a personal access token.
@@ -101,6 +145,10 @@ func (p *PersonalAccessTokenProvider) Authenticate(ctx context.Context) (string,
 		return "", fmt.Errorf("token cannot be empty")
 	}
 
+	if warning := ui.TruncatedTokenWarning(token); warning != "" {
+		fmt.Println(warning)
+	}
+
 	status, err := p.ValidateToken(ctx, token)
 	if status != ValidationStatusValid {
 		if err != nil {
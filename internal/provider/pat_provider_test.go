@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAuthSchemes(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{name: "classic token", token: strings.Repeat("a1", 20), want: "token,Bearer"},
+		{name: "prefixed token", token: "gitea_" + strings.Repeat("a1", 20), want: "token,Bearer"},
+		{name: "oauth token", token: "eyJhbGciOiJSUzI1NiJ9.eyJ0eXAiOjB9.c2ln", want: "Bearer,token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(authSchemes(tt.token), ","); got != tt.want {
+				t.Errorf("authSchemes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPersonalAccessTokenProvider_AuthSchemeFallback(t *testing.T) {
+	token := strings.Repeat("a1", 20)
+
+	tests := []struct {
+		name         string
+		authHeader   string
+		wantStatus   ValidationStatus
+		wantRequests int32
+	}{
+		{name: "token scheme accepted", authHeader: "token " + token, wantStatus: ValidationStatusValid, wantRequests: 1},
+		{name: "falls back to bearer", authHeader: "Bearer " + token, wantStatus: ValidationStatusValid, wantRequests: 2},
+		{name: "both rejected", authHeader: "token other", wantStatus: ValidationStatusInvalid, wantRequests: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+
+			server := newProviderTestServer(t, tt.authHeader, map[string]http.HandlerFunc{
+				"/user": jsonResponse(`{"login": "octocat"}`, nil),
+			})
+			counting := server.Config.Handler
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				counting.ServeHTTP(w, r)
+			})
+
+			p := newTestProvider(t, "gitea", server)
+
+			status, err := p.ValidateToken(context.Background(), token)
+			if status != tt.wantStatus {
+				t.Errorf("ValidateToken() status = %v, want %v (err %v)", status, tt.wantStatus, err)
+			}
+
+			if tt.wantStatus == ValidationStatusInvalid && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("ValidateToken() error = %v, want ErrInvalidToken", err)
+			}
+
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
@@ -2,7 +2,12 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 )
 
 // ValidationStatus represents the result of token validation.
@@ -17,6 +22,34 @@ const (
 	ValidationStatusUnknown
 )
 
+// AuthMethod describes how a provider obtains access tokens.
+type AuthMethod string
+
+const (
+	// AuthMethodDeviceFlow obtains tokens through the OAuth device flow.
+	AuthMethodDeviceFlow AuthMethod = "oauth-device-flow"
+	// AuthMethodPersonalAccessToken asks the user to create and paste a personal access token.
+	AuthMethodPersonalAccessToken AuthMethod = "personal-access-token"
+	// AuthMethodManual asks the user to paste a token that cannot be verified.
+	AuthMethodManual AuthMethod = "manual"
+)
+
+// ScopesNotExposedError is returned by GetTokenScopes when the token type does not
+// report scopes at all, as opposed to a token that has no scopes. GitHub
+// fine-grained personal access tokens, for example, use permissions instead.
+type ScopesNotExposedError struct {
+	// TokenType describes the kind of token, e.g. "fine-grained", if known.
+	TokenType string
+}
+
+func (e *ScopesNotExposedError) Error() string {
+	if e.TokenType == "" {
+		return "scopes are not exposed for this token"
+	}
+
+	return fmt.Sprintf("scopes are not exposed for %s tokens", e.TokenType)
+}
+
 // Provider defines the interface for authentication providers.
 type Provider interface {
 	// Name returns the provider name (e.g., "github", "gitlab")
@@ -42,10 +75,327 @@ type Provider interface {
 	GetTokenScopes(ctx context.Context, token string) ([]string, error)
 }
 
+// authMethodProvider is implemented by providers that report how they authenticate.
+type authMethodProvider interface {
+	AuthMethod() AuthMethod
+}
+
+// GetAuthMethod returns how the provider obtains tokens.
+// Providers that do not report it are treated as manual.
+func GetAuthMethod(p Provider) AuthMethod {
+	if m, ok := p.(authMethodProvider); ok {
+		return m.AuthMethod()
+	}
+
+	return AuthMethodManual
+}
+
+// editionProvider is implemented by providers offered both as a hosted
+// service and as a self-hosted product.
+type editionProvider interface {
+	// Edition names the self-hosted product, or returns "" for the hosted service.
+	Edition() string
+}
+
+// DisplayName returns the provider's name qualified with its edition when
+// the host is a self-hosted instance, e.g. "github (Enterprise)".
+func DisplayName(p Provider) string {
+	if e, ok := p.(editionProvider); ok {
+		if edition := e.Edition(); edition != "" {
+			return fmt.Sprintf("%s (%s)", p.Name(), edition)
+		}
+	}
+
+	return p.Name()
+}
+
+// tokenExpiryProvider is implemented by providers that learn when the token
+// they obtained expires, such as from an OAuth expires_in field.
+type tokenExpiryProvider interface {
+	TokenExpiry() time.Time
+}
+
+// GetTokenExpiry returns when the token from the provider's last Authenticate
+// call expires, or zero if it does not expire or the provider cannot tell.
+func GetTokenExpiry(p Provider) time.Time {
+	if e, ok := p.(tokenExpiryProvider); ok {
+		return e.TokenExpiry()
+	}
+
+	return time.Time{}
+}
+
+// rawScopesProvider is implemented by providers that can show the scopes of a
+// token exactly as their API reports them.
+type rawScopesProvider interface {
+	GetRawTokenScopes(ctx context.Context, token string) (string, error)
+}
+
+// ErrRawScopesUnsupported is returned by GetRawTokenScopes for providers whose
+// API does not report scopes.
+var ErrRawScopesUnsupported = errors.New("provider does not report token scopes")
+
+// GetRawTokenScopes returns the scopes of token as the provider's API reports
+// them, without parsing, for debugging permission problems.
+func GetRawTokenScopes(ctx context.Context, p Provider, token string) (string, error) {
+	if r, ok := p.(rawScopesProvider); ok {
+		return r.GetRawTokenScopes(ctx, token)
+	}
+
+	return "", ErrRawScopesUnsupported
+}
+
+// TokenInfo describes a token as its provider reports it, to tell several
+// tokens apart and decide when to rotate them.
+type TokenInfo struct {
+	// Name is the name the token was given when it was created, if any.
+	Name string
+	// CreatedAt is when the token was created, or zero if unknown.
+	CreatedAt time.Time
+}
+
+// tokenInfoProvider is implemented by providers whose API describes a token.
+type tokenInfoProvider interface {
+	// GetTokenInfo returns what the provider reports about token.
+	GetTokenInfo(ctx context.Context, token string) (TokenInfo, error)
+}
+
+// ErrTokenInfoUnsupported is returned by GetTokenInfo for providers whose API
+// does not describe tokens.
+var ErrTokenInfoUnsupported = errors.New("provider does not report token details")
+
+// GetTokenInfo returns the name and creation time of token, as far as the
+// provider reports them.
+func GetTokenInfo(ctx context.Context, p Provider, token string) (TokenInfo, error) {
+	if r, ok := p.(tokenInfoProvider); ok {
+		return r.GetTokenInfo(ctx, token)
+	}
+
+	return TokenInfo{}, ErrTokenInfoUnsupported
+}
+
+// repoAccessProvider is implemented by providers that can check whether a
+// token can read a repository.
+type repoAccessProvider interface {
+	// CanAccessRepo reports whether token can read the repository at repoPath,
+	// such as "owner/repo".
+	CanAccessRepo(ctx context.Context, token, repoPath string) (bool, error)
+}
+
+// ErrRepoAccessUnsupported is returned by CanAccessRepo for providers that
+// cannot check access to a repository.
+var ErrRepoAccessUnsupported = errors.New("provider cannot check access to a repository")
+
+// CanAccessRepo reports whether token can read the repository at repoPath on
+// the provider's host. A repository that does not exist is reported the same
+// as one the token cannot see, since providers do not tell them apart.
+func CanAccessRepo(ctx context.Context, p Provider, token, repoPath string) (bool, error) {
+	if r, ok := p.(repoAccessProvider); ok {
+		return r.CanAccessRepo(ctx, token, repoPath)
+	}
+
+	return false, ErrRepoAccessUnsupported
+}
+
+// repoAccessResult interprets the response to a request for a repository:
+// forbidden and not found mean the token cannot access it.
+func repoAccessResult(resp *http.Response, err error) (bool, error) {
+	var statusErr *unexpectedStatusError
+
+	switch {
+	case errors.As(err, &statusErr) && (statusErr.code == http.StatusForbidden || statusErr.code == http.StatusNotFound):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to check repository access: %w", err)
+	}
+
+	_ = resp.Body.Close()
+
+	return true, nil
+}
+
+// tokenStorer is implemented by providers whose tokens are stored in nix.conf
+// in a different form than the provider issues them.
+type tokenStorer interface {
+	// StoredToken returns token as it is stored in nix.conf.
+	StoredToken(token string) string
+	// IssuedToken returns a token stored in nix.conf as the provider issued it.
+	IssuedToken(token string) string
+}
+
+// StoredToken returns a token as issued by p in the form it is stored in
+// nix.conf, such as with the prefix Nix needs on GitLab tokens. Tokens of
+// other providers, and tokens already in that form, are returned unchanged.
+func StoredToken(p Provider, token string) string {
+	if storer, ok := p.(tokenStorer); ok {
+		return storer.StoredToken(token)
+	}
+
+	return token
+}
+
+// IssuedToken returns a token stored in nix.conf for p as p issued it, which
+// is how its API and other tools expect it, such as without the prefix Nix
+// needs on GitLab tokens. Tokens of other providers are returned unchanged.
+func IssuedToken(p Provider, token string) string {
+	if storer, ok := p.(tokenStorer); ok {
+		return storer.IssuedToken(token)
+	}
+
+	return token
+}
+
+// ReportsTokenScopes reports whether the provider's API tells which scopes a
+// token actually has. Other providers' GetTokenScopes returns the scopes they
+// request at login, or none at all.
+func ReportsTokenScopes(p Provider) bool {
+	_, ok := p.(rawScopesProvider)
+	return ok
+}
+
+// registryScopesProvider is implemented by providers with a container
+// registry that tokens can only pull from with certain scopes.
+type registryScopesProvider interface {
+	// RegistryScopes returns the scopes that grant access to the registry.
+	RegistryScopes() []string
+}
+
+// RegistryAccess returns the scopes among scopes that grant access to the
+// provider's container registry. It reports false for providers without a
+// registry, or whose registry access does not depend on scopes.
+func RegistryAccess(p Provider, scopes []string) ([]string, bool) {
+	r, ok := p.(registryScopesProvider)
+	if !ok {
+		return nil, false
+	}
+
+	var access []string
+
+	for _, scope := range scopes {
+		if slices.Contains(r.RegistryScopes(), scope) {
+			access = append(access, scope)
+		}
+	}
+
+	return access, true
+}
+
+// scopeImplier is implemented by providers where a broader scope grants
+// narrower ones.
+type scopeImplier interface {
+	// ImpliedScopes returns the scopes that scope also grants.
+	ImpliedScopes(scope string) []string
+}
+
+// MissingScopes returns the scopes the provider needs, as GetScopes reports
+// them, that a token with scopes lacks. A needed scope that a broader scope of
+// the token grants is not missing.
+func MissingScopes(p Provider, scopes []string) []string {
+	granted := make(map[string]bool, len(scopes))
+
+	for _, scope := range scopes {
+		granted[scope] = true
+
+		if i, ok := p.(scopeImplier); ok {
+			for _, implied := range i.ImpliedScopes(scope) {
+				granted[implied] = true
+			}
+		}
+	}
+
+	var missing []string
+
+	for _, scope := range p.GetScopes() {
+		if !granted[scope] {
+			missing = append(missing, scope)
+		}
+	}
+
+	return missing
+}
+
+// expiryFromExpiresIn converts an OAuth expires_in value in seconds to an
+// absolute time. A missing or non-positive value means the expiry is unknown.
+func expiryFromExpiresIn(expiresIn int, now time.Time) time.Time {
+	if expiresIn <= 0 {
+		return time.Time{}
+	}
+
+	return now.Add(time.Duration(expiresIn) * time.Second)
+}
+
 // Config contains configuration for creating a provider.
 type Config struct {
 	Host     string
 	ClientID string
+	// ClientSecret authenticates ClientID for OAuth apps configured as
+	// confidential. It is only sent to the provider's OAuth endpoints.
+	ClientSecret string
+	// APIURL overrides the API base URL derived from Host, for deployments
+	// where the API is served from a different host or behind a gateway.
+	APIURL string
+	// Scopes overrides the provider's default scopes when non-empty. Scopes
+	// written as "+scope" are added to the defaults instead of replacing them.
+	Scopes []string
+	// PollInterval is the shortest time between device flow token requests.
+	// A longer interval requested by the server is still honoured.
+	// If zero, defaultPollInterval is used.
+	PollInterval time.Duration
+	// SlowDownIncrement is added to the polling interval each time the server
+	// answers slow_down. If zero, defaultSlowDownIncrement is used.
+	SlowDownIncrement time.Duration
+	// NoBrowser makes the device flow print the authorization URL instead of
+	// opening it in a browser.
+	NoBrowser bool
+	// WaitForEnter makes the device flow wait for Enter after showing the
+	// one-time code, so it can be copied before the browser opens. The pause is
+	// skipped if the user cannot be prompted.
+	WaitForEnter bool
+	// QRCode makes the device flow show the authorization URL as a QR code,
+	// for logging in from a phone, instead of opening a browser.
+	QRCode bool
+	// DetectOrder lists the providers tried by DetectWithConfig, in order.
+	// If empty, all providers are tried in the order of ListForDetection.
+	DetectOrder []string
+	// HTTPClient is used for all requests made by the provider.
+	// If nil, a default client is used.
+	HTTPClient *http.Client
+}
+
+// addScopePrefix marks a configured scope that is added to the defaults.
+const addScopePrefix = "+"
+
+// scopesOr returns scopes, or defaults if scopes is empty. Scopes starting with
+// addScopePrefix are added to the others, or to defaults if there are no others.
+func scopesOr(scopes, defaults []string) []string {
+	var replaced, added []string
+
+	for _, scope := range scopes {
+		if extra, ok := strings.CutPrefix(scope, addScopePrefix); ok {
+			added = append(added, extra)
+		} else {
+			replaced = append(replaced, scope)
+		}
+	}
+
+	if len(replaced) == 0 {
+		replaced = defaults
+	}
+
+	result := slices.Clone(replaced)
+
+	for _, scope := range added {
+		if scope != "" && !slices.Contains(result, scope) {
+			result = append(result, scope)
+		}
+	}
+
+	return result
+}
+
+// apiURLOverride returns the configured API base URL without a trailing slash.
+func (c Config) apiURLOverride() string {
+	return strings.TrimSuffix(c.APIURL, "/")
 }
 
 // NewProviderFunc is a function that creates a new provider instance with configuration.
@@ -125,6 +475,20 @@ func List() []string {
 	return names
 }
 
+// CheckDetectOrder returns an error if a name in order is not a provider that
+// can be detected.
+func CheckDetectOrder(order []string) error {
+	for _, name := range order {
+		reg, ok := registry[name]
+		if !ok || reg.Detect == nil {
+			return fmt.Errorf("cannot detect provider '%s'. Detectable providers: %s",
+				name, strings.Join(ListForDetection(), ", "))
+		}
+	}
+
+	return nil
+}
+
 // ListForDetection returns provider names in the order they should be tried for detection.
 func ListForDetection() []string {
 	// Define preferred order for detection
@@ -2,8 +2,13 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRegistry(t *testing.T) {
@@ -198,3 +203,233 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestAPIURLOverride(t *testing.T) {
+	var requested []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"login": "octocat", "username": "octocat", "data": {"viewer": {"login": "octocat"}}}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		provider     string
+		expectedPath string
+	}{
+		{name: "github", provider: "github", expectedPath: "/gateway/graphql"},
+		{name: "gitlab", provider: "gitlab", expectedPath: "/gateway/user"},
+		{name: "gitea", provider: "gitea", expectedPath: "/gateway/user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requested = nil
+
+			p, ok := GetWithConfig(tt.provider, Config{
+				Host:   "git.company.com",
+				APIURL: server.URL + "/gateway/",
+			})
+			if !ok {
+				t.Fatalf("provider %q not registered", tt.provider)
+			}
+
+			token := "token123"
+			if tt.provider == "gitlab" {
+				token = "OAuth2:token123"
+			}
+
+			status, err := p.ValidateToken(context.Background(), token)
+			if err != nil || status != ValidationStatusValid {
+				t.Fatalf("ValidateToken() = %v, %v", status, err)
+			}
+
+			if len(requested) != 1 || requested[0] != tt.expectedPath {
+				t.Errorf("expected request to %q, got %v", tt.expectedPath, requested)
+			}
+		})
+	}
+}
+
+func TestScopesOverride(t *testing.T) {
+	for _, name := range []string{"github", "gitlab", "gitea", "forgejo", "codeberg"} {
+		t.Run(name, func(t *testing.T) {
+			defaults, ok := Get(name)
+			if !ok {
+				t.Fatalf("provider %q not registered", name)
+			}
+
+			if len(defaults.GetScopes()) == 0 {
+				t.Error("expected default scopes")
+			}
+
+			p, _ := GetWithConfig(name, Config{Host: "git.company.com", Scopes: []string{"read_api", "read_registry"}})
+			if got := strings.Join(p.GetScopes(), ","); got != "read_api,read_registry" {
+				t.Errorf("GetScopes() = %q, want the configured scopes", got)
+			}
+		})
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		scopes   []string
+		want     string
+	}{
+		{name: "github with repo", provider: &GitHubProvider{}, scopes: []string{"repo", "read:org"}, want: ""},
+		{name: "github with public_repo only", provider: &GitHubProvider{}, scopes: []string{"public_repo"}, want: "repo"},
+		{name: "gitlab with both read scopes", provider: &GitLabProvider{}, scopes: []string{"read_api", "read_repository"}, want: ""},
+		{name: "gitlab without read_repository", provider: &GitLabProvider{}, scopes: []string{"read_api"}, want: "read_repository"},
+		{name: "gitlab api grants everything", provider: &GitLabProvider{}, scopes: []string{"api"}, want: ""},
+		{name: "gitlab with registry scopes", provider: &GitLabProvider{}, scopes: []string{"read_api", "read_repository", "read_registry"}, want: ""},
+		{name: "gitlab registry scope requested", provider: &GitLabProvider{scopes: []string{"+read_registry"}}, scopes: []string{"api"}, want: ""},
+		{
+			name: "gitlab missing a requested registry scope", provider: &GitLabProvider{scopes: []string{"+read_registry"}},
+			scopes: []string{"read_api", "read_repository"}, want: "read_registry",
+		},
+		{name: "gitlab write_repository grants reading", provider: &GitLabProvider{}, scopes: []string{"read_api", "write_repository"}, want: ""},
+		{name: "no scopes", provider: &GitLabProvider{}, want: "read_api,read_repository"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(MissingScopes(tt.provider, tt.scopes), ","); got != tt.want {
+				t.Errorf("MissingScopes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopesOr(t *testing.T) {
+	defaults := []string{"read_api", "read_repository"}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		want   string
+	}{
+		{name: "defaults", want: "read_api,read_repository"},
+		{name: "replaced", scopes: []string{"api"}, want: "api"},
+		{name: "added to the defaults", scopes: []string{"+read_registry"}, want: "read_api,read_repository,read_registry"},
+		{name: "added to replaced scopes", scopes: []string{"api", "+read_registry"}, want: "api,read_registry"},
+		{name: "default added again", scopes: []string{"+read_api"}, want: "read_api,read_repository"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(scopesOr(tt.scopes, defaults), ","); got != tt.want {
+				t.Errorf("scopesOr(%v) = %q, want %q", tt.scopes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiryFromExpiresIn(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		expiresIn int
+		want      time.Time
+	}{
+		{name: "two hours", expiresIn: 7200, want: now.Add(2 * time.Hour)},
+		{name: "missing", expiresIn: 0, want: time.Time{}},
+		{name: "negative", expiresIn: -1, want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expiryFromExpiresIn(tt.expiresIn, now); !got.Equal(tt.want) {
+				t.Errorf("expiryFromExpiresIn(%d) = %v, want %v", tt.expiresIn, got, tt.want)
+			}
+		})
+	}
+
+	if !GetTokenExpiry(&GitHubProvider{}).IsZero() {
+		t.Errorf("expected providers without expiry support to report zero")
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		want     string
+	}{
+		{name: "github.com", provider: &GitHubProvider{host: "github.com"}, want: "github"},
+		{name: "default github host", provider: &GitHubProvider{}, want: "github"},
+		{name: "github enterprise", provider: &GitHubProvider{host: "github.company.com"}, want: "github (Enterprise)"},
+		{name: "gitlab.com", provider: &GitLabProvider{host: "GitLab.com"}, want: "gitlab"},
+		{name: "self-hosted gitlab", provider: &GitLabProvider{host: "gitlab.company.com"}, want: "gitlab (self-hosted)"},
+		{name: "provider without editions", provider: NewUnknownProvider("git.company.com"), want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DisplayName(tt.provider); got != tt.want {
+				t.Errorf("DisplayName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanAccessRepo(t *testing.T) {
+	var requestURIs []string
+
+	repos := map[string]http.HandlerFunc{
+		"/repos/numtide/nix-auth":   jsonResponse(`{"full_name": "numtide/nix-auth"}`, nil),
+		"/repos/numtide/sso-locked": func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusForbidden) },
+		"/projects/group/sub/flake": func(w http.ResponseWriter, r *http.Request) {
+			requestURIs = append(requestURIs, r.RequestURI)
+			jsonResponse(`{"id": 1}`, nil)(w, r)
+		},
+	}
+
+	tests := []struct {
+		name       string
+		provider   string
+		authHeader string
+		token      string
+		repo       string
+		want       bool
+		wantErr    error
+	}{
+		{name: "github repository", provider: "github", authHeader: "token ghp_x", token: "ghp_x", repo: "numtide/nix-auth", want: true},
+		{name: "github repository not found", provider: "github", authHeader: "token ghp_x", token: "ghp_x", repo: "numtide/private"},
+		{name: "github repository forbidden", provider: "github", authHeader: "token ghp_x", token: "ghp_x", repo: "numtide/sso-locked"},
+		{
+			name: "github invalid token", provider: "github", authHeader: "token ghp_x", token: "ghp_revoked", repo: "numtide/nix-auth",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "gitlab nested project", provider: "gitlab", authHeader: "Bearer gloas-x", token: tokenPrefix + ":gloas-x",
+			repo: "group/sub/flake", want: true,
+		},
+		{name: "unsupported provider", provider: "gitea", token: "x", repo: "owner/repo", wantErr: ErrRepoAccessUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newProviderTestServer(t, tt.authHeader, repos)
+			p := newTestProvider(t, tt.provider, server)
+
+			got, err := CanAccessRepo(context.Background(), p, tt.token, tt.repo)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CanAccessRepo() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("CanAccessRepo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// GitLab identifies a project by its URL-encoded full path
+	if len(requestURIs) != 1 || requestURIs[0] != "/projects/group%2Fsub%2Fflake" {
+		t.Errorf("expected the project path to be URL-encoded, got %v", requestURIs)
+	}
+}
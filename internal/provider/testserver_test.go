@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newProviderTestServer starts an httptest server serving routes by path.
+// Requests whose Authorization header does not equal authHeader get 401,
+// and unknown paths get 404.
+func newProviderTestServer(t *testing.T, authHeader string, routes map[string]http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != authHeader {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message": "Bad credentials"}`))
+
+			return
+		}
+
+		handler, ok := routes[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "Not Found"}`))
+
+			return
+		}
+
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// jsonResponse returns a handler that writes body as JSON with the given extra headers.
+func jsonResponse(body string, headers map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		for key, value := range headers {
+			w.Header().Set(key, value)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+// newTestProvider creates a registered provider whose API is served by server.
+func newTestProvider(t *testing.T, name string, server *httptest.Server) Provider {
+	t.Helper()
+
+	p, ok := GetWithConfig(name, Config{
+		Host:       "git.example.com",
+		APIURL:     server.URL,
+		HTTPClient: server.Client(),
+	})
+	if !ok {
+		t.Fatalf("provider %q not registered", name)
+	}
+
+	return p
+}
@@ -14,9 +14,20 @@ func NewUnknownProvider(host string) *UnknownProvider {
 	}
 }
 
+// newUnreachableProvider creates the unknown provider of a host whose provider
+// could not be detected because probing it failed with err.
+func newUnreachableProvider(host string, err error) *UnknownProvider {
+	return &UnknownProvider{
+		host:        host,
+		unreachable: &unreachableError{err: err},
+	}
+}
+
 // UnknownProvider handles hosts that don't match any known provider.
 type UnknownProvider struct {
 	host string
+	// unreachable is why detection failed, if the host could not be reached.
+	unreachable error
 }
 
 // Name returns the provider name "unknown".
@@ -29,6 +40,11 @@ func (u *UnknownProvider) Host() string {
 	return u.host
 }
 
+// AuthMethod reports that tokens are entered manually.
+func (u *UnknownProvider) AuthMethod() AuthMethod {
+	return AuthMethodManual
+}
+
 // GetScopes returns an empty list as scopes are unknown.
 func (u *UnknownProvider) GetScopes() []string {
 	return []string{}
@@ -38,7 +54,7 @@ func (u *UnknownProvider) GetScopes() []string {
 func (u *UnknownProvider) Authenticate(_ context.Context) (string, error) {
 	fmt.Printf("Unable to auto-detect provider type for %s\n\n", u.host)
 
-	confirm, err := ui.ReadYesNo("Would you like to manually add a token for this host? [y/N] ")
+	confirm, err := ui.Confirm("Would you like to manually add a token for this host?", false)
 	if err != nil {
 		return "", fmt.Errorf("failed to read confirmation: %w", err)
 	}
@@ -64,9 +80,11 @@ func (u *UnknownProvider) Authenticate(_ context.Context) (string, error) {
 }
 
 // ValidateToken always returns unknown status as validation is not possible.
+// If the host could not be reached for detection, that network error is
+// returned as well, so that the host can be reported as unreachable.
 func (u *UnknownProvider) ValidateToken(_ context.Context, _ string) (ValidationStatus, error) {
 	// Unknown providers cannot validate tokens
-	return ValidationStatusUnknown, nil
+	return ValidationStatusUnknown, u.unreachable
 }
 
 // GetUserInfo returns an error as user info is not available for unknown providers.
@@ -0,0 +1,244 @@
+// Package settings loads nix-auth's own configuration file.
+//
+// The settings file holds per-host provider configuration that cannot be
+// expressed in nix.conf, such as the OAuth client ID for a self-hosted instance:
+//
+//	{
+//	  "hosts": {
+//	    "gitlab.company.com": {"client-id": "abc123", "scopes": ["read_api", "read_registry"]},
+//	    "github.company.com": {"api-url": "https://api.github.company.com"}
+//	  },
+//	  "post-hook": "systemctl --user restart nix-cache-proxy",
+//	  "no-backup": true,
+//	  "no-include": true,
+//	  "token-mode": "0640",
+//	  "detect-order": ["gitlab", "forgejo"],
+//	  "store": "age",
+//	  "recipients": ["age1..."],
+//	  "identity": "~/.config/age/key.txt",
+//	  "aliases": {
+//	    "work": "gitlab.company.com",
+//	    "oss": {"host": "git.example.org", "provider": "forgejo", "client-id": "def456"}
+//	  }
+//	}
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// settingsFile is the name of the settings file inside the nix-auth config directory.
+	settingsFile = "settings.json"
+	// settingsEnv overrides the settings file location.
+	settingsEnv = "NIX_AUTH_SETTINGS"
+)
+
+// Host contains the settings for a single host.
+type Host struct {
+	ClientID string `json:"client-id,omitempty"`
+	// ClientSecret is sent with the client ID for OAuth apps configured as confidential.
+	ClientSecret string `json:"client-secret,omitempty"`
+	// APIURL overrides the API base URL when it differs from the web host.
+	APIURL string `json:"api-url,omitempty"`
+	// Scopes replaces the provider's default scopes requested at login.
+	Scopes []string `json:"scopes,omitempty"`
+	// Provider is used instead of detecting the host's provider.
+	Provider string `json:"provider,omitempty"`
+}
+
+// Alias is a short name for a host. It can also carry the provider and OAuth
+// client ID for the host, which then apply however the host is named.
+type Alias struct {
+	Host     string `json:"host"`
+	Provider string `json:"provider,omitempty"`
+	ClientID string `json:"client-id,omitempty"`
+}
+
+// UnmarshalJSON accepts an alias given as just the host name.
+func (a *Alias) UnmarshalJSON(data []byte) error {
+	var host string
+	if err := json.Unmarshal(data, &host); err == nil {
+		a.Host = host
+		return nil
+	}
+
+	type alias Alias
+
+	return json.Unmarshal(data, (*alias)(a))
+}
+
+// Settings is the content of the nix-auth settings file.
+type Settings struct {
+	Hosts map[string]Host `json:"hosts,omitempty"`
+	// PostHook is a shell command run after a token is added, changed or removed.
+	PostHook string `json:"post-hook,omitempty"`
+	// NoBackup disables the backup of nix.conf before it is modified.
+	NoBackup bool `json:"no-backup,omitempty"`
+	// NoInclude stops nix.conf from being modified; it must include the token file itself.
+	NoInclude bool `json:"no-include,omitempty"`
+	// TokenMode is the octal permission mode of the token file, e.g. "0640".
+	TokenMode string `json:"token-mode,omitempty"`
+	// DetectOrder restricts provider detection to these providers, tried in order.
+	DetectOrder []string `json:"detect-order,omitempty"`
+	// Store selects how the token file is stored: "plain" (default), "age" or "gpg".
+	Store string `json:"store,omitempty"`
+	// Recipients are the age or gpg recipients the token file is encrypted to.
+	Recipients []string `json:"recipients,omitempty"`
+	// Identity is the age identity file used to decrypt the token file.
+	Identity string `json:"identity,omitempty"`
+	// Aliases are short names for hosts, accepted wherever a host is.
+	Aliases map[string]Alias `json:"aliases,omitempty"`
+}
+
+// DefaultPath returns the settings file path based on environment variables:
+// 1. NIX_AUTH_SETTINGS
+// 2. XDG_CONFIG_HOME/nix-auth/settings.json
+// 3. ~/.config/nix-auth/settings.json (default).
+func DefaultPath() string {
+	if path := os.Getenv(settingsEnv); path != "" {
+		return path
+	}
+
+	if xdgConfigHome := os.Getenv("XDG_CONFIG_HOME"); xdgConfigHome != "" {
+		return filepath.Join(xdgConfigHome, "nix-auth", settingsFile)
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".config", "nix-auth", settingsFile)
+	}
+
+	return filepath.Join(homeDir, ".config", "nix-auth", settingsFile)
+}
+
+// Load reads the settings file at path.
+// A missing file is not an error and yields empty settings.
+func Load(path string) (*Settings, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // trusted settings file path
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Settings{}, nil
+		}
+
+		return nil, fmt.Errorf("failed to read settings %s: %w", path, err)
+	}
+
+	var s Settings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse settings %s: %w", path, err)
+	}
+
+	if err := s.applyAliases(); err != nil {
+		return nil, fmt.Errorf("invalid settings %s: %w", path, err)
+	}
+
+	return &s, nil
+}
+
+// applyAliases copies the provider and client ID of each alias into the
+// settings of its host. An alias without a host, or two that disagree about
+// the same host, is an error.
+func (s *Settings) applyAliases() error {
+	names := make([]string, 0, len(s.Aliases))
+	for name := range s.Aliases {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		alias := s.Aliases[name]
+		if alias.Host == "" {
+			return fmt.Errorf("alias %q has no host", name)
+		}
+
+		key := s.hostKey(alias.Host)
+		h := s.Hosts[key]
+
+		if alias.Provider != "" {
+			if h.Provider != "" && !strings.EqualFold(h.Provider, alias.Provider) {
+				return fmt.Errorf("alias %q sets provider %s for %s, which is already set to %s", name, alias.Provider, alias.Host, h.Provider)
+			}
+
+			h.Provider = alias.Provider
+		}
+
+		if alias.ClientID != "" {
+			if h.ClientID != "" && h.ClientID != alias.ClientID {
+				return fmt.Errorf("alias %q sets a client ID for %s, which already has a different one", name, alias.Host)
+			}
+
+			h.ClientID = alias.ClientID
+		}
+
+		if alias.Provider == "" && alias.ClientID == "" {
+			continue
+		}
+
+		if s.Hosts == nil {
+			s.Hosts = make(map[string]Host)
+		}
+
+		s.Hosts[key] = h
+	}
+
+	return nil
+}
+
+// hostKey returns the key host is configured under in Hosts, matched
+// case-insensitively, or host itself if it has no settings.
+func (s *Settings) hostKey(host string) string {
+	if _, ok := s.Hosts[host]; ok {
+		return host
+	}
+
+	for name := range s.Hosts {
+		if strings.EqualFold(name, host) {
+			return name
+		}
+	}
+
+	return host
+}
+
+// ResolveAlias returns the host an alias names, matched case-insensitively.
+// It is safe to call on nil settings.
+func (s *Settings) ResolveAlias(name string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+
+	for alias, target := range s.Aliases {
+		if strings.EqualFold(alias, name) {
+			return target.Host, true
+		}
+	}
+
+	return "", false
+}
+
+// Host returns the settings for a host, matched case-insensitively.
+// It is safe to call on nil settings.
+func (s *Settings) Host(host string) Host {
+	if s == nil {
+		return Host{}
+	}
+
+	if h, ok := s.Hosts[host]; ok {
+		return h
+	}
+
+	for name, h := range s.Hosts {
+		if strings.EqualFold(name, host) {
+			return h
+		}
+	}
+
+	return Host{}
+}
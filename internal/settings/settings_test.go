@@ -0,0 +1,189 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     *string
+		host        string
+		expectedID  string
+		expectError bool
+	}{
+		{
+			name:       "missing file yields empty settings",
+			content:    nil,
+			host:       "gitlab.company.com",
+			expectedID: "",
+		},
+		{
+			name:       "host client ID",
+			content:    ptr(`{"hosts": {"gitlab.company.com": {"client-id": "abc123"}}}`),
+			host:       "gitlab.company.com",
+			expectedID: "abc123",
+		},
+		{
+			name:       "host matched case-insensitively",
+			content:    ptr(`{"hosts": {"GitLab.Company.com": {"client-id": "abc123"}}}`),
+			host:       "gitlab.company.com",
+			expectedID: "abc123",
+		},
+		{
+			name:       "unconfigured host",
+			content:    ptr(`{"hosts": {"gitlab.company.com": {"client-id": "abc123"}}}`),
+			host:       "github.com",
+			expectedID: "",
+		},
+		{
+			name:        "malformed file",
+			content:     ptr(`{"hosts": `),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "settings.json")
+			if tt.content != nil {
+				if err := os.WriteFile(path, []byte(*tt.content), 0o600); err != nil {
+					t.Fatalf("failed to write settings: %v", err)
+				}
+			}
+
+			s, err := Load(path)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := s.Host(tt.host).ClientID; got != tt.expectedID {
+				t.Errorf("expected client ID %q, got %q", tt.expectedID, got)
+			}
+		})
+	}
+}
+
+func TestHostOnNilSettings(t *testing.T) {
+	var s *Settings
+	if got := s.Host("github.com"); !reflect.DeepEqual(got, Host{}) {
+		t.Errorf("expected empty host settings, got %+v", got)
+	}
+}
+
+func TestLoadAliases(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		alias        string
+		wantHost     string
+		wantProvider string
+		wantClientID string
+		errorMsg     string
+	}{
+		{
+			name:     "host name only",
+			content:  `{"aliases": {"work": "git.company.com"}}`,
+			alias:    "work",
+			wantHost: "git.company.com",
+		},
+		{
+			name:         "named profile",
+			content:      `{"aliases": {"oss": {"host": "git.example.org", "provider": "forgejo", "client-id": "def456"}}}`,
+			alias:        "OSS",
+			wantHost:     "git.example.org",
+			wantProvider: "forgejo",
+			wantClientID: "def456",
+		},
+		{
+			name: "profile merged into host settings",
+			content: `{"hosts": {"Git.Example.org": {"scopes": ["read_api"]}},
+				"aliases": {"oss": {"host": "git.example.org", "provider": "gitlab"}}}`,
+			alias:        "oss",
+			wantHost:     "git.example.org",
+			wantProvider: "gitlab",
+		},
+		{
+			name:     "alias without host",
+			content:  `{"aliases": {"work": {"provider": "gitlab"}}}`,
+			errorMsg: `alias "work" has no host`,
+		},
+		{
+			name: "conflicting providers",
+			content: `{"aliases": {"a": {"host": "git.company.com", "provider": "gitlab"},
+				"b": {"host": "git.company.com", "provider": "gitea"}}}`,
+			errorMsg: `alias "b" sets provider gitea`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "settings.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write settings: %v", err)
+			}
+
+			s, err := Load(path)
+			if tt.errorMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Errorf("expected error containing %q, got %v", tt.errorMsg, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			host, ok := s.ResolveAlias(tt.alias)
+			if !ok || host != tt.wantHost {
+				t.Errorf("ResolveAlias(%q) = %q, %v, want %q", tt.alias, host, ok, tt.wantHost)
+			}
+
+			if got := s.Host(host); got.Provider != tt.wantProvider || got.ClientID != tt.wantClientID {
+				t.Errorf("Host(%q) = %+v, want provider %q and client ID %q", host, got, tt.wantProvider, tt.wantClientID)
+			}
+
+			if _, ok := s.ResolveAlias(host); ok {
+				t.Errorf("expected the host %q not to be an alias", host)
+			}
+		})
+	}
+
+	var s *Settings
+	if _, ok := s.ResolveAlias("work"); ok {
+		t.Errorf("expected no alias on nil settings")
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("NIX_AUTH_SETTINGS", "/custom/settings.json")
+
+	if got := DefaultPath(); got != "/custom/settings.json" {
+		t.Errorf("expected NIX_AUTH_SETTINGS to win, got %q", got)
+	}
+
+	t.Setenv("NIX_AUTH_SETTINGS", "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+
+	if got := DefaultPath(); got != "/xdg/nix-auth/settings.json" {
+		t.Errorf("expected XDG path, got %q", got)
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
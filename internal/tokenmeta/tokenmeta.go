@@ -0,0 +1,156 @@
+// Package tokenmeta records facts about saved tokens that nix.conf has no
+// place for, such as when a token obtained at login expires or which OAuth
+// client ID obtained it.
+//
+// Metadata is kept in a JSON file in the nix-auth state directory, keyed by
+// lowercase host:
+//
+//	{
+//	  "gitlab.com": {"expires_at": "2026-01-02T15:04:05Z"},
+//	  "gitlab.company.com": {"client_id": "0123abcd"}
+//	}
+package tokenmeta
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/numtide/nix-auth/internal/fsutil"
+)
+
+const (
+	// metaFile is the name of the metadata file inside the state directory.
+	metaFile = "tokens.json"
+	// dirPermissions is the permission mode for the state directory.
+	dirPermissions = 0o700
+	// filePermissions is the permission mode for the metadata file.
+	filePermissions = 0o600
+	// lockSuffix names the lock file that serializes updates of the metadata file.
+	lockSuffix = ".lock"
+)
+
+// Metadata is what is known about the token saved for a host.
+type Metadata struct {
+	// ExpiresAt is when the token expires, or zero if it does not or is unknown.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// ClientID is the OAuth client ID the token was obtained with. It is not
+	// secret and lets a later login reuse it.
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// IsZero reports whether m records nothing.
+func (m Metadata) IsZero() bool {
+	return m.ExpiresAt.IsZero() && m.ClientID == ""
+}
+
+// Path returns the metadata file inside stateDir.
+func Path(stateDir string) string {
+	return filepath.Join(stateDir, metaFile)
+}
+
+// Load reads all metadata from path.
+// A missing file is not an error and yields no metadata.
+func Load(path string) (map[string]Metadata, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is inside the nix-auth state directory
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]Metadata{}, nil
+		}
+
+		return nil, fmt.Errorf("failed to read token metadata %s: %w", path, err)
+	}
+
+	meta := map[string]Metadata{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse token metadata %s: %w", path, err)
+	}
+
+	return meta, nil
+}
+
+// Get returns the metadata recorded for host, or zero metadata if there is none.
+func Get(path, host string) (Metadata, error) {
+	meta, err := Load(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	return meta[strings.ToLower(host)], nil
+}
+
+// Set records m for host, replacing what was known about its previous token.
+// Setting zero metadata removes the host's entry.
+func Set(path, host string, m Metadata) error {
+	return Update(path, host, func(old *Metadata) { *old = m })
+}
+
+// Update applies update to the metadata recorded for host and saves the
+// result, removing the host's entry if it ends up zero. Several nix-auth
+// processes may save tokens at once, so updates hold a lock on the file and
+// replace it atomically; a reader never sees a partial file.
+func Update(path, host string, update func(*Metadata)) error {
+	unlock, err := lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	meta, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	host = strings.ToLower(host)
+
+	m, found := meta[host]
+	update(&m)
+
+	if m.IsZero() {
+		if !found {
+			return nil
+		}
+
+		delete(meta, host)
+	} else {
+		meta[host] = m
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode token metadata: %w", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(path, append(data, '\n'), filePermissions); err != nil {
+		return fmt.Errorf("failed to write token metadata %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// lock takes an exclusive lock on the lock file next to path, waiting while
+// another process holds it, and returns the function that releases it.
+func lock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), dirPermissions); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	lockPath := path + lockSuffix
+
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, filePermissions) //nolint:gosec // inside the state directory
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token metadata lock: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to lock token metadata: %w", err)
+	}
+
+	// Closing the file releases the lock
+	return func() { _ = file.Close() }, nil
+}
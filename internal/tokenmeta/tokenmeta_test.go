@@ -0,0 +1,144 @@
+package tokenmeta
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetAndGet(t *testing.T) {
+	path := Path(filepath.Join(t.TempDir(), "state"))
+	expiresAt := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	if err := Set(path, "GitLab.com", Metadata{ExpiresAt: expiresAt, ClientID: "0123abcd"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("metadata file not written: %v", err)
+	}
+
+	if perm := info.Mode().Perm(); perm != filePermissions {
+		t.Errorf("expected permissions %o, got %o", filePermissions, perm)
+	}
+
+	m, err := Get(path, "gitlab.com")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	if !m.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("expected expiry %v, got %v", expiresAt, m.ExpiresAt)
+	}
+
+	if m.ClientID != "0123abcd" {
+		t.Errorf("expected client ID %q, got %q", "0123abcd", m.ClientID)
+	}
+
+	if err := Set(path, "gitlab.com", Metadata{}); err != nil {
+		t.Fatalf("Set with zero metadata failed: %v", err)
+	}
+
+	m, err = Get(path, "gitlab.com")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	if !m.IsZero() {
+		t.Errorf("expected entry to be removed, got %+v", m)
+	}
+}
+
+func TestUpdateConcurrently(t *testing.T) {
+	path := Path(t.TempDir())
+	expiresAt := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	const hosts = 20
+
+	var wg sync.WaitGroup
+
+	for i := range hosts {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := Update(path, fmt.Sprintf("host%d.example.com", i), func(m *Metadata) { m.ExpiresAt = expiresAt })
+			if err != nil {
+				t.Errorf("Update failed: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	meta, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(meta) != hosts {
+		t.Errorf("expected all %d concurrent updates to be kept, got %d", hosts, len(meta))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     *string
+		expectedLen int
+		expectError bool
+	}{
+		{
+			name:        "missing file yields no metadata",
+			content:     nil,
+			expectedLen: 0,
+		},
+		{
+			name:        "entries without expiry",
+			content:     ptr(`{"github.com": {}, "gitlab.com": {"expires_at": "2026-01-02T15:04:05Z"}}`),
+			expectedLen: 2,
+		},
+		{
+			name:        "malformed file",
+			content:     ptr(`{"gitlab.com": `),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), metaFile)
+			if tt.content != nil {
+				if err := os.WriteFile(path, []byte(*tt.content), 0o600); err != nil {
+					t.Fatalf("failed to write metadata: %v", err)
+				}
+			}
+
+			meta, err := Load(path)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(meta) != tt.expectedLen {
+				t.Errorf("expected %d entries, got %d", tt.expectedLen, len(meta))
+			}
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
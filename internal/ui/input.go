@@ -3,13 +3,32 @@ package ui
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"golang.org/x/term"
 )
 
+// stdinReader buffers stdin across prompts, so that consecutive prompts each get
+// their own line of piped input instead of the first one consuming it all.
+var (
+	stdinReader     *bufio.Reader
+	stdinReaderFile *os.File
+)
+
+// stdinLineReader returns the buffered reader for the current os.Stdin.
+func stdinLineReader() *bufio.Reader {
+	if stdinReader == nil || stdinReaderFile != os.Stdin {
+		stdinReader = bufio.NewReader(os.Stdin)
+		stdinReaderFile = os.Stdin
+	}
+
+	return stdinReader
+}
+
 // ReadSecureInput reads sensitive input (like tokens) from stdin.
 // It uses secure password input for terminals and handles non-terminal input gracefully.
 func ReadSecureInput(prompt string) (string, error) {
@@ -31,9 +50,7 @@ func ReadSecureInput(prompt string) (string, error) {
 	}
 
 	// For non-terminal input (like tests or piped input)
-	reader := bufio.NewReader(os.Stdin)
-
-	input, err := reader.ReadString('\n')
+	input, err := stdinLineReader().ReadString('\n')
 	if err != nil {
 		return "", fmt.Errorf("failed to read input: %w", err)
 	}
@@ -41,13 +58,17 @@ func ReadSecureInput(prompt string) (string, error) {
 	return strings.TrimSpace(strings.TrimSuffix(input, "\n")), nil
 }
 
+// IsInteractive reports whether both stdin and stdout are terminals,
+// so that the user can be prompted.
+func IsInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
 // ReadInput reads regular input from stdin (non-sensitive).
 func ReadInput(prompt string) (string, error) {
 	fmt.Print(prompt)
 
-	reader := bufio.NewReader(os.Stdin)
-
-	input, err := reader.ReadString('\n')
+	input, err := stdinLineReader().ReadString('\n')
 	if err != nil {
 		return "", fmt.Errorf("failed to read input: %w", err)
 	}
@@ -55,13 +76,49 @@ func ReadInput(prompt string) (string, error) {
 	return strings.TrimSpace(strings.TrimSuffix(input, "\n")), nil
 }
 
-// ReadYesNo reads a yes/no response from the user.
-// Returns true for "y" or "Y", false for anything else.
-func ReadYesNo(prompt string) (bool, error) {
-	response, err := ReadInput(prompt)
-	if err != nil {
-		return false, err
+// assumeYes answers every confirmation with yes, as set with SetAssumeYes.
+var assumeYes bool
+
+// SetAssumeYes makes Confirm answer yes without prompting, for the --yes flag.
+func SetAssumeYes(yes bool) {
+	assumeYes = yes
+}
+
+// Confirm asks a yes/no question and reports the answer. The hint [y/N] or
+// [Y/n] is appended to question according to defaultYes, which is also the
+// answer to an empty response and to input that ends without one, such as
+// an empty pipe. With SetAssumeYes(true) it answers yes without prompting.
+func Confirm(question string, defaultYes bool) (bool, error) {
+	hint := "[y/N]"
+	if defaultYes {
+		hint = "[Y/n]"
+	}
+
+	prompt := fmt.Sprintf("%s %s ", question, hint)
+
+	if assumeYes {
+		fmt.Println(prompt + "y (--yes)")
+		return true, nil
+	}
+
+	fmt.Print(prompt)
+
+	input, err := stdinLineReader().ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("failed to read input: %w", err)
 	}
 
-	return response == "y" || response == "Y", nil
+	if errors.Is(err, io.EOF) && input == "" {
+		// Nobody is there to answer, so keep the output readable
+		fmt.Println()
+	}
+
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	default:
+		return defaultYes, nil
+	}
 }
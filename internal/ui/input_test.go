@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		defaultYes bool
+		assumeYes  bool
+		want       bool
+	}{
+		{name: "yes", input: "y\n", want: true},
+		{name: "long yes in capitals", input: "YES\n", want: true},
+		{name: "no overrides default yes", input: "n\n", defaultYes: true, want: false},
+		{name: "empty answer uses default no", input: "\n", want: false},
+		{name: "empty answer uses default yes", input: "\n", defaultYes: true, want: true},
+		{name: "unrecognized answer uses default", input: "maybe\n", want: false},
+		{name: "end of input uses default", input: "", defaultYes: true, want: true},
+		{name: "assume yes does not read input", input: "n\n", assumeYes: true, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalStdin := os.Stdin
+
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, _ = io.WriteString(w, tt.input)
+			_ = w.Close()
+
+			os.Stdin = r
+			SetAssumeYes(tt.assumeYes)
+
+			defer func() {
+				os.Stdin = originalStdin
+				SetAssumeYes(false)
+
+				_ = r.Close()
+			}()
+
+			got, err := Confirm("Continue?", tt.defaultYes)
+			if err != nil {
+				t.Fatalf("Confirm() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Confirm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
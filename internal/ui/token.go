@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strings"
 )
@@ -16,33 +18,55 @@ const (
 	prefixLength = 4
 	// suffixLength is the length of suffix to show for known tokens.
 	suffixLength = 2
+	// fingerprintLength is the number of hex digits of the hash in a token fingerprint.
+	fingerprintLength = 8
 )
 
+// knownTokenPrefixes help identify the token type without revealing sensitive data.
+var knownTokenPrefixes = []string{
+	"gho_",        // GitHub OAuth token
+	"ghp_",        // GitHub personal access token
+	"ghs_",        // GitHub server-to-server token
+	"github_pat_", // GitHub fine-grained PAT
+	"glpat-",      // GitLab personal access token
+	"gloas-",      // GitLab OAuth access token
+	"glrt-",       // GitLab refresh token
+	"gitea_",      // Gitea/Forgejo access token
+}
+
+// HasKnownTokenPrefix reports whether s starts like a token of a well-known type.
+func HasKnownTokenPrefix(s string) bool {
+	for _, prefix := range knownTokenPrefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+
+	for _, known := range minTokenLengths {
+		if strings.HasPrefix(s, known.prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // MaskToken masks a token for security, showing only the token prefix for known types.
+// Lengths are measured in runes so the result is always valid UTF-8.
 func MaskToken(token string) string {
+	runes := []rune(token)
+
 	// Handle empty or very short tokens
-	if len(token) < minTokenLength {
+	if len(runes) < minTokenLength {
 		return strings.Repeat("*", defaultMaskLength)
 	}
 
-	// Known token prefixes - these help identify the token type without revealing sensitive data
-	knownPrefixes := []string{
-		"gho_",        // GitHub OAuth token
-		"ghp_",        // GitHub personal access token
-		"ghs_",        // GitHub server-to-server token
-		"github_pat_", // GitHub fine-grained PAT
-		"glpat-",      // GitLab personal access token
-		"gloas-",      // GitLab OAuth access token
-		"glrt-",       // GitLab refresh token
-		"gitea_",      // Gitea token prefix (if standardized)
-	}
-
 	// Check if token starts with a known prefix
-	for _, prefix := range knownPrefixes {
+	for _, prefix := range knownTokenPrefixes {
 		if strings.HasPrefix(token, prefix) {
 			// Show prefix + last 2 chars for better differentiation between multiple tokens
-			if len(token) >= len(prefix)+defaultMaskLength {
-				return fmt.Sprintf("%s%s%s", prefix, strings.Repeat("*", middleMaskLength), token[len(token)-suffixLength:])
+			if len(runes) >= len(prefix)+defaultMaskLength {
+				return fmt.Sprintf("%s%s%s", prefix, strings.Repeat("*", middleMaskLength), string(runes[len(runes)-suffixLength:]))
 			}
 			// Fallback if token is too short
 			return fmt.Sprintf("%s%s", prefix, strings.Repeat("*", defaultMaskLength))
@@ -51,5 +75,49 @@ func MaskToken(token string) string {
 
 	// For unknown token types, show first 4 chars (might indicate type) + mask
 	// This is more conservative than showing both prefix and suffix
-	return fmt.Sprintf("%s%s", token[:prefixLength], strings.Repeat("*", defaultMaskLength))
+	return fmt.Sprintf("%s%s", string(runes[:prefixLength]), strings.Repeat("*", defaultMaskLength))
+}
+
+// TokenFingerprint returns a short SHA-256 hash of token, such as
+// "sha256:1a2b3c4d". Unlike MaskToken it reveals no part of the token, and it
+// is the same wherever the same token is stored, so tokens can be compared
+// across machines or over time.
+func TokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return "sha256:" + hex.EncodeToString(sum[:])[:fingerprintLength]
+}
+
+// minTokenLengths are the shortest lengths of tokens with well-known prefixes.
+var minTokenLengths = []struct {
+	prefix string
+	length int
+}{
+	{prefix: "github_pat_", length: 93},
+	{prefix: "ghp_", length: 40},
+	{prefix: "gho_", length: 40},
+	{prefix: "ghu_", length: 40},
+	{prefix: "ghs_", length: 40},
+	{prefix: "ghr_", length: 40},
+	{prefix: "glpat-", length: 26},
+}
+
+// TruncatedTokenWarning returns a warning if token has a well-known prefix but is
+// shorter than such tokens are, which usually means a paste was cut off.
+// It returns "" if the token does not look truncated.
+func TruncatedTokenWarning(token string) string {
+	for _, known := range minTokenLengths {
+		if !strings.HasPrefix(token, known.prefix) {
+			continue
+		}
+
+		if length := len([]rune(token)); length < known.length {
+			return fmt.Sprintf("Warning: %s tokens are at least %d characters long, but this one has %d; "+
+				"it may have been cut off while pasting", known.prefix, known.length, length)
+		}
+
+		return ""
+	}
+
+	return ""
 }